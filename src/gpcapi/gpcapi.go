@@ -0,0 +1,466 @@
+package gpcapi
+
+/*
+Package gpcapi provides the HTTP API of the process controller, so dashboards and
+remote tools can work with the controller without access to the file system of the host.
+
+Endpoints:
+
+	GET /                          web dashboard, see gpcdashboard.go
+	GET /processes                 state of all processes as JSON (gpcprocessmgr.ProcessStatus)
+	GET /processes/{name}          state of a process as JSON
+	POST /processes/{name}/start   starts a process, also stop, restart, suspend and resume
+	POST /processes/{name}/signal  sends the signal of the body to a process, e.g. HUP, see handleSignal
+	POST /processes/{name}/input   writes the body to the stdin of a StdinPipe process
+	POST /tasks/{task}/rollout     restarts the instances of a task one at a time, see handleRollout
+	GET /groups/{tag}              names of the processes with a tag as JSON array
+	POST /groups/{tag}/start       starts the processes with a tag, also stop and restart, see handleGroups
+	GET /processes/{name}/logs     output of a process, see handleLogs
+	GET /processes/{name}/output   latest output lines kept in memory, see handleRecentOutput
+	GET /processes/{name}/stream   live output as Server-Sent Events, see handleStream
+	GET /metrics                   counters in the Prometheus text format, see handleMetrics
+	GET|PUT /loglevel              shows or changes the log level of the controller
+
+The gRPC service of proto/gpc/v1/process_controller.proto is served on the same port.
+*/
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"gpcconfig"
+	"gpclogging"
+	"gpcprocessmgr"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//#######################################################
+//### GLOBAL VARIABLES, INIT, CONSTS
+//#######################################################
+
+var gServer *http.Server
+
+// how long a start, stop, restart, suspend or resume may wait for the process
+const actionTimeout = 30 * time.Second
+
+// largest input written to the stdin of a process at once
+const maxInputBytes = 64 * 1024
+
+//Start opens the HTTP API on the configured address and serves requests in background, over
+//TLS and with token authentication if configured
+//#########################################################
+func Start(apiConfig gpcconfig.APIConfig) error {
+	gpclogging.Debug("Entering gpcapi.Start() with address <%s>", apiConfig.ListenAddress)
+
+	sToken, err := apiConfig.APIToken()
+	if err != nil {
+		return err
+	}
+	serverTLS, err := tlsConfig(apiConfig)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", apiConfig.ListenAddress)
+	if err != nil {
+		return err
+	}
+	if serverTLS != nil {
+		listener = tls.NewListener(listener, serverTLS)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handleDashboard)
+	mux.HandleFunc("/processes", handleStatus)
+	mux.HandleFunc("/processes/", handleProcesses)
+	mux.HandleFunc("/groups/", handleGroups)
+	mux.HandleFunc("/tasks/", handleRollout)
+	mux.HandleFunc("/maintenance", func(w http.ResponseWriter, r *http.Request) { handleMaintenance(w, r, "") })
+	mux.HandleFunc("/metrics", handleMetrics)
+	mux.HandleFunc("/loglevel", handleLogLevel)
+	// gRPC calls share the port, see gpcgrpc.go
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isGRPC(r) {
+			handleGRPC(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	if len(sToken) > 0 {
+		handler = requireToken(sToken, handler)
+	}
+	gServer = &http.Server{Handler: handler, Protocols: new(http.Protocols)}
+	gServer.Protocols.SetHTTP1(true)
+	gServer.Protocols.SetHTTP2(true)
+	gServer.Protocols.SetUnencryptedHTTP2(true)
+
+	go func() {
+		err := gServer.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			gpclogging.Error("HTTP API stopped: <%s>", err.Error())
+		}
+	}()
+
+	gpclogging.Info("HTTP API listening on <%s>, TLS=<%t>, token=<%t>, client certificates=<%t>", listener.Addr().String(),
+		serverTLS != nil, len(sToken) > 0, len(apiConfig.ClientCAFile) > 0)
+	return nil
+}
+
+//Stop closes the HTTP API, open requests are cancelled
+//#########################################################
+func Stop() {
+	if gServer != nil {
+		gServer.Close()
+		gServer = nil
+	}
+}
+
+// handleProcesses routes the requests below /processes/ by hand, the method and wildcard
+// patterns of http.ServeMux are not available to GOPATH builds
+//------------------------------------------------------------------------------
+func handleProcesses(w http.ResponseWriter, r *http.Request) {
+	procName, resource, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/processes/"), "/")
+	if len(procName) == 0 {
+		handleStatus(w, r)
+		return
+	}
+
+	switch resource {
+	case "":
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handleProcessStatus(w, r, procName)
+	case "start", "stop", "restart", "suspend", "resume":
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handleAction(w, r, procName, resource)
+	case "signal":
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handleSignal(w, r, procName)
+	case "input":
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handleInput(w, r, procName)
+	case "maintenance":
+		handleMaintenance(w, r, procName)
+	case "logs":
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handleLogs(w, r, procName)
+	case "output":
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handleRecentOutput(w, r, procName)
+	case "stream":
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handleStream(w, r, procName)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleGroups answers the processes with a tag, or starts, stops or restarts them in dependency
+// order. Answers 204 once done, the error of each process that failed otherwise.
+//------------------------------------------------------------------------------
+func handleGroups(w http.ResponseWriter, r *http.Request) {
+	sTag, sAction, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/groups/"), "/")
+	actions := map[string]func(context.Context, string) error{
+		"start":   gpcprocessmgr.StartGroup,
+		"stop":    gpcprocessmgr.StopGroup,
+		"restart": gpcprocessmgr.RestartGroup,
+	}
+	switch {
+	case len(sTag) == 0:
+		http.NotFound(w, r)
+	case len(sAction) == 0:
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		procNames := gpcprocessmgr.TaggedProcesses(sTag)
+		if len(procNames) == 0 {
+			http.Error(w, "no process has the tag <"+sTag+">", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, procNames)
+	case actions[sAction] == nil:
+		http.NotFound(w, r)
+	case r.Method != http.MethodPost:
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		gpclogging.Info("Group <%s>: %s requested via HTTP API from <%s>.", sTag, sAction, r.RemoteAddr)
+		ctx, cancel := context.WithTimeout(r.Context(), actionTimeout)
+		defer cancel()
+		if err := actions[sAction](ctx, sTag); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleRollout restarts the instances of a task with Instances one at a time, waiting for each to
+// get ready. Answers 204 once all of them are ready, it is cancelled if the client goes away.
+//------------------------------------------------------------------------------
+func handleRollout(w http.ResponseWriter, r *http.Request) {
+	sTemplate, found := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/tasks/"), "/rollout")
+	if !found || len(sTemplate) == 0 || strings.Contains(sTemplate, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	gpclogging.Info("Task <%s>: rollout requested via HTTP API from <%s>.", sTemplate, r.RemoteAddr)
+
+	if err := gpcprocessmgr.RollingRestart(r.Context(), sTemplate); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleMaintenance answers the maintenances as JSON array (GET, controller only), or puts a process
+// or with an empty name the controller into maintenance (PUT, the body is an optional duration like
+// 30m) or ends it (DELETE). Answers 204 once set or ended.
+//------------------------------------------------------------------------------
+func handleMaintenance(w http.ResponseWriter, r *http.Request, procName string) {
+	sTarget := procName
+	if len(sTarget) == 0 {
+		sTarget = "controller"
+	}
+	var err error
+	switch {
+	case r.Method == http.MethodGet && len(procName) == 0:
+		writeJSON(w, gpcprocessmgr.GetMaintenance())
+		return
+	case r.Method == http.MethodPut:
+		body, errRead := io.ReadAll(io.LimitReader(r.Body, 64))
+		if errRead != nil {
+			http.Error(w, errRead.Error(), http.StatusBadRequest)
+			return
+		}
+		var duration time.Duration
+		if sDuration := strings.TrimSpace(string(body)); len(sDuration) > 0 {
+			if duration, errRead = time.ParseDuration(sDuration); errRead != nil || duration <= 0 {
+				http.Error(w, "invalid duration <"+sDuration+">, e.g. 30m", http.StatusBadRequest)
+				return
+			}
+		}
+		gpclogging.Info("Maintenance of <%s> requested via HTTP API from <%s>.", sTarget, r.RemoteAddr)
+		err = gpcprocessmgr.SetMaintenance(procName, duration)
+	case r.Method == http.MethodDelete:
+		gpclogging.Info("End of maintenance of <%s> requested via HTTP API from <%s>.", sTarget, r.RemoteAddr)
+		err = gpcprocessmgr.EndMaintenance(procName)
+	default:
+		sAllow := http.MethodPut + ", " + http.MethodDelete
+		if len(procName) == 0 {
+			sAllow = http.MethodGet + ", " + sAllow
+		}
+		w.Header().Set("Allow", sAllow)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleStatus answers the state of all processes as JSON array
+//------------------------------------------------------------------------------
+func handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, gpcprocessmgr.GetStatus())
+}
+
+// handleProcessStatus answers the state of a process as JSON object
+//------------------------------------------------------------------------------
+func handleProcessStatus(w http.ResponseWriter, r *http.Request, procName string) {
+	procStatus, err := gpcprocessmgr.GetProcessStatus(procName)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, procStatus)
+}
+
+// handleAction starts, stops, restarts or suspends a process, or resumes a suspended one or takes
+// it out of quarantine and starts it. Answers 204 once done.
+//------------------------------------------------------------------------------
+func handleAction(w http.ResponseWriter, r *http.Request, procName string, sAction string) {
+	actions := map[string]func(context.Context, string) error{
+		"start":   gpcprocessmgr.StartProcess,
+		"stop":    gpcprocessmgr.StopProcess,
+		"restart": gpcprocessmgr.RestartProcess,
+		"suspend": gpcprocessmgr.SuspendProcess,
+		"resume":  gpcprocessmgr.ResumeProcess,
+	}
+	gpclogging.Info("Process <%s>: %s requested via HTTP API from <%s>.", procName, sAction, r.RemoteAddr)
+
+	ctx, cancel := context.WithTimeout(r.Context(), actionTimeout)
+	defer cancel()
+	if err := actions[sAction](ctx, procName); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSignal sends the signal named in the body (HUP, USR1, ... or CTRL_BREAK on Windows) to a
+// process. Answers 204 once sent, 400 for a signal the platform does not know.
+//------------------------------------------------------------------------------
+func handleSignal(w http.ResponseWriter, r *http.Request, procName string) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sSignal := strings.TrimSpace(string(body))
+	if len(sSignal) == 0 {
+		http.Error(w, "missing signal, one of "+strings.Join(gpcprocessmgr.Signals(), ", "), http.StatusBadRequest)
+		return
+	}
+	gpclogging.Info("Process <%s>: signal <%s> requested via HTTP API from <%s>.", procName, sSignal, r.RemoteAddr)
+
+	if err := gpcprocessmgr.SignalProcess(procName, sSignal); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleInput writes the body to the stdin of a process, a line break is added if it does not end
+// with one. Answers 204 once the process has taken it.
+//------------------------------------------------------------------------------
+func handleInput(w http.ResponseWriter, r *http.Request, procName string) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxInputBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	gpclogging.Info("Process <%s>: input of <%d> bytes via HTTP API from <%s>.", procName, len(body), r.RemoteAddr)
+
+	ctx, cancel := context.WithTimeout(r.Context(), actionTimeout)
+	defer cancel()
+	if err := gpcprocessmgr.WriteProcessInput(ctx, procName, string(body)); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJSON answers a value as JSON
+//------------------------------------------------------------------------------
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		gpclogging.Warn("Could not write the JSON answer: <%s>", err.Error())
+	}
+}
+
+// handleRecentOutput answers the latest output lines kept in memory as text, the number of lines
+// as lines parameter, all of them without
+//------------------------------------------------------------------------------
+func handleRecentOutput(w http.ResponseWriter, r *http.Request, procName string) {
+	numLines := 0
+	if sLines := r.URL.Query().Get("lines"); len(sLines) > 0 {
+		n, err := strconv.Atoi(sLines)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid lines <"+sLines+">", http.StatusBadRequest)
+			return
+		}
+		numLines = n
+	}
+	lines, err := gpcprocessmgr.RecentOutput(procName, numLines)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, sLine := range lines {
+		io.WriteString(w, sLine+"\n")
+	}
+}
+
+// handleLogLevel answers the log level as text, a PUT with the new level (debug, info, warn
+// or error) as body changes it first
+//------------------------------------------------------------------------------
+func handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		body, err := io.ReadAll(io.LimitReader(r.Body, 64))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := gpclogging.SetLogLevel(strings.TrimSpace(string(body))); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		gpclogging.Warn("Log level changed to <%s> via HTTP API from <%s>.", gpclogging.GetLogLevel(), r.RemoteAddr)
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPut)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, gpclogging.GetLogLevel()+"\n")
+}
+
+// writeError answers a request with the status code matching a gpcprocessmgr error
+//------------------------------------------------------------------------------
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, gpcprocessmgr.ErrUnknownProcess), errors.Is(err, gpcprocessmgr.ErrNoRecentOutput),
+		errors.Is(err, gpcprocessmgr.ErrNoTaggedProcess), errors.Is(err, gpcprocessmgr.ErrNoInstances):
+		status = http.StatusNotFound
+	case errors.Is(err, gpcprocessmgr.ErrAlreadyRunning), errors.Is(err, gpcprocessmgr.ErrNotRunning),
+		errors.Is(err, gpcprocessmgr.ErrNotQuarantined), errors.Is(err, gpcprocessmgr.ErrWaitingOnCondition),
+		errors.Is(err, gpcprocessmgr.ErrAlreadySuspended), errors.Is(err, gpcprocessmgr.ErrNoStdin),
+		errors.Is(err, gpcprocessmgr.ErrNotInMaintenance):
+		status = http.StatusConflict
+	case errors.Is(err, gpcprocessmgr.ErrSignalFailed):
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
@@ -0,0 +1,66 @@
+package gpcapi
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"gpcconfig"
+	"gpclogging"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// tlsConfig returns the TLS settings of the API, nil without certificate. With ClientCAFile
+// only clients with a certificate of one of its CAs get through the handshake.
+//------------------------------------------------------------------------------
+func tlsConfig(apiConfig gpcconfig.APIConfig) (*tls.Config, error) {
+	if len(apiConfig.TLSCertFile) == 0 {
+		return nil, nil
+	}
+	certificate, err := tls.LoadX509KeyPair(apiConfig.TLSCertFile, apiConfig.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12, NextProtos: []string{"h2", "http/1.1"}}
+
+	if len(apiConfig.ClientCAFile) > 0 {
+		caPEM, err := os.ReadFile(apiConfig.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in <%s>", apiConfig.ClientCAFile)
+		}
+		config.ClientCAs = clientCAs
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// requireToken lets requests through that carry the bearer token, in the Authorization header
+// or, for GET requests like an EventSource that cannot set headers, as access_token parameter.
+// The dashboard page itself holds no data and is served to everyone, it asks for the token.
+//------------------------------------------------------------------------------
+func requireToken(sToken string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sGiven, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found && r.Method == http.MethodGet {
+			sGiven = r.URL.Query().Get("access_token")
+		}
+		if subtle.ConstantTimeCompare([]byte(sGiven), []byte(sToken)) != 1 {
+			gpclogging.Warn("HTTP API request <%s %s> from <%s> rejected: missing or wrong token.", r.Method, r.URL.Path, r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gpc"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,31 @@
+package gpcapi
+
+import (
+	_ "embed" // the dashboard page is compiled into the binary
+	"net/http"
+)
+
+// The dashboard is a single page without external assets, it works on hosts without internet
+// access. It polls GET /processes, posts the buttons to the start, stop, restart, suspend and
+// resume endpoints and shows the live output of a process from its stream.
+//
+//go:embed dashboard.html
+var gDashboardPage []byte
+
+// handleDashboard serves the web dashboard at the root, any other unknown path is not found
+//------------------------------------------------------------------------------
+func handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Write(gDashboardPage)
+}
@@ -0,0 +1,294 @@
+package gpcapi
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"gpclogging"
+	"gpcprocessmgr"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The gRPC service of proto/gpc/v1/process_controller.proto, served on the HTTP/2 connections
+// of the API without a gRPC library: each call is a POST to /gpc.v1.ProcessController/<Method>
+// with length prefixed protobuf messages in the bodies and the status in the trailers.
+
+// consts
+const (
+	grpcServicePath    = "/gpc.v1.ProcessController/"
+	grpcMaxMessageSize = 4 * 1024 * 1024
+)
+
+// gRPC status codes
+const (
+	grpcOK                 = 0
+	grpcInvalidArgument    = 3
+	grpcNotFound           = 5
+	grpcFailedPrecondition = 9
+	grpcUnimplemented      = 12
+	grpcInternal           = 13
+	grpcUnavailable        = 14
+)
+
+// grpcError is an error with its gRPC status code
+type grpcError struct {
+	code    int
+	message string
+}
+
+// Error implements the error interface
+func (e *grpcError) Error() string {
+	return e.message
+}
+
+// isGRPC reports whether a request is a gRPC call
+//------------------------------------------------------------------------------
+func isGRPC(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+// handleGRPC answers a gRPC call: reads the request message, calls the method and sends the
+// status as trailers, also for errors
+//------------------------------------------------------------------------------
+func handleGRPC(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+
+	err := callGRPC(w, r)
+	code, message := grpcOK, ""
+	if err != nil {
+		code, message = grpcInternal, err.Error()
+		var statusErr *grpcError
+		if errors.As(err, &statusErr) {
+			code = statusErr.code
+		} else {
+			code = grpcCode(err)
+		}
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	w.Header().Set("Grpc-Message", grpcPercentEncode(message))
+}
+
+// callGRPC calls the method of the request path
+//------------------------------------------------------------------------------
+func callGRPC(w http.ResponseWriter, r *http.Request) error {
+	sMethod, found := strings.CutPrefix(r.URL.Path, grpcServicePath)
+	if !found {
+		return &grpcError{grpcUnimplemented, "unknown service of <" + r.URL.Path + ">"}
+	}
+	request, err := readGRPCMessage(r.Body)
+	if err != nil {
+		return err
+	}
+
+	switch sMethod {
+	case "ListTasks":
+		var response protoMessage
+		for _, procStatus := range gpcprocessmgr.GetStatus() {
+			response.message(1, taskStatusMessage(procStatus))
+		}
+		return writeGRPCMessage(w, response)
+	case "GetStatus":
+		procStatus, err := gpcprocessmgr.GetProcessStatus(request.string(1))
+		if err != nil {
+			return err
+		}
+		return writeGRPCMessage(w, taskStatusMessage(procStatus))
+	case "StartTask", "StopTask":
+		procName := request.string(1)
+		gpclogging.Info("Process <%s>: %s requested via gRPC from <%s>.", procName, strings.ToLower(strings.TrimSuffix(sMethod, "Task")), r.RemoteAddr)
+		ctx, cancel := context.WithTimeout(r.Context(), actionTimeout)
+		defer cancel()
+		action := gpcprocessmgr.StartProcess
+		if sMethod == "StopTask" {
+			action = gpcprocessmgr.StopProcess
+		}
+		if err := action(ctx, procName); err != nil {
+			return err
+		}
+		return writeGRPCMessage(w, nil)
+	case "StreamEvents":
+		return streamGRPCEvents(w, r, request.strings(1), request.string(2))
+	case "StreamLogs":
+		return streamGRPCLogs(w, r, request.string(1), int(request.uint64(2)))
+	}
+	return &grpcError{grpcUnimplemented, "unknown method <" + sMethod + ">"}
+}
+
+// streamGRPCEvents sends the lifecycle events of the wanted types and task until the client cancels
+//------------------------------------------------------------------------------
+func streamGRPCEvents(w http.ResponseWriter, r *http.Request, eventTypes []string, procName string) error {
+	wantedTypes := make(map[string]bool)
+	for _, sType := range eventTypes {
+		wantedTypes[sType] = true
+	}
+	subscription := gpcprocessmgr.Subscribe(0)
+	defer subscription.Unsubscribe()
+	w.(http.Flusher).Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case event, ok := <-subscription.C:
+			if !ok {
+				return &grpcError{grpcUnavailable, "event stream closed"}
+			}
+			if (len(wantedTypes) > 0 && !wantedTypes[string(event.Type)]) || (len(procName) > 0 && event.Process != procName) {
+				continue
+			}
+			var message protoMessage
+			message.string(1, string(event.Type))
+			message.int64(2, event.Time.UnixMilli())
+			message.string(3, event.Process)
+			message.string(4, event.State)
+			message.int64(5, int64(event.PID))
+			message.int64(6, int64(event.ExitCode))
+			message.bool(7, event.Failed)
+			message.string(8, event.Message)
+			if err := writeGRPCMessage(w, message); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// streamGRPCLogs sends the last lines of the current output of a process and then its new
+// output until the client cancels, like the SSE stream
+//------------------------------------------------------------------------------
+func streamGRPCLogs(w http.ResponseWriter, r *http.Request, procName string, numLines int) error {
+	currentLog, err := gpcprocessmgr.GetProcessLogFile(procName)
+	if err != nil && !errors.Is(err, gpcprocessmgr.ErrNoLogFile) {
+		return err
+	}
+	if numLines == 0 {
+		numLines = defStreamLines
+	}
+
+	var writeErr error
+	emit := func(line string) bool {
+		var message protoMessage
+		message.string(1, line)
+		writeErr = writeGRPCMessage(w, message)
+		return writeErr == nil && r.Context().Err() == nil
+	}
+	restarted := func() {
+		var message protoMessage
+		message.bool(2, true)
+		writeGRPCMessage(w, message)
+	}
+	followOutput(r, procName, currentLog, numLines, emit, restarted, w.(http.Flusher).Flush)
+	return writeErr
+}
+
+// taskStatusMessage encodes the state of a process as TaskStatus
+//------------------------------------------------------------------------------
+func taskStatusMessage(procStatus gpcprocessmgr.ProcessStatus) protoMessage {
+	var message protoMessage
+	message.string(1, procStatus.Name)
+	message.string(2, procStatus.State)
+	message.int64(3, int64(procStatus.PID))
+	message.bool(4, procStatus.Adopted)
+	if !procStatus.StartTime.IsZero() {
+		message.int64(5, procStatus.StartTime.UnixMilli())
+	}
+	message.int64(6, int64(procStatus.Uptime/time.Millisecond))
+	message.uint64(7, uint64(procStatus.RestartCount))
+	message.int64(8, int64(procStatus.LastExitCode))
+	message.string(9, procStatus.LastError)
+	message.double(10, procStatus.Usage.CPUPercent)
+	message.uint64(11, procStatus.Usage.MemoryBytes)
+	message.int64(12, int64(procStatus.Usage.Handles))
+	message.string(13, procStatus.WaitingOn)
+	for sEnvName, port := range procStatus.Ports {
+		var entry protoMessage
+		entry.string(1, sEnvName)
+		entry.int64(2, int64(port))
+		message.message(14, entry)
+	}
+	message.uint64(15, uint64(procStatus.Stats.Starts))
+	message.uint64(16, uint64(procStatus.Stats.Restarts))
+	message.uint64(17, uint64(procStatus.Stats.Crashes))
+	message.int64(18, int64(procStatus.Stats.TotalUptime/time.Millisecond))
+	message.int64(19, int64(procStatus.Stats.MTBF/time.Millisecond))
+	for _, exit := range procStatus.Stats.Exits {
+		var record protoMessage
+		record.int64(1, exit.Time.UnixMilli())
+		record.int64(2, int64(exit.ExitCode))
+		record.bool(3, exit.Failed)
+		record.string(4, exit.Message)
+		message.message(20, record)
+	}
+	return message
+}
+
+// readGRPCMessage reads the request message, compressed messages are not supported
+//------------------------------------------------------------------------------
+func readGRPCMessage(body io.Reader) (protoFields, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(body, prefix[:]); err != nil {
+		return nil, &grpcError{grpcInvalidArgument, "missing request message"}
+	}
+	if prefix[0] != 0 {
+		return nil, &grpcError{grpcUnimplemented, "compressed messages are not supported"}
+	}
+	length := binary.BigEndian.Uint32(prefix[1:])
+	if length > grpcMaxMessageSize {
+		return nil, &grpcError{grpcInvalidArgument, fmt.Sprintf("request message of %d bytes is too big", length)}
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(body, data); err != nil {
+		return nil, &grpcError{grpcInvalidArgument, "truncated request message"}
+	}
+	fields, err := parseProto(data)
+	if err != nil {
+		return nil, &grpcError{grpcInvalidArgument, err.Error()}
+	}
+	return fields, nil
+}
+
+// writeGRPCMessage sends a response message and flushes it to the client
+//------------------------------------------------------------------------------
+func writeGRPCMessage(w http.ResponseWriter, message protoMessage) error {
+	frame := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	if _, err := w.Write(append(frame, message...)); err != nil {
+		return err
+	}
+	w.(http.Flusher).Flush()
+	return nil
+}
+
+// grpcCode maps the errors of the process manager to gRPC status codes
+//------------------------------------------------------------------------------
+func grpcCode(err error) int {
+	switch {
+	case errors.Is(err, gpcprocessmgr.ErrUnknownProcess):
+		return grpcNotFound
+	case errors.Is(err, gpcprocessmgr.ErrAlreadyRunning), errors.Is(err, gpcprocessmgr.ErrNotRunning),
+		errors.Is(err, gpcprocessmgr.ErrQuarantined), errors.Is(err, gpcprocessmgr.ErrWaitingOnCondition),
+		errors.Is(err, gpcprocessmgr.ErrAlreadySuspended), errors.Is(err, gpcprocessmgr.ErrNotInMaintenance):
+		return grpcFailedPrecondition
+	}
+	return grpcInternal
+}
+
+// grpcPercentEncode encodes a status message for the Grpc-Message trailer
+//------------------------------------------------------------------------------
+func grpcPercentEncode(sMessage string) string {
+	var encoded strings.Builder
+	for i := 0; i < len(sMessage); i++ {
+		if c := sMessage[i]; c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		} else {
+			encoded.WriteByte(c)
+		}
+	}
+	return encoded.String()
+}
@@ -0,0 +1,310 @@
+package gpcapi
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"gpclogging"
+	"gpcprocessmgr"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// consts
+const (
+	defLogLimit       = 1000                   // lines per page
+	maxLogLimit       = 10000                  // most lines per page a client may ask for
+	maxLogLineLength  = 1024 * 1024            // longer lines are cut
+	logFollowInterval = 500 * time.Millisecond // how often a followed log is checked for new output
+)
+
+// logQuery holds the parameters of a logs request
+type logQuery struct {
+	since  time.Time // only log files written to after this time, zero => all
+	offset int       // lines to skip
+	limit  int       // most lines to return, ignored when following
+	follow bool      // keep the request open and stream new output
+}
+
+//handleLogs serves the output of a process as text: all its log files (one per launch),
+//oldest first. Parameters:
+//
+//	since   RFC3339 time or a duration like "2h" back from now. Log files not written to
+//	        after that time are skipped. The output has no time stamps, so it does not filter
+//	        lines within a file.
+//	offset  number of lines to skip, for paging
+//	limit   number of lines per page, default 1000, at most 10000. If there are more lines,
+//	        the answer has the offset of the next page in the header X-Next-Offset.
+//	follow  "true" keeps the request open and streams new output of the process, also
+//	        across restarts. limit is ignored then.
+//
+//The answer is gzip compressed if the client accepts it.
+//#########################################################
+func handleLogs(w http.ResponseWriter, r *http.Request, procName string) {
+	currentLog, err := gpcprocessmgr.GetProcessLogFile(procName)
+	if err != nil && !errors.Is(err, gpcprocessmgr.ErrNoLogFile) {
+		writeError(w, err)
+		return
+	}
+	query, err := parseLogQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logFiles, err := gpclogging.GetLogFilesOfProcess(procName)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	logFiles = filterLogFiles(logFiles, query.since)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Vary", "Accept-Encoding")
+	out := io.Writer(w)
+	flush := func() {}
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		gzipWriter := gzip.NewWriter(w)
+		defer gzipWriter.Close()
+		out = gzipWriter
+		flush = func() { gzipWriter.Flush() }
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flushGzip := flush
+		flush = func() {
+			flushGzip()
+			flusher.Flush()
+		}
+	}
+
+	if query.follow {
+		followLogs(r, procName, logFiles, currentLog, query, out, flush)
+		return
+	}
+
+	// One line more than asked for tells whether there is a next page
+	var lines []string
+	skip := query.offset
+	err = readLogLines(logFiles, func(line string) bool {
+		if skip > 0 {
+			skip--
+			return true
+		}
+		lines = append(lines, line)
+		return len(lines) <= query.limit
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(lines) > query.limit {
+		lines = lines[:query.limit]
+		nextOffset := query.offset + query.limit
+		w.Header().Set("X-Next-Offset", strconv.Itoa(nextOffset))
+		nextQuery := r.URL.Query()
+		nextQuery.Set("offset", strconv.Itoa(nextOffset))
+		w.Header().Set("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", r.URL.Path, nextQuery.Encode()))
+	}
+	for _, line := range lines {
+		fmt.Fprintln(out, line)
+	}
+}
+
+// followLogs writes the older log files and then streams the current one until the client
+// goes away. A restart of the process switches to its new log file.
+//------------------------------------------------------------------------------
+func followLogs(r *http.Request, procName string, logFiles []string, currentLog string, query logQuery, out io.Writer, flush func()) {
+	skip := query.offset
+	emit := func(line string) bool {
+		if skip > 0 {
+			skip--
+			return true
+		}
+		fmt.Fprintln(out, line)
+		return r.Context().Err() == nil
+	}
+
+	// History first, the current file is followed below
+	var olderFiles []string
+	for _, logFile := range logFiles {
+		if !sameFile(logFile, currentLog) {
+			olderFiles = append(olderFiles, logFile)
+		}
+	}
+	if err := readLogLines(olderFiles, emit); err != nil {
+		gpclogging.Warn("Could not read the logs of process <%s>: <%s>", procName, err.Error())
+		return
+	}
+	flush()
+
+	for len(currentLog) > 0 || waitForLogFile(r, procName, &currentLog) {
+		nextLog, err := followLogFile(r, procName, currentLog, 0, emit, flush)
+		if err != nil {
+			gpclogging.Warn("Could not follow log <%s> of process <%s>: <%s>", currentLog, procName, err.Error())
+			return
+		}
+		if len(nextLog) == 0 {
+			return
+		}
+		currentLog = nextLog
+	}
+}
+
+// followLogFile streams a log file from an offset until the process switches to a new one,
+// which is returned, or the client goes away, which returns an empty name
+//------------------------------------------------------------------------------
+func followLogFile(r *http.Request, procName string, logFile string, start int64, emit func(string) bool, flush func()) (string, error) {
+	f, err := os.Open(logFile)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	reader := bufio.NewReader(f)
+	var partialLine string
+	for {
+		chunk, err := reader.ReadString('\n')
+		if err == nil {
+			if !emit(strings.TrimRight(partialLine+chunk, "\r\n")) {
+				return "", nil
+			}
+			partialLine = ""
+			continue
+		}
+		if err != io.EOF {
+			return "", err
+		}
+		partialLine += chunk
+		flush()
+
+		// Everything written so far is sent, a new log file means the old one is complete
+		if nextLog, err := gpcprocessmgr.GetProcessLogFile(procName); err == nil && !sameFile(nextLog, logFile) {
+			if len(partialLine) > 0 {
+				emit(partialLine)
+			}
+			return nextLog, nil
+		}
+		select {
+		case <-r.Context().Done():
+			return "", nil
+		case <-time.After(logFollowInterval):
+		}
+	}
+}
+
+// waitForLogFile waits until a process that never ran gets its first log file
+//------------------------------------------------------------------------------
+func waitForLogFile(r *http.Request, procName string, logFile *string) bool {
+	for {
+		select {
+		case <-r.Context().Done():
+			return false
+		case <-time.After(logFollowInterval):
+		}
+		currentLog, err := gpcprocessmgr.GetProcessLogFile(procName)
+		if errors.Is(err, gpcprocessmgr.ErrUnknownProcess) {
+			return false
+		}
+		if err == nil {
+			*logFile = currentLog
+			return true
+		}
+	}
+}
+
+// readLogLines calls emit for each line of the log files, until it returns false
+//------------------------------------------------------------------------------
+func readLogLines(logFiles []string, emit func(string) bool) error {
+	for _, logFile := range logFiles {
+		f, err := os.Open(logFile)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Purged meanwhile
+				continue
+			}
+			return err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), maxLogLineLength)
+		for scanner.Scan() {
+			if !emit(scanner.Text()) {
+				f.Close()
+				return nil
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterLogFiles drops the log files that were last written to before a time
+//------------------------------------------------------------------------------
+func filterLogFiles(logFiles []string, since time.Time) []string {
+	if since.IsZero() {
+		return logFiles
+	}
+	var filtered []string
+	for _, logFile := range logFiles {
+		if fileInfo, err := os.Stat(logFile); err == nil && !fileInfo.ModTime().Before(since) {
+			filtered = append(filtered, logFile)
+		}
+	}
+	return filtered
+}
+
+// parseLogQuery reads the parameters of a logs request
+//------------------------------------------------------------------------------
+func parseLogQuery(values url.Values) (logQuery, error) {
+	query := logQuery{limit: defLogLimit}
+
+	if sSince := values.Get("since"); len(sSince) > 0 {
+		if since, err := time.Parse(time.RFC3339, sSince); err == nil {
+			query.since = since
+		} else if ago, err := time.ParseDuration(sSince); err == nil {
+			query.since = time.Now().Add(-ago)
+		} else {
+			return query, fmt.Errorf("since must be an RFC3339 time or a duration, found <%s>", sSince)
+		}
+	}
+	if sOffset := values.Get("offset"); len(sOffset) > 0 {
+		offset, err := strconv.Atoi(sOffset)
+		if err != nil || offset < 0 {
+			return query, fmt.Errorf("invalid offset <%s>", sOffset)
+		}
+		query.offset = offset
+	}
+	if sLimit := values.Get("limit"); len(sLimit) > 0 {
+		limit, err := strconv.Atoi(sLimit)
+		if err != nil || limit < 1 || limit > maxLogLimit {
+			return query, fmt.Errorf("limit must be 1-%d, found <%s>", maxLogLimit, sLimit)
+		}
+		query.limit = limit
+	}
+	if sFollow := values.Get("follow"); len(sFollow) > 0 {
+		follow, err := strconv.ParseBool(sFollow)
+		if err != nil {
+			return query, fmt.Errorf("invalid follow <%s>", sFollow)
+		}
+		query.follow = follow
+	}
+	return query, nil
+}
+
+// sameFile compares two paths of log files, which may be spelled differently
+//------------------------------------------------------------------------------
+func sameFile(pathA string, pathB string) bool {
+	return filepath.Clean(pathA) == filepath.Clean(pathB)
+}
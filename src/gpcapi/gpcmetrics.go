@@ -0,0 +1,107 @@
+package gpcapi
+
+import (
+	"fmt"
+	"gpclogging"
+	"gpcprocessmgr"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// metric describes one counter for the Prometheus text format
+type metric struct {
+	name  string
+	help  string
+	value uint64
+}
+
+// gauge describes one per-task gauge for the Prometheus text format
+type gauge struct {
+	name  string
+	help  string
+	value func(usage gpcprocessmgr.ResourceUsage) string
+}
+
+//handleMetrics serves the counters of the controller, the statistics and the resource usage of
+//the processes in the Prometheus text format
+//#########################################################
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	logMetrics := gpclogging.GetMetrics()
+	metrics := []metric{
+		{"gpc_log_lines_written_total", "Log lines written by the controller.", logMetrics.LinesWritten},
+		{"gpc_log_bytes_written_total", "Bytes of logs written by the controller.", logMetrics.BytesWritten},
+		{"gpc_log_rotations_total", "Log files started because of the day or size limit.", logMetrics.Rotations},
+		{"gpc_log_purged_files_total", "Old log files deleted.", logMetrics.PurgedFiles},
+		{"gpc_log_compressions_total", "Rotated log files compressed with gzip.", logMetrics.Compressions},
+		{"gpc_log_write_errors_total", "Failures to write, open or purge log files.", logMetrics.WriteErrors},
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, m := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", m.name, m.help, m.name, m.name, m.value)
+	}
+
+	eventCounts := gpcprocessmgr.EventCounts()
+	eventTypes := make([]string, 0, len(eventCounts))
+	for eventType := range eventCounts {
+		eventTypes = append(eventTypes, string(eventType))
+	}
+	sort.Strings(eventTypes)
+	fmt.Fprintf(w, "# HELP gpc_events_total Lifecycle events of the processes and the controller.\n# TYPE gpc_events_total counter\n")
+	for _, eventType := range eventTypes {
+		fmt.Fprintf(w, "gpc_events_total{type=%q} %d\n", eventType, eventCounts[gpcprocessmgr.EventType(eventType)])
+	}
+
+	procStatuses := gpcprocessmgr.GetStatus()
+	counters := []struct {
+		name  string
+		help  string
+		value func(stats gpcprocessmgr.ProcessStats) string
+	}{
+		{"gpc_process_starts_total", "Runs of the task launched or adopted.",
+			func(s gpcprocessmgr.ProcessStats) string { return strconv.FormatUint(uint64(s.Starts), 10) }},
+		{"gpc_process_restarts_total", "Automatic, scheduled and manual restarts of the task.",
+			func(s gpcprocessmgr.ProcessStats) string { return strconv.FormatUint(uint64(s.Restarts), 10) }},
+		{"gpc_process_crashes_total", "Runs of the task that ended on their own without success.",
+			func(s gpcprocessmgr.ProcessStats) string { return strconv.FormatUint(uint64(s.Crashes), 10) }},
+		{"gpc_process_uptime_seconds_total", "Time all runs of the task were running.",
+			func(s gpcprocessmgr.ProcessStats) string {
+				return strconv.FormatFloat(s.TotalUptime.Seconds(), 'f', 0, 64)
+			}},
+	}
+	for _, c := range counters {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, procStatus := range procStatuses {
+			fmt.Fprintf(w, "%s{task=%q} %s\n", c.name, procStatus.Name, c.value(procStatus.Stats))
+		}
+	}
+
+	// Only running processes that were sampled already
+	var sampled []gpcprocessmgr.ProcessStatus
+	for _, procStatus := range procStatuses {
+		if !procStatus.Usage.Sampled.IsZero() {
+			sampled = append(sampled, procStatus)
+		}
+	}
+	gauges := []gauge{
+		{"gpc_process_cpu_percent", "CPU usage of the process tree, 100 is one CPU busy.",
+			func(u gpcprocessmgr.ResourceUsage) string { return strconv.FormatFloat(u.CPUPercent, 'f', 1, 64) }},
+		{"gpc_process_memory_bytes", "Resident memory of the process tree.",
+			func(u gpcprocessmgr.ResourceUsage) string { return strconv.FormatUint(u.MemoryBytes, 10) }},
+		{"gpc_process_handles", "Open handles (Windows) or file descriptors (Linux) of the process tree.",
+			func(u gpcprocessmgr.ResourceUsage) string { return strconv.Itoa(u.Handles) }},
+	}
+	for _, g := range gauges {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+		for _, procStatus := range sampled {
+			fmt.Fprintf(w, "%s{task=%q} %s\n", g.name, procStatus.Name, g.value(procStatus.Usage))
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package gpcapi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Just enough of the protobuf wire format for the messages of the gRPC API, see
+// proto/gpc/v1/process_controller.proto. Like proto3, fields with their zero value are not
+// written, and unknown fields are skipped when reading.
+
+// wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// protoMessage is an encoded message, built field by field
+type protoMessage []byte
+
+// tag appends the key of a field
+//------------------------------------------------------------------------------
+func (m *protoMessage) tag(field int, wireType int) {
+	*m = binary.AppendUvarint(*m, uint64(field)<<3|uint64(wireType))
+}
+
+// uint64 appends an uint32, uint64 or bool field
+//------------------------------------------------------------------------------
+func (m *protoMessage) uint64(field int, value uint64) {
+	if value == 0 {
+		return
+	}
+	m.tag(field, wireVarint)
+	*m = binary.AppendUvarint(*m, value)
+}
+
+// int64 appends an int32 or int64 field, negative values take ten bytes as in protobuf
+//------------------------------------------------------------------------------
+func (m *protoMessage) int64(field int, value int64) {
+	m.uint64(field, uint64(value))
+}
+
+// bool appends a bool field
+//------------------------------------------------------------------------------
+func (m *protoMessage) bool(field int, value bool) {
+	if value {
+		m.uint64(field, 1)
+	}
+}
+
+// double appends a double field
+//------------------------------------------------------------------------------
+func (m *protoMessage) double(field int, value float64) {
+	if value == 0 {
+		return
+	}
+	m.tag(field, wireFixed64)
+	*m = binary.LittleEndian.AppendUint64(*m, math.Float64bits(value))
+}
+
+// string appends a string field
+//------------------------------------------------------------------------------
+func (m *protoMessage) string(field int, value string) {
+	if len(value) == 0 {
+		return
+	}
+	m.tag(field, wireBytes)
+	*m = binary.AppendUvarint(*m, uint64(len(value)))
+	*m = append(*m, value...)
+}
+
+// message appends an embedded message, also an empty one, as element of a repeated field
+//------------------------------------------------------------------------------
+func (m *protoMessage) message(field int, value protoMessage) {
+	m.tag(field, wireBytes)
+	*m = binary.AppendUvarint(*m, uint64(len(value)))
+	*m = append(*m, value...)
+}
+
+// protoFields are the varint and length delimited fields of a received message by number,
+// repeated ones in their order
+type protoFields map[int][]protoValue
+
+// protoValue is the value of a field as it was on the wire
+type protoValue struct {
+	varint uint64
+	bytes  []byte
+}
+
+// parseProto splits a received message into its fields
+//------------------------------------------------------------------------------
+func parseProto(data []byte) (protoFields, error) {
+	fields := make(protoFields)
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid field key")
+		}
+		data = data[n:]
+		field, wireType := int(key>>3), int(key&7)
+
+		switch wireType {
+		case wireVarint:
+			value, n := binary.Uvarint(data)
+			if n <= 0 {
+				return nil, fmt.Errorf("invalid varint of field %d", field)
+			}
+			data = data[n:]
+			fields[field] = append(fields[field], protoValue{varint: value})
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return nil, fmt.Errorf("invalid length of field %d", field)
+			}
+			fields[field] = append(fields[field], protoValue{bytes: data[n : n+int(length)]})
+			data = data[n+int(length):]
+		case wireFixed64:
+			if len(data) < 8 {
+				return nil, fmt.Errorf("truncated field %d", field)
+			}
+			data = data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return nil, fmt.Errorf("truncated field %d", field)
+			}
+			data = data[4:]
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d of field %d", wireType, field)
+		}
+	}
+	return fields, nil
+}
+
+// string returns the last value of a string field, empty if missing
+//------------------------------------------------------------------------------
+func (f protoFields) string(field int) string {
+	values := f[field]
+	if len(values) == 0 {
+		return ""
+	}
+	return string(values[len(values)-1].bytes)
+}
+
+// strings returns all values of a repeated string field
+//------------------------------------------------------------------------------
+func (f protoFields) strings(field int) []string {
+	var values []string
+	for _, value := range f[field] {
+		values = append(values, string(value.bytes))
+	}
+	return values
+}
+
+// uint64 returns the last value of a varint field, zero if missing
+//------------------------------------------------------------------------------
+func (f protoFields) uint64(field int) uint64 {
+	values := f[field]
+	if len(values) == 0 {
+		return 0
+	}
+	return values[len(values)-1].varint
+}
@@ -0,0 +1,151 @@
+package gpcapi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"gpclogging"
+	"gpcprocessmgr"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// consts
+const (
+	defStreamLines    = 10               // lines of history before the live output, like tail -f
+	maxStreamTail     = 1024 * 1024      // bytes at the end of the log file the history is taken from
+	streamKeepalive   = 15 * time.Second // idle streams get a comment, so proxies keep them open
+	streamRetryMillis = 3000             // reconnect delay told to EventSource clients
+)
+
+//handleStream streams the output of a process live as Server-Sent Events, one data event per
+//line, for a browser EventSource or gpcctl tail -f. It starts with the last lines of the
+//current log file (lines parameter, default 10, 0 for none) and follows the output across
+//restarts until the client goes away. A restart of the process is sent as "restart" event.
+//#########################################################
+func handleStream(w http.ResponseWriter, r *http.Request, procName string) {
+	currentLog, err := gpcprocessmgr.GetProcessLogFile(procName)
+	if err != nil && !errors.Is(err, gpcprocessmgr.ErrNoLogFile) {
+		writeError(w, err)
+		return
+	}
+	numLines := defStreamLines
+	if sLines := r.URL.Query().Get("lines"); len(sLines) > 0 {
+		n, err := strconv.Atoi(sLines)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid lines <"+sLines+">", http.StatusBadRequest)
+			return
+		}
+		numLines = n
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetryMillis)
+
+	lastWrite := time.Now()
+	emit := func(line string) bool {
+		fmt.Fprintf(w, "data: %s\n\n", line)
+		lastWrite = time.Now()
+		return r.Context().Err() == nil
+	}
+	flush := func() {
+		if time.Since(lastWrite) >= streamKeepalive {
+			io.WriteString(w, ": keepalive\n\n")
+			lastWrite = time.Now()
+		}
+		flusher.Flush()
+	}
+
+	restarted := func() {
+		fmt.Fprintf(w, "event: restart\ndata: %s\n\n", procName)
+	}
+	followOutput(r, procName, currentLog, numLines, emit, restarted, flush)
+}
+
+// followOutput emits the last numLines lines of the current log file of a process and then its
+// new output, across restarts, until the client goes away. restarted is called when the
+// process continues in a new log file.
+//------------------------------------------------------------------------------
+func followOutput(r *http.Request, procName string, currentLog string, numLines int, emit func(string) bool, restarted func(), flush func()) {
+	var start int64
+	if len(currentLog) > 0 {
+		lines, end, err := lastLines(currentLog, numLines)
+		if err != nil {
+			gpclogging.Warn("Could not read the log of process <%s>: <%s>", procName, err.Error())
+			return
+		}
+		for _, line := range lines {
+			emit(line)
+		}
+		start = end
+	}
+	flush()
+
+	for len(currentLog) > 0 || waitForLogFile(r, procName, &currentLog) {
+		nextLog, err := followLogFile(r, procName, currentLog, start, emit, flush)
+		if err != nil {
+			gpclogging.Warn("Could not follow log <%s> of process <%s>: <%s>", currentLog, procName, err.Error())
+			return
+		}
+		if len(nextLog) == 0 {
+			return
+		}
+		currentLog, start = nextLog, 0
+		restarted()
+	}
+}
+
+// lastLines returns the last numLines complete lines of a log file and the offset after them,
+// where following the file goes on
+//------------------------------------------------------------------------------
+func lastLines(logFile string, numLines int) ([]string, int64, error) {
+	f, err := os.Open(logFile)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Only complete lines up to the size seen now, a partial last line is sent when it is complete
+	begin := info.Size() - maxStreamTail
+	if begin < 0 {
+		begin = 0
+	}
+	tail := make([]byte, info.Size()-begin)
+	if _, err := f.ReadAt(tail, begin); err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	end := int64(strings.LastIndexByte(string(tail), '\n') + 1)
+	if numLines == 0 || end == 0 {
+		return nil, begin + end, nil
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(string(tail[:end])))
+	scanner.Buffer(make([]byte, 64*1024), maxLogLineLength)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	// The first line is likely cut when the tail starts within the file
+	if begin > 0 && len(lines) > 0 {
+		lines = lines[1:]
+	}
+	if len(lines) > numLines {
+		lines = lines[len(lines)-numLines:]
+	}
+	return lines, begin + end, nil
+}
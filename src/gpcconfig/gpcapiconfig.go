@@ -0,0 +1,74 @@
+package gpcconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+)
+
+// APIConfig is the HTTP API of the controller. Without TLS, token and client certificates anyone
+// who reaches ListenAddress can start and stop processes: keep it on loopback then.
+type APIConfig struct {
+	ListenAddress string // address of the HTTP API, e.g. "127.0.0.1:8080". Empty disables it
+	TokenEnv      string // name of an environment variable holding the bearer token, takes precedence over Token
+	Token         string // bearer token required in the Authorization header, empty => no token. Prefer TokenEnv
+	TLSCertFile   string // PEM certificate (chain) of the API, with TLSKeyFile the API is served over HTTPS only
+	TLSKeyFile    string // PEM private key of TLSCertFile
+	ClientCAFile  string // PEM CA certificates, clients must present a certificate issued by one of them (mTLS). Requires TLS
+}
+
+//CheckAPI verifies the security settings of the HTTP API. Returns one error per problem found.
+//#########################################################
+func CheckAPI(tConfigData *ConfigData) (errs []error) {
+	api := tConfigData.API
+	if len(api.ListenAddress) == 0 {
+		return nil
+	}
+
+	sHost, _, err := net.SplitHostPort(api.ListenAddress)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("API: listen address <%s> is not host:port", api.ListenAddress))
+	}
+	if (len(api.TLSCertFile) == 0) != (len(api.TLSKeyFile) == 0) {
+		errs = append(errs, fmt.Errorf("API: TLSCertFile and TLSKeyFile must be set together"))
+	} else if len(api.TLSCertFile) > 0 {
+		if _, err := tls.LoadX509KeyPair(api.TLSCertFile, api.TLSKeyFile); err != nil {
+			errs = append(errs, fmt.Errorf("API: invalid certificate or key: %s", err.Error()))
+		}
+	}
+	if len(api.ClientCAFile) > 0 {
+		if len(api.TLSCertFile) == 0 {
+			errs = append(errs, fmt.Errorf("API: ClientCAFile requires TLSCertFile and TLSKeyFile"))
+		}
+		if _, err := os.Stat(api.ClientCAFile); err != nil {
+			errs = append(errs, fmt.Errorf("API: ClientCAFile: %s", err.Error()))
+		}
+	}
+	sToken, err := api.APIToken()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("API: %s", err.Error()))
+	}
+
+	// An unprotected API is only acceptable where no one else can connect
+	if len(sToken) == 0 && len(api.ClientCAFile) == 0 && err == nil {
+		if ip := net.ParseIP(sHost); sHost != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			errs = append(errs, fmt.Errorf("API: <%s> is reachable from the network without Token or ClientCAFile, anyone there can stop the processes", api.ListenAddress))
+		}
+	}
+	return errs
+}
+
+//APIToken returns the bearer token of the HTTP API, from the environment variable named in
+//TokenEnv if set. Empty if no token is required.
+//#########################################################
+func (a *APIConfig) APIToken() (string, error) {
+	if len(a.TokenEnv) == 0 {
+		return a.Token, nil
+	}
+	sToken, found := os.LookupEnv(a.TokenEnv)
+	if !found || len(sToken) == 0 {
+		return "", fmt.Errorf("token environment variable <%s> is not set", a.TokenEnv)
+	}
+	return sToken, nil
+}
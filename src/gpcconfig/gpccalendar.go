@@ -0,0 +1,106 @@
+package gpcconfig
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Calendar is a set of holidays on which scheduled tasks are skipped
+type Calendar struct {
+	Name     string
+	dates    map[string]string // "2006-01-02" => description, single days
+	annually map[string]string // "01-02" => description, same day every year
+}
+
+//LoadCalendar reads a holiday calendar file. Each line holds a date followed by an
+//optional description. A date is either a single day ("2024-12-24") or a day that
+//repeats every year ("12-25"). Empty lines and lines starting with # are ignored.
+//#########################################################
+func LoadCalendar(sName string, sCalendarFilePath string) (*Calendar, error) {
+
+	fCalendarFile, err := os.Open(sCalendarFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("Can't open calendar file: %s", err.Error())
+	}
+	// Close File when this function returns
+	defer fCalendarFile.Close()
+
+	cal := &Calendar{Name: sName, dates: make(map[string]string), annually: make(map[string]string)}
+
+	lineNum := 0
+	scanner := bufio.NewScanner(fCalendarFile)
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// the date and the description may be separated by any white space, e.g. a tab
+		date := strings.Fields(line)[0]
+		description := strings.TrimSpace(strings.TrimPrefix(line, date))
+
+		if _, err := time.Parse("2006-01-02", date); err == nil {
+			cal.dates[date] = description
+		} else if _, err := time.Parse("01-02", date); err == nil {
+			cal.annually[date] = description
+		} else {
+			return nil, fmt.Errorf("calendar <%s>, line %d: invalid date <%s>", sCalendarFilePath, lineNum, date)
+		}
+	}
+
+	return cal, scanner.Err()
+}
+
+//IsHoliday reports whether the day of the given time is a holiday in this calendar.
+//The day is evaluated in the location of t, so pass times in the task timezone.
+//#########################################################
+func (c *Calendar) IsHoliday(t time.Time) (bool, string) {
+	if description, found := c.dates[t.Format("2006-01-02")]; found {
+		return true, description
+	}
+	if description, found := c.annually[t.Format("01-02")]; found {
+		return true, description
+	}
+	return false, ""
+}
+
+//LoadCalendars loads all calendar files of the configuration, keyed by calendar name
+//#########################################################
+func LoadCalendars(tConfigData *ConfigData) (map[string]*Calendar, error) {
+	calendars := make(map[string]*Calendar)
+
+	for sName, sPath := range tConfigData.Calendars {
+		cal, err := LoadCalendar(sName, sPath)
+		if err != nil {
+			return nil, err
+		}
+		calendars[sName] = cal
+	}
+
+	return calendars, nil
+}
+
+//CheckCalendars verifies that all calendar files can be loaded and that tasks only
+//reference known calendars. Returns one error per problem found.
+//#########################################################
+func CheckCalendars(tConfigData *ConfigData) (errs []error) {
+
+	for sName, sPath := range tConfigData.Calendars {
+		if _, err := LoadCalendar(sName, sPath); err != nil {
+			errs = append(errs, fmt.Errorf("calendar <%s>: %s", sName, err.Error()))
+		}
+	}
+	for _, task := range tConfigData.Tasks {
+		for _, sName := range task.SkipCalendars {
+			if _, found := tConfigData.Calendars[sName]; !found {
+				errs = append(errs, fmt.Errorf("task <%s>: unknown calendar <%s>", task.Name, sName))
+			}
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,50 @@
+package gpcconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadCalendar(t *testing.T) {
+	calendarPath := filepath.Join(t.TempDir(), "holidays.txt")
+	sCalendar := "# company holidays\n\n2024-12-24 Christmas Eve\n12-25\tChristmas Day\n  12-31   New  Year's Eve  \n01-01\n"
+	if err := os.WriteFile(calendarPath, []byte(sCalendar), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cal, err := LoadCalendar("holidays", calendarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		sDay         string
+		bHoliday     bool
+		sDescription string
+	}{
+		{"2024-12-24", true, "Christmas Eve"},
+		{"2025-12-24", false, ""},
+		{"2030-12-25", true, "Christmas Day"},
+		{"2024-12-31", true, "New  Year's Eve"},
+		{"2024-01-01", true, ""},
+		{"2024-12-23", false, ""},
+	}
+	for _, test := range tests {
+		day, _ := time.Parse("2006-01-02", test.sDay)
+		bHoliday, sDescription := cal.IsHoliday(day)
+		if bHoliday != test.bHoliday || sDescription != test.sDescription {
+			t.Errorf("%s: got <%t> <%s>, expected <%t> <%s>", test.sDay, bHoliday, sDescription, test.bHoliday, test.sDescription)
+		}
+	}
+}
+
+func TestLoadCalendarInvalidDate(t *testing.T) {
+	calendarPath := filepath.Join(t.TempDir(), "holidays.txt")
+	if err := os.WriteFile(calendarPath, []byte("2024-12-24\n24.12.\tChristmas Eve\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCalendar("holidays", calendarPath); err == nil {
+		t.Error("expected an error for the invalid date")
+	}
+}
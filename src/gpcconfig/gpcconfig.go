@@ -1,22 +1,189 @@
 package gpcconfig
 
 import (
+	"bytes"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// configuration file formats
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// actions when a task exceeds its MemoryLimitMB
+const (
+	MemoryActionRestart = "restart"
+	MemoryActionLog     = "log"
+	MemoryActionAlert   = "alert"
+)
+
+// CPU priority classes
+const (
+	PriorityIdle        = "idle"
+	PriorityBelowNormal = "below-normal"
+	PriorityNormal      = "normal"
+	PriorityHigh        = "high"
+)
+
+// overlap policies of scheduled tasks
+const (
+	OverlapSkip  = "skip"
+	OverlapQueue = "queue"
+	OverlapKill  = "kill"
+)
+
+// mutex group policies
+const (
+	MutexQueue = "queue" // wait until the group is free (default)
+	MutexSkip  = "skip"  // do not run at all if the group is busy
+)
+
+// standby modes
+const (
+	StandbyCold = "cold"
+	StandbyWarm = "warm"
+)
+
+// restart strategies of no-wait tasks
+const (
+	RestartStopFirst  = "stop-first"
+	RestartStartFirst = "start-first"
+)
+
+// what happens when a port of BindPorts is in use before a launch
+const (
+	PortConflictWait = "wait"
+	PortConflictFail = "fail"
+	PortConflictKill = "kill"
+)
+
+// Session value of the session attached to the physical console
+const SessionConsole = "console"
+
+// StopGracePeriod of tasks that do not set one
+const DefStopGracePeriod = 2 * time.Second
+
+// forced file format, empty => detected by the file extension
+var gFormat string
+
 //ProcessConfig is the in-memory representation of the configuration file part of process
 type ProcessConfig struct {
-	Name                string   // Name for the process to run
-	StartPath           string   // Exact path to executable
-	StartArgs           []string // Arguments passed to the executable
-	StartDelayS         uint32   // zero => no start delay
-	MaxRestarts         uint32   // zero => do not automatically restart
-	WaitForExitTimeoutS uint32   // zero => no waiting for application to end. If specified, the process will be terminated when it exeeds the timeout
-	HideWindow          bool     // true hides the window, false will show it
-	StopPath            string   // Exact path to executable
-	StopArgs            []string // Arguments passed to the executable
+	Name                    string         // Name for the process to run
+	Enabled                 *bool          `json:",omitempty"` // false => the task is left out, e.g. to keep its definition for later. Empty => enabled
+	Tags                    []string       // labels to select tasks with -only and -skip, e.g. "batch"
+	StartPath               string         // Exact path to executable
+	StartArgs               []string       // Arguments passed to the executable
+	Command                 string         // command line run by Shell instead of StartPath/StartArgs, e.g. "backup.sh | gzip > backup.gz"
+	Shell                   string         // shell of Command: "cmd" (default on Windows), "powershell", "pwsh", "sh" (default elsewhere) or "bash"
+	StartDelay              Duration       // zero => no start delay
+	MaxRestarts             uint32         // zero => do not automatically restart
+	CrashLoopRestarts       uint32         // more restarts than this within CrashLoopWindow quarantine the task until it is resumed, zero => no limit
+	CrashLoopWindow         Duration       // period CrashLoopRestarts are counted in, zero => 10 minutes
+	SuccessExitCodes        []int          // no-wait tasks only: exit codes of a deliberate end, the task is done and not restarted. Empty => every exit is a crash
+	NoRestartExitCodes      []int          // no-wait tasks only: exit codes of a failure a restart does not fix, e.g. a bad setting. The task is not restarted
+	WaitForExitTimeout      Duration       // zero => no waiting for application to end. If specified, the process will be terminated when it exeeds the timeout
+	MaxRuntime              Duration       // no-wait tasks only: the process tree is terminated when it runs longer and not restarted, flagged "timeout". Zero => no limit
+	MaxRuntimeIsError       bool           // exceeding MaxRuntime puts the task into the error state, standbys take over
+	HungTimeout             Duration       // no-wait tasks only: a running process without any output (or heartbeat) for this long is hung, its process tree is killed and restarted like after a crash. Zero => no check
+	ExpectHeartbeat         Duration       // no-wait tasks only: the process must send a heartbeat at least this often (UDP datagram with its name to GPC_HEARTBEAT_ADDR), it is killed and restarted like after a crash when they stop. Zero => none expected
+	HeartbeatFile           string         // ExpectHeartbeat only: touching this file counts as heartbeat too, "{name}" is replaced by the task name, passed as GPC_HEARTBEAT_FILE. Empty => none
+	NotifyReady             bool           // no-wait tasks only, Unix: the process gets a NOTIFY_SOCKET like under systemd and counts as ready (TaskReady, readiness waits) only once it sent READY=1
+	HideWindow              bool           // true hides the window, false will show it
+	SeparateStderr          bool           // stderr goes to a log file of its own (".err.log"), false => mixed into the output log
+	TimestampOutput         bool           // each line of the output is logged with the time it was written
+	TaskNameInOutput        bool           // with TimestampOutput: each line also gets the task name, e.g. "[Notepad]"
+	OutputMaxSizeMB         uint32         // the output log continues in a new file when it reaches this size, zero => no rotation
+	OutputMaxFiles          uint32         // output log files kept per task (of launches and rotations), zero => all
+	RecentOutputLines       uint32         // latest lines of stdout and stderr kept in memory for the status API, zero => not bounded by lines
+	RecentOutputKB          uint32         // size bound of the lines kept in memory, zero => not bounded by size. Both zero => none kept
+	StopPath                string         // Exact path to executable
+	StopArgs                []string       // Arguments passed to the executable
+	StopGracePeriod         Duration       // time to exit after the stop command or SIGTERM/WM_CLOSE before the process tree is killed, zero => 2s
+	StdinPipe               bool           // stdin of the process is a pipe of the controller, lines are sent with gpcctl input. False => no stdin
+	StopInput               []string       // StdinPipe only: lines written to stdin to stop the process gently (e.g. "stop"), before the stop command and SIGTERM/WM_CLOSE
+	DependsOn               []string       // tasks this task uses, at shutdown it is stopped before them
+	Preconditions           []Precondition // checked before each launch, the launch waits until all of them hold
+	PreconditionInterval    Duration       // how often unmet Preconditions (and BindPorts in use) are checked again, zero => 5s
+	Schedule                string         // cron expression "minute hour day month weekday", the task is started on schedule instead of at startup
+	OverlapPolicy           string         // scheduled tasks: "skip" (default), "queue" or "kill" a run that is still active when the next one is due
+	Timezone                string         // IANA zone name (e.g. "Europe/Berlin") for schedule times, empty => local time
+	SkipCalendars           []string       // names of holiday calendars on which scheduled starts are skipped
+	MutexGroup              string         // tasks sharing a mutex group never run at the same time, empty => no group
+	MutexPolicy             string         // "queue" (default) waits until the group is free, "skip" does not run the task
+	OnSuccess               []string       // wait tasks only: tasks started when this task ended with exit code 0
+	OnFailure               []string       // wait tasks only: tasks started when this task failed, timed out or could not start
+	Retries                 uint32         // wait tasks only: how often a failed run is retried, zero => no retry
+	RetryDelay              Duration       // wait tasks only: pause before a retry
+	RetryOn                 []int          // wait tasks only: exit codes that are retried, empty => any failure
+	ExpectedSHA256          string         // hex SHA256 of the executable, the task is not started if it differs. Empty => no check
+	MemoryLimitMB           uint32         // resident memory of the whole process tree, zero => no limit
+	MemoryLimitAction       string         // "restart" (default) kills the process tree, "log" only logs, "alert" logs an error
+	MaxMemoryRestartMB      uint32         // no-wait tasks only: restart gracefully when the resident memory of the process tree stays above, zero => never
+	MaxMemoryRestartSamples uint32         // consecutive resource samples above MaxMemoryRestartMB that trigger the restart, zero => 3
+	WatchPaths              []string       // no-wait tasks only: files or folders (recursively) whose changes restart the task gracefully, along with its executable. Empty => no watching
+	WatchDebounce           Duration       // quiet time after the last change of WatchPaths before the restart, zero => 1s
+	RestartEvery            string         // no-wait tasks only: restart gracefully after a duration ("24h", from the start) or on a cron expression ("0 3 * * *", in Timezone), empty => never
+	Priority                string         // CPU priority class: "idle", "below-normal", "normal" (default) or "high"
+	CPURatePercent          uint32         // cap of the CPU time of the process tree in percent of all CPUs, zero => no cap (Windows only)
+	MaxBandwidthKbps        uint32         // egress limit of the executable in kbit/s as QoS policy, zero => no limit (Windows only)
+	StandbyFor              string         // name of a primary task this task takes over from when the primary failed, empty => no standby
+	StandbyMode             string         // "cold" (default) is only started on takeover, "warm" runs all the time and is promoted on takeover
+	PromotePath             string         // warm standby only: command run on takeover, e.g. to make the standby accept work
+	PromoteArgs             []string       // Arguments passed to the promote command
+	PIDFile                 string         // PID file written while the process runs, "{name}" is replaced by the task name. Empty => none
+	BindPorts               []uint16       // TCP ports the process listens on, checked before each launch, see PortConflict
+	PortConflict            string         // a port of BindPorts in use: "wait" (default) until it is free, "fail" the start, or "kill" the process holding it
+	ListenSockets           []string       // sockets the controller listens on and passes to the process (socket activation), "tcp://host:port", "host:port" or "unix:///path". They stay open across restarts
+	ReadinessURL            string         // http(s):// URL answering below 400 or tcp://host:port accepting connections once the process is ready. Empty => ready once started
+	ReadinessTimeout        Duration       // how long a rolling or start-first restart waits for the new process to get ready, zero => 60s
+	RestartStrategy         string         // no-wait tasks only: "stop-first" (default) or "start-first", which starts the new process next to the old one and stops the old one once the new one is ready
+
+	// Account the process runs as
+	RunAsUser        string // "DOMAIN\user" or "user@domain" on Windows, user name on Unix. Empty => user of the controller
+	RunAsPassword    string // Windows only: password of RunAsUser, prefer RunAsPasswordEnv
+	RunAsPasswordEnv string // Windows only: environment variable of the controller holding the password of RunAsUser
+
+	// Replicas, see ExpandTask
+	Instances uint32 // number of processes started from this definition, zero => a single one without templates
+	BasePort  uint32 // {{.Port}} of the first instance, the following ones count up
+
+	// Environment variables that get a free port of Control.PortRange each, e.g. ["PORT"]. A process keeps its ports across restarts
+	AssignPorts []string
+	Template    string `json:"-"` // name of the definition with Instances this task was expanded from, empty if none
+
+	// Environment of the process
+	Env      map[string]string // extra environment variables, added to the inherited ones
+	Locale   string            // sets LANG and LC_ALL, e.g. "de_DE.UTF-8", empty => inherited
+	CodePage uint32            // Windows: console code page the process is started with, e.g. 437 or 65001, zero => inherited
+	GPUs     []int             // GPU indexes the process may use, sets CUDA_VISIBLE_DEVICES, empty => not restricted
+
+	// Main window of the process, Windows only. Lets operators tell identical console windows apart
+	WindowTitle    string // title of the window, empty => as set by the program
+	WindowIcon     string // path of an .ico file shown for the window, empty => icon of the program
+	WindowPosition []int  // left, top and optionally width, height of the window in pixels, empty => placed by Windows
+
+	// Interactive desktop the process is shown on, Windows and no-wait tasks only. Used for kiosks and signage
+	Desktop string // window station and desktop, e.g. "WinSta0\Default". Empty => desktop of the controller, "WinSta0\Default" with Session
+	Session string // "console" or a session ID, the process runs as the user logged on there (controller must run as LocalSystem)
+
+	// Taking over an instance that was started outside of the controller, no-wait tasks only
+	AdoptPIDFile    string // PID file of a running instance, it is monitored instead of starting the task
+	AdoptExecutable string // executable name (e.g. "nginx" or "nginx.exe") of a running instance to monitor instead
+
+	// Deprecated names of StartDelay and WaitForExitTimeout, still read from older files
+	StartDelayS         *Duration `json:",omitempty"`
+	WaitForExitTimeoutS *Duration `json:",omitempty"`
+
+	encryptedValues map[string]string // encrypted values as written, by setting path within the task, see EncryptedCopy
 }
 
 //ConfigData is the in-memory representation of the configuration file
@@ -25,51 +192,676 @@ type ConfigData struct {
 		LogsFolder      string // folder where to store logs
 		LogFileSizeMB   uint32 // Max file size for log file in MB
 		LogDebugEnabled bool   // Enables debug output
+		LogFormat       string // "text" (default) or "json" for one JSON object per line
+		CompressRotated bool   // gzip log files of the controller once they were rotated out
+		MaxTotalSizeMB  uint32 // quota of the whole log folder, the oldest files are deleted above it, zero => no quota
+	}
+	Control struct {
+		SocketPath       string // local control socket used by gpcctl (a named pipe on Windows), empty disables it
+		WatchConfig      bool   // reload the configuration automatically when the file changes
+		StateFile        string // restart counts, quarantines and PIDs are kept here, a restarted controller re-attaches to the processes still running. Empty => not kept
+		HeartbeatAddress string // UDP address the tasks with ExpectHeartbeat send their heartbeats to, e.g. "127.0.0.1:7070". Empty => a free port on 127.0.0.1
+		PortRange        string // ports handed out for AssignPorts, "first-last". Empty => 20000-29999
+	}
+	API     APIConfig     // HTTP API with dashboard, optionally with TLS and authentication
+	Secrets SecretsConfig // decryption of the "enc:" values of the configuration
+	Startup struct {
+		MaxConcurrentStarts uint32 // how many processes are launched at the same time, the others wait in a queue in the order they are due, zero => no limit
+	}
+	Shutdown struct {
+		TierTimeout  Duration // how long each tier of the DependsOn order may take to stop before what is left of it is killed, zero => no limit
+		ExitWhenDone bool     // the controller ends once all processes ended and none is restarted, e.g. as launcher of a fixed set of programs
+	}
+	Resources struct {
+		SampleInterval Duration // how often CPU, memory and handles of the processes are sampled, zero => 10s
+		LogInterval    Duration // how often the samples are written to the log, zero => never
+	}
+	Notifications struct {
+		Webhooks []WebhookConfig // endpoints that receive the lifecycle events
+		Emails   []EmailConfig   // SMTP accounts that mail the lifecycle events
+		Slack    []ChatConfig    // Slack channels that receive the lifecycle events
+		Teams    []ChatConfig    // Microsoft Teams channels that receive the lifecycle events
+	}
+	LogShipping LogShippingConfig          // forwarding of the output of the tasks to central logging
+	Calendars   map[string]string          // holiday calendars: name => path of the calendar file
+	Defaults    TaskDefaults               // settings all tasks inherit unless they set them themselves
+	Profiles    map[string]json.RawMessage // named overlays of this configuration selected with -profile, e.g. "prod"
+	Tasks       []ProcessConfig            // The actual processes that shall be started
+
+	taskKeys        []map[string]bool // settings each task has in the file, the others get the Defaults
+	encryptedValues map[string]string // encrypted values outside of the tasks as written, by setting path
+}
+
+//ReadConfigFromFile loads the configuration the controller starts with. The error names the
+//file, it is up to the caller whether to end the program.
+//#########################################################
+func ReadConfigFromFile(sConfigFilePath string) (tConfigData ConfigData, err error) {
+
+	tConfigData, err = LoadConfigFromFile(sConfigFilePath)
+	if err != nil {
+		return tConfigData, fmt.Errorf("Can't read configuration <%s>: %w", sConfigFilePath, err)
 	}
-	Tasks []ProcessConfig // The actual processes that shall be started
+
+	return tConfigData, nil
 }
 
-//ReadConfigFromFile loads a active configuration from a JSON
+//LoadConfigFromFile loads a configuration from a JSON file and returns an error
+//instead of ending the program, e.g. to reload the configuration at runtime.
+//The tasks get the Defaults, tasks with Instances are expanded into one task per instance.
+//Disabled tasks and the ones left out by SetTaskSelection are dropped, encrypted values are
+//decrypted.
 //#########################################################
-func ReadConfigFromFile(sConfigFilePath string) (tConfigData ConfigData) {
+func LoadConfigFromFile(sConfigFilePath string) (tConfigData ConfigData, err error) {
 
-	fConfigFile, err := os.Open(sConfigFilePath)
+	tConfigData, jsonBytes, err := loadConfigFile(sConfigFilePath)
 	if err != nil {
-		log.Fatal("Can't open config file: ", err)
+		return tConfigData, err
+	}
+	if len(gProfile) > 0 {
+		if tConfigData, err = applyProfile(sConfigFilePath, jsonBytes, tConfigData.Profiles); err != nil {
+			return tConfigData, err
+		}
+	}
+	// inherited settings may contradict the ones of a task
+	tConfigData.applyDefaults()
+	if err = tConfigData.Validate(); err != nil {
+		return tConfigData, fmt.Errorf("with the Defaults applied: %w", err)
+	}
+	if err = tConfigData.expandInstances(); err != nil {
+		return tConfigData, err
+	}
+	tConfigData.selectTasks()
+	// after the expansion, plain text secrets must not be taken as templates
+	return tConfigData, tConfigData.DecryptSecrets()
+}
+
+//LoadConfigFileAsWritten loads a configuration from a JSON file without applying the profile
+//and the Defaults and expanding the tasks with Instances, e.g. to change the file and save it again
+//#########################################################
+func LoadConfigFileAsWritten(sConfigFilePath string) (tConfigData ConfigData, err error) {
+
+	tConfigData, _, err = loadConfigFile(sConfigFilePath)
+	return tConfigData, err
+}
+
+// loadConfigFile is LoadConfigFileAsWritten, which also returns the file as JSON
+//------------------------------------------------------------------------------
+func loadConfigFile(sConfigFilePath string) (tConfigData ConfigData, jsonBytes []byte, err error) {
+
+	tConfigData, jsonBytes, err = readConfigDocument(sConfigFilePath)
+	var unknownErr *ValidationError
+	if err != nil && !errors.As(err, &unknownErr) {
+		return tConfigData, nil, err
+	}
+	tConfigData.taskKeys = taskKeysOf(jsonBytes)
+	// the unknown settings are reported together with the other problems
+	if err = joinValidationErrors(err, tConfigData.Validate()); err != nil {
+		return tConfigData, jsonBytes, err
+	}
+	if IsRemote(sConfigFilePath) {
+		// a failed write only matters once the remote is unreachable, which is reported then
+		storeRemoteCache(sConfigFilePath, jsonBytes)
+	}
+
+	return tConfigData, jsonBytes, nil
+}
+
+// readConfigDocument reads and decodes a configuration file or overlay file without validating
+// it, remote configurations are fetched. Returns the file as JSON as well, converted if it is YAML.
+//------------------------------------------------------------------------------
+func readConfigDocument(sConfigFilePath string) (tConfigData ConfigData, jsonBytes []byte, err error) {
+
+	var configBytes []byte
+	sFormat := fileFormat(sConfigFilePath)
+	if IsRemote(sConfigFilePath) {
+		configBytes, sFormat, err = readRemoteConfig(sConfigFilePath)
+		if err != nil {
+			return tConfigData, nil, err
+		}
+	} else if configBytes, err = os.ReadFile(sConfigFilePath); err != nil {
+		return tConfigData, nil, fmt.Errorf("Can't open config file: %s", err.Error())
+	}
+
+	jsonBytes = configBytes
+	if sFormat == FormatYAML {
+		jsonBytes, err = yamlToJSON(configBytes)
+		if err != nil {
+			return tConfigData, nil, fmt.Errorf("Can't decode config YAML: %s", err.Error())
+		}
 	}
-	// Close File when this function returns
-	defer fConfigFile.Close()
 
-	jsonDecoder := json.NewDecoder(fConfigFile)
 	tConfigData = ConfigData{}
+	err = decodeStrict(jsonBytes, configBytes, sFormat, &tConfigData)
+	var unknownErr *ValidationError
+	if err != nil && !errors.As(err, &unknownErr) {
+		return tConfigData, nil, fmt.Errorf("Can't decode config %s <%s>: %s", strings.ToUpper(sFormat), sConfigFilePath, err.Error())
+	}
+	tConfigData.migrateDeprecatedFields()
+	if err != nil {
+		return tConfigData, jsonBytes, fmt.Errorf("Can't decode config %s <%s>: %w", strings.ToUpper(sFormat), sConfigFilePath, err)
+	}
 
-	err = jsonDecoder.Decode(&tConfigData)
+	return tConfigData, jsonBytes, nil
+}
+
+//ParseTaskDefinition decodes a single task definition given as JSON object, e.g. to add a task at runtime.
+//With activeConfig the task gets its Defaults and the encrypted values are decrypted with its
+//Secrets, nil returns the task as written.
+//#########################################################
+func ParseTaskDefinition(taskBytes []byte, activeConfig *ConfigData) (tTask ProcessConfig, err error) {
+
+	tConfigData := ConfigData{Tasks: make([]ProcessConfig, 1)}
+	err = decodeStrict(taskBytes, taskBytes, FormatJSON, &tConfigData.Tasks[0])
 	if err != nil {
-		log.Fatal("Can't decode config JSON, using defaults: ", err)
+		return tTask, fmt.Errorf("Can't decode task JSON: %w", err)
+	}
+	tConfigData.migrateDeprecatedFields()
+	if activeConfig != nil {
+		activeConfig.Defaults.applyTo(&tConfigData.Tasks[0], keysOf(taskBytes))
 	}
+	if err = tConfigData.Validate(); err != nil || activeConfig == nil {
+		return tConfigData.Tasks[0], err
+	}
+	tConfigData.Secrets = activeConfig.Secrets
+	err = tConfigData.DecryptSecrets()
+	tTask = tConfigData.Tasks[0]
 
-	return tConfigData
+	return tTask, err
 }
 
-//WriteDefaultConfigFile writes a default configuration file to disk
+//SaveConfigToFile writes a configuration to disk, as YAML if the file name asks for it.
+//Comments of an existing YAML file are not kept.
+//#########################################################
+func SaveConfigToFile(sConfigFilePath string, tConfigData *ConfigData) error {
+	if IsRemote(sConfigFilePath) {
+		return fmt.Errorf("the remote configuration <%s> can not be changed here", sConfigFilePath)
+	}
+
+	var outBuffer bytes.Buffer
+	jsonEncoder := json.NewEncoder(&outBuffer)
+
+	// Encoding, YAML is converted from the JSON output
+	jsonEncoder.SetIndent("", "    ")
+	err := jsonEncoder.Encode(tConfigData)
+	if err != nil {
+		return fmt.Errorf("Can not encode configuration: %s", err.Error())
+	}
+	outBytes := outBuffer.Bytes()
+	if fileFormat(sConfigFilePath) == FormatYAML {
+		outBytes, err = jsonToYAML(outBytes)
+		if err != nil {
+			return fmt.Errorf("Can not encode configuration as YAML: %s", err.Error())
+		}
+	}
+
+	// Write a temporary file first, so a failing write does not leave a broken configuration
+	sTempFilePath := sConfigFilePath + ".tmp"
+	err = os.WriteFile(sTempFilePath, outBytes, 0644)
+	if err != nil {
+		return fmt.Errorf("Can not write configuration file: %s", err.Error())
+	}
+	return os.Rename(sTempFilePath, sConfigFilePath)
+}
+
+// migrateDeprecatedFields moves values of renamed settings to their new names
+//------------------------------------------------------------------------------
+func (c *ConfigData) migrateDeprecatedFields() {
+	for i := range c.Tasks {
+		task := &c.Tasks[i]
+		if task.StartDelayS != nil {
+			if task.StartDelay.Duration == 0 {
+				task.StartDelay = *task.StartDelayS
+			}
+			task.StartDelayS = nil
+		}
+		if task.WaitForExitTimeoutS != nil {
+			if task.WaitForExitTimeout.Duration == 0 {
+				task.WaitForExitTimeout = *task.WaitForExitTimeoutS
+			}
+			task.WaitForExitTimeoutS = nil
+		}
+	}
+}
+
+//SetFormat forces the format of configuration files to "json" or "yaml".
+//An empty format detects it by the file extension (.yaml/.yml => YAML, else JSON).
+//#########################################################
+func SetFormat(sFormat string) error {
+	switch strings.ToLower(sFormat) {
+	case "":
+		gFormat = ""
+	case FormatJSON:
+		gFormat = FormatJSON
+	case FormatYAML, "yml":
+		gFormat = FormatYAML
+	default:
+		return fmt.Errorf("unknown configuration format <%s>", sFormat)
+	}
+	return nil
+}
+
+// fileFormat returns the format to use for the given file
+//------------------------------------------------------------------------------
+func fileFormat(sConfigFilePath string) string {
+	if len(gFormat) > 0 {
+		return gFormat
+	}
+	sExt := filepath.Ext(sConfigFilePath)
+	if IsRemote(sConfigFilePath) {
+		sExt = remoteExt(sConfigFilePath)
+	}
+	switch strings.ToLower(sExt) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+	return FormatJSON
+}
+
+//CheckExecutables is a preflight check that verifies the start, stop and promote executables
+//of all tasks can be resolved and are executable. A broken stop or promote command would
+//otherwise only be noticed during shutdown or a takeover. Returns one error per problem found.
+//#########################################################
+func CheckExecutables(tConfigData *ConfigData) (errs []error) {
+
+	for _, task := range tConfigData.Tasks {
+		// Every command the controller may run for the task, only the start command is required
+		commands := []struct {
+			sRole string
+			sPath string
+		}{
+			{"start", task.Executable()},
+			{"stop", task.StopPath},
+			{"promote", task.PromotePath},
+		}
+		for i, command := range commands {
+			if i > 0 && len(command.sPath) == 0 {
+				continue
+			}
+			if _, err := exec.LookPath(command.sPath); err != nil {
+				errs = append(errs, fmt.Errorf("task <%s>: %s executable <%s> is not usable: %s", task.Name, command.sRole, command.sPath, err.Error()))
+			}
+		}
+	}
+
+	return errs
+}
+
+//CheckTimezones verifies that the configured timezone of each task is known.
+//Returns one error per problem found.
+//#########################################################
+func CheckTimezones(tConfigData *ConfigData) (errs []error) {
+
+	for _, task := range tConfigData.Tasks {
+		if _, err := task.Location(); err != nil {
+			errs = append(errs, fmt.Errorf("task <%s>: unknown timezone <%s>: %s", task.Name, task.Timezone, err.Error()))
+		}
+	}
+
+	return errs
+}
+
+//CheckFollowUps verifies the task chains: OnSuccess/OnFailure are only allowed on wait tasks
+//and must reference known tasks. Returns one error per problem found.
+//#########################################################
+func CheckFollowUps(tConfigData *ConfigData) (errs []error) {
+
+	taskNames := make(map[string]bool)
+	for _, task := range tConfigData.Tasks {
+		taskNames[task.Name] = true
+	}
+
+	for _, task := range tConfigData.Tasks {
+		followUps := append(append([]string{}, task.OnSuccess...), task.OnFailure...)
+		if len(followUps) > 0 && task.WaitForExitTimeout.Duration == 0 {
+			errs = append(errs, fmt.Errorf("task <%s>: OnSuccess/OnFailure require a WaitForExitTimeout", task.Name))
+		}
+		for _, sName := range followUps {
+			if !taskNames[sName] {
+				errs = append(errs, fmt.Errorf("task <%s>: unknown follow up task <%s>", task.Name, sName))
+			}
+		}
+	}
+
+	return errs
+}
+
+//CheckSchedules verifies the cron expressions and overlap policies of scheduled tasks.
+//Returns one error per problem found.
+//#########################################################
+func CheckSchedules(tConfigData *ConfigData) (errs []error) {
+
+	for _, task := range tConfigData.Tasks {
+		if len(task.Schedule) == 0 {
+			if len(task.OverlapPolicy) > 0 {
+				errs = append(errs, fmt.Errorf("task <%s>: OverlapPolicy requires a Schedule", task.Name))
+			}
+			continue
+		}
+		if _, err := ParseSchedule(task.Schedule); err != nil {
+			errs = append(errs, fmt.Errorf("task <%s>: %s", task.Name, err.Error()))
+		}
+		switch task.OverlapPolicy {
+		case "", OverlapSkip, OverlapQueue, OverlapKill:
+		default:
+			errs = append(errs, fmt.Errorf("task <%s>: unknown OverlapPolicy <%s>", task.Name, task.OverlapPolicy))
+		}
+	}
+
+	return errs
+}
+
+//CheckMemoryLimits verifies the configured memory limit actions. Returns one error per problem found.
+//#########################################################
+func CheckMemoryLimits(tConfigData *ConfigData) (errs []error) {
+
+	for _, task := range tConfigData.Tasks {
+		switch task.MemoryLimitAction {
+		case "", MemoryActionRestart, MemoryActionLog, MemoryActionAlert:
+		default:
+			errs = append(errs, fmt.Errorf("task <%s>: unknown MemoryLimitAction <%s>", task.Name, task.MemoryLimitAction))
+		}
+		if len(task.MemoryLimitAction) > 0 && task.MemoryLimitMB == 0 {
+			errs = append(errs, fmt.Errorf("task <%s>: MemoryLimitAction is set but MemoryLimitMB is not", task.Name))
+		}
+		if task.MaxMemoryRestartMB > 0 && task.WaitForExitTimeout.Duration > 0 {
+			errs = append(errs, fmt.Errorf("task <%s>: MaxMemoryRestartMB is only supported for no-wait tasks", task.Name))
+		}
+		if task.MaxMemoryRestartSamples > 0 && task.MaxMemoryRestartMB == 0 {
+			errs = append(errs, fmt.Errorf("task <%s>: MaxMemoryRestartSamples is set but MaxMemoryRestartMB is not", task.Name))
+		}
+	}
+
+	return errs
+}
+
+//CheckCPULimits verifies the configured priority classes and CPU rate caps.
+//Returns one error per problem found.
+//#########################################################
+func CheckCPULimits(tConfigData *ConfigData) (errs []error) {
+
+	for _, task := range tConfigData.Tasks {
+		switch task.Priority {
+		case "", PriorityIdle, PriorityBelowNormal, PriorityNormal, PriorityHigh:
+		default:
+			errs = append(errs, fmt.Errorf("task <%s>: unknown Priority <%s>", task.Name, task.Priority))
+		}
+		if task.CPURatePercent > 100 {
+			errs = append(errs, fmt.Errorf("task <%s>: CPURatePercent <%d> is above 100", task.Name, task.CPURatePercent))
+		}
+	}
+
+	return errs
+}
+
+//CheckGPUs verifies that no GPU is assigned to more than one task. Standbys may share
+//the GPUs of their primary. Returns one error per problem found.
+//#########################################################
+func CheckGPUs(tConfigData *ConfigData) (errs []error) {
+
+	gpuOwners := make(map[int]string)
+	for _, task := range tConfigData.Tasks {
+		owner := task.Name
+		if len(task.StandbyFor) > 0 {
+			owner = task.StandbyFor
+		}
+		for _, gpu := range task.GPUs {
+			if gpu < 0 {
+				errs = append(errs, fmt.Errorf("task <%s>: invalid GPU index <%d>", task.Name, gpu))
+				continue
+			}
+			if otherOwner, found := gpuOwners[gpu]; found && otherOwner != owner {
+				errs = append(errs, fmt.Errorf("task <%s>: GPU <%d> is also assigned to task <%s>", task.Name, gpu, otherOwner))
+				continue
+			}
+			gpuOwners[gpu] = owner
+		}
+	}
+
+	return errs
+}
+
+//CheckStandbys verifies that standby tasks reference a known primary and use a known mode.
+//Returns one error per problem found.
+//#########################################################
+func CheckStandbys(tConfigData *ConfigData) (errs []error) {
+
+	taskNames := make(map[string]bool)
+	for _, task := range tConfigData.Tasks {
+		taskNames[task.Name] = true
+	}
+
+	for _, task := range tConfigData.Tasks {
+		if len(task.StandbyFor) == 0 {
+			if len(task.StandbyMode) > 0 || len(task.PromotePath) > 0 {
+				errs = append(errs, fmt.Errorf("task <%s>: StandbyMode/PromotePath require StandbyFor", task.Name))
+			}
+			continue
+		}
+		if task.StandbyFor == task.Name || !taskNames[task.StandbyFor] {
+			errs = append(errs, fmt.Errorf("task <%s>: unknown primary task <%s>", task.Name, task.StandbyFor))
+		}
+		switch task.StandbyMode {
+		case "", StandbyCold:
+			if len(task.PromotePath) > 0 {
+				errs = append(errs, fmt.Errorf("task <%s>: PromotePath is only used by warm standbys", task.Name))
+			}
+		case StandbyWarm:
+		default:
+			errs = append(errs, fmt.Errorf("task <%s>: unknown StandbyMode <%s>", task.Name, task.StandbyMode))
+		}
+	}
+
+	return errs
+}
+
+//CheckWindows verifies the window settings of the tasks. Returns one error per problem found.
+//#########################################################
+func CheckWindows(tConfigData *ConfigData) (errs []error) {
+
+	for _, task := range tConfigData.Tasks {
+		hasSettings := len(task.WindowTitle) > 0 || len(task.WindowIcon) > 0 || len(task.WindowPosition) > 0
+		if hasSettings && task.HideWindow {
+			errs = append(errs, fmt.Errorf("task <%s>: window settings have no effect with HideWindow", task.Name))
+		}
+		switch len(task.WindowPosition) {
+		case 0, 2:
+		case 4:
+			if task.WindowPosition[2] <= 0 || task.WindowPosition[3] <= 0 {
+				errs = append(errs, fmt.Errorf("task <%s>: WindowPosition width and height must be positive", task.Name))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("task <%s>: WindowPosition needs left, top and optionally width, height", task.Name))
+		}
+		if len(task.WindowIcon) > 0 {
+			if _, err := os.Stat(task.WindowIcon); err != nil {
+				errs = append(errs, fmt.Errorf("task <%s>: WindowIcon: %s", task.Name, err.Error()))
+			}
+		}
+	}
+
+	return errs
+}
+
+//CheckDesktops verifies the Desktop and Session settings of the tasks. Returns one error per problem found.
+//#########################################################
+func CheckDesktops(tConfigData *ConfigData) (errs []error) {
+
+	for _, task := range tConfigData.Tasks {
+		if len(task.Desktop) == 0 && len(task.Session) == 0 {
+			continue
+		}
+		if task.WaitForExitTimeout.Duration > 0 {
+			errs = append(errs, fmt.Errorf("task <%s>: Desktop/Session can not be used with WaitForExitTimeout", task.Name))
+		}
+		if len(task.Session) == 0 {
+			continue
+		}
+		if _, err := strconv.ParseUint(task.Session, 10, 32); err != nil && !strings.EqualFold(task.Session, SessionConsole) {
+			errs = append(errs, fmt.Errorf("task <%s>: Session must be \"%s\" or a session ID, found <%s>", task.Name, SessionConsole, task.Session))
+		}
+		if len(task.RunAsUser) > 0 {
+			errs = append(errs, fmt.Errorf("task <%s>: Session runs the process as the user of the session, RunAsUser can not be used", task.Name))
+		}
+	}
+
+	return errs
+}
+
+//CheckAdoptions verifies that only no-wait tasks adopt running processes, a wait task needs
+//the exit code of a child of its own. Returns one error per problem found.
+//#########################################################
+func CheckAdoptions(tConfigData *ConfigData) (errs []error) {
+
+	for _, task := range tConfigData.Tasks {
+		if len(task.AdoptPIDFile) == 0 && len(task.AdoptExecutable) == 0 {
+			continue
+		}
+		if task.WaitForExitTimeout.Duration > 0 {
+			errs = append(errs, fmt.Errorf("task <%s>: AdoptPIDFile/AdoptExecutable can not be used with WaitForExitTimeout", task.Name))
+		}
+		if strings.ContainsAny(task.AdoptExecutable, "/\\") {
+			errs = append(errs, fmt.Errorf("task <%s>: AdoptExecutable <%s> must be a file name without directory", task.Name, task.AdoptExecutable))
+		}
+	}
+
+	return errs
+}
+
+//IsColdStandby reports whether a task is a cold standby, which is not started before its primary failed
 //#########################################################
-func WriteDefaultConfigFile(sConfigFilePath string) {
+func (p *ProcessConfig) IsColdStandby() bool {
+	return len(p.StandbyFor) > 0 && p.StandbyMode != StandbyWarm
+}
 
-	fOutFile, err := os.Create(sConfigFilePath)
+//IsFollowUpTask reports whether a task is started by another task via OnSuccess/OnFailure.
+//Such tasks are not started when the controller starts up.
+//#########################################################
+func (c *ConfigData) IsFollowUpTask(sName string) bool {
+	for _, task := range c.Tasks {
+		for _, sFollowUp := range append(append([]string{}, task.OnSuccess...), task.OnFailure...) {
+			if sFollowUp == sName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//ShouldRetry reports whether a failed run of a wait task with the given exit code is retried.
+//An exit code of -1 means the process could not be started at all.
+//#########################################################
+func (p *ProcessConfig) ShouldRetry(exitCode int) bool {
+	if len(p.RetryOn) == 0 {
+		return true
+	}
+	for _, retryCode := range p.RetryOn {
+		if retryCode == exitCode {
+			return true
+		}
+	}
+	return false
+}
+
+//IsSuccessExit reports whether a no-wait task ended deliberately, i.e. with one of its
+//SuccessExitCodes. An unknown exit code (-1) never is.
+//#########################################################
+func (p *ProcessConfig) IsSuccessExit(exitCode int) bool {
+	return exitCode >= 0 && containsExitCode(p.SuccessExitCodes, exitCode)
+}
+
+//IsNoRestartExit reports whether a no-wait task failed with one of its NoRestartExitCodes and
+//must not be restarted. An unknown exit code (-1) never is.
+//#########################################################
+func (p *ProcessConfig) IsNoRestartExit(exitCode int) bool {
+	return exitCode >= 0 && containsExitCode(p.NoRestartExitCodes, exitCode)
+}
+
+// containsExitCode reports whether an exit code is in a list
+//------------------------------------------------------------------------------
+func containsExitCode(exitCodes []int, exitCode int) bool {
+	for _, listedCode := range exitCodes {
+		if listedCode == exitCode {
+			return true
+		}
+	}
+	return false
+}
+
+//RunAsCredentials returns user name, domain and password for RunAsUser. The domain is
+//empty if the user is given without one or as "user@domain".
+//#########################################################
+func (p *ProcessConfig) RunAsCredentials() (sUser string, sDomain string, sPassword string, err error) {
+	sUser = p.RunAsUser
+	if sDomainPart, sUserPart, found := strings.Cut(p.RunAsUser, "\\"); found {
+		sDomain, sUser = sDomainPart, sUserPart
+	}
+
+	sPassword = p.RunAsPassword
+	if len(p.RunAsPasswordEnv) > 0 {
+		var found bool
+		sPassword, found = os.LookupEnv(p.RunAsPasswordEnv)
+		if !found {
+			return sUser, sDomain, "", fmt.Errorf("environment variable <%s> with the password of <%s> is not set", p.RunAsPasswordEnv, p.RunAsUser)
+		}
+	}
+	return sUser, sDomain, sPassword, nil
+}
+
+//PIDFilePath returns the path of the PID file of the task, empty if it has none
+//#########################################################
+func (p *ProcessConfig) PIDFilePath() string {
+	return strings.ReplaceAll(p.PIDFile, "{name}", p.Name)
+}
+
+//Location returns the timezone in which the schedule times of the task are interpreted
+//#########################################################
+func (p *ProcessConfig) Location() (*time.Location, error) {
+	if len(p.Timezone) == 0 {
+		return time.Local, nil
+	}
+	return time.LoadLocation(p.Timezone)
+}
+
+//RestartPeriod returns when RestartEvery restarts the task: after a duration of running or on
+//a cron schedule. Both are empty if the task has no RestartEvery.
+//#########################################################
+func (p *ProcessConfig) RestartPeriod() (time.Duration, *Schedule, error) {
+	if len(strings.TrimSpace(p.RestartEvery)) == 0 {
+		return 0, nil, nil
+	}
+	if every, err := ParseDuration(p.RestartEvery); err == nil {
+		if every <= 0 {
+			return 0, nil, fmt.Errorf("RestartEvery <%s> must be longer than zero", p.RestartEvery)
+		}
+		return every, nil, nil
+	}
+	schedule, err := ParseSchedule(p.RestartEvery)
 	if err != nil {
-		log.Fatal("Can not create new default configuration file.", err)
-		return
+		return 0, nil, fmt.Errorf("RestartEvery <%s> is neither a duration nor a cron expression: %s", p.RestartEvery, err.Error())
 	}
-	// Close file when this function ends
-	defer fOutFile.Close()
+	return 0, schedule, nil
+}
+
+//GracePeriod returns the StopGracePeriod of the task
+//#########################################################
+func (p *ProcessConfig) GracePeriod() time.Duration {
+	if p.StopGracePeriod.Duration == 0 {
+		return DefStopGracePeriod
+	}
+	return p.StopGracePeriod.Duration
+}
+
+//WriteDefaultConfigFile writes a default configuration file to disk
+//#########################################################
+func WriteDefaultConfigFile(sConfigFilePath string) error {
 
-	jsonEncoder := json.NewEncoder(fOutFile)
 	tDefaultConf := ConfigData{}
 
 	// Setting default values
 	tDefaultConf.Logging.LogsFolder = "./logs"
 	tDefaultConf.Logging.LogFileSizeMB = 20
 	tDefaultConf.Logging.LogDebugEnabled = true
+	tDefaultConf.Control.SocketPath = "./gpc.sock"
 
 	p1 := ProcessConfig{}
 	p2 := ProcessConfig{}
@@ -77,33 +869,26 @@ func WriteDefaultConfigFile(sConfigFilePath string) {
 	p1.Name = "Notepad"
 	p1.StartPath = "notepad.exe"
 	p1.StartArgs = []string{"notepad.exe", "myfile.txt"}
-	p1.StartDelayS = 0
+	p1.StartDelay = Seconds(0)
 	p1.MaxRestarts = 3
-	p1.WaitForExitTimeoutS = 0
+	p1.WaitForExitTimeout = Seconds(0)
 	p1.HideWindow = false
 	p1.StopPath = ""
-	p1.StopArgs = []string{"", ""}
 
 	p2.Name = "Paint"
 	p2.StartPath = "mspaint.exe"
 	p2.StartArgs = []string{"mspaint.exe", ""}
-	p2.StartDelayS = 5
+	p2.StartDelay = Seconds(5)
 	p2.MaxRestarts = 0
-	p2.WaitForExitTimeoutS = 0
+	p2.WaitForExitTimeout = Seconds(0)
 	p2.HideWindow = true
 	p2.StopPath = ""
-	p2.StopArgs = []string{"", ""}
+	p2.Timezone = "UTC"
 
 	tDefaultConf.Tasks = make([]ProcessConfig, 0)
 	tDefaultConf.Tasks = append(tDefaultConf.Tasks, p1)
 	tDefaultConf.Tasks = append(tDefaultConf.Tasks, p2)
 
 	// Writing file
-	jsonEncoder.SetIndent("", "    ")
-	encodeErr := jsonEncoder.Encode(&tDefaultConf)
-	if encodeErr != nil {
-		log.Fatal("Can not write to new default configuration file.", encodeErr)
-		return
-	}
-
+	return SaveConfigToFile(sConfigFilePath, &tDefaultConf)
 }
@@ -0,0 +1,186 @@
+package gpcconfig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression with the five classic fields
+// "minute hour day-of-month month day-of-week"
+type Schedule struct {
+	minute     uint64 // bit n set => minute n matches
+	hour       uint64
+	dayOfMonth uint64
+	month      uint64
+	dayOfWeek  uint64 // 0 = Sunday
+	domAny     bool   // day of month was "*"
+	dowAny     bool   // day of week was "*"
+}
+
+// shortcuts for common schedules
+var scheduleMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+var weekdayNames = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+// Upper bound of the steps of Schedule.Next. It is called from the monitor loop for RestartEvery,
+// so it must return soon whatever the schedule and time zone. Five years take about 2000 steps.
+const maxScheduleSteps = 100000
+
+//ParseSchedule parses a cron expression. Fields may hold "*", values, names (jan, mon),
+//ranges ("1-5"), steps ("*/15", "8-18/2") and lists of those ("0,30"). Day of week 7 is
+//Sunday as well. As in cron, a day matches if day of month OR day of week matches when
+//both are restricted. The shortcuts @yearly, @monthly, @weekly, @daily and @hourly are known.
+//#########################################################
+func ParseSchedule(sExpression string) (*Schedule, error) {
+	sExpression = strings.TrimSpace(sExpression)
+	if sMacro, found := scheduleMacros[strings.ToLower(sExpression)]; found {
+		sExpression = sMacro
+	}
+
+	fields := strings.Fields(sExpression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule <%s>: expected 5 fields, found %d", sExpression, len(fields))
+	}
+
+	var s Schedule
+	var err error
+	if s.minute, _, err = parseScheduleField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("schedule <%s>, minute: %s", sExpression, err.Error())
+	}
+	if s.hour, _, err = parseScheduleField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("schedule <%s>, hour: %s", sExpression, err.Error())
+	}
+	if s.dayOfMonth, s.domAny, err = parseScheduleField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("schedule <%s>, day of month: %s", sExpression, err.Error())
+	}
+	if s.month, _, err = parseScheduleField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("schedule <%s>, month: %s", sExpression, err.Error())
+	}
+	if s.dayOfWeek, s.dowAny, err = parseScheduleField(fields[4], 0, 7, weekdayNames); err != nil {
+		return nil, fmt.Errorf("schedule <%s>, day of week: %s", sExpression, err.Error())
+	}
+	// 7 is another name for Sunday
+	if s.dayOfWeek&(1<<7) != 0 {
+		s.dayOfWeek |= 1
+	}
+
+	return &s, nil
+}
+
+// parseScheduleField parses one field of a cron expression into a bit set. Reports
+// whether the field was "*".
+//------------------------------------------------------------------------------
+func parseScheduleField(sField string, minValue int, maxValue int, names map[string]int) (uint64, bool, error) {
+	var bits uint64
+
+	for _, sPart := range strings.Split(sField, ",") {
+		sRange, sStep, hasStep := strings.Cut(sPart, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(sStep)
+			if err != nil || step <= 0 {
+				return 0, false, fmt.Errorf("invalid step <%s>", sStep)
+			}
+		}
+
+		first, last := minValue, maxValue
+		if sRange != "*" {
+			sFirst, sLast, isRange := strings.Cut(sRange, "-")
+			var err error
+			if first, err = parseScheduleValue(sFirst, minValue, maxValue, names); err != nil {
+				return 0, false, err
+			}
+			switch {
+			case isRange:
+				if last, err = parseScheduleValue(sLast, minValue, maxValue, names); err != nil {
+					return 0, false, err
+				}
+			case !hasStep:
+				last = first
+			}
+			if first > last {
+				return 0, false, fmt.Errorf("invalid range <%s>", sRange)
+			}
+		}
+
+		for value := first; value <= last; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+
+	return bits, sField == "*", nil
+}
+
+// parseScheduleValue parses a number or name of a cron field
+//------------------------------------------------------------------------------
+func parseScheduleValue(sValue string, minValue int, maxValue int, names map[string]int) (int, error) {
+	if value, found := names[strings.ToLower(sValue)]; found {
+		return value, nil
+	}
+	value, err := strconv.Atoi(sValue)
+	if err != nil || value < minValue || value > maxValue {
+		return 0, fmt.Errorf("invalid value <%s>, expected %d-%d", sValue, minValue, maxValue)
+	}
+	return value, nil
+}
+
+//Next returns the first time after t that matches the schedule, in the location of t.
+//Returns the zero time if there is none within the next five years (e.g. "0 0 31 2 *")
+//or it takes more than maxScheduleSteps steps to find.
+//Hours and minutes are stepped in absolute time, so the hour repeated when daylight saving
+//time ends is matched twice and the hour skipped when it starts is not matched at all.
+//#########################################################
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	// Start at the next full minute
+	t = t.Add(-time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond())).Add(time.Minute)
+	yearLimit := t.Year() + 5
+
+	for step := 0; t.Year() <= yearLimit && step < maxScheduleSteps; step++ {
+		var next time.Time
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			next = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			next = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			// Not with time.Date, the wall clock of an hour repeated at the end of daylight
+			// saving time would lead back to its first occurrence
+			next = t.Add(time.Hour - time.Duration(t.Minute())*time.Minute)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			next = t.Add(time.Minute)
+		default:
+			return t
+		}
+		if !next.After(t) {
+			// Midnight may not exist on a day daylight saving time starts, never go back
+			next = t.Add(time.Minute)
+		}
+		t = next
+	}
+	return time.Time{}
+}
+
+// dayMatches applies the cron rule for day of month and day of week
+//------------------------------------------------------------------------------
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dayOfMonth&(1<<uint(t.Day())) != 0
+	dowMatch := s.dayOfWeek&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,112 @@
+package gpcconfig
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		sExpression string
+		bValid      bool
+	}{
+		{"* * * * *", true},
+		{"0 5 * * *", true},
+		{"*/15 8-18/2 1,15 jan-mar mon-fri", true},
+		{"0 0 * * 7", true},
+		{"@daily", true},
+		{"@HOURLY", true},
+		{"0 0 31 2 *", true},
+		{"", false},
+		{"* * * *", false},
+		{"* * * * * *", false},
+		{"60 * * * *", false},
+		{"* 24 * * *", false},
+		{"* * 0 * *", false},
+		{"* * * 13 *", false},
+		{"* * * * 8", false},
+		{"*/0 * * * *", false},
+		{"5-1 * * * *", false},
+		{"* * * foo *", false},
+		{"@weekday", false},
+	}
+	for _, test := range tests {
+		_, err := ParseSchedule(test.sExpression)
+		if (err == nil) != test.bValid {
+			t.Errorf("ParseSchedule(%q): error <%v>, expected valid=%t", test.sExpression, err, test.bValid)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data not available: %s", err.Error())
+	}
+	edt := time.FixedZone("EDT", -4*3600)
+	est := time.FixedZone("EST", -5*3600)
+
+	tests := []struct {
+		sExpression string
+		from        time.Time
+		expected    time.Time // zero if never
+	}{
+		{"* * * * *", time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC), time.Date(2025, 6, 1, 10, 1, 0, 0, time.UTC)},
+		{"* * * * *", time.Date(2025, 6, 1, 10, 0, 59, 999, time.UTC), time.Date(2025, 6, 1, 10, 1, 0, 0, time.UTC)},
+		{"30 9 * * *", time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC), time.Date(2025, 6, 2, 9, 30, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC), time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Time{}},
+		// 2025-06-01 is a Sunday, 7 is Sunday as well
+		{"0 12 * * 7", time.Date(2025, 5, 30, 0, 0, 0, 0, time.UTC), time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 12 * * 0", time.Date(2025, 5, 30, 0, 0, 0, 0, time.UTC), time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)},
+		// Day of month OR day of week when both are restricted
+		{"0 0 15 * mon", time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC), time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC)},
+		{"0 0 5 * mon", time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC), time.Date(2025, 6, 5, 0, 0, 0, 0, time.UTC)},
+		// Half-hour offset
+		{"0 * * * *", time.Date(2025, 6, 1, 10, 45, 0, 0, time.FixedZone("IST", 19800)), time.Date(2025, 6, 1, 11, 0, 0, 0, time.FixedZone("IST", 19800))},
+		// Daylight saving time ends on 2025-11-02 at 02:00 EDT, 01:00-01:59 exists twice
+		{"0 5 * * *", time.Date(2025, 11, 2, 0, 30, 0, 0, newYork), time.Date(2025, 11, 2, 5, 0, 0, 0, est)},
+		{"*/15 * * * *", time.Date(2025, 11, 2, 1, 50, 0, 0, est).In(newYork), time.Date(2025, 11, 2, 2, 0, 0, 0, est)},
+		{"*/15 * * * *", time.Date(2025, 11, 2, 1, 50, 0, 0, edt).In(newYork), time.Date(2025, 11, 2, 1, 0, 0, 0, est)},
+		{"30 1 * * *", time.Date(2025, 11, 2, 1, 40, 0, 0, edt).In(newYork), time.Date(2025, 11, 2, 1, 30, 0, 0, est)},
+		{"0 3 * * *", time.Date(2025, 11, 1, 23, 0, 0, 0, newYork), time.Date(2025, 11, 2, 3, 0, 0, 0, est)},
+		// Daylight saving time starts on 2025-03-09 at 02:00 EST, 02:00-02:59 does not exist
+		{"30 2 * * *", time.Date(2025, 3, 9, 0, 0, 0, 0, newYork), time.Date(2025, 3, 10, 2, 30, 0, 0, edt)},
+		{"0 * * * *", time.Date(2025, 3, 9, 1, 30, 0, 0, newYork), time.Date(2025, 3, 9, 3, 0, 0, 0, edt)},
+		{"0 5 * * *", time.Date(2025, 3, 8, 23, 0, 0, 0, newYork), time.Date(2025, 3, 9, 5, 0, 0, 0, edt)},
+	}
+	for _, test := range tests {
+		schedule, err := ParseSchedule(test.sExpression)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q): %s", test.sExpression, err.Error())
+		}
+		next := schedule.Next(test.from)
+		if !next.Equal(test.expected) {
+			t.Errorf("%q.Next(%s) = %s, expected %s", test.sExpression, test.from, next, test.expected)
+		}
+		if !next.IsZero() && !next.After(test.from) {
+			t.Errorf("%q.Next(%s) = %s is not after the start", test.sExpression, test.from, next)
+		}
+	}
+}
+
+func TestScheduleNextIncreases(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data not available: %s", err.Error())
+	}
+	schedule, err := ParseSchedule("*/15 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Through both transitions of 2025, every quarter hour of absolute time
+	from := time.Date(2025, 3, 8, 0, 0, 0, 0, newYork)
+	for last := from; last.Before(time.Date(2025, 11, 3, 0, 0, 0, 0, newYork)); {
+		next := schedule.Next(last)
+		if next.Sub(last) != 15*time.Minute {
+			t.Fatalf("Next(%s) = %s, expected 15 minutes later", last, next)
+		}
+		last = next
+	}
+}
@@ -0,0 +1,133 @@
+package gpcconfig
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+//TaskDefaults are the settings of the Defaults section. Each task inherits the ones it does not
+//set itself, a task that sets a value (even false or zero) keeps it. Env is merged, variables
+//of the task win. The restart settings only go to tasks that keep running, the retry settings
+//only to tasks with WaitForExitTimeout.
+type TaskDefaults struct {
+	// Restart policy of no-wait tasks
+	MaxRestarts        uint32
+	CrashLoopRestarts  uint32
+	CrashLoopWindow    Duration
+	SuccessExitCodes   []int
+	NoRestartExitCodes []int
+
+	// Retry policy of wait tasks
+	Retries    uint32
+	RetryDelay Duration
+	RetryOn    []int
+
+	// Output logs
+	SeparateStderr    bool
+	TimestampOutput   bool
+	TaskNameInOutput  bool
+	OutputMaxSizeMB   uint32
+	OutputMaxFiles    uint32
+	RecentOutputLines uint32
+	RecentOutputKB    uint32
+
+	// Environment and window
+	Env        map[string]string
+	Locale     string
+	HideWindow bool
+
+	StopGracePeriod Duration
+}
+
+// fields of TaskDefaults that only apply to no-wait respectively wait tasks
+var (
+	restartDefaults = map[string]bool{"MaxRestarts": true, "CrashLoopRestarts": true, "CrashLoopWindow": true,
+		"SuccessExitCodes": true, "NoRestartExitCodes": true}
+	retryDefaults = map[string]bool{"Retries": true, "RetryDelay": true, "RetryOn": true}
+)
+
+// validate returns the problems of the Defaults section
+//------------------------------------------------------------------------------
+func (d *TaskDefaults) validate() (problems []string) {
+	if d.CrashLoopWindow.Duration > 0 && d.CrashLoopRestarts == 0 {
+		problems = append(problems, "CrashLoopWindow requires CrashLoopRestarts")
+	}
+	if d.TaskNameInOutput && !d.TimestampOutput {
+		problems = append(problems, "TaskNameInOutput requires TimestampOutput")
+	}
+	return problems
+}
+
+// applyTo sets the defaults in a task, except for the settings in taskKeys. The keys are the
+// ones of the task's JSON object in lower case, JSON names are not case sensitive.
+//------------------------------------------------------------------------------
+func (d *TaskDefaults) applyTo(task *ProcessConfig, taskKeys map[string]bool) {
+	bWaitTask := task.WaitForExitTimeout.Duration > 0
+	defaultsValue := reflect.ValueOf(d).Elem()
+	taskValue := reflect.ValueOf(task).Elem()
+
+	for fieldIndex := 0; fieldIndex < defaultsValue.NumField(); fieldIndex++ {
+		sField := defaultsValue.Type().Field(fieldIndex).Name
+		defaultValue := defaultsValue.Field(fieldIndex)
+		if defaultValue.IsZero() || (restartDefaults[sField] && bWaitTask) || (retryDefaults[sField] && !bWaitTask) {
+			continue
+		}
+		if sField == "Env" {
+			env := make(map[string]string, len(d.Env)+len(task.Env))
+			for sName, sValue := range d.Env {
+				env[sName] = sValue
+			}
+			for sName, sValue := range task.Env {
+				env[sName] = sValue
+			}
+			task.Env = env
+			continue
+		}
+		if !taskKeys[strings.ToLower(sField)] {
+			taskValue.FieldByName(sField).Set(defaultValue)
+		}
+	}
+}
+
+// applyDefaults applies the Defaults section to all tasks, using the settings each task has in
+// the file as recorded by LoadConfigFileAsWritten
+//------------------------------------------------------------------------------
+func (c *ConfigData) applyDefaults() {
+	for taskIndex := range c.Tasks {
+		var taskKeys map[string]bool
+		if taskIndex < len(c.taskKeys) {
+			taskKeys = c.taskKeys[taskIndex]
+		}
+		c.Defaults.applyTo(&c.Tasks[taskIndex], taskKeys)
+	}
+}
+
+// keysOf returns the keys of a JSON object in lower case, nil if it is none
+//------------------------------------------------------------------------------
+func keysOf(objectBytes []byte) map[string]bool {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(objectBytes, &object); err != nil {
+		return nil
+	}
+	keys := make(map[string]bool, len(object))
+	for sKey := range object {
+		keys[strings.ToLower(sKey)] = true
+	}
+	return keys
+}
+
+// taskKeysOf returns the keys of each task of a configuration document, see keysOf
+//------------------------------------------------------------------------------
+func taskKeysOf(jsonBytes []byte) (taskKeys []map[string]bool) {
+	var document struct {
+		Tasks []json.RawMessage
+	}
+	if err := json.Unmarshal(jsonBytes, &document); err != nil {
+		return nil
+	}
+	for _, taskBytes := range document.Tasks {
+		taskKeys = append(taskKeys, keysOf(taskBytes))
+	}
+	return taskKeys
+}
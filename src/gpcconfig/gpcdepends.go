@@ -0,0 +1,68 @@
+package gpcconfig
+
+import (
+	"fmt"
+	"sort"
+)
+
+//CheckDependencies verifies that DependsOn references known tasks and that the dependencies
+//have no cycle, as a cycle leaves no order to stop the tasks in. Returns one error per problem found.
+//#########################################################
+func CheckDependencies(tConfigData *ConfigData) (errs []error) {
+
+	dependsOn := make(map[string][]string)
+	for _, task := range tConfigData.Tasks {
+		dependsOn[task.Name] = nil
+	}
+	for _, task := range tConfigData.Tasks {
+		for _, sName := range task.DependsOn {
+			if _, found := dependsOn[sName]; !found || sName == task.Name {
+				errs = append(errs, fmt.Errorf("task <%s>: unknown dependency <%s>", task.Name, sName))
+				continue
+			}
+			dependsOn[task.Name] = append(dependsOn[task.Name], sName)
+		}
+	}
+
+	// depth first search, a task met again while its dependencies are searched closes a cycle
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := make(map[string]int)
+	var path []string
+	var visit func(sName string)
+	visit = func(sName string) {
+		switch states[sName] {
+		case visiting:
+			for pathIndex := range path {
+				if path[pathIndex] == sName {
+					errs = append(errs, fmt.Errorf("task <%s>: dependency cycle %v", sName, append(path[pathIndex:], sName)))
+					break
+				}
+			}
+			return
+		case visited:
+			return
+		}
+		states[sName] = visiting
+		path = append(path, sName)
+		for _, sDependency := range dependsOn[sName] {
+			visit(sDependency)
+		}
+		path = path[:len(path)-1]
+		states[sName] = visited
+	}
+
+	taskNames := make([]string, 0, len(dependsOn))
+	for sName := range dependsOn {
+		taskNames = append(taskNames, sName)
+	}
+	sort.Strings(taskNames)
+	for _, sName := range taskNames {
+		visit(sName)
+	}
+
+	return errs
+}
@@ -0,0 +1,76 @@
+package gpcconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a time span in the configuration file, written as a duration string
+// like "500ms", "90s" or "2m30s". For compatibility with older configuration files
+// a bare integer (or a string holding one) is read as a number of seconds.
+type Duration struct {
+	time.Duration
+}
+
+// Seconds creates a Duration of full seconds
+func Seconds(s uint32) Duration {
+	return Duration{time.Duration(s) * time.Second}
+}
+
+//MarshalJSON writes the duration as a string
+//#########################################################
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+//UnmarshalJSON reads a duration string or a number of seconds
+//#########################################################
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case nil:
+		d.Duration = 0
+	case float64:
+		if v < 0 {
+			return fmt.Errorf("negative duration <%v>", v)
+		}
+		d.Duration = time.Duration(v * float64(time.Second))
+	case string:
+		parsed, err := ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		d.Duration = parsed
+	default:
+		return fmt.Errorf("invalid duration <%s>", string(data))
+	}
+	return nil
+}
+
+//ParseDuration parses a duration string, bare numbers are seconds
+//#########################################################
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration <%s>, use e.g. \"500ms\" or \"2m30s\"", s)
+	}
+	if parsed < 0 {
+		return 0, fmt.Errorf("negative duration <%s>", s)
+	}
+	return parsed, nil
+}
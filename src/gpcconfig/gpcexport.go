@@ -0,0 +1,101 @@
+package gpcconfig
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+)
+
+// formats of ExportTasks
+const (
+	ExportSystemd       = "systemd"
+	ExportTaskScheduler = "taskscheduler"
+)
+
+// settings that describe the task for the controller only or go to its output logs, which
+// the other service managers keep on their own
+var exportIgnored = map[string]bool{
+	"Name": true, "Enabled": true, "Tags": true, "Template": true, "Instances": true, "BasePort": true,
+	"SeparateStderr": true, "TimestampOutput": true, "TaskNameInOutput": true, "OutputMaxSizeMB": true,
+	"OutputMaxFiles": true, "RecentOutputLines": true, "RecentOutputKB": true,
+	"StartDelayS": true, "WaitForExitTimeoutS": true,
+}
+
+// characters that are replaced in unit and task names
+var exportNameRe = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+//ExportTasks renders the tasks of a loaded configuration for another service manager:
+//ExportSystemd writes a service unit per task and a timer for scheduled and delayed tasks,
+//ExportTaskScheduler an XML definition per task for "schtasks /create /xml". sWorkDir is the
+//folder the tasks run in, the one of the controller. Returns the content by file name and a
+//note for each setting the format has no equivalent for.
+//#########################################################
+func ExportTasks(tConfigData *ConfigData, sFormat string, sWorkDir string) (files map[string][]byte, notes []string, err error) {
+	files = make(map[string][]byte)
+	for taskIndex := range tConfigData.Tasks {
+		task := &tConfigData.Tasks[taskIndex]
+		var taskNotes []string
+		switch sFormat {
+		case ExportSystemd:
+			taskNotes, err = exportSystemd(tConfigData, task, sWorkDir, files)
+		case ExportTaskScheduler:
+			taskNotes, err = exportTaskScheduler(tConfigData, task, sWorkDir, files)
+		default:
+			return nil, nil, fmt.Errorf("unknown export format <%s>, expected %s or %s", sFormat, ExportSystemd, ExportTaskScheduler)
+		}
+		if err != nil {
+			return nil, notes, fmt.Errorf("task <%s>: %s", task.Name, err.Error())
+		}
+		for _, sNote := range taskNotes {
+			notes = append(notes, fmt.Sprintf("task <%s>: %s", task.Name, sNote))
+		}
+	}
+	return files, notes, nil
+}
+
+// exportName returns the name of a task as the file name of an exported unit, prefixed so
+// tasks do not clash with the units of the system, e.g. "nginx" => "gpc-nginx"
+//------------------------------------------------------------------------------
+func exportName(sTaskName string) string {
+	return "gpc-" + exportNameRe.ReplaceAllString(sTaskName, "_")
+}
+
+// unexportedSettings returns a note for each setting of a task that is set and neither in
+// handled nor ignored by all formats
+//------------------------------------------------------------------------------
+func unexportedSettings(task *ProcessConfig, handled map[string]bool) (notes []string) {
+	taskValue := reflect.ValueOf(task).Elem()
+	for fieldIndex := 0; fieldIndex < taskValue.NumField(); fieldIndex++ {
+		sField := taskValue.Type().Field(fieldIndex).Name
+		if handled[sField] || exportIgnored[sField] || taskValue.Field(fieldIndex).IsZero() {
+			continue
+		}
+		notes = append(notes, fmt.Sprintf("%s is not exported", sField))
+	}
+	return notes
+}
+
+// exportCommand returns executable and arguments of a task on the platform of a format, the
+// shell of a Command defaults to the one of that platform
+//------------------------------------------------------------------------------
+func exportCommand(task *ProcessConfig, sDefaultShell string) (string, []string) {
+	exportTask := *task
+	if exportTask.IsShellCommand() && len(exportTask.Shell) == 0 {
+		exportTask.Shell = sDefaultShell
+	}
+	if exportTask.IsShellCommand() && exportTask.Shell == ShellCmd {
+		return "cmd.exe", exportTask.Arguments()
+	}
+	return exportTask.Executable(), exportTask.Arguments()
+}
+
+// scheduleValues returns the values in the bit set of a cron field, in order
+//------------------------------------------------------------------------------
+func scheduleValues(bits uint64, minValue int, maxValue int) (values []int) {
+	for value := minValue; value <= maxValue; value++ {
+		if bits&(1<<uint(value)) != 0 {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,27 @@
+package gpcconfig
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+//HeartbeatFilePath returns the path of the heartbeat file of the task, empty if it has none
+//#########################################################
+func (p *ProcessConfig) HeartbeatFilePath() string {
+	return strings.ReplaceAll(p.HeartbeatFile, "{name}", p.Name)
+}
+
+//CheckHeartbeats verifies the UDP address the processes send their heartbeats to. Returns one
+//error per problem found.
+//#########################################################
+func CheckHeartbeats(tConfigData *ConfigData) (errs []error) {
+	sAddress := tConfigData.Control.HeartbeatAddress
+	if len(sAddress) == 0 {
+		return nil
+	}
+	if _, err := net.ResolveUDPAddr("udp", sAddress); err != nil {
+		errs = append(errs, fmt.Errorf("Control: HeartbeatAddress <%s> is not host:port: %s", sAddress, err.Error()))
+	}
+	return errs
+}
@@ -0,0 +1,373 @@
+package gpcconfig
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxRestarts of imported tasks the original tool restarts without limit
+const importMaxRestarts = 100
+
+//ImportConfig converts the configuration of another process manager into one of the controller:
+//a supervisord INI file (.conf, .ini) or a PM2 ecosystem file (.json, .js). Returns notes on
+//settings that were changed or could not be converted, the result should be reviewed.
+//#########################################################
+func ImportConfig(sPath string) (tConfigData ConfigData, notes []string, err error) {
+	sourceBytes, err := os.ReadFile(sPath)
+	if err != nil {
+		return tConfigData, nil, fmt.Errorf("Can't open file to import: %s", err.Error())
+	}
+
+	tConfigData.Logging.LogsFolder = "./logs"
+	tConfigData.Logging.LogFileSizeMB = 20
+	switch strings.ToLower(filepath.Ext(sPath)) {
+	case ".conf", ".ini":
+		sHere, _ := filepath.Abs(filepath.Dir(sPath))
+		tConfigData.Tasks, notes, err = importSupervisord(sourceBytes, sHere)
+	case ".json", ".js", ".cjs":
+		tConfigData.Tasks, tConfigData.Profiles, notes, err = importPM2(sourceBytes, strings.ToLower(filepath.Ext(sPath)) != ".json")
+	default:
+		return tConfigData, nil, fmt.Errorf("unknown file type <%s>, expected a supervisord .conf/.ini or a PM2 .json/.js file", sPath)
+	}
+	if err != nil {
+		return tConfigData, notes, err
+	}
+	if len(tConfigData.Tasks) == 0 {
+		return tConfigData, notes, fmt.Errorf("no programs found in <%s>", sPath)
+	}
+	return tConfigData, notes, nil
+}
+
+// iniSection is a section of an INI file with its keys in lower case
+type iniSection struct {
+	name   string
+	values map[string]string
+	keys   []string // in the order of the file
+}
+
+// parseINI reads the sections of an INI file as supervisord writes them: ";" and "#" start
+// comments, inline only after white space, indented lines continue the value before
+//------------------------------------------------------------------------------
+func parseINI(data []byte) (sections []*iniSection, err error) {
+	var section *iniSection
+	var sLastKey string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		sLine := scanner.Text()
+		if sTrimmed := strings.TrimSpace(sLine); len(sTrimmed) == 0 || sTrimmed[0] == ';' || sTrimmed[0] == '#' {
+			continue
+		}
+		if commentIndex := strings.Index(sLine, " ;"); commentIndex >= 0 {
+			sLine = sLine[:commentIndex]
+		}
+
+		switch {
+		case strings.HasPrefix(strings.TrimSpace(sLine), "["):
+			sName := strings.TrimSpace(sLine)
+			if !strings.HasSuffix(sName, "]") {
+				return nil, fmt.Errorf("line %d: invalid section <%s>", lineNum, sName)
+			}
+			section = &iniSection{name: strings.TrimSpace(sName[1 : len(sName)-1]), values: make(map[string]string)}
+			sections = append(sections, section)
+			sLastKey = ""
+		case section == nil:
+			return nil, fmt.Errorf("line %d: setting outside of a section", lineNum)
+		case (sLine[0] == ' ' || sLine[0] == '\t') && len(sLastKey) > 0:
+			section.values[sLastKey] += "\n" + strings.TrimSpace(sLine)
+		default:
+			sKey, sValue, found := strings.Cut(sLine, "=")
+			if !found {
+				sKey, sValue, found = strings.Cut(sLine, ":")
+			}
+			if !found {
+				return nil, fmt.Errorf("line %d: expected key = value", lineNum)
+			}
+			sLastKey = strings.ToLower(strings.TrimSpace(sKey))
+			section.values[sLastKey] = strings.TrimSpace(sValue)
+			section.keys = append(section.keys, sLastKey)
+		}
+	}
+	return sections, scanner.Err()
+}
+
+// supervisord expressions like %(program_name)s or %(process_num)02d
+var supervisordExprRe = regexp.MustCompile(`%\(([A-Za-z_]+)\)([-#0 +]*[0-9]*)[sd]`)
+
+// importSupervisord converts the [program:x] sections of a supervisord configuration.
+// sHere is the folder of the file, for %(here)s.
+//------------------------------------------------------------------------------
+func importSupervisord(data []byte, sHere string) (tasks []ProcessConfig, notes []string, err error) {
+	sections, err := parseINI(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, section := range sections {
+		sKind, sProgram, _ := strings.Cut(section.name, ":")
+		switch sKind {
+		case "program":
+		case "supervisord", "supervisorctl", "unix_http_server", "inet_http_server", "rpcinterface":
+			continue
+		default:
+			notes = append(notes, fmt.Sprintf("section [%s] is not converted", section.name))
+			continue
+		}
+
+		task, taskNotes := importSupervisordProgram(sProgram, section, sHere)
+		for _, sNote := range taskNotes {
+			notes = append(notes, fmt.Sprintf("program <%s>: %s", sProgram, sNote))
+		}
+		if len(task.Name) > 0 {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, notes, nil
+}
+
+// importSupervisordProgram converts a single [program:x] section, an empty task if it can not
+//------------------------------------------------------------------------------
+func importSupervisordProgram(sProgram string, section *iniSection, sHere string) (task ProcessConfig, notes []string) {
+	values := section.values
+	numProcs, _ := strconv.Atoi(values["numprocs"])
+	expand := func(sValue string) string {
+		return supervisordExprRe.ReplaceAllStringFunc(sValue, func(sExpr string) string {
+			match := supervisordExprRe.FindStringSubmatch(sExpr)
+			sName := match[1]
+			switch {
+			case sName == "process_num" && numProcs > 1 && len(match[2]) > 0:
+				return fmt.Sprintf("{{printf \"%%%sd\" .InstanceID}}", match[2])
+			case sName == "program_name" || sName == "group_name":
+				return sProgram
+			case sName == "process_num" && numProcs > 1:
+				return "{{.InstanceID}}"
+			case sName == "process_num":
+				return "1"
+			case sName == "here":
+				return sHere
+			case strings.HasPrefix(sName, "ENV_"):
+				return "${" + strings.TrimPrefix(sName, "ENV_") + "}"
+			}
+			notes = append(notes, fmt.Sprintf("%s can not be converted", sExpr))
+			return sExpr
+		})
+	}
+
+	sCommand := expand(values["command"])
+	if len(sCommand) == 0 {
+		return ProcessConfig{}, append(notes, "has no command, left out")
+	}
+	task.Name = sProgram
+	if numProcs > 1 {
+		task.Instances = uint32(numProcs)
+		task.Name = expand(values["process_name"])
+		if len(task.Name) == 0 || !strings.Contains(task.Name, "{{") {
+			task.Name = sProgram + "-{{.InstanceID}}"
+		}
+		notes = append(notes, "instances are numbered from 1, %(process_num)s of supervisord from numprocs_start (0)")
+	}
+	setCommandLine(&task, sCommand)
+
+	task.MaxRestarts = importMaxRestarts
+	task.SeparateStderr = true
+	sAutorestart := "unexpected"
+	exitCodes := []int{0}
+	for _, sKey := range section.keys {
+		sValue := expand(values[sKey])
+		switch sKey {
+		case "command", "numprocs", "process_name":
+		case "autostart":
+			if !parseINIBool(sValue) {
+				task.Enabled = new(bool)
+			}
+		case "autorestart":
+			sAutorestart = sValue
+			if sValue == "false" {
+				task.MaxRestarts = 0
+			}
+		case "exitcodes":
+			exitCodes = nil
+			for _, sExitCode := range strings.Split(sValue, ",") {
+				if exitCode, err := strconv.Atoi(strings.TrimSpace(sExitCode)); err == nil {
+					exitCodes = append(exitCodes, exitCode)
+				}
+			}
+		case "environment":
+			task.Env = parseSupervisordEnv(sValue)
+		case "user":
+			task.RunAsUser = sValue
+		case "stopwaitsecs":
+			if seconds, err := strconv.Atoi(sValue); err == nil {
+				task.StopGracePeriod = Seconds(uint32(seconds))
+			}
+		case "redirect_stderr":
+			task.SeparateStderr = !parseINIBool(sValue)
+		case "stdout_logfile_maxbytes":
+			task.OutputMaxSizeMB = parseSizeMB(sValue)
+		case "stdout_logfile_backups":
+			if backups, err := strconv.Atoi(sValue); err == nil && backups > 0 {
+				task.OutputMaxFiles = uint32(backups) + 1
+			}
+		case "stdout_logfile", "stderr_logfile", "stderr_logfile_maxbytes", "stderr_logfile_backups":
+			notes = append(notes, fmt.Sprintf("%s is not converted, output goes to the log folder", sKey))
+		default:
+			notes = append(notes, fmt.Sprintf("%s = %s is not converted", sKey, sValue))
+		}
+	}
+	// "unexpected" restarts only the exit codes not listed in exitcodes
+	if task.MaxRestarts > 0 && sAutorestart != "true" {
+		task.SuccessExitCodes = exitCodes
+	}
+	if task.MaxRestarts > 0 {
+		notes = append(notes, fmt.Sprintf("supervisord restarts without limit, MaxRestarts is set to %d", importMaxRestarts))
+	}
+	return task, notes
+}
+
+// setCommandLine sets StartPath and StartArgs of a command line, or Command with the sh shell
+// if the line needs a shell, e.g. for pipes or variables
+//------------------------------------------------------------------------------
+func setCommandLine(task *ProcessConfig, sCommandLine string) {
+	words, ok := splitCommandLine(sCommandLine)
+	if !ok || len(words) == 0 {
+		task.Command = sCommandLine
+		task.Shell = ShellSh
+		return
+	}
+	task.StartPath = words[0]
+	task.StartArgs = words[1:]
+}
+
+// splitCommandLine splits a command line into words like sh does with quotes and backslashes.
+// Reports false if the line uses anything else of the shell, e.g. variables or redirections.
+//------------------------------------------------------------------------------
+func splitCommandLine(sCommandLine string) (words []string, ok bool) {
+	var word strings.Builder
+	bInWord := false
+	var quote rune
+	bEscaped := false
+	for _, char := range sCommandLine {
+		switch {
+		case bEscaped:
+			word.WriteRune(char)
+			bEscaped = false
+		case char == '\\' && quote != '\'':
+			bEscaped = true
+			bInWord = true
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			} else if quote == '"' && (char == '$' || char == '`') {
+				return nil, false
+			} else {
+				word.WriteRune(char)
+			}
+		case char == '\'' || char == '"':
+			quote = char
+			bInWord = true
+		case char == ' ' || char == '\t' || char == '\n':
+			if bInWord {
+				words = append(words, word.String())
+				word.Reset()
+				bInWord = false
+			}
+		case strings.ContainsRune("$`|&;<>()*?~{}[]#", char):
+			return nil, false
+		default:
+			word.WriteRune(char)
+			bInWord = true
+		}
+	}
+	if quote != 0 || bEscaped {
+		return nil, false
+	}
+	if bInWord {
+		words = append(words, word.String())
+	}
+	return words, true
+}
+
+// parseSupervisordEnv parses KEY="value",KEY2=value2 of supervisord
+//------------------------------------------------------------------------------
+func parseSupervisordEnv(sValue string) map[string]string {
+	env := make(map[string]string)
+	for len(sValue) > 0 {
+		sKey, sRest, found := strings.Cut(sValue, "=")
+		if !found {
+			break
+		}
+		sKey = strings.TrimSpace(strings.TrimLeft(sKey, ", \n"))
+		var sVal string
+		if len(sRest) > 0 && (sRest[0] == '"' || sRest[0] == '\'') {
+			endIndex := strings.IndexByte(sRest[1:], sRest[0])
+			if endIndex < 0 {
+				endIndex = len(sRest) - 1
+			}
+			sVal = sRest[1 : endIndex+1]
+			sValue = sRest[min(endIndex+2, len(sRest)):]
+		} else {
+			sVal, sValue, _ = strings.Cut(sRest, ",")
+		}
+		env[sKey] = strings.TrimSpace(sVal)
+	}
+	return env
+}
+
+// parseINIBool reads a boolean of supervisord
+//------------------------------------------------------------------------------
+func parseINIBool(sValue string) bool {
+	switch strings.ToLower(sValue) {
+	case "true", "yes", "on", "1":
+		return true
+	}
+	return false
+}
+
+// parseSizeMB reads sizes like "50MB", "1GB" or "300M" in MB, rounded up, zero if unknown
+//------------------------------------------------------------------------------
+func parseSizeMB(sValue string) uint32 {
+	sNumber := strings.TrimRight(strings.ToUpper(strings.TrimSpace(sValue)), "B")
+	unit := 1.0 / (1 << 20)
+	switch {
+	case strings.HasSuffix(sNumber, "K"):
+		unit = 1.0 / 1024
+	case strings.HasSuffix(sNumber, "M"):
+		unit = 1
+	case strings.HasSuffix(sNumber, "G"):
+		unit = 1024
+	}
+	number, err := strconv.ParseFloat(strings.TrimRight(sNumber, "KMG"), 64)
+	if err != nil || number <= 0 {
+		return 0
+	}
+	return uint32(math.Ceil(number * unit))
+}
+
+// durationOfMillis converts milliseconds of PM2 settings, which may be numbers or strings
+//------------------------------------------------------------------------------
+func durationOfMillis(value interface{}) (Duration, bool) {
+	millis, err := strconv.ParseFloat(fmt.Sprint(value), 64)
+	if err != nil || millis < 0 {
+		return Duration{}, false
+	}
+	return Duration{time.Duration(millis) * time.Millisecond}, true
+}
+
+// sortedKeys returns the keys of a map in order, to make the notes repeatable
+//------------------------------------------------------------------------------
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for sKey := range values {
+		keys = append(keys, sKey)
+	}
+	sort.Strings(keys)
+	return keys
+}
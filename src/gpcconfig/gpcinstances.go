@@ -0,0 +1,123 @@
+package gpcconfig
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// InstanceData is what the templates of a task with Instances can refer to
+type InstanceData struct {
+	Name       string // name of the task definition
+	InstanceID int    // number of the instance, starting at 1
+	Port       int    // BasePort plus InstanceID - 1
+}
+
+//ExpandTask turns a task definition with Instances into one task per instance. Name,
+//StartArgs, Command, StopArgs, ListenSockets, ReadinessURL and the values of Env are templates (text/template) with the fields
+//of InstanceData, e.g. "worker-{{.InstanceID}}" or "--port={{.Port}}". Without a template in
+//the name, the instances are named "<name>-<InstanceID>". Tasks without Instances are
+//returned as they are.
+//#########################################################
+func ExpandTask(task ProcessConfig) ([]ProcessConfig, error) {
+	if task.Instances == 0 {
+		return []ProcessConfig{task}, nil
+	}
+
+	instances := make([]ProcessConfig, 0, task.Instances)
+	for instanceID := 1; instanceID <= int(task.Instances); instanceID++ {
+		data := InstanceData{Name: task.Name, InstanceID: instanceID, Port: int(task.BasePort) + instanceID - 1}
+
+		instance := task
+		instance.Instances = 0
+		instance.Template = task.Name
+		var err error
+		if instance.Name, err = expandField(task.Name, data); err != nil {
+			return nil, fmt.Errorf("task <%s>, Name: %s", task.Name, err.Error())
+		}
+		if instance.Name == task.Name {
+			instance.Name = fmt.Sprintf("%s-%d", task.Name, instanceID)
+		}
+		if instance.StartArgs, err = expandFields(task.StartArgs, data); err != nil {
+			return nil, fmt.Errorf("task <%s>, StartArgs: %s", task.Name, err.Error())
+		}
+		if instance.Command, err = expandField(task.Command, data); err != nil {
+			return nil, fmt.Errorf("task <%s>, Command: %s", task.Name, err.Error())
+		}
+		if instance.StopArgs, err = expandFields(task.StopArgs, data); err != nil {
+			return nil, fmt.Errorf("task <%s>, StopArgs: %s", task.Name, err.Error())
+		}
+		if instance.ListenSockets, err = expandFields(task.ListenSockets, data); err != nil {
+			return nil, fmt.Errorf("task <%s>, ListenSockets: %s", task.Name, err.Error())
+		}
+		if instance.ReadinessURL, err = expandField(task.ReadinessURL, data); err != nil {
+			return nil, fmt.Errorf("task <%s>, ReadinessURL: %s", task.Name, err.Error())
+		}
+		if task.Env != nil {
+			instance.Env = make(map[string]string, len(task.Env))
+			for sKey, sValue := range task.Env {
+				if instance.Env[sKey], err = expandField(sValue, data); err != nil {
+					return nil, fmt.Errorf("task <%s>, Env <%s>: %s", task.Name, sKey, err.Error())
+				}
+			}
+		}
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
+
+// expandInstances replaces the tasks with Instances by their instances. Fails if an instance
+// gets the name of another task.
+//------------------------------------------------------------------------------
+func (c *ConfigData) expandInstances() error {
+	taskNames := make(map[string]bool)
+	expandedTasks := make([]ProcessConfig, 0, len(c.Tasks))
+	for _, task := range c.Tasks {
+		instances, err := ExpandTask(task)
+		if err != nil {
+			return err
+		}
+		for _, instance := range instances {
+			if taskNames[instance.Name] {
+				return fmt.Errorf("task <%s> is defined more than once", instance.Name)
+			}
+			taskNames[instance.Name] = true
+		}
+		expandedTasks = append(expandedTasks, instances...)
+	}
+	c.Tasks = expandedTasks
+	return nil
+}
+
+// expandFields expands a list of templates, nil stays nil
+//------------------------------------------------------------------------------
+func expandFields(sTemplates []string, data InstanceData) ([]string, error) {
+	if sTemplates == nil {
+		return nil, nil
+	}
+	expanded := make([]string, len(sTemplates))
+	for i, sTemplate := range sTemplates {
+		var err error
+		if expanded[i], err = expandField(sTemplate, data); err != nil {
+			return nil, err
+		}
+	}
+	return expanded, nil
+}
+
+// expandField expands a single template, text without "{{" is returned as it is
+//------------------------------------------------------------------------------
+func expandField(sTemplate string, data InstanceData) (string, error) {
+	if !strings.Contains(sTemplate, "{{") {
+		return sTemplate, nil
+	}
+	tmpl, err := template.New("").Option("missingkey=error").Parse(sTemplate)
+	if err != nil {
+		return "", err
+	}
+	var expanded strings.Builder
+	if err := tmpl.Execute(&expanded, data); err != nil {
+		return "", err
+	}
+	return expanded.String(), nil
+}
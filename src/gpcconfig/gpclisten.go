@@ -0,0 +1,24 @@
+package gpcconfig
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+//ListenNetwork splits an entry of ListenSockets into network and address for net.Listen:
+//"tcp://host:port" or plain "host:port" is TCP, "unix:///path" a Unix domain socket
+//#########################################################
+func ListenNetwork(sSocket string) (string, string, error) {
+	if sPath, found := strings.CutPrefix(sSocket, "unix://"); found {
+		if len(sPath) == 0 {
+			return "", "", fmt.Errorf("unix:// needs the path of the socket")
+		}
+		return "unix", sPath, nil
+	}
+	sAddress := strings.TrimPrefix(sSocket, "tcp://")
+	if _, _, err := net.SplitHostPort(sAddress); err != nil {
+		return "", "", err
+	}
+	return "tcp", sAddress, nil
+}
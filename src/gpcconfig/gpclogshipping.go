@@ -0,0 +1,43 @@
+package gpcconfig
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// LogShippingConfig forwards the captured output of the tasks to central logging, without an agent
+// on the host
+type LogShippingConfig struct {
+	Protocol      string            // "tcp" (JSON lines), "http" (NDJSON bulk POSTs) or "loki" (push API), empty => no shipping
+	Address       string            // host:port with tcp, the URL with http and loki, e.g. "http://loki:3100/loki/api/v1/push"
+	Headers       map[string]string // extra request headers with http and loki, e.g. Authorization or X-Scope-OrgID
+	Labels        map[string]string // added to each line, the stream labels with loki, e.g. "env": "prod"
+	BufferLines   uint32            // lines held while the endpoint is slow or down, further lines are dropped, zero => 10000
+	BatchLines    uint32            // lines sent at most at once, zero => 500
+	FlushInterval Duration          // how long lines wait for a batch to fill up, zero => 2s
+	MaxBackoff    Duration          // longest pause between attempts while the endpoint fails, zero => 1m
+}
+
+//CheckLogShipping verifies the protocol and address of the log shipping. Returns one error per problem found.
+//#########################################################
+func CheckLogShipping(tConfigData *ConfigData) (errs []error) {
+	shipping := tConfigData.LogShipping
+
+	switch shipping.Protocol {
+	case "":
+	case "tcp":
+		if _, _, err := net.SplitHostPort(shipping.Address); err != nil {
+			errs = append(errs, fmt.Errorf("log shipping: address <%s> is not host:port", shipping.Address))
+		}
+	case "http", "loki":
+		shippingURL, err := url.Parse(shipping.Address)
+		if err != nil || (shippingURL.Scheme != "http" && shippingURL.Scheme != "https") || len(shippingURL.Host) == 0 {
+			errs = append(errs, fmt.Errorf("log shipping: <%s> is not an http or https URL", shipping.Address))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("log shipping: unknown protocol <%s>, use tcp, http or loki", shipping.Protocol))
+	}
+
+	return errs
+}
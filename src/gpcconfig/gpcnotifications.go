@@ -0,0 +1,93 @@
+package gpcconfig
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"text/template"
+)
+
+// WebhookConfig is an HTTP endpoint that receives the lifecycle events as JSON
+type WebhookConfig struct {
+	URL          string            // http or https URL the events are POSTed to
+	Events       []string          // event types sent, e.g. "ProcessExited" or "ProcessRestartLimitReached", empty => all
+	FailuresOnly bool              // send ProcessExited only for processes that failed, not for stops and successful runs
+	Headers      map[string]string // extra request headers, e.g. Authorization
+	Timeout      Duration          // of each attempt, zero => 10s
+	Retries      uint32            // further attempts after a failed delivery, zero => none
+	RetryDelay   Duration          // pause before a retry, zero => 5s
+}
+
+// EmailConfig is an SMTP account that mails the lifecycle events, e.g. to an on-call inbox
+type EmailConfig struct {
+	Server      string   // SMTP server as host:port. Port 465 uses TLS from the start, other ports STARTTLS when offered
+	User        string   // login (PLAIN), empty => no authentication
+	PasswordEnv string   // name of an environment variable holding the password, takes precedence over Password
+	Password    string   // password of User, prefer PasswordEnv to keep it out of the file
+	From        string   // sender address
+	To          []string // recipient addresses
+	Events      []string // event types sent, empty => events that leave a process in the error or quarantined state
+	OutputLines uint32   // last lines of the task's output attached, zero => 50
+}
+
+// ChatConfig is an incoming webhook of a Slack or Microsoft Teams channel
+type ChatConfig struct {
+	URL          string   // incoming webhook URL of the channel
+	Events       []string // event types sent, empty => all
+	FailuresOnly bool     // send ProcessExited only for processes that failed, not for stops and successful runs
+	Template     string   // Go text/template of the message with .Task, .Event, .State, .ExitCode, .PID, .Host, .Message and .Time, empty => a default one-liner
+	Timeout      Duration // of each attempt, zero => 10s
+	Retries      uint32   // further attempts after a failed delivery, zero => none
+	RetryDelay   Duration // pause before a retry, zero => 5s
+}
+
+//CheckNotifications verifies the configured notification targets. Returns one error per problem found.
+//#########################################################
+func CheckNotifications(tConfigData *ConfigData) (errs []error) {
+
+	for i, webhook := range tConfigData.Notifications.Webhooks {
+		webhookURL, err := url.Parse(webhook.URL)
+		if err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || len(webhookURL.Host) == 0 {
+			errs = append(errs, fmt.Errorf("webhook <%d>: <%s> is not an http or https URL", i, webhook.URL))
+		}
+	}
+	for i, email := range tConfigData.Notifications.Emails {
+		if _, _, err := net.SplitHostPort(email.Server); err != nil {
+			errs = append(errs, fmt.Errorf("email <%d>: server <%s> is not host:port", i, email.Server))
+		}
+		if len(email.From) == 0 || len(email.To) == 0 {
+			errs = append(errs, fmt.Errorf("email <%d>: From and To are required", i))
+		}
+		if _, err := email.SMTPPassword(); err != nil {
+			errs = append(errs, fmt.Errorf("email <%d>: %s", i, err.Error()))
+		}
+	}
+	for sKind, chats := range map[string][]ChatConfig{"slack": tConfigData.Notifications.Slack, "teams": tConfigData.Notifications.Teams} {
+		for i, chat := range chats {
+			chatURL, err := url.Parse(chat.URL)
+			if err != nil || chatURL.Scheme != "https" || len(chatURL.Host) == 0 {
+				errs = append(errs, fmt.Errorf("%s <%d>: <%s> is not an https URL", sKind, i, chat.URL))
+			}
+			if _, err := template.New(sKind).Parse(chat.Template); err != nil {
+				errs = append(errs, fmt.Errorf("%s <%d>: invalid Template: %s", sKind, i, err.Error()))
+			}
+		}
+	}
+
+	return errs
+}
+
+//SMTPPassword returns the password of the SMTP account, from the environment variable named in
+//PasswordEnv if set
+//#########################################################
+func (e *EmailConfig) SMTPPassword() (string, error) {
+	if len(e.PasswordEnv) == 0 {
+		return e.Password, nil
+	}
+	sPassword, found := os.LookupEnv(e.PasswordEnv)
+	if !found {
+		return "", fmt.Errorf("password environment variable <%s> is not set", e.PasswordEnv)
+	}
+	return sPassword, nil
+}
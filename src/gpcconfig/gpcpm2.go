@@ -0,0 +1,319 @@
+package gpcconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// PM2 defaults
+const (
+	pm2MaxRestarts = 16 // max_restarts
+)
+
+// interpreters PM2 picks by the extension of a script
+var pm2Interpreters = map[string]string{
+	".js": "node", ".mjs": "node", ".cjs": "node", ".py": "python3", ".sh": "bash", ".rb": "ruby", ".php": "php", ".pl": "perl",
+}
+
+// importPM2 converts the apps of a PM2 ecosystem file. bJS reads the object exported by a
+// JavaScript file, as far as it is a plain literal. The env_<name> sections of the apps become
+// profiles of that name.
+//------------------------------------------------------------------------------
+func importPM2(data []byte, bJS bool) (tasks []ProcessConfig, profiles map[string]json.RawMessage, notes []string, err error) {
+	if bJS {
+		if data, err = jsLiteralToJSON(data); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	document, err := decodeJSONValue(data)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Can't decode PM2 file: %s", err.Error())
+	}
+
+	apps, isList := document.([]interface{})
+	if object, isObject := document.(map[string]interface{}); isObject {
+		apps, isList = object["apps"].([]interface{})
+	}
+	if !isList {
+		return nil, nil, nil, fmt.Errorf("PM2 file has no list of apps")
+	}
+
+	profileTasks := make(map[string][]interface{})
+	var profileNames []string // in the order of the file
+	for appIndex, app := range apps {
+		appObject, isObject := app.(map[string]interface{})
+		if !isObject {
+			return nil, nil, notes, fmt.Errorf("app #%d is not an object", appIndex+1)
+		}
+		task, appNotes := importPM2App(appObject)
+		for _, sNote := range appNotes {
+			notes = append(notes, fmt.Sprintf("app <%s>: %s", task.Name, sNote))
+		}
+		tasks = append(tasks, task)
+
+		for _, sKey := range sortedKeys(appObject) {
+			if sProfile := strings.TrimPrefix(sKey, "env_"); sProfile != sKey && len(sProfile) > 0 {
+				if _, found := profileTasks[sProfile]; !found {
+					profileNames = append(profileNames, sProfile)
+				}
+				profileTasks[sProfile] = append(profileTasks[sProfile], map[string]interface{}{"Name": task.Name, "Env": stringMap(appObject[sKey])})
+			}
+		}
+	}
+
+	if len(profileNames) > 0 {
+		profiles = make(map[string]json.RawMessage)
+		for _, sProfile := range profileNames {
+			if profiles[sProfile], err = json.Marshal(map[string]interface{}{"Tasks": profileTasks[sProfile]}); err != nil {
+				return nil, nil, notes, err
+			}
+			notes = append(notes, fmt.Sprintf("env_%s is the profile <%s>, select it with -profile %s", sProfile, sProfile, sProfile))
+		}
+	}
+	return tasks, profiles, notes, nil
+}
+
+// importPM2App converts a single app of a PM2 ecosystem file
+//------------------------------------------------------------------------------
+func importPM2App(app map[string]interface{}) (task ProcessConfig, notes []string) {
+	sScript := stringOf(app["script"])
+	task.Name = stringOf(app["name"])
+	if len(task.Name) == 0 {
+		task.Name = strings.TrimSuffix(filepath.Base(sScript), filepath.Ext(sScript))
+	}
+
+	sInterpreter := stringOf(app["interpreter"])
+	if len(sInterpreter) == 0 {
+		sInterpreter = stringOf(app["exec_interpreter"])
+	}
+	if len(sInterpreter) == 0 {
+		sInterpreter = pm2Interpreters[strings.ToLower(filepath.Ext(sScript))]
+	}
+	args, argNotes := argumentsOf(app["args"])
+	notes = append(notes, argNotes...)
+	if sInterpreter == "none" || len(sInterpreter) == 0 {
+		task.StartPath = sScript
+		task.StartArgs = args
+	} else {
+		interpreterArgs, argNotes := argumentsOf(app["interpreter_args"])
+		if app["interpreter_args"] == nil {
+			interpreterArgs, argNotes = argumentsOf(app["node_args"])
+		}
+		notes = append(notes, argNotes...)
+		task.StartPath = sInterpreter
+		task.StartArgs = append(append(interpreterArgs, sScript), args...)
+	}
+
+	task.MaxRestarts = pm2MaxRestarts
+	task.SeparateStderr = true
+	for _, sKey := range sortedKeys(app) {
+		value := app[sKey]
+		switch sKey {
+		case "name", "script", "args", "interpreter", "exec_interpreter", "interpreter_args", "node_args":
+		case "env":
+			task.Env = stringMap(value)
+		case "instances":
+			instances, err := strconv.Atoi(stringOf(value))
+			if err != nil || instances < 1 {
+				instances = runtime.NumCPU()
+				notes = append(notes, fmt.Sprintf("instances <%v> is set to the %d CPUs of this machine", value, instances))
+			}
+			if instances > 1 {
+				task.Instances = uint32(instances)
+			}
+		case "exec_mode":
+			if stringOf(value) == "cluster" || stringOf(value) == "cluster_mode" {
+				notes = append(notes, "cluster mode is not converted, instances are separate processes that can not share a port")
+			}
+		case "autorestart":
+			if stringOf(value) == "false" {
+				task.MaxRestarts = 0
+			}
+		case "max_restarts":
+			if restarts, err := strconv.Atoi(stringOf(value)); err == nil && app["autorestart"] != false {
+				task.MaxRestarts = uint32(max(restarts, 0))
+			}
+		case "max_memory_restart":
+			task.MaxMemoryRestartMB = parseSizeMB(stringOf(value))
+		case "kill_timeout":
+			if killTimeout, ok := durationOfMillis(value); ok {
+				task.StopGracePeriod = killTimeout
+			}
+		case "stop_exit_codes":
+			// a list or a single number, the app is not restarted after these
+			exitCodes, _ := value.([]interface{})
+			if exitCodes == nil {
+				exitCodes = []interface{}{value}
+			}
+			for _, exitCodeValue := range exitCodes {
+				if exitCode, err := strconv.Atoi(stringOf(exitCodeValue)); err == nil {
+					task.SuccessExitCodes = append(task.SuccessExitCodes, exitCode)
+				}
+			}
+		case "time":
+			task.TimestampOutput = value == true
+		case "cwd":
+			notes = append(notes, fmt.Sprintf("cwd <%v> is not converted, the task runs in the folder of the controller", value))
+		case "out_file", "error_file", "log_file", "merge_logs", "combine_logs", "log_date_format":
+			notes = append(notes, fmt.Sprintf("%s is not converted, output goes to the log folder", sKey))
+		default:
+			if !strings.HasPrefix(sKey, "env_") {
+				notes = append(notes, fmt.Sprintf("%s = %v is not converted", sKey, value))
+			}
+		}
+	}
+	return task, notes
+}
+
+// argumentsOf returns the arguments of PM2 args, which may be a list or a command line
+//------------------------------------------------------------------------------
+func argumentsOf(value interface{}) (args []string, notes []string) {
+	switch argsValue := value.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		for _, arg := range argsValue {
+			args = append(args, stringOf(arg))
+		}
+		return args, nil
+	}
+	sArgs := stringOf(value)
+	args, ok := splitCommandLine(sArgs)
+	if !ok {
+		args = strings.Fields(sArgs)
+		notes = append(notes, fmt.Sprintf("arguments <%s> are passed without a shell", sArgs))
+	}
+	return args, notes
+}
+
+// stringOf returns a JSON value as string, numbers as written
+//------------------------------------------------------------------------------
+func stringOf(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// stringMap converts a JSON object to strings, e.g. environment values given as numbers
+//------------------------------------------------------------------------------
+func stringMap(value interface{}) map[string]string {
+	object, _ := value.(map[string]interface{})
+	values := make(map[string]string, len(object))
+	for sKey, element := range object {
+		values[sKey] = stringOf(element)
+	}
+	return values
+}
+
+// jsLiteralToJSON converts the object literal exported by a JavaScript file (module.exports =
+// {...}) to JSON: comments are removed, keys and single quoted strings quoted and trailing
+// commas dropped. Anything computed, like process.env or require(), is reported as error.
+//------------------------------------------------------------------------------
+func jsLiteralToJSON(source []byte) ([]byte, error) {
+	sSource := string(source)
+	startIndex := strings.Index(sSource, "module.exports")
+	if startIndex < 0 {
+		startIndex = 0
+	}
+	braceIndex := strings.IndexAny(sSource[startIndex:], "{[")
+	if braceIndex < 0 {
+		return nil, fmt.Errorf("no exported object found")
+	}
+	runes := []rune(sSource[startIndex+braceIndex:])
+	lineOf := func(runeIndex int) int {
+		return strings.Count(sSource[:startIndex+braceIndex], "\n") + strings.Count(string(runes[:runeIndex]), "\n") + 1
+	}
+
+	var out strings.Builder
+	depth := 0
+	for i := 0; i < len(runes); i++ {
+		char := runes[i]
+		switch {
+		case unicode.IsSpace(char):
+		case char == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case char == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			endIndex := strings.Index(string(runes[i+2:]), "*/")
+			if endIndex < 0 {
+				return nil, fmt.Errorf("line %d: comment is not closed", lineOf(i))
+			}
+			i += 2 + len([]rune(string(runes[i+2:])[:endIndex])) + 1
+		case char == '{' || char == '[':
+			out.WriteRune(char)
+			depth++
+		case char == '}' || char == ']':
+			sOut := strings.TrimSuffix(out.String(), ",")
+			out.Reset()
+			out.WriteString(sOut)
+			out.WriteRune(char)
+			if depth--; depth == 0 {
+				return []byte(out.String()), nil
+			}
+		case char == ':' || char == ',':
+			out.WriteRune(char)
+		case char == '"' || char == '\'' || char == '`':
+			var value strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != char; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					switch runes[i] {
+					case 'n':
+						value.WriteRune('\n')
+					case 't':
+						value.WriteRune('\t')
+					default:
+						value.WriteRune(runes[i])
+					}
+					continue
+				}
+				if char == '`' && runes[i] == '$' && i+1 < len(runes) && runes[i+1] == '{' {
+					return nil, fmt.Errorf("line %d: template strings with ${...} can not be converted", lineOf(i))
+				}
+				value.WriteRune(runes[i])
+			}
+			quoted, _ := json.Marshal(value.String())
+			out.Write(quoted)
+		case char == '-' || char == '.' || unicode.IsDigit(char):
+			startNumber := i
+			for i+1 < len(runes) && strings.ContainsRune("0123456789.eE+-xXabcdefABCDEF_", runes[i+1]) {
+				i++
+			}
+			sNumber := strings.ReplaceAll(string(runes[startNumber:i+1]), "_", "")
+			if _, err := strconv.ParseFloat(sNumber, 64); err != nil {
+				return nil, fmt.Errorf("line %d: number <%s> can not be converted", lineOf(startNumber), sNumber)
+			}
+			out.WriteString(sNumber)
+		case char == '_' || char == '$' || unicode.IsLetter(char):
+			startWord := i
+			for i+1 < len(runes) && (runes[i+1] == '_' || runes[i+1] == '$' || unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1])) {
+				i++
+			}
+			sWord := string(runes[startWord : i+1])
+			nextIndex := i + 1
+			for nextIndex < len(runes) && unicode.IsSpace(runes[nextIndex]) {
+				nextIndex++
+			}
+			switch {
+			case nextIndex < len(runes) && runes[nextIndex] == ':':
+				quoted, _ := json.Marshal(sWord)
+				out.Write(quoted)
+			case sWord == "true" || sWord == "false" || sWord == "null":
+				out.WriteString(sWord)
+			default:
+				return nil, fmt.Errorf("line %d: <%s> is computed and can not be converted, use a value or the JSON form of the file", lineOf(startWord), sWord)
+			}
+		default:
+			return nil, fmt.Errorf("line %d: <%c> can not be converted, use the JSON form of the file", lineOf(i), char)
+		}
+	}
+	return nil, fmt.Errorf("exported object is not closed")
+}
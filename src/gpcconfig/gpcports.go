@@ -0,0 +1,53 @@
+package gpcconfig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Ports assigned to the tasks with AssignPorts if Control.PortRange is not set
+const DefPortRange = "20000-29999"
+
+//PortRange returns the first and last port of Control.PortRange, "first-last"
+//#########################################################
+func (c *ConfigData) PortRange() (int, int, error) {
+	sRange := c.Control.PortRange
+	if len(sRange) == 0 {
+		sRange = DefPortRange
+	}
+	sFirst, sLast, found := strings.Cut(sRange, "-")
+	if !found {
+		return 0, 0, fmt.Errorf("<%s> is not first-last", sRange)
+	}
+	first, err := strconv.Atoi(strings.TrimSpace(sFirst))
+	if err != nil {
+		return 0, 0, fmt.Errorf("<%s> is not first-last: %s", sRange, err.Error())
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(sLast))
+	if err != nil {
+		return 0, 0, fmt.Errorf("<%s> is not first-last: %s", sRange, err.Error())
+	}
+	if first < 1 || last > 65535 || first > last {
+		return 0, 0, fmt.Errorf("<%s> is not a range of ports between 1 and 65535", sRange)
+	}
+	return first, last, nil
+}
+
+//CheckPorts verifies the range the ports of AssignPorts are taken from and that it holds enough
+//ports for all tasks. Returns one error per problem found.
+//#########################################################
+func CheckPorts(tConfigData *ConfigData) (errs []error) {
+	first, last, err := tConfigData.PortRange()
+	if err != nil {
+		return []error{fmt.Errorf("Control: PortRange %s", err.Error())}
+	}
+	needed := 0
+	for _, task := range tConfigData.Tasks {
+		needed += len(task.AssignPorts)
+	}
+	if needed > last-first+1 {
+		errs = append(errs, fmt.Errorf("Control: PortRange <%d-%d> has fewer ports than the <%d> of AssignPorts", first, last, needed))
+	}
+	return errs
+}
@@ -0,0 +1,76 @@
+package gpcconfig
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Precondition must hold before a task is launched, exactly one of its fields is set
+type Precondition struct {
+	FileExists string // path of a file or folder that must exist
+	PortFree   uint16 // local TCP port nothing may listen on
+	TaskReady  string // task that must be running (and notified READY=1 with NotifyReady), or have ended with exit code 0 if it is waited for
+	EnvSet     string // environment variable of the controller that must be set and not empty
+}
+
+// How often unmet preconditions are checked again without PreconditionInterval
+const defPreconditionInterval = 5 * time.Second
+
+//String describes the condition, e.g. "port 8080 is free"
+//#########################################################
+func (c Precondition) String() string {
+	switch {
+	case len(c.FileExists) > 0:
+		return fmt.Sprintf("file <%s> exists", c.FileExists)
+	case c.PortFree > 0:
+		return "port " + strconv.Itoa(int(c.PortFree)) + " is free"
+	case len(c.TaskReady) > 0:
+		return fmt.Sprintf("task <%s> is ready", c.TaskReady)
+	case len(c.EnvSet) > 0:
+		return fmt.Sprintf("env var <%s> is set", c.EnvSet)
+	}
+	return "empty condition"
+}
+
+// kinds returns how many kinds of conditions are set
+//------------------------------------------------------------------------------
+func (c Precondition) kinds() int {
+	kinds := 0
+	for _, bSet := range []bool{len(c.FileExists) > 0, c.PortFree > 0, len(c.TaskReady) > 0, len(c.EnvSet) > 0} {
+		if bSet {
+			kinds++
+		}
+	}
+	return kinds
+}
+
+//PreconditionRetryInterval returns how often the unmet Preconditions of the task are checked again
+//#########################################################
+func (p *ProcessConfig) PreconditionRetryInterval() time.Duration {
+	if p.PreconditionInterval.Duration > 0 {
+		return p.PreconditionInterval.Duration
+	}
+	return defPreconditionInterval
+}
+
+//CheckPreconditions verifies that TaskReady conditions reference known tasks other than the task
+//itself. Returns one error per problem found.
+//#########################################################
+func CheckPreconditions(tConfigData *ConfigData) (errs []error) {
+
+	taskNames := make(map[string]bool)
+	for _, task := range tConfigData.Tasks {
+		taskNames[task.Name] = true
+	}
+
+	for _, task := range tConfigData.Tasks {
+		for _, condition := range task.Preconditions {
+			if len(condition.TaskReady) > 0 && (!taskNames[condition.TaskReady] || condition.TaskReady == task.Name) {
+				errs = append(errs, fmt.Errorf("task <%s>: unknown task <%s> in Preconditions", task.Name, condition.TaskReady))
+			}
+		}
+	}
+
+	return errs
+}
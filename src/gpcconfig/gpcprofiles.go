@@ -0,0 +1,165 @@
+package gpcconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// profile selected with -profile, empty => none
+var gProfile string
+
+//SetProfile selects the profile LoadConfigFromFile merges into configurations, empty => none.
+//A profile is the section of that name in Profiles and the overlay file of that name, see
+//OverlayPath, at least one of both must exist. Objects are merged key by key and tasks by
+//their Name, tasks the configuration does not have are added. All other values of a profile,
+//including lists, replace the ones of the configuration.
+//#########################################################
+func SetProfile(sProfile string) error {
+	if strings.ContainsAny(sProfile, `/\`) {
+		return fmt.Errorf("invalid profile name <%s>", sProfile)
+	}
+	gProfile = sProfile
+	return nil
+}
+
+//OverlayPath returns the overlay file of a profile, next to the configuration file with the
+//profile in the name: "pc-conf.json" => "pc-conf.prod.json"
+//#########################################################
+func OverlayPath(sConfigFilePath string, sProfile string) string {
+	sExt := filepath.Ext(sConfigFilePath)
+	return strings.TrimSuffix(sConfigFilePath, sExt) + "." + sProfile + sExt
+}
+
+// applyProfile merges the selected profile into a configuration given as JSON, first the
+// section in Profiles, then the overlay file. Returns the merged configuration, validated.
+//------------------------------------------------------------------------------
+func applyProfile(sConfigFilePath string, jsonBytes []byte, profiles map[string]json.RawMessage) (tConfigData ConfigData, err error) {
+	document, err := decodeJSONValue(jsonBytes)
+	if err != nil {
+		return tConfigData, err
+	}
+
+	bFound := false
+	if profileBytes, found := profiles[gProfile]; found {
+		// errors have no line, the profile is only a part of the file
+		if err = decodeStrict(profileBytes, nil, "", &ConfigData{}); err != nil {
+			return tConfigData, fmt.Errorf("profile <%s>: %w", gProfile, err)
+		}
+		overlay, err := decodeJSONValue(profileBytes)
+		if err != nil {
+			return tConfigData, err
+		}
+		document = mergeJSON(document, overlay, "")
+		bFound = true
+	}
+	sOverlayPath := OverlayPath(sConfigFilePath, gProfile)
+	if _, err = os.Stat(sOverlayPath); err == nil {
+		_, overlayBytes, err := readConfigDocument(sOverlayPath)
+		if err != nil {
+			return tConfigData, err
+		}
+		overlay, err := decodeJSONValue(overlayBytes)
+		if err != nil {
+			return tConfigData, err
+		}
+		document = mergeJSON(document, overlay, "")
+		bFound = true
+	}
+	if !bFound {
+		return tConfigData, fmt.Errorf("unknown profile <%s>: it is not in Profiles and there is no file <%s>", gProfile, sOverlayPath)
+	}
+
+	mergedBytes, err := json.Marshal(document)
+	if err != nil {
+		return tConfigData, err
+	}
+	if err = decodeStrict(mergedBytes, nil, "", &tConfigData); err != nil {
+		return tConfigData, fmt.Errorf("with profile <%s>: %w", gProfile, err)
+	}
+	tConfigData.migrateDeprecatedFields()
+	tConfigData.taskKeys = taskKeysOf(mergedBytes)
+	if err = tConfigData.Validate(); err != nil {
+		return tConfigData, fmt.Errorf("with profile <%s>: %w", gProfile, err)
+	}
+	return tConfigData, nil
+}
+
+// decodeJSONValue decodes JSON into maps, lists and values, numbers are kept as written
+//------------------------------------------------------------------------------
+func decodeJSONValue(jsonBytes []byte) (value interface{}, err error) {
+	jsonDecoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	jsonDecoder.UseNumber()
+	err = jsonDecoder.Decode(&value)
+	return value, err
+}
+
+// mergeJSON merges overlay into base, see SetProfile. sKey is the key base has in its parent
+// object, lists of "Tasks" are merged by name. Keys are compared like the JSON decoder does,
+// without case.
+//------------------------------------------------------------------------------
+func mergeJSON(base interface{}, overlay interface{}, sKey string) interface{} {
+	switch overlayValue := overlay.(type) {
+	case map[string]interface{}:
+		baseObject, isObject := base.(map[string]interface{})
+		if !isObject {
+			return overlay
+		}
+		for sOverlayKey, value := range overlayValue {
+			sBaseKey := sOverlayKey
+			for sExistingKey := range baseObject {
+				if strings.EqualFold(sExistingKey, sOverlayKey) {
+					sBaseKey = sExistingKey
+					break
+				}
+			}
+			baseObject[sBaseKey] = mergeJSON(baseObject[sBaseKey], value, sBaseKey)
+		}
+		return baseObject
+	case []interface{}:
+		baseList, isList := base.([]interface{})
+		if !isList || !strings.EqualFold(sKey, "Tasks") {
+			return overlay
+		}
+		for _, task := range overlayValue {
+			if taskIndex := indexOfTask(baseList, task); taskIndex >= 0 {
+				baseList[taskIndex] = mergeJSON(baseList[taskIndex], task, "")
+			} else {
+				baseList = append(baseList, task)
+			}
+		}
+		return baseList
+	}
+	return overlay
+}
+
+// indexOfTask returns the index of the task with the name of task in a list of tasks, -1 if
+// there is none
+//------------------------------------------------------------------------------
+func indexOfTask(tasks []interface{}, task interface{}) int {
+	sName := nameOfTask(task)
+	if len(sName) == 0 {
+		return -1
+	}
+	for taskIndex, candidate := range tasks {
+		if nameOfTask(candidate) == sName {
+			return taskIndex
+		}
+	}
+	return -1
+}
+
+// nameOfTask returns the Name of a task object, empty if it has none
+//------------------------------------------------------------------------------
+func nameOfTask(task interface{}) string {
+	taskObject, _ := task.(map[string]interface{})
+	for sKey, value := range taskObject {
+		if sName, isString := value.(string); isString && strings.EqualFold(sKey, "Name") {
+			return sName
+		}
+	}
+	return ""
+}
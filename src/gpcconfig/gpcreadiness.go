@@ -0,0 +1,15 @@
+package gpcconfig
+
+import "time"
+
+// How long a rolling restart waits for a replica without ReadinessTimeout
+const defReadinessTimeout = 60 * time.Second
+
+//ReadinessWait returns how long a rolling restart waits for the task to get ready
+//#########################################################
+func (p *ProcessConfig) ReadinessWait() time.Duration {
+	if p.ReadinessTimeout.Duration > 0 {
+		return p.ReadinessTimeout.Duration
+	}
+	return defReadinessTimeout
+}
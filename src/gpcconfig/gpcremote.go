@@ -0,0 +1,167 @@
+package gpcconfig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// consts of remote configurations
+const (
+	remoteFetchTimeout = 30 * time.Second
+	remoteMaxSize      = 10 << 20 // bytes, a configuration is far smaller
+)
+
+// Options of remote configurations, see SetRemoteOptions
+var gRemoteHeaders = http.Header{}
+var gRemoteCachePath string
+var gRemoteMux sync.Mutex
+var gRemoteFallback error // why the last load used the cached copy, nil if it was fetched
+
+//IsRemote reports whether a configuration path is an URL, which is fetched instead of read
+//#########################################################
+func IsRemote(sConfigFilePath string) bool {
+	sLower := strings.ToLower(sConfigFilePath)
+	return strings.HasPrefix(sLower, "https://") || strings.HasPrefix(sLower, "http://")
+}
+
+//SetRemoteOptions sets the request headers of remote configurations, "Name: value" each, where
+//$VAR and ${VAR} in the value are taken from the environment, e.g. "Authorization: Bearer ${TOKEN}".
+//sCachePath is where the last good copy is kept, empty => in the user cache folder.
+//#########################################################
+func SetRemoteOptions(headers []string, sCachePath string) error {
+	gRemoteMux.Lock()
+	defer gRemoteMux.Unlock()
+
+	gRemoteHeaders = http.Header{}
+	for _, sHeader := range headers {
+		sName, sValue, found := strings.Cut(sHeader, ":")
+		if !found || len(strings.TrimSpace(sName)) == 0 {
+			return fmt.Errorf("invalid header <%s>, use \"Name: value\"", sHeader)
+		}
+		gRemoteHeaders.Add(strings.TrimSpace(sName), os.ExpandEnv(strings.TrimSpace(sValue)))
+	}
+	gRemoteCachePath = sCachePath
+	return nil
+}
+
+//RemoteFallback returns why the last load of a remote configuration used the cached copy,
+//nil if it was fetched or the configuration is a file
+//#########################################################
+func RemoteFallback() error {
+	gRemoteMux.Lock()
+	defer gRemoteMux.Unlock()
+	return gRemoteFallback
+}
+
+//FetchRemoteConfig downloads a remote configuration as it is, without falling back to the cache
+//#########################################################
+func FetchRemoteConfig(sURL string) ([]byte, error) {
+	if strings.HasPrefix(strings.ToLower(sURL), "http://") {
+		return nil, fmt.Errorf("remote configuration <%s> must use https", sURL)
+	}
+	request, err := http.NewRequest(http.MethodGet, sURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	gRemoteMux.Lock()
+	request.Header = gRemoteHeaders.Clone()
+	gRemoteMux.Unlock()
+
+	client := &http.Client{Timeout: remoteFetchTimeout}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote configuration <%s>: %s", sURL, response.Status)
+	}
+	configBytes, err := io.ReadAll(io.LimitReader(response.Body, remoteMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(configBytes) > remoteMaxSize {
+		return nil, fmt.Errorf("remote configuration <%s> is larger than %d bytes", sURL, remoteMaxSize)
+	}
+	return configBytes, nil
+}
+
+// readRemoteConfig fetches a remote configuration, the cached copy if the remote is unreachable.
+// Returns the format of the returned bytes, the cache is always JSON.
+//------------------------------------------------------------------------------
+func readRemoteConfig(sURL string) ([]byte, string, error) {
+	configBytes, err := FetchRemoteConfig(sURL)
+	gRemoteMux.Lock()
+	defer gRemoteMux.Unlock()
+	gRemoteFallback = nil
+	if err == nil {
+		return configBytes, fileFormat(sURL), nil
+	}
+
+	cacheBytes, cacheErr := os.ReadFile(remoteCachePath(sURL))
+	if cacheErr != nil {
+		return nil, "", fmt.Errorf("Can't fetch config: %s (no cached copy: %s)", err.Error(), cacheErr.Error())
+	}
+	gRemoteFallback = err
+	return cacheBytes, FormatJSON, nil
+}
+
+// storeRemoteCache keeps a remote configuration that was fetched and found valid as the copy
+// to fall back to. It is written as JSON, unless the load used the cache anyway.
+//------------------------------------------------------------------------------
+func storeRemoteCache(sURL string, jsonBytes []byte) error {
+	gRemoteMux.Lock()
+	defer gRemoteMux.Unlock()
+	if gRemoteFallback != nil {
+		return nil
+	}
+
+	sCachePath := remoteCachePath(sURL)
+	if cacheBytes, err := os.ReadFile(sCachePath); err == nil && bytes.Equal(cacheBytes, jsonBytes) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(sCachePath), 0700); err != nil {
+		return fmt.Errorf("Can not create cache folder: %s", err.Error())
+	}
+	// the configuration may hold credentials
+	if err := os.WriteFile(sCachePath+".tmp", jsonBytes, 0600); err != nil {
+		return fmt.Errorf("Can not write cached configuration: %s", err.Error())
+	}
+	return os.Rename(sCachePath+".tmp", sCachePath)
+}
+
+// remoteCachePath returns the cache file of a remote configuration. The caller must hold
+// gRemoteMux.
+//------------------------------------------------------------------------------
+func remoteCachePath(sURL string) string {
+	if len(gRemoteCachePath) > 0 {
+		return gRemoteCachePath
+	}
+	sCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		sCacheDir = os.TempDir()
+	}
+	urlHash := sha256.Sum256([]byte(sURL))
+	return filepath.Join(sCacheDir, "gpc", "config-"+hex.EncodeToString(urlHash[:8])+".json")
+}
+
+// remoteExt returns the extension of the file name of an URL, without query
+//------------------------------------------------------------------------------
+func remoteExt(sURL string) string {
+	parsedURL, err := url.Parse(sURL)
+	if err != nil {
+		return ""
+	}
+	return path.Ext(parsedURL.Path)
+}
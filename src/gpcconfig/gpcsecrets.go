@@ -0,0 +1,282 @@
+package gpcconfig
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// prefixes of encrypted values, followed by the base64 encoded ciphertext
+const (
+	SecretPrefixAES   = "enc:aes:"   // AES-256-GCM with the key of Secrets.KeyFile, nonce before the ciphertext
+	SecretPrefixDPAPI = "enc:dpapi:" // Windows DPAPI for the local machine
+)
+
+// the plain text of all values decrypted by DecryptSecrets, for RedactSecrets
+var gSecretValues = make(map[string]bool)
+var gSecretValuesMux sync.Mutex
+
+// SecretsConfig is how encrypted values of the configuration are decrypted
+type SecretsConfig struct {
+	KeyFile string // file with the hex encoded 32 byte key of "enc:aes:" values, see GenerateKeyFile
+}
+
+//GenerateKeyFile writes a new random key for encrypted values to a file only the owner can read
+//#########################################################
+func GenerateKeyFile(sKeyFile string) error {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	sKey := hex.EncodeToString(key) + "\n"
+	// O_EXCL: an existing key may still be needed to decrypt values
+	keyFile, err := os.OpenFile(sKeyFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("Can not create key file: %s", err.Error())
+	}
+	if _, err = keyFile.WriteString(sKey); err != nil {
+		keyFile.Close()
+		return fmt.Errorf("Can not write key file: %s", err.Error())
+	}
+	return keyFile.Close()
+}
+
+//EncryptSecret encrypts a value for the configuration. With a key file the value is encrypted
+//with AES, without one by DPAPI, which only works on Windows and only on this machine.
+//#########################################################
+func EncryptSecret(sValue string, sKeyFile string) (string, error) {
+	if len(sKeyFile) == 0 {
+		sealed, err := protectData([]byte(sValue))
+		if err != nil {
+			return "", err
+		}
+		return SecretPrefixDPAPI + base64.StdEncoding.EncodeToString(sealed), nil
+	}
+
+	aead, err := secretCipher(sKeyFile)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(sValue), nil)
+	return SecretPrefixAES + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// secretCipher reads a key file and returns the AES-GCM cipher of the key
+//------------------------------------------------------------------------------
+func secretCipher(sKeyFile string) (cipher.AEAD, error) {
+	keyBytes, err := os.ReadFile(sKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Can not read key file: %s", err.Error())
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(keyBytes)))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("key file <%s> must hold 64 hex digits", sKeyFile)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decryptSecret returns the plain text of an encrypted value, other values as they are
+//------------------------------------------------------------------------------
+func decryptSecret(sValue string, aead cipher.AEAD) (string, error) {
+	switch {
+	case strings.HasPrefix(sValue, SecretPrefixAES):
+		sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sValue, SecretPrefixAES))
+		if err != nil {
+			return "", fmt.Errorf("invalid base64: %s", err.Error())
+		}
+		if aead == nil {
+			return "", fmt.Errorf("Secrets.KeyFile is not set")
+		}
+		if len(sealed) < aead.NonceSize() {
+			return "", fmt.Errorf("value is too short")
+		}
+		plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+		if err != nil {
+			return "", fmt.Errorf("wrong key or damaged value")
+		}
+		return string(plain), nil
+	case strings.HasPrefix(sValue, SecretPrefixDPAPI):
+		sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sValue, SecretPrefixDPAPI))
+		if err != nil {
+			return "", fmt.Errorf("invalid base64: %s", err.Error())
+		}
+		plain, err := unprotectData(sealed)
+		if err != nil {
+			return "", err
+		}
+		return string(plain), nil
+	}
+	return sValue, nil
+}
+
+//DecryptSecrets replaces all encrypted values of a configuration by their plain text, in any
+//setting and list or map of strings. The key file is only read if there are AES values. The
+//encrypted values are kept for EncryptedCopy.
+//#########################################################
+func (c *ConfigData) DecryptSecrets() error {
+	var aead cipher.AEAD
+	var problems []error
+	encryptedValues := make(map[string]string)
+	walkStrings(reflect.ValueOf(c).Elem(), "", func(value reflect.Value, sPath string) {
+		sValue := value.String()
+		if !strings.HasPrefix(sValue, SecretPrefixAES) && !strings.HasPrefix(sValue, SecretPrefixDPAPI) {
+			return
+		}
+		if aead == nil && strings.HasPrefix(sValue, SecretPrefixAES) && len(c.Secrets.KeyFile) > 0 {
+			var err error
+			if aead, err = secretCipher(c.Secrets.KeyFile); err != nil {
+				problems = append(problems, fmt.Errorf("Secrets: %s", err.Error()))
+				return
+			}
+		}
+		sPlain, err := decryptSecret(sValue, aead)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: can not decrypt: %s", sPath, err.Error()))
+			return
+		}
+		value.SetString(sPlain)
+		encryptedValues[sPath] = sValue
+		addSecretValue(sPlain)
+	})
+
+	// The values of a task go with it, tasks are added and removed at runtime
+	for sPath, sValue := range encryptedValues {
+		var taskIndex int
+		if _, err := fmt.Sscanf(sPath, "Tasks[%d].", &taskIndex); err == nil && taskIndex < len(c.Tasks) {
+			task := &c.Tasks[taskIndex]
+			if task.encryptedValues == nil {
+				task.encryptedValues = make(map[string]string)
+			}
+			task.encryptedValues[strings.TrimPrefix(sPath, fmt.Sprintf("Tasks[%d].", taskIndex))] = sValue
+			continue
+		}
+		if c.encryptedValues == nil {
+			c.encryptedValues = make(map[string]string)
+		}
+		c.encryptedValues[sPath] = sValue
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// addSecretValue remembers the plain text of a decrypted value, for RedactSecrets
+//------------------------------------------------------------------------------
+func addSecretValue(sPlain string) {
+	if len(sPlain) == 0 {
+		return
+	}
+	gSecretValuesMux.Lock()
+	defer gSecretValuesMux.Unlock()
+	gSecretValues[sPlain] = true
+}
+
+//RedactSecrets replaces the plain text of every value decrypted by DecryptSecrets in a text,
+//whatever setting it came from, e.g. for logs of the environment and arguments of a process
+//#########################################################
+func RedactSecrets(sText string, sRedacted string) string {
+	gSecretValuesMux.Lock()
+	secrets := make([]string, 0, len(gSecretValues))
+	for sSecret := range gSecretValues {
+		secrets = append(secrets, sSecret)
+	}
+	gSecretValuesMux.Unlock()
+
+	// The longest first, a secret may contain a shorter one
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	for _, sSecret := range secrets {
+		sText = strings.ReplaceAll(sText, sSecret, sRedacted)
+	}
+	return sText
+}
+
+//EncryptedCopy returns a deep copy of a configuration with the values decrypted by
+//DecryptSecrets encrypted again as they were written, e.g. to store the active configuration.
+//Values of the tasks added at runtime are encrypted as they were given.
+//#########################################################
+func (c *ConfigData) EncryptedCopy() (ConfigData, error) {
+	var tCopy ConfigData
+	configBytes, err := json.Marshal(c)
+	if err == nil {
+		err = json.Unmarshal(configBytes, &tCopy)
+	}
+	if err != nil {
+		return tCopy, err
+	}
+
+	encryptedValues := make(map[string]string)
+	for sPath, sValue := range c.encryptedValues {
+		encryptedValues[sPath] = sValue
+	}
+	for taskIndex := range c.Tasks {
+		for sPath, sValue := range c.Tasks[taskIndex].encryptedValues {
+			encryptedValues[fmt.Sprintf("Tasks[%d].%s", taskIndex, sPath)] = sValue
+		}
+	}
+	walkStrings(reflect.ValueOf(&tCopy).Elem(), "", func(value reflect.Value, sPath string) {
+		if sValue, found := encryptedValues[sPath]; found {
+			value.SetString(sValue)
+		}
+	})
+	return tCopy, nil
+}
+
+// walkStrings calls visit for every settable string of a configuration value: settings, elements
+// of lists and values of maps, also within lists of structs. The path names the setting, e.g.
+// "Tasks[2].Env[DB_PASSWORD]".
+//------------------------------------------------------------------------------
+func walkStrings(value reflect.Value, sPath string, visit func(value reflect.Value, sPath string)) {
+	switch value.Kind() {
+	case reflect.String:
+		visit(value, sPath)
+	case reflect.Struct:
+		for fieldIndex := 0; fieldIndex < value.NumField(); fieldIndex++ {
+			field := value.Type().Field(fieldIndex)
+			if !field.IsExported() {
+				continue
+			}
+			if len(sPath) == 0 {
+				walkStrings(value.Field(fieldIndex), field.Name, visit)
+			} else {
+				walkStrings(value.Field(fieldIndex), sPath+"."+field.Name, visit)
+			}
+		}
+	case reflect.Slice:
+		if kind := value.Type().Elem().Kind(); kind != reflect.String && kind != reflect.Struct {
+			return
+		}
+		for elementIndex := 0; elementIndex < value.Len(); elementIndex++ {
+			walkStrings(value.Index(elementIndex), fmt.Sprintf("%s[%d]", sPath, elementIndex), visit)
+		}
+	case reflect.Map:
+		if value.Type().Elem().Kind() != reflect.String {
+			return
+		}
+		// map values can not be set in place
+		for _, key := range value.MapKeys() {
+			element := reflect.New(value.Type().Elem()).Elem()
+			element.Set(value.MapIndex(key))
+			walkStrings(element, fmt.Sprintf("%s[%v]", sPath, key.Interface()), visit)
+			value.SetMapIndex(key, element)
+		}
+	}
+}
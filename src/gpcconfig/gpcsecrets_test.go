@@ -0,0 +1,172 @@
+package gpcconfig
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestKeyFile generates a key file in the temporary folder of the test
+func newTestKeyFile(t *testing.T, sName string) string {
+	t.Helper()
+	sKeyFile := filepath.Join(t.TempDir(), sName)
+	if err := GenerateKeyFile(sKeyFile); err != nil {
+		t.Fatal(err)
+	}
+	return sKeyFile
+}
+
+// encryptTestSecret encrypts a value with AES or ends the test
+func encryptTestSecret(t *testing.T, sValue string, sKeyFile string) string {
+	t.Helper()
+	sEncrypted, err := EncryptSecret(sValue, sKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sEncrypted
+}
+
+func TestSecretRoundTrip(t *testing.T) {
+	sKeyFile := newTestKeyFile(t, "secret.key")
+	aead, err := secretCipher(sKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		sName  string
+		sValue string
+	}{
+		{"empty", ""},
+		{"password", "s3cr3t!"},
+		{"white space and unicode", " pass wört\t€ "},
+		{"looks encrypted", "enc:aes:not really"},
+		{"long", strings.Repeat("0123456789", 100)},
+	}
+	for _, test := range tests {
+		sEncrypted := encryptTestSecret(t, test.sValue, sKeyFile)
+		if !strings.HasPrefix(sEncrypted, SecretPrefixAES) {
+			t.Errorf("%s: <%s> does not start with %s", test.sName, sEncrypted, SecretPrefixAES)
+		}
+		if sAgain := encryptTestSecret(t, test.sValue, sKeyFile); sAgain == sEncrypted {
+			t.Errorf("%s: encrypting twice gave the same value, the nonce must differ", test.sName)
+		}
+		sPlain, err := decryptSecret(sEncrypted, aead)
+		if err != nil {
+			t.Errorf("%s: unexpected error <%s>", test.sName, err.Error())
+			continue
+		}
+		if sPlain != test.sValue {
+			t.Errorf("%s: got <%s>, expected <%s>", test.sName, sPlain, test.sValue)
+		}
+	}
+}
+
+func TestGenerateKeyFileKeepsExistingKey(t *testing.T) {
+	sKeyFile := newTestKeyFile(t, "secret.key")
+	if err := GenerateKeyFile(sKeyFile); err == nil {
+		t.Error("expected an error, an existing key file must not be replaced")
+	}
+}
+
+func TestDecryptSecretsErrors(t *testing.T) {
+	sKeyFile := newTestKeyFile(t, "secret.key")
+	sWrongKeyFile := newTestKeyFile(t, "wrong.key")
+	sEncrypted := encryptTestSecret(t, "s3cr3t!", sKeyFile)
+
+	tests := []struct {
+		sName    string
+		sKeyFile string
+		sValue   string
+		sProblem string
+	}{
+		{"wrong key", sWrongKeyFile, sEncrypted, "wrong key or damaged value"},
+		{"damaged value", sKeyFile, sEncrypted[:len(sEncrypted)-4] + "AAAA", "wrong key or damaged value"},
+		{"too short", sKeyFile, SecretPrefixAES + "AAAA", "value is too short"},
+		{"invalid base64", sKeyFile, SecretPrefixAES + "%%%", "invalid base64"},
+		{"no key file", "", sEncrypted, "Secrets.KeyFile is not set"},
+		{"missing key file", filepath.Join(t.TempDir(), "missing.key"), sEncrypted, "Can not read key file"},
+	}
+	for _, test := range tests {
+		tConfigData := ConfigData{Tasks: []ProcessConfig{{Name: "db", Env: map[string]string{"DB_PASSWORD": test.sValue}}}}
+		tConfigData.Secrets.KeyFile = test.sKeyFile
+		err := tConfigData.DecryptSecrets()
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("%s: expected a *ValidationError, got <%v>", test.sName, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.sProblem) {
+			t.Errorf("%s: <%s> does not tell <%s>", test.sName, err.Error(), test.sProblem)
+		}
+		if tConfigData.Tasks[0].Env["DB_PASSWORD"] != test.sValue {
+			t.Errorf("%s: a value that could not be decrypted must be kept as it is", test.sName)
+		}
+	}
+}
+
+func TestEncryptedCopy(t *testing.T) {
+	sKeyFile := newTestKeyFile(t, "secret.key")
+	sToken := encryptTestSecret(t, "api-token", sKeyFile)
+	sPassword := encryptTestSecret(t, "db-password", sKeyFile)
+	sArg := encryptTestSecret(t, "--key=abc", sKeyFile)
+
+	tConfigData := ConfigData{Tasks: []ProcessConfig{
+		{Name: "db", Env: map[string]string{"DB_PASSWORD": sPassword, "DB_USER": "admin"}},
+		{Name: "worker", StartPath: "worker", StartArgs: []string{"-v", sArg}},
+	}}
+	tConfigData.Secrets.KeyFile = sKeyFile
+	tConfigData.API.Token = sToken
+	if err := tConfigData.DecryptSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	if tConfigData.API.Token != "api-token" || tConfigData.Tasks[0].Env["DB_PASSWORD"] != "db-password" || tConfigData.Tasks[1].StartArgs[1] != "--key=abc" {
+		t.Fatalf("values were not decrypted: %+v", tConfigData)
+	}
+	if sRedacted := RedactSecrets("token api-token for db-password", "***"); sRedacted != "token *** for ***" {
+		t.Errorf("RedactSecrets: got <%s>", sRedacted)
+	}
+
+	tCopy, err := tConfigData.EncryptedCopy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		sName     string
+		sActual   string
+		sExpected string
+	}{
+		{"API.Token", tCopy.API.Token, sToken},
+		{"Tasks[0].Env[DB_PASSWORD]", tCopy.Tasks[0].Env["DB_PASSWORD"], sPassword},
+		{"Tasks[0].Env[DB_USER]", tCopy.Tasks[0].Env["DB_USER"], "admin"},
+		{"Tasks[1].StartArgs[0]", tCopy.Tasks[1].StartArgs[0], "-v"},
+		{"Tasks[1].StartArgs[1]", tCopy.Tasks[1].StartArgs[1], sArg},
+	}
+	for _, test := range tests {
+		if test.sActual != test.sExpected {
+			t.Errorf("%s: got <%s>, expected <%s>", test.sName, test.sActual, test.sExpected)
+		}
+	}
+	// the copy is deep, the configuration itself stays decrypted
+	if tConfigData.Tasks[0].Env["DB_PASSWORD"] != "db-password" || tConfigData.Tasks[1].StartArgs[1] != "--key=abc" {
+		t.Error("EncryptedCopy changed the configuration it copied")
+	}
+
+	// the values of a task go with it when the tasks before it are removed at runtime
+	tConfigData.Tasks = tConfigData.Tasks[1:]
+	if tCopy, err = tConfigData.EncryptedCopy(); err != nil {
+		t.Fatal(err)
+	}
+	if tCopy.Tasks[0].StartArgs[1] != sArg {
+		t.Errorf("Tasks[0].StartArgs[1] of the remaining task: got <%s>, expected <%s>", tCopy.Tasks[0].StartArgs[1], sArg)
+	}
+
+	// the copy decrypts again to the same values
+	if err = tCopy.DecryptSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	if tCopy.API.Token != "api-token" || tCopy.Tasks[0].StartArgs[1] != "--key=abc" {
+		t.Errorf("the copy did not decrypt to the values: %+v", tCopy)
+	}
+}
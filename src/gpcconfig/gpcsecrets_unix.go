@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package gpcconfig
+
+import "fmt"
+
+// protectData encrypts data with DPAPI, which only exists on Windows
+//------------------------------------------------------------------------------
+func protectData(data []byte) ([]byte, error) {
+	return nil, fmt.Errorf("DPAPI is only available on Windows, set Secrets.KeyFile")
+}
+
+// unprotectData decrypts data encrypted by DPAPI, which only exists on Windows
+//------------------------------------------------------------------------------
+func unprotectData(sealed []byte) ([]byte, error) {
+	return nil, fmt.Errorf("DPAPI values can only be decrypted on Windows")
+}
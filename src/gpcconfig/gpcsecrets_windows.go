@@ -0,0 +1,72 @@
+package gpcconfig
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// consts of CryptProtectData
+const (
+	cryptProtectUIForbidden  = 0x1
+	cryptProtectLocalMachine = 0x4 // any account of the machine can decrypt, e.g. the service as LocalSystem
+)
+
+var (
+	modcrypt32             = syscall.NewLazyDLL("crypt32.dll")
+	modkernel32            = syscall.NewLazyDLL("kernel32.dll")
+	procCryptProtectData   = modcrypt32.NewProc("CryptProtectData")
+	procCryptUnprotectData = modcrypt32.NewProc("CryptUnprotectData")
+	procLocalFree          = modkernel32.NewProc("LocalFree")
+)
+
+// dataBlob is the DATA_BLOB of the crypt32 API
+type dataBlob struct {
+	cbData uint32
+	pbData *byte
+}
+
+// newDataBlob returns a DATA_BLOB of data
+//------------------------------------------------------------------------------
+func newDataBlob(data []byte) *dataBlob {
+	if len(data) == 0 {
+		return &dataBlob{}
+	}
+	return &dataBlob{cbData: uint32(len(data)), pbData: &data[0]}
+}
+
+// bytes copies the data of a DATA_BLOB returned by crypt32 and frees it
+//------------------------------------------------------------------------------
+func (b *dataBlob) bytes() []byte {
+	if b.pbData == nil {
+		return nil
+	}
+	data := make([]byte, b.cbData)
+	copy(data, unsafe.Slice(b.pbData, b.cbData))
+	procLocalFree.Call(uintptr(unsafe.Pointer(b.pbData)))
+	return data
+}
+
+// protectData encrypts data with DPAPI for the local machine
+//------------------------------------------------------------------------------
+func protectData(data []byte) ([]byte, error) {
+	var out dataBlob
+	ret, _, err := procCryptProtectData.Call(uintptr(unsafe.Pointer(newDataBlob(data))), 0, 0, 0, 0,
+		cryptProtectUIForbidden|cryptProtectLocalMachine, uintptr(unsafe.Pointer(&out)))
+	if ret == 0 {
+		return nil, fmt.Errorf("CryptProtectData failed: %s", err.Error())
+	}
+	return out.bytes(), nil
+}
+
+// unprotectData decrypts data encrypted by DPAPI
+//------------------------------------------------------------------------------
+func unprotectData(sealed []byte) ([]byte, error) {
+	var out dataBlob
+	ret, _, err := procCryptUnprotectData.Call(uintptr(unsafe.Pointer(newDataBlob(sealed))), 0, 0, 0, 0,
+		cryptProtectUIForbidden, uintptr(unsafe.Pointer(&out)))
+	if ret == 0 {
+		return nil, fmt.Errorf("CryptUnprotectData failed: %s", err.Error())
+	}
+	return out.bytes(), nil
+}
@@ -0,0 +1,108 @@
+package gpcconfig
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// kinds of task filters
+const (
+	FilterTag  = "tag"
+	FilterName = "name"
+)
+
+//TaskFilter selects tasks by a tag or by name. The value may have the wildcards of path.Match,
+//e.g. "web-*". A name also matches the instances of a task with Instances.
+type TaskFilter struct {
+	Kind  string // FilterTag or FilterName
+	Value string
+}
+
+// filters of -only and -skip applied by LoadConfigFromFile, empty => all tasks
+var gOnlyFilters, gSkipFilters []TaskFilter
+
+//ParseTaskFilters parses a comma separated list of filters, e.g. "tag=batch,name=report"
+//#########################################################
+func ParseTaskFilters(sFilters string) (filters []TaskFilter, err error) {
+	for _, sFilter := range strings.Split(sFilters, ",") {
+		sFilter = strings.TrimSpace(sFilter)
+		if len(sFilter) == 0 {
+			continue
+		}
+		sKind, sValue, found := strings.Cut(sFilter, "=")
+		sKind = strings.ToLower(strings.TrimSpace(sKind))
+		if !found || (sKind != FilterTag && sKind != FilterName) {
+			return nil, fmt.Errorf("invalid task filter <%s>, use tag=<tag> or name=<name>", sFilter)
+		}
+		filter := TaskFilter{Kind: sKind, Value: strings.TrimSpace(sValue)}
+		if _, err := path.Match(filter.Value, ""); err != nil {
+			return nil, fmt.Errorf("invalid task filter <%s>: %s", sFilter, err.Error())
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+//SetTaskSelection sets the filters of -only and -skip. Configurations loaded by LoadConfigFromFile
+//keep the tasks matching one of the only filters, if there are any, and drop the ones matching
+//one of the skip filters.
+//#########################################################
+func SetTaskSelection(sOnlyFilters string, sSkipFilters string) (err error) {
+	if gOnlyFilters, err = ParseTaskFilters(sOnlyFilters); err != nil {
+		return err
+	}
+	gSkipFilters, err = ParseTaskFilters(sSkipFilters)
+	return err
+}
+
+//Matches reports whether a task is selected by the filter
+//#########################################################
+func (f TaskFilter) Matches(task *ProcessConfig) bool {
+	var candidates []string
+	if f.Kind == FilterTag {
+		candidates = task.Tags
+	} else {
+		candidates = []string{task.Name, task.Template}
+	}
+	for _, sCandidate := range candidates {
+		if matched, _ := path.Match(f.Value, sCandidate); matched && len(sCandidate) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+//IsEnabled reports whether a task is enabled, tasks without Enabled are
+//#########################################################
+func (p *ProcessConfig) IsEnabled() bool {
+	return p.Enabled == nil || *p.Enabled
+}
+
+// selectTasks drops the disabled tasks and the ones left out by the -only and -skip filters
+//------------------------------------------------------------------------------
+func (c *ConfigData) selectTasks() {
+	selectedTasks := make([]ProcessConfig, 0, len(c.Tasks))
+	for taskIndex := range c.Tasks {
+		task := &c.Tasks[taskIndex]
+		if !task.IsEnabled() || !matchesAny(gOnlyFilters, task, true) || matchesAny(gSkipFilters, task, false) {
+			continue
+		}
+		selectedTasks = append(selectedTasks, *task)
+	}
+	c.Tasks = selectedTasks
+}
+
+// matchesAny reports whether a task matches one of the filters, bEmpty if there are none
+//------------------------------------------------------------------------------
+func matchesAny(filters []TaskFilter, task *ProcessConfig, bEmpty bool) bool {
+	if len(filters) == 0 {
+		return bEmpty
+	}
+	for _, filter := range filters {
+		if filter.Matches(task) {
+			return true
+		}
+	}
+	return false
+}
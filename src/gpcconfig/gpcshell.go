@@ -0,0 +1,102 @@
+package gpcconfig
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// shells of tasks with a Command
+const (
+	ShellCmd        = "cmd"
+	ShellPowerShell = "powershell"
+	ShellPwsh       = "pwsh"
+	ShellSh         = "sh"
+	ShellBash       = "bash"
+)
+
+//IsShellCommand reports whether the task runs a Command line through a shell instead of StartPath
+//#########################################################
+func (p *ProcessConfig) IsShellCommand() bool {
+	return len(p.Command) > 0
+}
+
+//ShellName returns the shell running the Command of the task: Shell, or cmd on Windows and sh elsewhere
+//#########################################################
+func (p *ProcessConfig) ShellName() string {
+	if len(p.Shell) > 0 {
+		return p.Shell
+	}
+	if runtime.GOOS == "windows" {
+		return ShellCmd
+	}
+	return ShellSh
+}
+
+//Executable returns the executable the task is started with: StartPath, or the shell of a Command
+//#########################################################
+func (p *ProcessConfig) Executable() string {
+	if !p.IsShellCommand() {
+		return p.StartPath
+	}
+	switch p.ShellName() {
+	case ShellCmd:
+		if comSpec := os.Getenv("ComSpec"); len(comSpec) > 0 {
+			return comSpec
+		}
+		return "cmd.exe"
+	case ShellPowerShell:
+		return "powershell.exe"
+	case ShellSh:
+		return "/bin/sh"
+	}
+	return p.Shell
+}
+
+//Arguments returns the arguments the executable of the task is started with: StartArgs, or
+//the options making the shell run the Command line and exit
+//#########################################################
+func (p *ProcessConfig) Arguments() []string {
+	if !p.IsShellCommand() {
+		return p.StartArgs
+	}
+	switch p.ShellName() {
+	case ShellCmd:
+		return []string{"/S", "/C", p.Command}
+	case ShellPowerShell, ShellPwsh:
+		return []string{"-NoProfile", "-NonInteractive", "-Command", p.Command}
+	}
+	return []string{"-c", p.Command}
+}
+
+//CheckShellCommands verifies that tasks with a Command name a known shell that exists on this
+//platform and do not set StartPath or StartArgs as well. Returns one error per problem found.
+//#########################################################
+func CheckShellCommands(tConfigData *ConfigData) (errs []error) {
+
+	for _, task := range tConfigData.Tasks {
+		if !task.IsShellCommand() {
+			if len(task.Shell) > 0 {
+				errs = append(errs, fmt.Errorf("task <%s>: Shell is only used with Command", task.Name))
+			}
+			continue
+		}
+		if len(task.StartPath) > 0 || len(task.StartArgs) > 0 {
+			errs = append(errs, fmt.Errorf("task <%s>: Command can not be used with StartPath/StartArgs", task.Name))
+		}
+		if len(task.ExpectedSHA256) > 0 {
+			errs = append(errs, fmt.Errorf("task <%s>: ExpectedSHA256 can not verify a Command, only executables", task.Name))
+		}
+		switch task.ShellName() {
+		case ShellCmd, ShellPowerShell:
+			if runtime.GOOS != "windows" {
+				errs = append(errs, fmt.Errorf("task <%s>: Shell <%s> only exists on Windows", task.Name, task.Shell))
+			}
+		case ShellPwsh, ShellSh, ShellBash:
+		default:
+			errs = append(errs, fmt.Errorf("task <%s>: unknown Shell <%s>, expected cmd, powershell, pwsh, sh or bash", task.Name, task.Shell))
+		}
+	}
+
+	return errs
+}
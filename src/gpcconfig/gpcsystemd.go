@@ -0,0 +1,272 @@
+package gpcconfig
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// settings exportSystemd maps to directives
+var systemdHandled = map[string]bool{
+	"StartPath": true, "StartArgs": true, "Command": true, "Shell": true, "StartDelay": true,
+	"MaxRestarts": true, "CrashLoopRestarts": true, "CrashLoopWindow": true, "WaitForExitTimeout": true,
+	"StopPath": true, "StopArgs": true, "StopGracePeriod": true, "DependsOn": true, "Schedule": true,
+	"OverlapPolicy": true, "Timezone": true, "OnSuccess": true, "OnFailure": true, "Retries": true,
+	"RetryDelay": true, "RetryOn": true, "MemoryLimitMB": true, "MemoryLimitAction": true, "Priority": true,
+	"StandbyFor": true, "StandbyMode": true, "RunAsUser": true, "Env": true, "Locale": true, "GPUs": true,
+	"HideWindow": true, "SuccessExitCodes": true, "NoRestartExitCodes": true,
+}
+
+// nice values of the Priority classes
+var systemdNice = map[string]int{PriorityIdle: 19, PriorityBelowNormal: 10, PriorityHigh: -10}
+
+var systemdWeekdays = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// the CrashLoopWindow the controller uses for tasks that do not set one
+const systemdCrashLoopWindow = 10 * time.Minute
+
+// exportSystemd renders a task as service unit, with a timer unit if it is scheduled or
+// delayed. Tasks started by other tasks get no [Install] section, the other units start them
+// with OnSuccess=/OnFailure=.
+//------------------------------------------------------------------------------
+func exportSystemd(c *ConfigData, task *ProcessConfig, sWorkDir string, files map[string][]byte) (notes []string, err error) {
+	sUnit := exportName(task.Name)
+	bWait := task.WaitForExitTimeout.Duration > 0
+	bTimer := len(task.Schedule) > 0 || task.StartDelay.Duration > 0
+
+	var service strings.Builder
+	fmt.Fprintf(&service, "[Unit]\nDescription=%s (exported from go-process-controller)\n", task.Name)
+	for _, sDependency := range task.DependsOn {
+		fmt.Fprintf(&service, "Requires=%s.service\nAfter=%s.service\n", exportName(sDependency), exportName(sDependency))
+	}
+	for _, sFollowUp := range task.OnSuccess {
+		fmt.Fprintf(&service, "OnSuccess=%s.service\n", exportName(sFollowUp))
+	}
+	for _, sFollowUp := range task.OnFailure {
+		fmt.Fprintf(&service, "OnFailure=%s.service\n", exportName(sFollowUp))
+	}
+	for _, standby := range c.Tasks {
+		if standby.StandbyFor == task.Name && standby.IsColdStandby() {
+			fmt.Fprintf(&service, "OnFailure=%s.service\n", exportName(standby.Name))
+		}
+	}
+
+	sRestart := "no"
+	switch {
+	case !bWait && task.CrashLoopRestarts > 0:
+		sRestart = "always"
+		crashLoopWindow := task.CrashLoopWindow.Duration
+		if crashLoopWindow == 0 {
+			crashLoopWindow = systemdCrashLoopWindow
+		}
+		fmt.Fprintf(&service, "StartLimitIntervalSec=%s\nStartLimitBurst=%d\n", systemdDuration(crashLoopWindow), task.CrashLoopRestarts+1)
+		if task.MaxRestarts > 0 {
+			notes = append(notes, "MaxRestarts is not exported, systemd limits the restarts within CrashLoopWindow only")
+		}
+	case !bWait && task.MaxRestarts > 0:
+		sRestart = "always"
+		fmt.Fprintf(&service, "StartLimitIntervalSec=infinity\nStartLimitBurst=%d\n", task.MaxRestarts+1)
+	case bWait && task.Retries > 0:
+		sRestart = "on-failure"
+		// the time a run with all its retries can take, later failures belong to the next run
+		runTime := time.Duration(task.Retries+1) * (task.WaitForExitTimeout.Duration + task.RetryDelay.Duration)
+		fmt.Fprintf(&service, "StartLimitIntervalSec=%s\nStartLimitBurst=%d\n", systemdDuration(runTime), task.Retries+1)
+	}
+
+	service.WriteString("\n[Service]\n")
+	if bWait {
+		fmt.Fprintf(&service, "Type=oneshot\nTimeoutStartSec=%s\n", systemdDuration(task.WaitForExitTimeout.Duration))
+	} else {
+		service.WriteString("Type=simple\n")
+	}
+	fmt.Fprintf(&service, "WorkingDirectory=%s\n", strings.ReplaceAll(sWorkDir, "%", "%%"))
+	sExecutable, args := exportCommand(task, ShellSh)
+	fmt.Fprintf(&service, "ExecStart=%s\n", systemdCommandLine(sExecutable, args))
+	if len(task.StopPath) > 0 {
+		fmt.Fprintf(&service, "ExecStop=%s\n", systemdCommandLine(task.StopPath, task.StopArgs))
+	}
+	fmt.Fprintf(&service, "TimeoutStopSec=%s\n", systemdDuration(task.GracePeriod()))
+	if sRestart == "on-failure" && len(task.RetryOn) > 0 {
+		// only the exit codes of RetryOn are restarted
+		sRestart = "no"
+		fmt.Fprintf(&service, "RestartForceExitStatus=%s\n", systemdExitCodes(task.RetryOn))
+	}
+	fmt.Fprintf(&service, "Restart=%s\n", sRestart)
+	// Restart=always restarts clean exits as well, the codes are listed as preventing it
+	if len(task.SuccessExitCodes) > 0 {
+		fmt.Fprintf(&service, "SuccessExitStatus=%s\n", systemdExitCodes(task.SuccessExitCodes))
+	}
+	if preventCodes := append(append([]int(nil), task.SuccessExitCodes...), task.NoRestartExitCodes...); len(preventCodes) > 0 && sRestart != "no" {
+		fmt.Fprintf(&service, "RestartPreventExitStatus=%s\n", systemdExitCodes(preventCodes))
+	}
+	if bWait && task.Retries > 0 && task.RetryDelay.Duration > 0 {
+		fmt.Fprintf(&service, "RestartSec=%s\n", systemdDuration(task.RetryDelay.Duration))
+	}
+	if len(task.RunAsUser) > 0 {
+		fmt.Fprintf(&service, "User=%s\n", task.RunAsUser)
+	}
+	for _, sVariable := range systemdEnvironment(task) {
+		fmt.Fprintf(&service, "Environment=%s\n", systemdQuote(sVariable))
+	}
+	if nice, found := systemdNice[task.Priority]; found {
+		fmt.Fprintf(&service, "Nice=%d\n", nice)
+	}
+	if task.MemoryLimitMB > 0 {
+		if task.MemoryLimitAction == "" || task.MemoryLimitAction == MemoryActionRestart {
+			fmt.Fprintf(&service, "MemoryMax=%dM\n", task.MemoryLimitMB)
+		} else {
+			notes = append(notes, fmt.Sprintf("MemoryLimitAction <%s> is not exported, systemd can only kill at MemoryMax", task.MemoryLimitAction))
+		}
+	}
+	if !bTimer && !c.IsFollowUpTask(task.Name) && !task.IsColdStandby() {
+		service.WriteString("\n[Install]\nWantedBy=multi-user.target\n")
+	}
+	files[sUnit+".service"] = []byte(service.String())
+
+	if task.OverlapPolicy == OverlapQueue || task.OverlapPolicy == OverlapKill {
+		notes = append(notes, fmt.Sprintf("OverlapPolicy <%s> is not exported, a timer does not start a unit that is still active", task.OverlapPolicy))
+	}
+	if bTimer {
+		var timer strings.Builder
+		fmt.Fprintf(&timer, "[Unit]\nDescription=Starts %s (exported from go-process-controller)\n\n[Timer]\n", task.Name)
+		if len(task.Schedule) > 0 {
+			schedule, err := ParseSchedule(task.Schedule)
+			if err != nil {
+				return notes, err
+			}
+			for _, sCalendar := range systemdCalendars(schedule, task.Timezone) {
+				fmt.Fprintf(&timer, "OnCalendar=%s\n", sCalendar)
+			}
+			timer.WriteString("AccuracySec=1s\n")
+		} else {
+			fmt.Fprintf(&timer, "OnStartupSec=%s\n", systemdDuration(task.StartDelay.Duration))
+		}
+		timer.WriteString("\n[Install]\nWantedBy=timers.target\n")
+		files[sUnit+".timer"] = []byte(timer.String())
+	}
+
+	return append(notes, unexportedSettings(task, systemdHandled)...), nil
+}
+
+// systemdEnvironment returns the variables a task sets as "NAME=value", in order
+//------------------------------------------------------------------------------
+func systemdEnvironment(task *ProcessConfig) (variables []string) {
+	for sName, sValue := range task.Env {
+		variables = append(variables, sName+"="+sValue)
+	}
+	if len(task.Locale) > 0 {
+		variables = append(variables, "LANG="+task.Locale, "LC_ALL="+task.Locale)
+	}
+	if len(task.GPUs) > 0 {
+		var gpus []string
+		for _, gpu := range task.GPUs {
+			gpus = append(gpus, strconv.Itoa(gpu))
+		}
+		variables = append(variables, "CUDA_VISIBLE_DEVICES="+strings.Join(gpus, ","))
+	}
+	sort.Strings(variables)
+	return variables
+}
+
+// systemdCalendars converts a cron schedule to OnCalendar expressions. A day matching day of
+// month OR day of week, as in cron, needs one expression each.
+//------------------------------------------------------------------------------
+func systemdCalendars(schedule *Schedule, sTimezone string) (calendars []string) {
+	sTime := systemdList(schedule.hour, 0, 23, nil) + ":" + systemdList(schedule.minute, 0, 59, nil) + ":00"
+	sMonth := systemdList(schedule.month, 1, 12, nil)
+	sWeekdays := systemdList(schedule.dayOfWeek, 0, 6, systemdWeekdays)
+	sDays := systemdList(schedule.dayOfMonth, 1, 31, nil)
+	switch {
+	case schedule.domAny && schedule.dowAny:
+		calendars = []string{"*-" + sMonth + "-* " + sTime}
+	case schedule.domAny:
+		calendars = []string{sWeekdays + " *-" + sMonth + "-* " + sTime}
+	case schedule.dowAny:
+		calendars = []string{"*-" + sMonth + "-" + sDays + " " + sTime}
+	default:
+		calendars = []string{"*-" + sMonth + "-" + sDays + " " + sTime, sWeekdays + " *-" + sMonth + "-* " + sTime}
+	}
+	if len(sTimezone) > 0 {
+		for calendarIndex := range calendars {
+			calendars[calendarIndex] += " " + sTimezone
+		}
+	}
+	return calendars
+}
+
+// systemdList writes the values of a cron field as calendar list: "*" for all values, runs of
+// three and more as range "a..b", names instead of numbers if given
+//------------------------------------------------------------------------------
+func systemdList(bits uint64, minValue int, maxValue int, names []string) string {
+	values := scheduleValues(bits, minValue, maxValue)
+	if len(values) == maxValue-minValue+1 {
+		return "*"
+	}
+	valueName := func(value int) string {
+		if names != nil {
+			return names[value]
+		}
+		return fmt.Sprintf("%02d", value)
+	}
+
+	var parts []string
+	for startIndex := 0; startIndex < len(values); {
+		endIndex := startIndex
+		for endIndex+1 < len(values) && values[endIndex+1] == values[endIndex]+1 {
+			endIndex++
+		}
+		switch {
+		case endIndex-startIndex >= 2:
+			parts = append(parts, valueName(values[startIndex])+".."+valueName(values[endIndex]))
+		case endIndex > startIndex:
+			parts = append(parts, valueName(values[startIndex]), valueName(values[endIndex]))
+		default:
+			parts = append(parts, valueName(values[startIndex]))
+		}
+		startIndex = endIndex + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// systemdDuration writes a duration as systemd time span
+//------------------------------------------------------------------------------
+func systemdDuration(duration time.Duration) string {
+	if duration%time.Second != 0 {
+		return fmt.Sprintf("%dms", duration.Milliseconds())
+	}
+	return fmt.Sprintf("%ds", int64(duration/time.Second))
+}
+
+// systemdCommandLine writes executable and arguments for ExecStart=/ExecStop=
+//------------------------------------------------------------------------------
+func systemdCommandLine(sExecutable string, args []string) string {
+	// "$" would be replaced by a variable of the environment
+	quoted := []string{systemdQuote(strings.ReplaceAll(sExecutable, "$", "$$"))}
+	for _, sArg := range args {
+		quoted = append(quoted, systemdQuote(strings.ReplaceAll(sArg, "$", "$$")))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// systemdQuote quotes a word of a unit file if needed, "%" is doubled as systemd would
+// replace a specifier otherwise
+//------------------------------------------------------------------------------
+func systemdQuote(sWord string) string {
+	sWord = strings.ReplaceAll(sWord, "%", "%%")
+	if len(sWord) > 0 && !strings.ContainsAny(sWord, " \t\"'\\;") {
+		return sWord
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(sWord) + `"`
+}
+
+// systemdExitCodes returns exit codes as list of a directive, e.g. "0 3"
+//------------------------------------------------------------------------------
+func systemdExitCodes(exitCodes []int) string {
+	sExitCodes := make([]string, 0, len(exitCodes))
+	for _, exitCode := range exitCodes {
+		sExitCodes = append(sExitCodes, strconv.Itoa(exitCode))
+	}
+	return strings.Join(sExitCodes, " ")
+}
@@ -0,0 +1,374 @@
+package gpcconfig
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// settings exportTaskScheduler maps to the task definition
+var taskSchedulerHandled = map[string]bool{
+	"StartPath": true, "StartArgs": true, "Command": true, "Shell": true, "StartDelay": true,
+	"MaxRestarts": true, "WaitForExitTimeout": true, "Schedule": true, "Timezone": true, "OverlapPolicy": true,
+	"Retries": true, "RetryDelay": true, "Priority": true, "RunAsUser": true, "RunAsPassword": true,
+	"RunAsPasswordEnv": true, "HideWindow": true,
+}
+
+// task priorities of the Priority classes, 7 is the default of Task Scheduler
+var taskSchedulerPriority = map[string]int{PriorityIdle: 10, PriorityBelowNormal: 7, PriorityNormal: 5, PriorityHigh: 1}
+
+// MultipleInstancesPolicy of the OverlapPolicy values
+var taskSchedulerOverlap = map[string]string{"": "IgnoreNew", OverlapSkip: "IgnoreNew", OverlapQueue: "Queue", OverlapKill: "StopExisting"}
+
+var taskSchedulerWeekdays = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+var taskSchedulerMonths = []string{"", "January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+
+// limits of Task Scheduler
+const (
+	taskSchedulerMaxTriggers     = 48          // start times per day expanded into triggers
+	taskSchedulerMinRestartDelay = time.Minute // RestartOnFailure interval
+	taskSchedulerMaxRestarts     = 999         // RestartOnFailure count
+)
+
+// taskDefinition is the XML of a task of Task Scheduler, schema version 1.2
+type taskDefinition struct {
+	XMLName          xml.Name `xml:"Task"`
+	Version          string   `xml:"version,attr"`
+	Namespace        string   `xml:"xmlns,attr"`
+	RegistrationInfo struct {
+		Description string
+		URI         string
+	}
+	Triggers struct {
+		BootTrigger     *taskBootTrigger
+		CalendarTrigger []taskCalendarTrigger
+	}
+	Principals struct {
+		Principal struct {
+			ID        string `xml:"id,attr"`
+			UserID    string `xml:"UserId"`
+			LogonType string `xml:",omitempty"`
+			RunLevel  string
+		}
+	}
+	Settings struct {
+		MultipleInstancesPolicy    string
+		DisallowStartIfOnBatteries bool
+		StopIfGoingOnBatteries     bool
+		AllowStartOnDemand         bool
+		Enabled                    bool
+		ExecutionTimeLimit         string
+		Priority                   int
+		RestartOnFailure           *struct {
+			Interval string
+			Count    uint32
+		}
+	}
+	Actions struct {
+		Context string `xml:",attr"`
+		Exec    struct {
+			Command          string
+			Arguments        string `xml:",omitempty"`
+			WorkingDirectory string
+		}
+	}
+}
+
+// taskBootTrigger starts a task when the machine starts
+type taskBootTrigger struct {
+	Enabled bool
+	Delay   string `xml:",omitempty"`
+}
+
+// taskCalendarTrigger starts a task at a time of the days of one of the Schedule elements
+type taskCalendarTrigger struct {
+	Repetition *struct {
+		Interval string
+		Duration string
+	}
+	StartBoundary      string
+	Enabled            bool
+	ScheduleByDay      *struct{ DaysInterval int }
+	ScheduleByWeek     *taskScheduleByWeek
+	ScheduleByMonth    *taskScheduleByMonth
+	ScheduleByMonthDoW *taskScheduleByMonthDayOfWeek `xml:"ScheduleByMonthDayOfWeek"`
+}
+
+type taskScheduleByWeek struct {
+	WeeksInterval int
+	DaysOfWeek    xmlFlags
+}
+
+type taskScheduleByMonth struct {
+	DaysOfMonth struct {
+		Day []int
+	}
+	Months xmlFlags
+}
+
+type taskScheduleByMonthDayOfWeek struct {
+	Weeks struct {
+		Week []string
+	}
+	DaysOfWeek xmlFlags
+	Months     xmlFlags
+}
+
+// xmlFlags is an element with an empty element for each value, e.g. <DaysOfWeek><Monday/></DaysOfWeek>
+type xmlFlags []string
+
+//MarshalXML writes the values as empty elements
+//#########################################################
+func (f xmlFlags) MarshalXML(encoder *xml.Encoder, start xml.StartElement) error {
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, sFlag := range f {
+		if err := encoder.EncodeElement("", xml.StartElement{Name: xml.Name{Local: sFlag}}); err != nil {
+			return err
+		}
+	}
+	return encoder.EncodeToken(start.End())
+}
+
+// exportTaskScheduler renders a task as XML definition of Task Scheduler, in UTF-16 as
+// schtasks expects it. Tasks are started at boot or by calendar triggers, tasks started by
+// other tasks get no trigger.
+//------------------------------------------------------------------------------
+func exportTaskScheduler(c *ConfigData, task *ProcessConfig, sWorkDir string, files map[string][]byte) (notes []string, err error) {
+	bWait := task.WaitForExitTimeout.Duration > 0
+	definition := taskDefinition{Version: "1.2", Namespace: "http://schemas.microsoft.com/windows/2004/02/mit/task"}
+	definition.RegistrationInfo.Description = task.Name + " (exported from go-process-controller)"
+	definition.RegistrationInfo.URI = `\gpc\` + exportName(task.Name)
+
+	switch {
+	case c.IsFollowUpTask(task.Name) || task.IsColdStandby():
+		notes = append(notes, "has no trigger, it is started by another task in the controller")
+	case len(task.Schedule) > 0:
+		schedule, err := ParseSchedule(task.Schedule)
+		if err != nil {
+			return notes, err
+		}
+		triggers, bExported := taskSchedulerTriggers(schedule)
+		if !bExported {
+			return append(notes, fmt.Sprintf("schedule <%s> has more than %d start times a day and is not exported", task.Schedule, taskSchedulerMaxTriggers)), nil
+		}
+		definition.Triggers.CalendarTrigger = triggers
+		if len(task.Timezone) > 0 {
+			notes = append(notes, fmt.Sprintf("Timezone <%s> is not exported, the schedule is in the time of the machine", task.Timezone))
+		}
+	default:
+		definition.Triggers.BootTrigger = &taskBootTrigger{Enabled: true}
+		if task.StartDelay.Duration > 0 {
+			definition.Triggers.BootTrigger.Delay = isoDuration(task.StartDelay.Duration)
+		}
+	}
+
+	principal := &definition.Principals.Principal
+	principal.ID = "Author"
+	principal.UserID = "S-1-5-18" // LocalSystem, the account of the controller service
+	principal.RunLevel = "HighestAvailable"
+	if len(task.RunAsUser) > 0 {
+		principal.UserID = task.RunAsUser
+		principal.LogonType = "Password"
+		principal.RunLevel = "LeastPrivilege"
+		notes = append(notes, fmt.Sprintf("register it with the password of <%s>: schtasks /create /xml <file> /tn %s /ru %s /rp", task.RunAsUser, definition.RegistrationInfo.URI, task.RunAsUser))
+	}
+
+	settings := &definition.Settings
+	settings.MultipleInstancesPolicy = taskSchedulerOverlap[task.OverlapPolicy]
+	settings.AllowStartOnDemand = true
+	settings.Enabled = true
+	settings.ExecutionTimeLimit = "PT0S"
+	if bWait {
+		settings.ExecutionTimeLimit = isoDuration(task.WaitForExitTimeout.Duration)
+	}
+	settings.Priority = taskSchedulerPriority[PriorityNormal]
+	if priority, found := taskSchedulerPriority[task.Priority]; found {
+		settings.Priority = priority
+	}
+	restarts, restartDelay := task.MaxRestarts, time.Duration(0)
+	if bWait {
+		restarts, restartDelay = task.Retries, task.RetryDelay.Duration
+	}
+	if restarts > 0 {
+		if restartDelay < taskSchedulerMinRestartDelay {
+			restartDelay = taskSchedulerMinRestartDelay
+		}
+		settings.RestartOnFailure = &struct {
+			Interval string
+			Count    uint32
+		}{Interval: isoDuration(restartDelay), Count: min(restarts, taskSchedulerMaxRestarts)}
+		notes = append(notes, fmt.Sprintf("restarted at most %d times, at least a minute apart, when Task Scheduler sees it fail", settings.RestartOnFailure.Count))
+	}
+
+	definition.Actions.Context = "Author"
+	sExecutable, args := exportCommand(task, ShellCmd)
+	definition.Actions.Exec.Command = sExecutable
+	var quotedArgs []string
+	for _, sArg := range args {
+		quotedArgs = append(quotedArgs, windowsQuote(sArg))
+	}
+	definition.Actions.Exec.Arguments = strings.Join(quotedArgs, " ")
+	definition.Actions.Exec.WorkingDirectory = sWorkDir
+
+	xmlBytes, err := xml.MarshalIndent(definition, "", "  ")
+	if err != nil {
+		return notes, err
+	}
+	files[exportName(task.Name)+".xml"] = utf16File(`<?xml version="1.0" encoding="UTF-16"?>` + "\r\n" + strings.ReplaceAll(string(xmlBytes), "\n", "\r\n") + "\r\n")
+
+	return append(notes, unexportedSettings(task, taskSchedulerHandled)...), nil
+}
+
+// taskSchedulerTriggers converts a cron schedule to calendar triggers, one per start time of
+// a day. Minutes with an even step ("*/15") become a trigger per hour repeated in that step,
+// or a single one if it is all day. Reports false if there are more start times than
+// triggers make sense for.
+//------------------------------------------------------------------------------
+func taskSchedulerTriggers(schedule *Schedule) (triggers []taskCalendarTrigger, bExported bool) {
+	hours := scheduleValues(schedule.hour, 0, 23)
+	minutes := scheduleValues(schedule.minute, 0, 59)
+	var startTimes []string
+	var repeatInterval, repeatSpan time.Duration
+	step := evenStep(minutes)
+	switch {
+	case step > 0 && len(hours) == 24:
+		startTimes, repeatInterval, repeatSpan = []string{fmt.Sprintf("2000-01-01T00:%02d:00", minutes[0])}, time.Duration(step)*time.Minute, 24*time.Hour
+	case step > 0 && len(hours) <= taskSchedulerMaxTriggers:
+		for _, hour := range hours {
+			startTimes = append(startTimes, fmt.Sprintf("2000-01-01T%02d:%02d:00", hour, minutes[0]))
+		}
+		repeatInterval, repeatSpan = time.Duration(step)*time.Minute, time.Hour
+	case len(hours)*len(minutes) <= taskSchedulerMaxTriggers:
+		for _, hour := range hours {
+			for _, minute := range minutes {
+				startTimes = append(startTimes, fmt.Sprintf("2000-01-01T%02d:%02d:00", hour, minute))
+			}
+		}
+	default:
+		return nil, false
+	}
+
+	var weekdays, months xmlFlags
+	for _, weekday := range scheduleValues(schedule.dayOfWeek, 0, 6) {
+		weekdays = append(weekdays, taskSchedulerWeekdays[weekday])
+	}
+	for _, month := range scheduleValues(schedule.month, 1, 12) {
+		months = append(months, taskSchedulerMonths[month])
+	}
+	bAllMonths := len(months) == 12
+
+	for _, sStartTime := range startTimes {
+		base := taskCalendarTrigger{StartBoundary: sStartTime, Enabled: true}
+		if repeatInterval > 0 {
+			// ends a minute after the last repetition, before the next trigger starts
+			base.Repetition = &struct {
+				Interval string
+				Duration string
+			}{Interval: isoDuration(repeatInterval), Duration: isoDuration(repeatSpan - repeatInterval + time.Minute)}
+		}
+		// as in cron a day matches by day of month OR day of week, each is a trigger of its own
+		if !schedule.domAny || schedule.dowAny {
+			trigger := base
+			switch {
+			case !schedule.domAny:
+				trigger.ScheduleByMonth = &taskScheduleByMonth{Months: months}
+				trigger.ScheduleByMonth.DaysOfMonth.Day = scheduleValues(schedule.dayOfMonth, 1, 31)
+			case bAllMonths:
+				trigger.ScheduleByDay = &struct{ DaysInterval int }{DaysInterval: 1}
+			default:
+				trigger.ScheduleByMonth = &taskScheduleByMonth{Months: months}
+				trigger.ScheduleByMonth.DaysOfMonth.Day = scheduleValues(^uint64(0), 1, 31)
+			}
+			triggers = append(triggers, trigger)
+		}
+		if !schedule.dowAny {
+			trigger := base
+			if bAllMonths {
+				trigger.ScheduleByWeek = &taskScheduleByWeek{WeeksInterval: 1, DaysOfWeek: weekdays}
+			} else {
+				trigger.ScheduleByMonthDoW = &taskScheduleByMonthDayOfWeek{DaysOfWeek: weekdays, Months: months}
+				trigger.ScheduleByMonthDoW.Weeks.Week = []string{"1", "2", "3", "4", "Last"}
+			}
+			triggers = append(triggers, trigger)
+		}
+	}
+	return triggers, true
+}
+
+// evenStep returns the step of minutes that repeat evenly all hour, e.g. 15 for 0,15,30,45,
+// zero if they do not
+//------------------------------------------------------------------------------
+func evenStep(minutes []int) int {
+	if len(minutes) < 2 || 60%len(minutes) != 0 {
+		return 0
+	}
+	step := 60 / len(minutes)
+	for minuteIndex, minute := range minutes {
+		if minute != minutes[0]+minuteIndex*step {
+			return 0
+		}
+	}
+	return step
+}
+
+// isoDuration writes a duration as ISO 8601 duration, e.g. "PT1H30M"
+//------------------------------------------------------------------------------
+func isoDuration(duration time.Duration) string {
+	seconds := int64((duration + time.Second - 1) / time.Second)
+	sDuration := "PT"
+	if hours := seconds / 3600; hours > 0 {
+		sDuration += fmt.Sprintf("%dH", hours)
+	}
+	if minutes := seconds % 3600 / 60; minutes > 0 {
+		sDuration += fmt.Sprintf("%dM", minutes)
+	}
+	if seconds%60 > 0 || seconds == 0 {
+		sDuration += fmt.Sprintf("%dS", seconds%60)
+	}
+	return sDuration
+}
+
+// windowsQuote quotes an argument the way the C runtime of Windows programs splits them
+//------------------------------------------------------------------------------
+func windowsQuote(sArg string) string {
+	if len(sArg) > 0 && !strings.ContainsAny(sArg, " \t\"") {
+		return sArg
+	}
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	backslashes := 0
+	for _, char := range sArg {
+		switch char {
+		case '\\':
+			backslashes++
+			continue
+		case '"':
+			// backslashes before a quote are escaped, and the quote itself
+			quoted.WriteString(strings.Repeat(`\`, 2*backslashes+1))
+		default:
+			quoted.WriteString(strings.Repeat(`\`, backslashes))
+		}
+		backslashes = 0
+		quoted.WriteRune(char)
+	}
+	quoted.WriteString(strings.Repeat(`\`, 2*backslashes))
+	quoted.WriteByte('"')
+	return quoted.String()
+}
+
+// utf16File encodes a text as UTF-16 little endian with byte order mark
+//------------------------------------------------------------------------------
+func utf16File(sText string) []byte {
+	var buffer bytes.Buffer
+	buffer.Write([]byte{0xFF, 0xFE})
+	for _, unit := range utf16.Encode([]rune(sText)) {
+		binary.Write(&buffer, binary.LittleEndian, unit)
+	}
+	return buffer.Bytes()
+}
@@ -0,0 +1,423 @@
+package gpcconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// ValidationError lists all problems found in a configuration, not only the first one
+type ValidationError struct {
+	Problems []error
+}
+
+// Error lists the problems one per line
+func (e *ValidationError) Error() string {
+	var sProblems []string
+	for _, problem := range e.Problems {
+		sProblems = append(sProblems, problem.Error())
+	}
+	return "invalid configuration:\n  " + strings.Join(sProblems, "\n  ")
+}
+
+// Unwrap makes errors.Is and errors.As see the single problems
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+// type of a JSON value whose keys are all known, e.g. the value of an unknown setting
+// joinValidationErrors merges the problems of *ValidationErrors into one, nil ones are left out
+//------------------------------------------------------------------------------
+func joinValidationErrors(errs ...error) error {
+	var problems []error
+	for _, err := range errs {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) && validationErr != nil {
+			problems = append(problems, validationErr.Problems...)
+		} else if err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: problems}
+}
+
+var anyValueType = reflect.TypeOf((*interface{})(nil)).Elem()
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+//Validate checks the settings of a configuration as written: required fields, duplicate names
+//and combinations of settings that contradict each other or have no effect. Returns a
+//*ValidationError with all problems, nil if there are none. References between tasks and
+//things outside the file (executables, time zones, ...) are left to the preflight checks.
+//#########################################################
+func (c *ConfigData) Validate() error {
+	var problems []error
+
+	switch c.Logging.LogFormat {
+	case "", "text", "json":
+	default:
+		problems = append(problems, fmt.Errorf("Logging: unknown LogFormat <%s>, use text or json", c.Logging.LogFormat))
+	}
+	for _, problem := range c.Defaults.validate() {
+		problems = append(problems, fmt.Errorf("Defaults: %s", problem))
+	}
+
+	taskNames := make(map[string]bool)
+	for taskIndex := range c.Tasks {
+		task := &c.Tasks[taskIndex]
+		sTask := fmt.Sprintf("task <%s>", task.Name)
+		if len(task.Name) == 0 {
+			sTask = fmt.Sprintf("task #%d", taskIndex+1)
+		} else if taskNames[task.Name] {
+			problems = append(problems, fmt.Errorf("%s is defined more than once", sTask))
+		}
+		taskNames[task.Name] = true
+
+		for _, problem := range task.validate() {
+			problems = append(problems, fmt.Errorf("%s: %s", sTask, problem))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validate returns the problems of a single task, without its name
+//------------------------------------------------------------------------------
+func (p *ProcessConfig) validate() (problems []string) {
+	bWaitTask := p.WaitForExitTimeout.Duration > 0
+
+	if len(p.Name) == 0 {
+		problems = append(problems, "Name is required")
+	} else if strings.ContainsAny(p.Name, " \t\r\n") {
+		problems = append(problems, "Name must not contain white space, gpcctl could not address the task")
+	}
+	switch {
+	case len(p.StartPath) == 0 && len(p.Command) == 0:
+		problems = append(problems, "StartPath or Command is required")
+	case len(p.StartPath) > 0 && len(p.Command) > 0:
+		problems = append(problems, "StartPath and Command exclude each other")
+	case len(p.Command) > 0 && len(p.StartArgs) > 0:
+		problems = append(problems, "StartArgs have no effect with Command, put the arguments into the command line")
+	}
+	if len(p.Shell) > 0 && len(p.Command) == 0 {
+		problems = append(problems, "Shell requires a Command")
+	}
+	// the -dc template writes empty StopArgs, they are as good as none
+	if len(strings.Join(p.StopArgs, "")) > 0 && len(p.StopPath) == 0 {
+		problems = append(problems, "StopArgs require a StopPath")
+	}
+	if p.MaxRestarts > 0 && bWaitTask {
+		problems = append(problems, "MaxRestarts has no effect with WaitForExitTimeout, use Retries for tasks that are waited for")
+	}
+	if !bWaitTask && (p.Retries > 0 || p.RetryDelay.Duration > 0 || len(p.RetryOn) > 0) {
+		problems = append(problems, "Retries, RetryDelay and RetryOn require a WaitForExitTimeout, use MaxRestarts for tasks that keep running")
+	}
+	if p.CrashLoopWindow.Duration > 0 && p.CrashLoopRestarts == 0 {
+		problems = append(problems, "CrashLoopWindow requires CrashLoopRestarts")
+	}
+	if bWaitTask && (len(p.SuccessExitCodes) > 0 || len(p.NoRestartExitCodes) > 0) {
+		problems = append(problems, "SuccessExitCodes and NoRestartExitCodes have no effect with WaitForExitTimeout, use RetryOn for tasks that are waited for")
+	}
+	for _, exitCode := range p.SuccessExitCodes {
+		if exitCode < 0 {
+			problems = append(problems, fmt.Sprintf("exit code <%d> of SuccessExitCodes must not be negative", exitCode))
+		}
+		if containsExitCode(p.NoRestartExitCodes, exitCode) {
+			problems = append(problems, fmt.Sprintf("exit code <%d> is in both SuccessExitCodes and NoRestartExitCodes", exitCode))
+		}
+	}
+	for _, exitCode := range p.NoRestartExitCodes {
+		if exitCode < 0 {
+			problems = append(problems, fmt.Sprintf("exit code <%d> of NoRestartExitCodes must not be negative", exitCode))
+		}
+	}
+	if len(p.WatchPaths) > 0 && bWaitTask {
+		problems = append(problems, "WatchPaths is only supported for no-wait tasks")
+	}
+	if p.WatchDebounce.Duration > 0 && len(p.WatchPaths) == 0 {
+		problems = append(problems, "WatchDebounce requires WatchPaths")
+	}
+	if p.MaxRuntime.Duration > 0 && bWaitTask {
+		problems = append(problems, "MaxRuntime has no effect with WaitForExitTimeout, which limits the run of tasks that are waited for")
+	}
+	if p.MaxRuntimeIsError && p.MaxRuntime.Duration == 0 {
+		problems = append(problems, "MaxRuntimeIsError requires MaxRuntime")
+	}
+	if (p.HungTimeout.Duration > 0 || p.ExpectHeartbeat.Duration > 0) && bWaitTask {
+		problems = append(problems, "HungTimeout and ExpectHeartbeat are only supported for no-wait tasks")
+	}
+	if len(p.HeartbeatFile) > 0 && p.ExpectHeartbeat.Duration == 0 {
+		problems = append(problems, "HeartbeatFile requires ExpectHeartbeat")
+	}
+	if p.NotifyReady && bWaitTask {
+		problems = append(problems, "NotifyReady is only supported for no-wait tasks, they are ready once they exit")
+	}
+	if len(p.RestartEvery) > 0 {
+		if _, _, err := p.RestartPeriod(); err != nil {
+			problems = append(problems, err.Error())
+		}
+		if bWaitTask || len(p.Schedule) > 0 {
+			problems = append(problems, "RestartEvery is only supported for no-wait tasks without a Schedule")
+		}
+	}
+	if len(p.StopInput) > 0 && !p.StdinPipe {
+		problems = append(problems, "StopInput requires StdinPipe")
+	}
+	if p.StdinPipe && (len(p.Desktop) > 0 || len(p.Session) > 0) {
+		problems = append(problems, "StdinPipe can not be used with Desktop or Session")
+	}
+	for _, sSocket := range p.ListenSockets {
+		if _, _, err := ListenNetwork(sSocket); err != nil {
+			problems = append(problems, fmt.Sprintf("ListenSockets <%s>: %s", sSocket, err.Error()))
+		}
+	}
+	portNames := make(map[string]bool)
+	for _, sEnvName := range p.AssignPorts {
+		if len(sEnvName) == 0 || strings.ContainsAny(sEnvName, "= \t") {
+			problems = append(problems, fmt.Sprintf("AssignPorts <%s> is not the name of an environment variable", sEnvName))
+		} else if portNames[sEnvName] {
+			problems = append(problems, fmt.Sprintf("AssignPorts names <%s> twice", sEnvName))
+		}
+		portNames[sEnvName] = true
+	}
+	if len(p.ListenSockets) > 0 && (len(p.Desktop) > 0 || len(p.Session) > 0) {
+		problems = append(problems, "ListenSockets can not be passed to a process started with Desktop or Session")
+	}
+	if p.TaskNameInOutput && !p.TimestampOutput {
+		problems = append(problems, "TaskNameInOutput requires TimestampOutput")
+	}
+	switch p.MutexPolicy {
+	case "", MutexQueue, MutexSkip:
+		if len(p.MutexPolicy) > 0 && len(p.MutexGroup) == 0 {
+			problems = append(problems, "MutexPolicy requires a MutexGroup")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown MutexPolicy <%s>, use queue or skip", p.MutexPolicy))
+	}
+	if p.BasePort > 0 && p.Instances == 0 {
+		problems = append(problems, "BasePort requires Instances")
+	}
+	for _, sTag := range p.Tags {
+		if len(sTag) == 0 || strings.ContainsAny(sTag, ",= \t") {
+			problems = append(problems, fmt.Sprintf("tag <%s> must not be empty or contain commas, \"=\" or white space", sTag))
+		}
+	}
+	for conditionIndex, condition := range p.Preconditions {
+		if condition.kinds() != 1 {
+			problems = append(problems, fmt.Sprintf("precondition #%d must set exactly one of FileExists, PortFree, TaskReady and EnvSet", conditionIndex+1))
+		}
+	}
+	if p.PreconditionInterval.Duration > 0 && len(p.Preconditions) == 0 && len(p.BindPorts) == 0 {
+		problems = append(problems, "PreconditionInterval requires Preconditions or BindPorts")
+	}
+	if len(p.ReadinessURL) > 0 && !strings.HasPrefix(p.ReadinessURL, "http://") && !strings.HasPrefix(p.ReadinessURL, "https://") &&
+		!strings.HasPrefix(p.ReadinessURL, "tcp://") {
+		problems = append(problems, fmt.Sprintf("ReadinessURL <%s> must start with http://, https:// or tcp://", p.ReadinessURL))
+	}
+	switch p.PortConflict {
+	case "", PortConflictWait, PortConflictFail, PortConflictKill:
+		if len(p.PortConflict) > 0 && len(p.BindPorts) == 0 {
+			problems = append(problems, "PortConflict requires BindPorts")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown PortConflict <%s>, use %s, %s or %s", p.PortConflict, PortConflictWait, PortConflictFail,
+			PortConflictKill))
+	}
+	for _, port := range p.BindPorts {
+		if port == 0 {
+			problems = append(problems, "BindPorts must not hold port 0")
+		}
+	}
+	switch p.RestartStrategy {
+	case "", RestartStopFirst:
+	case RestartStartFirst:
+		if bWaitTask || len(p.MutexGroup) > 0 || len(p.AdoptPIDFile) > 0 || len(p.AdoptExecutable) > 0 {
+			problems = append(problems, "RestartStrategy start-first is only supported for no-wait tasks without MutexGroup, AdoptPIDFile and AdoptExecutable")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown RestartStrategy <%s>, use %s or %s", p.RestartStrategy, RestartStopFirst, RestartStartFirst))
+	}
+	if len(p.PromotePath) > 0 && len(p.StandbyFor) == 0 {
+		problems = append(problems, "PromotePath requires StandbyFor")
+	}
+	return problems
+}
+
+// decodeStrict decodes a configuration document, which must not have fields the configuration
+// does not know, e.g. misspelled or outdated ones. Errors tell the line if it can be found.
+// Unknown fields are returned as *ValidationError with one problem per field, the target is
+// decoded anyway.
+//------------------------------------------------------------------------------
+func decodeStrict(jsonBytes []byte, sourceBytes []byte, sFormat string, target interface{}) error {
+	jsonDecoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	err := jsonDecoder.Decode(target)
+	if err == nil {
+		return unknownSettings(jsonBytes, sourceBytes, sFormat, reflect.TypeOf(target))
+	}
+
+	// Offsets are of the JSON, which was converted from YAML files
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr) && sFormat == FormatJSON:
+		return fmt.Errorf("line %d: %s", lineOfOffset(sourceBytes, syntaxErr.Offset), err.Error())
+	case errors.As(err, &typeErr):
+		sProblem := fmt.Sprintf("%s: a %s can not be a %s", typeErr.Field, typeErr.Type.String(), typeErr.Value)
+		if sFormat == FormatJSON {
+			return fmt.Errorf("line %d: %s", lineOfOffset(sourceBytes, typeErr.Offset), sProblem)
+		}
+		return fmt.Errorf("%s", sProblem)
+	}
+	return err
+}
+
+// unknownSettings lists the keys of a decoded document the target type does not know, as
+// *ValidationError. Nil if there are none.
+//------------------------------------------------------------------------------
+func unknownSettings(jsonBytes []byte, sourceBytes []byte, sFormat string, targetType reflect.Type) error {
+	var problems []error
+	err := walkUnknownKeys(json.NewDecoder(bytes.NewReader(jsonBytes)), targetType, func(sKey string, offset int64) {
+		if line := lineOfKey(sourceBytes, sFormat, sKey, offset); line > 0 {
+			problems = append(problems, fmt.Errorf("line %d: unknown setting <%s>", line, sKey))
+		} else {
+			problems = append(problems, fmt.Errorf("unknown setting <%s>", sKey))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// walkUnknownKeys reads the next JSON value from the decoder and reports every key in it that
+// has no field in the type the value is decoded into, with the offset the key starts after.
+// Values of unknown keys and of types that decode themselves are not looked into.
+//------------------------------------------------------------------------------
+func walkUnknownKeys(decoder *json.Decoder, valueType reflect.Type, report func(sKey string, offset int64)) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	delim, bDelim := token.(json.Delim)
+	if !bDelim {
+		return nil
+	}
+	for valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+	if reflect.PtrTo(valueType).Implements(jsonUnmarshalerType) {
+		valueType = anyValueType
+	}
+
+	for decoder.More() {
+		itemType := anyValueType
+		switch {
+		case delim == '{':
+			offset := decoder.InputOffset()
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			sKey, _ := keyToken.(string)
+			switch valueType.Kind() {
+			case reflect.Struct:
+				if field, found := jsonField(valueType, sKey); found {
+					itemType = field.Type
+				} else {
+					report(sKey, offset)
+				}
+			case reflect.Map:
+				itemType = valueType.Elem()
+			}
+		case valueType.Kind() == reflect.Slice || valueType.Kind() == reflect.Array:
+			itemType = valueType.Elem()
+		}
+		if err = walkUnknownKeys(decoder, itemType, report); err != nil {
+			return err
+		}
+	}
+	// the closing delimiter
+	_, err = decoder.Token()
+	return err
+}
+
+// jsonField finds the field of a struct a JSON key is decoded into, matching the name like
+// encoding/json does, i.e. ignoring the case.
+//------------------------------------------------------------------------------
+func jsonField(structType reflect.Type, sKey string) (reflect.StructField, bool) {
+	for fieldIndex := 0; fieldIndex < structType.NumField(); fieldIndex++ {
+		field := structType.Field(fieldIndex)
+		sName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if sName == "-" {
+			continue
+		}
+		if field.Anonymous && len(sName) == 0 {
+			embeddedType := field.Type
+			if embeddedType.Kind() == reflect.Ptr {
+				embeddedType = embeddedType.Elem()
+			}
+			if embeddedType.Kind() == reflect.Struct {
+				if embeddedField, found := jsonField(embeddedType, sKey); found {
+					return embeddedField, true
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if len(sName) == 0 {
+			sName = field.Name
+		}
+		if strings.EqualFold(sName, sKey) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// lineOfOffset returns the line number of a byte offset
+//------------------------------------------------------------------------------
+func lineOfOffset(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// lineOfKey finds the line of an unknown key: in JSON the first occurrence after the offset the
+// key starts after, in YAML the first one. Zero if not found.
+//------------------------------------------------------------------------------
+func lineOfKey(sourceBytes []byte, sFormat string, sKey string, offset int64) int {
+	if sFormat == FormatJSON {
+		if offset > int64(len(sourceBytes)) {
+			offset = int64(len(sourceBytes))
+		}
+		keyRe := regexp.MustCompile(`"` + regexp.QuoteMeta(sKey) + `"\s*:`)
+		match := keyRe.FindIndex(sourceBytes[offset:])
+		if match == nil {
+			return 0
+		}
+		return lineOfOffset(sourceBytes, offset+int64(match[0]))
+	}
+
+	keyRe := regexp.MustCompile(`^\s*(-\s+)?["']?` + regexp.QuoteMeta(sKey) + `["']?\s*:`)
+	for lineIndex, sLine := range strings.Split(string(sourceBytes), "\n") {
+		if keyRe.MatchString(sLine) {
+			return lineIndex + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,517 @@
+package gpcconfig
+
+// A small YAML reader/writer, just enough for configuration files.
+// Supported: block mappings and sequences, plain/quoted scalars, comments,
+// simple flow collections ([a, b] and {k: v}) and literal/folded block scalars (| and >).
+// Anchors, aliases, tags and multiple documents are not supported.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a single, comment free and non empty line of the document
+type yamlLine struct {
+	num    int    // line number in the file, for error messages
+	indent int    // number of leading spaces
+	text   string // content without indentation
+	tab    bool   // the indentation contains a tab, which YAML does not allow
+}
+
+// yamlParser walks over the lines of a document
+type yamlParser struct {
+	raw   []string // all lines, needed for block scalars
+	lines []yamlLine
+	pos   int
+}
+
+var yamlNumberRe = regexp.MustCompile(`^[-+]?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+//yamlToJSON converts a YAML document into the equivalent JSON document
+//#########################################################
+func yamlToJSON(data []byte) ([]byte, error) {
+	p := newYAMLParser(string(data))
+	if len(p.lines) == 0 {
+		return []byte("{}"), nil
+	}
+
+	value, err := p.parseBlock(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, p.lines[p.pos].indentError()
+	}
+	return json.Marshal(value)
+}
+
+// newYAMLParser splits the document into lines and strips comments
+//------------------------------------------------------------------------------
+func newYAMLParser(doc string) *yamlParser {
+	p := &yamlParser{raw: strings.Split(strings.ReplaceAll(doc, "\r\n", "\n"), "\n")}
+
+	for i, rawLine := range p.raw {
+		text := strings.TrimRight(stripYAMLComment(rawLine), " \t")
+		trimmed := strings.TrimLeft(text, " \t")
+		if len(trimmed) == 0 || trimmed == "---" || trimmed == "..." {
+			continue
+		}
+		indent := len(text) - len(trimmed)
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: indent, text: trimmed, tab: strings.Contains(text[:indent], "\t")})
+	}
+	return p
+}
+
+// indentError reports a line that does not fit the indentation of the document.
+// Tabs are only checked here, as they are allowed in the content of block scalars.
+//------------------------------------------------------------------------------
+func (l yamlLine) indentError() error {
+	if l.tab {
+		return fmt.Errorf("yaml line %d: tabs are not allowed for indentation", l.num)
+	}
+	return fmt.Errorf("yaml line %d: unexpected indentation", l.num)
+}
+
+// stripYAMLComment removes a trailing comment that is not part of a quoted string
+//------------------------------------------------------------------------------
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// isSequenceItem checks if the line starts a sequence entry
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseBlock parses the mapping or sequence starting at the current line,
+// which must be indented by at least minIndent
+//------------------------------------------------------------------------------
+func (p *yamlParser) parseBlock(minIndent int) (interface{}, error) {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent < minIndent {
+		return nil, nil
+	}
+	line := p.lines[p.pos]
+	if line.tab {
+		return nil, line.indentError()
+	}
+	if isSequenceItem(line.text) {
+		return p.parseSequence(line.indent)
+	}
+	if _, _, isKey := splitYAMLKey(line.text); isKey {
+		return p.parseMapping(line.indent)
+	}
+
+	// A lonely scalar (e.g. the continuation of a value)
+	p.pos++
+	return parseYAMLScalar(line.text, line.num)
+}
+
+// parseSequence parses all "- item" lines at the given indentation
+//------------------------------------------------------------------------------
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	items := make([]interface{}, 0)
+
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSequenceItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		if line.tab {
+			return nil, line.indentError()
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+
+		if len(rest) == 0 {
+			// Nested block below the dash
+			p.pos++
+			value, err := p.parseBlock(indent + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+			continue
+		}
+
+		if _, _, isKey := splitYAMLKey(rest); isKey || isSequenceItem(rest) {
+			// "- key: value" starts a nested collection, continue it at the column of the key
+			p.lines[p.pos] = yamlLine{num: line.num, indent: indent + len(line.text) - len(rest), text: rest}
+			value, err := p.parseBlock(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+			continue
+		}
+
+		p.pos++
+		value, err := parseYAMLScalar(rest, line.num)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+	}
+	return items, nil
+}
+
+// parseMapping parses all "key: value" lines at the given indentation
+//------------------------------------------------------------------------------
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	mapping := make(map[string]interface{})
+
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && !isSequenceItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		if line.tab {
+			return nil, line.indentError()
+		}
+		key, rest, isKey := splitYAMLKey(line.text)
+		if !isKey {
+			return nil, fmt.Errorf("yaml line %d: expected <key: value>", line.num)
+		}
+		if _, duplicate := mapping[key]; duplicate {
+			return nil, fmt.Errorf("yaml line %d: duplicate key <%s>", line.num, key)
+		}
+		p.pos++
+
+		var value interface{}
+		var err error
+		switch {
+		case len(rest) == 0:
+			// Nested block, sequences may also start at the indentation of the key
+			if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSequenceItem(p.lines[p.pos].text) {
+				value, err = p.parseSequence(indent)
+			} else {
+				value, err = p.parseBlock(indent + 1)
+			}
+		case strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">"):
+			value = p.parseBlockScalar(rest, indent, line.num)
+		default:
+			value, err = parseYAMLScalar(rest, line.num)
+		}
+		if err != nil {
+			return nil, err
+		}
+		mapping[key] = value
+	}
+	return mapping, nil
+}
+
+// parseBlockScalar reads the raw lines of a literal (|) or folded (>) scalar
+//------------------------------------------------------------------------------
+func (p *yamlParser) parseBlockScalar(header string, parentIndent int, headerLineNum int) string {
+	var body []string
+	blockIndent := -1
+
+	// headerLineNum is 1 based, so it is the index of the first line after the header
+	for i := headerLineNum; i < len(p.raw); i++ {
+		rawLine := strings.TrimRight(p.raw[i], " \t\r")
+		trimmed := strings.TrimLeft(rawLine, " ")
+		lineIndent := len(rawLine) - len(trimmed)
+		if len(trimmed) > 0 && lineIndent <= parentIndent {
+			break
+		}
+		if blockIndent < 0 && len(trimmed) > 0 {
+			blockIndent = lineIndent
+		}
+		if blockIndent >= 0 && len(rawLine) >= blockIndent {
+			body = append(body, rawLine[blockIndent:])
+		} else {
+			body = append(body, "")
+		}
+	}
+
+	// Skip the parsed lines
+	lastLineNum := headerLineNum + len(body)
+	for p.pos < len(p.lines) && p.lines[p.pos].num <= lastLineNum {
+		p.pos++
+	}
+
+	// Trailing empty lines are not part of the value
+	for len(body) > 0 && len(body[len(body)-1]) == 0 {
+		body = body[:len(body)-1]
+	}
+
+	var value string
+	if strings.HasPrefix(header, ">") {
+		value = foldYAMLLines(body)
+	} else {
+		value = strings.Join(body, "\n")
+	}
+	if !strings.HasSuffix(header, "-") {
+		value += "\n"
+	}
+	return value
+}
+
+// foldYAMLLines joins the lines of a folded scalar: lines of a paragraph are joined
+// with spaces, empty lines become line breaks and more indented lines are kept as they are
+//------------------------------------------------------------------------------
+func foldYAMLLines(body []string) string {
+	var folded strings.Builder
+	for i, bodyLine := range body {
+		switch {
+		case len(bodyLine) == 0:
+			folded.WriteString("\n")
+		case i == 0 || len(body[i-1]) == 0:
+		case strings.HasPrefix(bodyLine, " ") || strings.HasPrefix(body[i-1], " "):
+			folded.WriteString("\n")
+		default:
+			folded.WriteString(" ")
+		}
+		folded.WriteString(bodyLine)
+	}
+	return folded.String()
+}
+
+// splitYAMLKey splits "key: value" outside of quotes. The value may be empty.
+//------------------------------------------------------------------------------
+func splitYAMLKey(text string) (key string, rest string, isKey bool) {
+	if strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		return "", "", false
+	}
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && i == 0:
+			quote = c
+		case c == ':' && (i == len(text)-1 || text[i+1] == ' ' || text[i+1] == '\t'):
+			key = strings.TrimSpace(text[:i])
+			if unquoted, err := parseYAMLScalar(key, 0); err == nil {
+				if s, isString := unquoted.(string); isString {
+					key = s
+				}
+			}
+			return key, strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// parseYAMLScalar converts a scalar or a simple flow collection into a JSON compatible value
+//------------------------------------------------------------------------------
+func parseYAMLScalar(text string, lineNum int) (interface{}, error) {
+	text = strings.TrimSpace(text)
+
+	switch {
+	case strings.HasPrefix(text, "\""):
+		value, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("yaml line %d: invalid double quoted string %s", lineNum, text)
+		}
+		return value, nil
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, fmt.Errorf("yaml line %d: invalid single quoted string %s", lineNum, text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("yaml line %d: unterminated flow sequence", lineNum)
+		}
+		items := make([]interface{}, 0)
+		for _, part := range splitYAMLFlow(text[1 : len(text)-1]) {
+			item, err := parseYAMLScalar(part, lineNum)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case strings.HasPrefix(text, "{"):
+		if !strings.HasSuffix(text, "}") {
+			return nil, fmt.Errorf("yaml line %d: unterminated flow mapping", lineNum)
+		}
+		mapping := make(map[string]interface{})
+		for _, part := range splitYAMLFlow(text[1 : len(text)-1]) {
+			key, rest, isKey := splitYAMLKey(part)
+			if !isKey {
+				return nil, fmt.Errorf("yaml line %d: expected <key: value> in flow mapping", lineNum)
+			}
+			value, err := parseYAMLScalar(rest, lineNum)
+			if err != nil {
+				return nil, err
+			}
+			mapping[key] = value
+		}
+		return mapping, nil
+	}
+
+	switch text {
+	case "", "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if yamlNumberRe.MatchString(text) {
+		return json.Number(strings.TrimPrefix(text, "+")), nil
+	}
+	return text, nil
+}
+
+// splitYAMLFlow splits the content of a flow collection at commas outside of quotes
+// and nested collections
+//------------------------------------------------------------------------------
+func splitYAMLFlow(text string) []string {
+	var parts []string
+	var quote byte
+	depth := 0
+	start := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(text[start:]); len(last) > 0 {
+		parts = append(parts, last)
+	}
+	return parts
+}
+
+//jsonToYAML converts a JSON document into YAML, keeping the order of the keys
+//#########################################################
+func jsonToYAML(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var out bytes.Buffer
+	if err := writeYAMLValue(&out, decoder, 0, ""); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// writeYAMLValue writes the next JSON value of the decoder. prefix is written in front
+// of the value ("key:" or "-") and indent is the indentation for nested lines.
+//------------------------------------------------------------------------------
+func writeYAMLValue(out *bytes.Buffer, decoder *json.Decoder, indent int, prefix string) error {
+	token, err := decoder.Token()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	pad := strings.Repeat("  ", indent)
+	delim, isDelim := token.(json.Delim)
+	if !isDelim {
+		if len(prefix) > 0 {
+			fmt.Fprintf(out, "%s%s %s\n", pad, prefix, formatYAMLScalar(token))
+		} else {
+			fmt.Fprintf(out, "%s%s\n", pad, formatYAMLScalar(token))
+		}
+		return nil
+	}
+
+	// Empty collections are written in flow style
+	if !decoder.More() {
+		decoder.Token()
+		empty := "{}"
+		if delim == '[' {
+			empty = "[]"
+		}
+		if len(prefix) > 0 {
+			fmt.Fprintf(out, "%s%s %s\n", pad, prefix, empty)
+		} else {
+			fmt.Fprintf(out, "%s%s\n", pad, empty)
+		}
+		return nil
+	}
+
+	// Mappings in sequences start on the line of the dash: "- key: value"
+	if prefix == "-" && delim == '{' {
+		var nested bytes.Buffer
+		if err := writeYAMLEntries(&nested, decoder, delim, indent+1); err != nil {
+			return err
+		}
+		out.WriteString(pad + "- ")
+		out.Write(nested.Bytes()[len(pad)+2:])
+		return nil
+	}
+
+	childIndent := indent
+	if len(prefix) > 0 {
+		fmt.Fprintf(out, "%s%s\n", pad, prefix)
+		childIndent = indent + 1
+	}
+	return writeYAMLEntries(out, decoder, delim, childIndent)
+}
+
+// writeYAMLEntries writes the entries of a collection up to and including its closing delimiter
+//------------------------------------------------------------------------------
+func writeYAMLEntries(out *bytes.Buffer, decoder *json.Decoder, delim json.Delim, childIndent int) error {
+	for decoder.More() {
+		if delim == '{' {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			key := formatYAMLScalar(keyToken) + ":"
+			if err := writeYAMLValue(out, decoder, childIndent, key); err != nil {
+				return err
+			}
+		} else if err := writeYAMLValue(out, decoder, childIndent, "-"); err != nil {
+			return err
+		}
+	}
+	// Closing delimiter
+	_, err := decoder.Token()
+	return err
+}
+
+// formatYAMLScalar writes a JSON scalar token, quoting strings where YAML would read them differently
+//------------------------------------------------------------------------------
+func formatYAMLScalar(token json.Token) string {
+	switch value := token.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(value)
+	case json.Number:
+		return value.String()
+	case string:
+		parsed, err := parseYAMLScalar(value, 0)
+		if err != nil || parsed != value || strings.TrimSpace(value) != value ||
+			strings.ContainsAny(value, ":#{}[],&*!|>'\"%@`\\\n\t") || strings.HasPrefix(value, "-") || strings.HasPrefix(value, "?") {
+			return strconv.Quote(value)
+		}
+		return value
+	}
+	return fmt.Sprint(token)
+}
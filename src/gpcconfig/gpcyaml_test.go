@@ -0,0 +1,114 @@
+package gpcconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestYAMLToJSON(t *testing.T) {
+	tests := []struct {
+		sName     string
+		sYAML     string
+		sExpected string // empty if the document must be rejected
+	}{
+		{"empty", "# only a comment\n", `{}`},
+		{"scalars", "a: 1\nb: -2.5\nc: true\nd: ~\ne: text\nf: \"quoted # not a comment\"\ng: 'it''s'\n", `{"a":1,"b":-2.5,"c":true,"d":null,"e":"text","f":"quoted # not a comment","g":"it's"}`},
+		{"comments", "---\na: 1 # one\n# between\nb: x#y\n", `{"a":1,"b":"x#y"}`},
+		{"nested mapping", "a:\n  b:\n    c: 1\n  d: 2\n", `{"a":{"b":{"c":1},"d":2}}`},
+		{"sequence", "a:\n  - 1\n  - two\n", `{"a":[1,"two"]}`},
+		{"sequence at key indentation", "a:\n- 1\n- 2\nb: 3\n", `{"a":[1,2],"b":3}`},
+		{"sequence of mappings", "tasks:\n  - name: x\n    args: [a, b]\n  - name: y\n", `{"tasks":[{"args":["a","b"],"name":"x"},{"name":"y"}]}`},
+		{"nested sequences", "- - 1\n  - 2\n- - 3\n", `[[1,2],[3]]`},
+		{"flow sequence", "a: [1, 'b, c', \"d\"]\n", `{"a":[1,"b, c","d"]}`},
+		{"nested flow sequence", "a: [1, [2, 3], []]\n", `{"a":[1,[2,3],[]]}`},
+		{"flow mapping", "a: {b: 1, c: [2, 3], d: {e: f}}\n", `{"a":{"b":1,"c":[2,3],"d":{"e":"f"}}}`},
+		{"literal scalar", "a: |\n  line one\n\n  line two\nb: 1\n", `{"a":"line one\n\nline two\n","b":1}`},
+		{"literal scalar strip", "a: |-\n  one\n  two\n", `{"a":"one\ntwo"}`},
+		{"folded scalar", "a: >\n  one\n  two\n\n  three\nb: 1\n", `{"a":"one two\nthree\n","b":1}`},
+		{"folded scalar paragraphs", "a: >-\n  one\n\n\n  two\n  three\n", `{"a":"one\n\ntwo three"}`},
+		{"folded scalar more indented", "a: >-\n  one\n    code\n  two\n", `{"a":"one\n  code\ntwo"}`},
+		{"crlf", "a: 1\r\nb: 2\r\n", `{"a":1,"b":2}`},
+		{"tab indentation", "a:\n\tb: 1\n", ""},
+		{"tab in sequence", "a:\n  - 1\n \t- 2\n", ""},
+		{"tab after mapping", "a:\n  b: 1\n\tc: 2\n", ""},
+		{"duplicate key", "a: 1\na: 2\n", ""},
+		{"bad indentation", "a: 1\n  b: 2\n", ""},
+		{"unterminated flow sequence", "a: [1, 2\n", ""},
+		{"unterminated flow mapping", "a: {b: 1\n", ""},
+		{"bad quoted string", "a: \"x\n", ""},
+	}
+	for _, test := range tests {
+		jsonBytes, err := yamlToJSON([]byte(test.sYAML))
+		if len(test.sExpected) == 0 {
+			if err == nil {
+				t.Errorf("%s: expected an error, got <%s>", test.sName, jsonBytes)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error <%s>", test.sName, err.Error())
+			continue
+		}
+		if string(jsonBytes) != test.sExpected {
+			t.Errorf("%s: got <%s>, expected <%s>", test.sName, jsonBytes, test.sExpected)
+		}
+	}
+}
+
+func TestJSONToYAMLRoundTrip(t *testing.T) {
+	tests := []struct {
+		sName string
+		sJSON string
+	}{
+		{"scalars", `{"a":1,"b":-2.5e3,"c":true,"d":false,"e":null,"f":"text"}`},
+		{"special strings", `{"a":"","b":" padded ","c":"x: y","d":"# hash","e":"- dash","f":"true","g":"123","h":"null","i":"line\nbreak","j":"tab\there","k":"quote \" and ' ","l":"[x]","m":"{x}","n":"a, b","o":"~"}`},
+		{"special keys", `{"key: colon":1,"- dash":2,"123":3,"true":4}`},
+		{"empty collections", `{"a":[],"b":{},"c":[[],{}]}`},
+		{"nested", `{"a":{"b":{"c":[1,2,{"d":[3]}]}}}`},
+		{"sequence of mappings", `{"tasks":[{"name":"x","args":["a","b"],"env":{"K":"V"}},{"name":"y"}]}`},
+		{"nested sequences", `[[1,2],[[3]],[]]`},
+		{"top level scalar", `"text"`},
+	}
+	for _, test := range tests {
+		yamlBytes, err := jsonToYAML([]byte(test.sJSON))
+		if err != nil {
+			t.Errorf("%s: jsonToYAML error <%s>", test.sName, err.Error())
+			continue
+		}
+		jsonBytes, err := yamlToJSON(yamlBytes)
+		if err != nil {
+			t.Errorf("%s: yamlToJSON error <%s> for\n%s", test.sName, err.Error(), yamlBytes)
+			continue
+		}
+		expected, actual := decodeJSON(t, test.sJSON), decodeJSON(t, string(jsonBytes))
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("%s: got <%s>, expected <%s> from\n%s", test.sName, jsonBytes, test.sJSON, yamlBytes)
+		}
+	}
+}
+
+func TestJSONToYAMLKeepsKeyOrder(t *testing.T) {
+	yamlBytes, err := jsonToYAML([]byte(`{"z":1,"a":{"y":[true],"b":"x"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "z: 1\na:\n  y:\n    - true\n  b: x\n"
+	if string(yamlBytes) != expected {
+		t.Errorf("got\n%s\nexpected\n%s", yamlBytes, expected)
+	}
+}
+
+// decodeJSON decodes a document keeping numbers as written
+func decodeJSON(t *testing.T, sJSON string) interface{} {
+	t.Helper()
+	decoder := json.NewDecoder(bytes.NewReader([]byte(strings.TrimSpace(sJSON))))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatalf("invalid JSON <%s>: %s", sJSON, err.Error())
+	}
+	return value
+}
@@ -0,0 +1,491 @@
+package gpccontrol
+
+/*
+Package gpccontrol provides the local control channel of the process controller.
+
+The controller listens on a local socket: a unix domain socket, on Windows a named pipe.
+A client sends one command line, e.g. "restart Notepad",
+and receives the answer line by line until the connection is closed. The first answer
+line is either "OK" or "ERROR <message>", all following lines are the command output.
+*/
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gpclogging"
+	"gpcprocessmgr"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CommandHandler handles one control command and returns the output lines
+type CommandHandler func(args []string) ([]string, error)
+
+// TextCommandHandler handles a control command with free text, it gets everything after
+// the command name unchanged
+type TextCommandHandler func(sArgs string) ([]string, error)
+
+// consts
+const (
+	replyOK    = "OK"
+	replyError = "ERROR"
+
+	defTailLines = 20
+
+	// how long start, stop and restart may wait for a process
+	commandTimeout = 30 * time.Second
+)
+
+//#######################################################
+//### GLOBAL VARIABLES, INIT, CONSTS
+//#######################################################
+
+var gHandlers = make(map[string]TextCommandHandler)
+var gTextCommands = make(map[string]bool) // their arguments may hold secrets, they are not logged
+var gHandlersMux sync.Mutex
+var gListener net.Listener
+var gConnections sync.WaitGroup // connections being answered
+
+func init() {
+	RegisterCommand("status", cmdStatus)
+	RegisterCommand("start", cmdStart)
+	RegisterCommand("stop", cmdStop)
+	RegisterCommand("restart", cmdRestart)
+	RegisterCommand("suspend", cmdSuspend)
+	RegisterCommand("resume", cmdResume)
+	RegisterCommand("signal", cmdSignal)
+	RegisterTextCommand("input", cmdInput)
+	RegisterCommand("group", cmdGroup)
+	RegisterCommand("rollout", cmdRollout)
+	RegisterCommand("maintenance", cmdMaintenance)
+	RegisterCommand("tail", cmdTail)
+	RegisterCommand("output", cmdOutput)
+	RegisterCommand("launches", cmdLaunches)
+	RegisterCommand("stats", cmdStats)
+	RegisterCommand("logstats", cmdLogStats)
+	RegisterCommand("loglevel", cmdLogLevel)
+}
+
+//RegisterCommand adds (or replaces) a command that can be invoked via the control socket.
+//The handler gets the arguments split at whitespace.
+//#########################################################
+func RegisterCommand(name string, handler CommandHandler) {
+	registerHandler(name, func(sArgs string) ([]string, error) {
+		return handler(strings.Fields(sArgs))
+	}, false)
+}
+
+//RegisterTextCommand adds (or replaces) a command whose arguments keep their whitespace,
+//e.g. a line of text or a JSON document. Only the name of the command is logged.
+//#########################################################
+func RegisterTextCommand(name string, handler TextCommandHandler) {
+	registerHandler(name, handler, true)
+}
+
+// registerHandler adds (or replaces) the handler of a command
+//------------------------------------------------------------------------------
+func registerHandler(name string, handler TextCommandHandler, bText bool) {
+	gHandlersMux.Lock()
+	defer gHandlersMux.Unlock()
+
+	gHandlers[name] = handler
+	gTextCommands[name] = bText
+}
+
+//Start opens the control socket and serves requests in background
+//#########################################################
+func Start(socketPath string) error {
+	gpclogging.Debug("Entering gpccontrol.Start() with socket <%s>", socketPath)
+
+	listener, err := listen(socketPath)
+	if err != nil {
+		return err
+	}
+	gListener = listener
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				gpclogging.Debug("Control socket closed: <%s>", err.Error())
+				return
+			}
+			gConnections.Add(1)
+			go func() {
+				defer gConnections.Done()
+				handleConnection(conn)
+			}()
+		}
+	}()
+
+	gpclogging.Info("Control socket is listening on <%s>", socketPath)
+	return nil
+}
+
+//Stop closes the control socket and waits until the commands already received are answered
+//#########################################################
+func Stop() {
+	if gListener != nil {
+		gListener.Close()
+		gListener = nil
+	}
+	gConnections.Wait()
+}
+
+//SendCommand connects to a running controller, sends a command and returns the output lines
+//#########################################################
+func SendCommand(socketPath string, command []string) ([]string, error) {
+	sCommand := strings.Join(command, " ")
+	if strings.ContainsAny(sCommand, "\r\n") {
+		return nil, fmt.Errorf("a command must be a single line")
+	}
+
+	conn, err := dial(socketPath, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("can not connect to controller at <%s>: %s", socketPath, err.Error())
+	}
+	defer conn.Close()
+
+	_, err = fmt.Fprintln(conn, sCommand)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(conn)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("controller closed the connection without answer")
+	}
+	reply := scanner.Text()
+
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	if reply != replyOK {
+		return lines, fmt.Errorf("%s", strings.TrimSpace(strings.TrimPrefix(reply, replyError)))
+	}
+	return lines, scanner.Err()
+}
+
+// handleConnection reads a single command from the client and writes the answer
+//------------------------------------------------------------------------------
+func handleConnection(conn net.Conn) {
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && err != io.EOF {
+		gpclogging.Warn("Could not read control command: <%s>", err.Error())
+		return
+	}
+
+	// Only the command name is split off, the handler gets the rest as it was sent
+	sName, sArgs, _ := strings.Cut(strings.TrimLeft(strings.TrimRight(line, "\r\n"), " \t"), " ")
+	if len(strings.TrimSpace(sName)) == 0 {
+		fmt.Fprintln(conn, replyError, "empty command")
+		return
+	}
+	gHandlersMux.Lock()
+	handler, found := gHandlers[sName]
+	bText := gTextCommands[sName]
+	gHandlersMux.Unlock()
+	if bText || !found {
+		// e.g. the JSON of add may hold passwords, the text of input anything
+		gpclogging.Info("Received control command <%s> with <%d> bytes of arguments", sName, len(sArgs))
+	} else {
+		gpclogging.Info("Received control command <%s>", strings.TrimSpace(line))
+	}
+	if !found {
+		fmt.Fprintln(conn, replyError, "unknown command", sName)
+		return
+	}
+
+	output, err := handler(sArgs)
+	if err != nil {
+		fmt.Fprintln(conn, replyError, err.Error())
+	} else {
+		fmt.Fprintln(conn, replyOK)
+	}
+	for _, outLine := range output {
+		fmt.Fprintln(conn, outLine)
+	}
+}
+
+// requireName checks that exactly one process name was passed
+//------------------------------------------------------------------------------
+func requireName(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("expected exactly one process name")
+	}
+	return args[0], nil
+}
+
+// cmdStatus returns the state of all processes: status [-json]. -json returns one
+// ProcessStatus per line, for clients that show it themselves
+func cmdStatus(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return gpcprocessmgr.GetStatusText(), nil
+	}
+	if len(args) > 1 || args[0] != "-json" {
+		return nil, fmt.Errorf("usage: status [-json]")
+	}
+
+	var lines []string
+	for _, procStatus := range gpcprocessmgr.GetStatus() {
+		encoded, err := json.Marshal(&procStatus)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, string(encoded))
+	}
+	return lines, nil
+}
+
+func cmdStart(args []string) ([]string, error) {
+	procName, err := requireName(args)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	return nil, gpcprocessmgr.StartProcess(ctx, procName)
+}
+
+func cmdStop(args []string) ([]string, error) {
+	procName, err := requireName(args)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	return nil, gpcprocessmgr.StopProcess(ctx, procName)
+}
+
+func cmdRestart(args []string) ([]string, error) {
+	procName, err := requireName(args)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	return nil, gpcprocessmgr.RestartProcess(ctx, procName)
+}
+
+func cmdSuspend(args []string) ([]string, error) {
+	procName, err := requireName(args)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	return nil, gpcprocessmgr.SuspendProcess(ctx, procName)
+}
+
+func cmdResume(args []string) ([]string, error) {
+	procName, err := requireName(args)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	return nil, gpcprocessmgr.ResumeProcess(ctx, procName)
+}
+
+// cmdSignal sends a signal to a process: signal <name> <signal>
+func cmdSignal(args []string) ([]string, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("usage: signal <name> <signal>, signal one of %s", strings.Join(gpcprocessmgr.Signals(), ", "))
+	}
+	return nil, gpcprocessmgr.SignalProcess(args[0], args[1])
+}
+
+// cmdInput writes a line to the stdin of a StdinPipe process: input <name> <text>. The text is
+// written as it was sent, with its whitespace.
+func cmdInput(sArgs string) ([]string, error) {
+	procName, sText, found := strings.Cut(strings.TrimLeft(sArgs, " \t"), " ")
+	if !found || len(procName) == 0 || len(sText) == 0 {
+		return nil, fmt.Errorf("usage: input <name> <text>")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	return nil, gpcprocessmgr.WriteProcessInput(ctx, procName, sText)
+}
+
+// cmdGroup starts, stops or restarts the processes with a tag: group <start|stop|restart> <tag>
+func cmdGroup(args []string) ([]string, error) {
+	actions := map[string]func(context.Context, string) error{
+		"start":   gpcprocessmgr.StartGroup,
+		"stop":    gpcprocessmgr.StopGroup,
+		"restart": gpcprocessmgr.RestartGroup,
+	}
+	if len(args) != 2 || actions[args[0]] == nil {
+		return nil, fmt.Errorf("usage: group <start|stop|restart> <tag>")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	if err := actions[args[0]](ctx, args[1]); err != nil {
+		return nil, err
+	}
+	return []string{fmt.Sprintf("%s: %s", args[0], strings.Join(gpcprocessmgr.TaggedProcesses(args[1]), ", "))}, nil
+}
+
+// cmdRollout restarts the instances of a task one after the other: rollout <task>. Each instance
+// may take its ReadinessTimeout, so there is no command timeout. A shutdown ends the rollout,
+// Stop waits for the connection.
+func cmdRollout(args []string) ([]string, error) {
+	sTemplate, err := requireName(args)
+	if err != nil {
+		return nil, err
+	}
+	return nil, gpcprocessmgr.RollingRestart(gpcprocessmgr.ShutdownContext(), sTemplate)
+}
+
+// cmdMaintenance shows the maintenances, or puts a process or the whole controller (all) into
+// maintenance or ends it: maintenance [on <name|all> [duration] | off <name|all>]
+func cmdMaintenance(args []string) ([]string, error) {
+	const sUsage = "usage: maintenance [on <name|all> [duration] | off <name|all>]"
+	if len(args) == 0 {
+		lines := []string{}
+		for _, window := range gpcprocessmgr.GetMaintenance() {
+			sName, sUntil := window.Process, "off"
+			if len(sName) == 0 {
+				sName = "all"
+			}
+			if !window.Until.IsZero() {
+				sUntil = window.Until.Format(time.RFC3339)
+			}
+			lines = append(lines, fmt.Sprintf("%-24s since %s until %s", sName, window.Since.Format(time.RFC3339), sUntil))
+		}
+		return lines, nil
+	}
+	if len(args) < 2 {
+		return nil, fmt.Errorf(sUsage)
+	}
+	procName := args[1]
+	if procName == "all" {
+		procName = ""
+	}
+	switch {
+	case args[0] == "on" && len(args) <= 3:
+		var duration time.Duration
+		if len(args) == 3 {
+			var err error
+			if duration, err = time.ParseDuration(args[2]); err != nil || duration <= 0 {
+				return nil, fmt.Errorf("invalid duration <%s>, e.g. 30m", args[2])
+			}
+		}
+		return nil, gpcprocessmgr.SetMaintenance(procName, duration)
+	case args[0] == "off" && len(args) == 2:
+		return nil, gpcprocessmgr.EndMaintenance(procName)
+	}
+	return nil, fmt.Errorf(sUsage)
+}
+
+// cmdLogLevel shows or changes the log level of the controller: loglevel [debug|info|warn|error]
+func cmdLogLevel(args []string) ([]string, error) {
+	if len(args) > 1 {
+		return nil, fmt.Errorf("usage: loglevel [debug|info|warn|error]")
+	}
+	if len(args) == 1 {
+		if err := gpclogging.SetLogLevel(args[0]); err != nil {
+			return nil, err
+		}
+		gpclogging.Warn("Log level changed to <%s> via control socket.", gpclogging.GetLogLevel())
+	}
+	return []string{gpclogging.GetLogLevel()}, nil
+}
+
+// cmdLogStats returns the counters of the logging of the controller
+func cmdLogStats(args []string) ([]string, error) {
+	metrics := gpclogging.GetMetrics()
+	return []string{
+		fmt.Sprintf("lines written  %d", metrics.LinesWritten),
+		fmt.Sprintf("bytes written  %d", metrics.BytesWritten),
+		fmt.Sprintf("rotations      %d", metrics.Rotations),
+		fmt.Sprintf("purged files   %d", metrics.PurgedFiles),
+		fmt.Sprintf("compressions   %d", metrics.Compressions),
+		fmt.Sprintf("write errors   %d", metrics.WriteErrors),
+	}, nil
+}
+
+// cmdLaunches returns the latest launch contexts of a process, one JSON object per line
+func cmdLaunches(args []string) ([]string, error) {
+	procName, err := requireName(args)
+	if err != nil {
+		return nil, err
+	}
+	history, err := gpcprocessmgr.GetLaunchHistory(procName)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, 0, len(history))
+	for _, launchCtx := range history {
+		encoded, err := json.Marshal(&launchCtx)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, string(encoded))
+	}
+	return lines, nil
+}
+
+// cmdStats returns the uptime, restart and crash statistics of the processes: stats [name], with a
+// name as JSON with the exit history
+func cmdStats(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return gpcprocessmgr.GetStatsText(), nil
+	}
+	if len(args) > 1 {
+		return nil, fmt.Errorf("usage: stats [name]")
+	}
+	stats, err := gpcprocessmgr.GetStats(args[0])
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(&stats)
+	if err != nil {
+		return nil, err
+	}
+	return []string{string(encoded)}, nil
+}
+
+// cmdTail returns the last lines of the output log of a process: tail <name> [lines]
+func cmdTail(args []string) ([]string, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("usage: tail <name> [lines]")
+	}
+	numLines := defTailLines
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid number of lines <%s>", args[1])
+		}
+		numLines = n
+	}
+
+	return gpcprocessmgr.TailProcessLog(args[0], numLines)
+}
+
+// cmdOutput returns the latest output lines a process keeps in memory: output <name> [lines]
+func cmdOutput(args []string) ([]string, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("usage: output <name> [lines]")
+	}
+	numLines := 0
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid number of lines <%s>", args[1])
+		}
+		numLines = n
+	}
+
+	return gpcprocessmgr.RecentOutput(args[0], numLines)
+}
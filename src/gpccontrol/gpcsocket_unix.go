@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package gpccontrol
+
+import (
+	"net"
+	"os"
+	"time"
+)
+
+// listen opens the unix domain socket of the controller
+//------------------------------------------------------------------------------
+func listen(socketPath string) (net.Listener, error) {
+	// Remove a stale socket left over by a previous run
+	os.Remove(socketPath)
+
+	return net.Listen("unix", socketPath)
+}
+
+// dial connects to the unix domain socket of a controller
+//------------------------------------------------------------------------------
+func dial(socketPath string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("unix", socketPath, timeout)
+}
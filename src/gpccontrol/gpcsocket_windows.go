@@ -0,0 +1,236 @@
+package gpccontrol
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// On Windows the control socket is a named pipe. A SocketPath that is not a pipe name
+// (\\.\pipe\...) is made absolute and used as name of the pipe, e.g. ./gpc.sock in C:\gpc
+// becomes \\.\pipe\C:/gpc/gpc.sock.
+
+// consts of the named pipe API
+const (
+	pipePrefix = `\\.\pipe\`
+
+	pipeAccessDuplex          = 0x3
+	fileFlagFirstPipeInstance = 0x80000
+	pipeRejectRemoteClients   = 0x8
+	pipeUnlimitedInstances    = 255
+	pipeBufferSize            = 4096
+
+	errorPipeBusy      syscall.Errno = 231
+	errorNoData        syscall.Errno = 232
+	errorPipeConnected syscall.Errno = 535
+)
+
+var (
+	modkernel32             = syscall.NewLazyDLL("kernel32.dll")
+	procCreateNamedPipeW    = modkernel32.NewProc("CreateNamedPipeW")
+	procConnectNamedPipe    = modkernel32.NewProc("ConnectNamedPipe")
+	procWaitNamedPipeW      = modkernel32.NewProc("WaitNamedPipeW")
+	procGetOverlappedResult = modkernel32.NewProc("GetOverlappedResult")
+)
+
+// pipeAddr is the net.Addr of a named pipe
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
+
+// pipeConn is a connected instance of a named pipe. The handle is opened for overlapped
+// I/O, so the os package reads and writes it with deadlines.
+type pipeConn struct {
+	*os.File
+}
+
+func (c pipeConn) LocalAddr() net.Addr  { return pipeAddr(c.Name()) }
+func (c pipeConn) RemoteAddr() net.Addr { return pipeAddr(c.Name()) }
+
+// pipeListener accepts clients on a named pipe, one pipe instance per connection
+type pipeListener struct {
+	sName      string
+	mux        sync.Mutex
+	handle     syscall.Handle // the instance for the next client
+	bAccepting bool           // Accept waits on handle, it closes the handle itself
+	bClosed    bool
+}
+
+// pipeName returns the name of the named pipe for a SocketPath
+//------------------------------------------------------------------------------
+func pipeName(socketPath string) string {
+	if strings.HasPrefix(strings.ToLower(socketPath), pipePrefix) {
+		return socketPath
+	}
+	if absPath, err := filepath.Abs(socketPath); err == nil {
+		socketPath = absPath
+	}
+	// a pipe name may contain any character but the backslash
+	return pipePrefix + strings.ReplaceAll(socketPath, `\`, "/")
+}
+
+// listen creates the named pipe of the controller
+//------------------------------------------------------------------------------
+func listen(socketPath string) (net.Listener, error) {
+	listener := &pipeListener{sName: pipeName(socketPath)}
+
+	// The first instance fails if another program, e.g. a running controller, has the pipe already
+	handle, err := listener.newInstance(true)
+	if err != nil {
+		return nil, err
+	}
+	listener.handle = handle
+	return listener, nil
+}
+
+// newInstance creates an instance of the named pipe that waits for a client
+//------------------------------------------------------------------------------
+func (l *pipeListener) newInstance(bFirst bool) (syscall.Handle, error) {
+	name, err := syscall.UTF16PtrFromString(l.sName)
+	if err != nil {
+		return syscall.InvalidHandle, err
+	}
+	openMode := uint32(pipeAccessDuplex | syscall.FILE_FLAG_OVERLAPPED)
+	if bFirst {
+		openMode |= fileFlagFirstPipeInstance
+	}
+	r, _, callErr := procCreateNamedPipeW.Call(uintptr(unsafe.Pointer(name)), uintptr(openMode), pipeRejectRemoteClients,
+		pipeUnlimitedInstances, pipeBufferSize, pipeBufferSize, 0, 0)
+	if syscall.Handle(r) == syscall.InvalidHandle {
+		return syscall.InvalidHandle, callErr
+	}
+	return syscall.Handle(r), nil
+}
+
+//Accept waits for the next client of the named pipe
+//#########################################################
+func (l *pipeListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.acceptInstance()
+		// errorNoData: the client gave up before it was accepted, wait for the next one
+		if err != errorNoData {
+			return conn, err
+		}
+	}
+}
+
+// acceptInstance waits until a client connects to the current instance of the pipe
+//------------------------------------------------------------------------------
+func (l *pipeListener) acceptInstance() (net.Conn, error) {
+	l.mux.Lock()
+	if l.bClosed {
+		l.mux.Unlock()
+		return nil, net.ErrClosed
+	}
+	if l.handle == syscall.InvalidHandle {
+		handle, err := l.newInstance(false)
+		if err != nil {
+			l.mux.Unlock()
+			return nil, err
+		}
+		l.handle = handle
+	}
+	handle := l.handle
+	l.bAccepting = true
+	l.mux.Unlock()
+
+	// Without an event the handle itself is signaled when the client connects
+	overlapped := new(syscall.Overlapped)
+	r, _, err := procConnectNamedPipe.Call(uintptr(handle), uintptr(unsafe.Pointer(overlapped)))
+	if r == 0 {
+		switch err {
+		case errorPipeConnected:
+			// connected between the creation of the instance and the call
+			err = nil
+		case syscall.ERROR_IO_PENDING:
+			// Close may have missed the pending call
+			l.mux.Lock()
+			if l.bClosed {
+				syscall.CancelIoEx(handle, overlapped)
+			}
+			l.mux.Unlock()
+			var transferred uint32
+			r, _, err = procGetOverlappedResult.Call(uintptr(handle), uintptr(unsafe.Pointer(overlapped)), uintptr(unsafe.Pointer(&transferred)), 1)
+			if r != 0 {
+				err = nil
+			}
+		}
+	} else {
+		err = nil
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.bAccepting = false
+	l.handle = syscall.InvalidHandle
+	if l.bClosed || err != nil {
+		syscall.CloseHandle(handle)
+		if l.bClosed {
+			return nil, net.ErrClosed
+		}
+		return nil, err
+	}
+
+	// The next client can connect while this one is answered
+	if next, err := l.newInstance(false); err == nil {
+		l.handle = next
+	}
+	return pipeConn{os.NewFile(uintptr(handle), l.sName)}, nil
+}
+
+//Close stops accepting clients, connections already accepted are not affected
+//#########################################################
+func (l *pipeListener) Close() error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if l.bClosed {
+		return nil
+	}
+	l.bClosed = true
+	if l.handle != syscall.InvalidHandle {
+		if l.bAccepting {
+			// Accept returns and closes the handle
+			syscall.CancelIoEx(l.handle, nil)
+		} else {
+			syscall.CloseHandle(l.handle)
+			l.handle = syscall.InvalidHandle
+		}
+	}
+	return nil
+}
+
+//Addr returns the name of the named pipe
+//#########################################################
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr(l.sName)
+}
+
+// dial connects to the named pipe of a controller, waiting up to timeout while all instances are busy
+//------------------------------------------------------------------------------
+func dial(socketPath string, timeout time.Duration) (net.Conn, error) {
+	sName := pipeName(socketPath)
+	name, err := syscall.UTF16PtrFromString(sName)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		handle, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_OVERLAPPED, 0)
+		if err == nil {
+			return pipeConn{os.NewFile(uintptr(handle), sName)}, nil
+		}
+		remaining := time.Until(deadline)
+		if err != errorPipeBusy || remaining <= 0 {
+			return nil, err
+		}
+		procWaitNamedPipeW.Call(uintptr(unsafe.Pointer(name)), uintptr(max(remaining.Milliseconds(), 1)))
+	}
+}
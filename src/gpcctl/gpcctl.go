@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"gpccontrol"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// const strings
+const (
+	GPCCtlVersion = "0.1"
+	// Default control socket, must match the controller configuration
+	GPCDefSocketPath = "./gpc.sock"
+)
+
+//#########################################################
+//#########################################################
+func printHelp() {
+	fmt.Println("############################################################")
+	fmt.Println("# gpcctl v", GPCCtlVersion, ". Controls a running Process Controller.")
+	fmt.Println("# ")
+	fmt.Println("# Usage: gpcctl [-s <socket>] <command> [arguments]")
+	fmt.Println("# ")
+	fmt.Println("# Arguments:")
+	fmt.Println("# ")
+	fmt.Println("#   -h")
+	fmt.Println("#       Prints this help output")
+	fmt.Println("#   -s <path to socket>")
+	fmt.Println("#       Path to the control socket of the controller. Default is", GPCDefSocketPath)
+	fmt.Println("#   -api <host:port>")
+	fmt.Println("#       HTTP API of the controller (API.ListenAddress), needed by tail -f. https://host:port with TLS")
+	fmt.Println("#   -token <token>")
+	fmt.Println("#       Bearer token of the HTTP API. Default is the environment variable GPC_API_TOKEN")
+	fmt.Println("#   -cacert <file>, -cert <file>, -key <file>")
+	fmt.Println("#       CA certificate to verify the HTTP API with, client certificate and key for mTLS")
+	fmt.Println("# ")
+	fmt.Println("# Commands:")
+	fmt.Println("# ")
+	fmt.Println("#   status [-json]          Shows the state of all processes, -json one JSON object per process")
+	fmt.Println("#   tui [seconds]           Live table of the processes with CPU, memory and restarts, refreshed every")
+	fmt.Println("#                           2 seconds. Keys: up/down select, a start, s stop, r restart, z suspend,")
+	fmt.Println("#                           u resume, l show/hide the output of the selected process, q quit")
+	fmt.Println("#   start <name>            Starts a process")
+	fmt.Println("#   stop <name>             Stops a process, it will not be restarted")
+	fmt.Println("#   restart <name>          Stops and starts a process")
+	fmt.Println("#   suspend <name>          Freezes a running process and its children (SIGSTOP, NtSuspendProcess)")
+	fmt.Println("#   resume <name>           Lets a suspended process continue, or starts a process again that was")
+	fmt.Println("#                           quarantined for a crash loop")
+	fmt.Println("#   signal <name> <signal>  Sends a signal to the main process, e.g. HUP to reload its configuration:")
+	fmt.Println("#                           HUP, INT, QUIT, TERM, USR1, USR2, WINCH or CTRL_BREAK on Windows")
+	fmt.Println("#   input <name> <text>     Writes a line to the stdin of a task with StdinPipe, e.g. a console command")
+	fmt.Println("#   group <action> <tag>    Starts, stops or restarts all tasks with a tag in dependency order,")
+	fmt.Println("#                           action start, stop or restart. restart stops all of them before starting them")
+	fmt.Println("#   rollout <task>          Restarts the instances of a task with Instances one at a time, each after")
+	fmt.Println("#                           the one before passed its ReadinessURL")
+	fmt.Println("#   maintenance             Shows the processes in maintenance, all for the whole controller")
+	fmt.Println("#   maintenance on <name|all> [duration]")
+	fmt.Println("#                           Processes that exit are not restarted until the maintenance is off or")
+	fmt.Println("#                           the duration (e.g. 30m) is over, then they are restarted")
+	fmt.Println("#   maintenance off <name|all>")
+	fmt.Println("#                           Ends the maintenance of a process or of the whole controller")
+	fmt.Println("#   tail <name> [lines]     Prints the last lines of the process output")
+	fmt.Println("#   tail -f <name> [lines]  Prints the last lines and then the new output until Ctrl+C, through -api")
+	fmt.Println("#   output <name> [lines]   Prints the latest output lines kept in memory (RecentOutputLines), of earlier runs too")
+	fmt.Println("#   launches <name>         Shows how the latest runs were launched (argv, env, user, ...)")
+	fmt.Println("#   stats [name]            Shows uptime, starts, restarts, crashes and MTBF of all processes since the")
+	fmt.Println("#                           controller started, of one process as JSON with its latest exits")
+	fmt.Println("#   logstats                Shows counters of the controller's own logging (lines, bytes, errors, ...)")
+	fmt.Println("#   loglevel [level]        Shows or sets the log level of the controller: debug, info, warn or error")
+	fmt.Println("#   reload                  Reloads the configuration file and applies the changes")
+	fmt.Println("#   add [-persist] <json>   Adds a task given as JSON object (or @file with the JSON) and starts it")
+	fmt.Println("#   remove [-persist] <name>")
+	fmt.Println("#                           Stops a task and removes it")
+	fmt.Println("#                           -persist writes the change back to the configuration file")
+	fmt.Println("#   export <archive>        Writes configuration, runtime tasks and process states to a zip archive")
+	fmt.Println("#   import <archive>        Replaces the configuration file by the one of an archive and applies it")
+	fmt.Println("#                           Archive paths are relative to the controller's working directory")
+	fmt.Println("############################################################")
+}
+
+//prepareCommand puts the task JSON of an add command on a single line, as the control
+//channel only takes one line. "@file" reads the JSON from a file.
+//#########################################################
+func prepareCommand(args []string) ([]string, error) {
+	if args[0] != "add" || len(args) < 2 {
+		return args, nil
+	}
+	jsonIndex := 1
+	if args[1] == "-persist" {
+		jsonIndex = 2
+	}
+	if len(args) <= jsonIndex {
+		return args, nil
+	}
+
+	taskJSON := []byte(strings.Join(args[jsonIndex:], " "))
+	if strings.HasPrefix(args[jsonIndex], "@") {
+		fileBytes, err := os.ReadFile(strings.TrimPrefix(args[jsonIndex], "@"))
+		if err != nil {
+			return nil, err
+		}
+		taskJSON = fileBytes
+	}
+
+	var compactJSON bytes.Buffer
+	if err := json.Compact(&compactJSON, taskJSON); err != nil {
+		return nil, fmt.Errorf("invalid task JSON: %s", err.Error())
+	}
+	return append(append([]string{}, args[:jsonIndex]...), compactJSON.String()), nil
+}
+
+//apiAccess holds how to reach the HTTP API of the controller
+type apiAccess struct {
+	sAddress    string // host:port, or an http:// or https:// URL
+	sToken      string // bearer token, empty => none
+	sCACert     string // CA certificate of the API's certificate, empty => the system roots
+	sClientCert string // client certificate and key for mTLS, empty => none
+	sClientKey  string
+}
+
+//client returns an HTTP client and the base URL of the API
+//#########################################################
+func (a apiAccess) client() (*http.Client, string, error) {
+	sBaseURL := a.sAddress
+	if !strings.Contains(sBaseURL, "://") {
+		sBaseURL = "http://" + sBaseURL
+	}
+	tlsConfig := &tls.Config{}
+	if len(a.sCACert) > 0 {
+		caPEM, err := os.ReadFile(a.sCACert)
+		if err != nil {
+			return nil, "", err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, "", fmt.Errorf("no certificates found in <%s>", a.sCACert)
+		}
+	}
+	if len(a.sClientCert) > 0 {
+		certificate, err := tls.LoadX509KeyPair(a.sClientCert, a.sClientKey)
+		if err != nil {
+			return nil, "", err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}, strings.TrimSuffix(sBaseURL, "/"), nil
+}
+
+//followOutput prints the live output of a process from the stream of the HTTP API until the
+//controller ends the stream: tail -f <name> [lines]
+//#########################################################
+func followOutput(api apiAccess, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: tail -f <name> [lines]")
+	}
+	if len(api.sAddress) == 0 {
+		return fmt.Errorf("tail -f needs the HTTP API of the controller, use -api <host:port>")
+	}
+	client, sBaseURL, err := api.client()
+	if err != nil {
+		return err
+	}
+	streamURL := sBaseURL + "/processes/" + url.PathEscape(args[0]) + "/stream"
+	if len(args) == 2 {
+		streamURL += "?lines=" + url.QueryEscape(args[1])
+	}
+
+	request, err := http.NewRequest(http.MethodGet, streamURL, nil)
+	if err != nil {
+		return err
+	}
+	if len(api.sToken) > 0 {
+		request.Header.Set("Authorization", "Bearer "+api.sToken)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("can not connect to controller at <%s>: %s", api.sAddress, err.Error())
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+		return fmt.Errorf("%s", strings.TrimSpace(string(message)))
+	}
+
+	// Only data lines are output, events like restart are shown as a note
+	sEvent := ""
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		sLine := scanner.Text()
+		switch {
+		case strings.HasPrefix(sLine, "event: "):
+			sEvent = strings.TrimPrefix(sLine, "event: ")
+		case strings.HasPrefix(sLine, "data: ") && len(sEvent) == 0:
+			fmt.Println(strings.TrimPrefix(sLine, "data: "))
+		case strings.HasPrefix(sLine, "data: "):
+			fmt.Fprintf(os.Stderr, "--- %s %s ---\n", strings.TrimPrefix(sLine, "data: "), sEvent)
+		case len(sLine) == 0:
+			sEvent = ""
+		}
+	}
+	return scanner.Err()
+}
+
+//#########################################################
+//#########################################################
+func main() {
+
+	// ---- Local Variables
+	var bCmdFlagH bool
+	var sCmdFlagS string
+	var api apiAccess
+
+	// SETUP CMD LINE ARGUMENTS
+	flag.BoolVar(&bCmdFlagH, "h", false, "Prints help output")
+	flag.StringVar(&sCmdFlagS, "s", GPCDefSocketPath, "Path to the control socket of the controller")
+	flag.StringVar(&api.sAddress, "api", "", "Address of the HTTP API of the controller, for tail -f")
+	flag.StringVar(&api.sToken, "token", os.Getenv("GPC_API_TOKEN"), "Bearer token of the HTTP API")
+	flag.StringVar(&api.sCACert, "cacert", "", "CA certificate to verify the HTTP API with")
+	flag.StringVar(&api.sClientCert, "cert", "", "Client certificate for the HTTP API (mTLS)")
+	flag.StringVar(&api.sClientKey, "key", "", "Key of the client certificate")
+	flag.Parse()
+
+	if bCmdFlagH || flag.NArg() == 0 {
+		printHelp()
+		return
+	}
+
+	if flag.NArg() >= 2 && flag.Arg(0) == "tail" && flag.Arg(1) == "-f" {
+		if err := followOutput(api, flag.Args()[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "tui" {
+		if err := runTUI(sCmdFlagS, flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	command, err := prepareCommand(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err.Error())
+		os.Exit(1)
+	}
+
+	lines, err := gpccontrol.SendCommand(sCmdFlagS, command)
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"gpccontrol"
+	"gpcprocessmgr"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// consts of the terminal UI
+const (
+	defTUIInterval = 2 * time.Second
+
+	// escape sequences
+	ansiReset      = "\x1b[0m"
+	ansiBold       = "\x1b[1m"
+	ansiReverse    = "\x1b[7m"
+	ansiRed        = "\x1b[31m"
+	ansiGreen      = "\x1b[32m"
+	ansiYellow     = "\x1b[33m"
+	ansiAltScreen  = "\x1b[?1049h\x1b[?25l" // alternate screen, cursor hidden
+	ansiMainScreen = "\x1b[?25h\x1b[?1049l"
+)
+
+// keys of the terminal UI, other keys are passed as the character
+const (
+	keyUp   = "up"
+	keyDown = "down"
+	keyQuit = "quit"
+)
+
+// colors of the process states
+var tuiStateColors = map[string]string{
+	"running": ansiGreen, "done": ansiGreen,
+	"error": ansiRed, "timeout": ansiRed, "quarantined": ansiRed,
+	"stopped": ansiYellow, "exited": ansiYellow, "condition": ansiYellow, "suspended": ansiYellow,
+	"starting": ansiYellow, "stopping": ansiYellow,
+}
+
+// commands of the keys that act on the selected process
+var tuiKeyCommands = map[string]string{"a": "start", "s": "stop", "r": "restart", "z": "suspend", "u": "resume"}
+
+//tuiView is the state of the terminal UI
+type tuiView struct {
+	sSocketPath string
+	statuses    []gpcprocessmgr.ProcessStatus
+	sSelected   string   // name of the selected process, kept across refreshes
+	firstRow    int      // first process shown when they do not fit on the screen
+	bShowOutput bool     // output pane of the selected process is shown
+	outputLines []string // last lines of the output log of the selected process
+	sMessage    string   // result of the last action or refresh error
+}
+
+//runTUI shows the processes of the controller as a table that is refreshed every interval,
+//with keys to start, stop, restart, suspend and resume the selected process and to show its output:
+//tui [seconds]
+//#########################################################
+func runTUI(sSocketPath string, args []string) error {
+	interval := defTUIInterval
+	if len(args) > 1 {
+		return fmt.Errorf("usage: tui [seconds]")
+	}
+	if len(args) == 1 {
+		seconds, err := strconv.ParseFloat(args[0], 64)
+		if err != nil || seconds <= 0 {
+			return fmt.Errorf("invalid refresh interval <%s>", args[0])
+		}
+		interval = time.Duration(seconds * float64(time.Second))
+	}
+	// fails early when the controller is not reachable, before the screen is taken over
+	if _, err := gpccontrol.SendCommand(sSocketPath, []string{"status", "-json"}); err != nil {
+		return err
+	}
+
+	restoreTerminal, err := enterRawMode()
+	if err != nil {
+		return err
+	}
+	defer restoreTerminal()
+	fmt.Print(ansiAltScreen)
+	defer fmt.Print(ansiMainScreen)
+
+	keys := make(chan string)
+	go readKeys(keys)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	view := &tuiView{sSocketPath: sSocketPath}
+	for {
+		view.refresh()
+		view.draw()
+		select {
+		case sKey, ok := <-keys:
+			if !ok || sKey == keyQuit || sKey == "q" {
+				return nil
+			}
+			view.handleKey(sKey)
+		case <-ticker.C:
+		}
+	}
+}
+
+// readKeys sends the keys pressed to a channel, arrows as keyUp/keyDown, and closes it
+// when the input ends
+//------------------------------------------------------------------------------
+func readKeys(keys chan<- string) {
+	defer close(keys)
+	buffer := make([]byte, 32)
+	for {
+		n, err := os.Stdin.Read(buffer)
+		if err != nil {
+			return
+		}
+		input := buffer[:n]
+		for len(input) > 0 {
+			switch {
+			case len(input) >= 3 && input[0] == 0x1b && (input[1] == '[' || input[1] == 'O'):
+				switch input[2] {
+				case 'A':
+					keys <- keyUp
+				case 'B':
+					keys <- keyDown
+				}
+				input = input[3:]
+			case input[0] == 0x03: // Ctrl+C, raw mode does not turn it into a signal
+				keys <- keyQuit
+				input = input[1:]
+			default:
+				keys <- string(input[0])
+				input = input[1:]
+			}
+		}
+	}
+}
+
+// refresh reads the state of the processes and the output of the selected one
+//------------------------------------------------------------------------------
+func (v *tuiView) refresh() {
+	lines, err := gpccontrol.SendCommand(v.sSocketPath, []string{"status", "-json"})
+	if err != nil {
+		v.sMessage = "Error: " + err.Error()
+		return
+	}
+	statuses := make([]gpcprocessmgr.ProcessStatus, 0, len(lines))
+	for _, sLine := range lines {
+		var procStatus gpcprocessmgr.ProcessStatus
+		if err := json.Unmarshal([]byte(sLine), &procStatus); err != nil {
+			v.sMessage = "Error: " + err.Error()
+			return
+		}
+		statuses = append(statuses, procStatus)
+	}
+	v.statuses = statuses
+	if v.selectedIndex() < 0 && len(v.statuses) > 0 {
+		v.sSelected = v.statuses[0].Name
+	}
+
+	v.outputLines = nil
+	if v.bShowOutput && len(v.sSelected) > 0 {
+		rows, _ := terminalSize()
+		v.outputLines, err = gpccontrol.SendCommand(v.sSocketPath, []string{"tail", v.sSelected, strconv.Itoa(max(rows/2-2, 1))})
+		if err != nil {
+			v.outputLines = []string{"(" + err.Error() + ")"}
+		}
+	}
+}
+
+// selectedIndex returns the index of the selected process, -1 if it is gone
+//------------------------------------------------------------------------------
+func (v *tuiView) selectedIndex() int {
+	for statusIndex, procStatus := range v.statuses {
+		if procStatus.Name == v.sSelected {
+			return statusIndex
+		}
+	}
+	return -1
+}
+
+// handleKey moves the selection or runs the command of a key on the selected process
+//------------------------------------------------------------------------------
+func (v *tuiView) handleKey(sKey string) {
+	selectedIndex := v.selectedIndex()
+	switch sKey {
+	case keyUp, "k":
+		if selectedIndex > 0 {
+			v.sSelected = v.statuses[selectedIndex-1].Name
+		}
+	case keyDown, "j":
+		if selectedIndex >= 0 && selectedIndex < len(v.statuses)-1 {
+			v.sSelected = v.statuses[selectedIndex+1].Name
+		}
+	case "l", "\r":
+		v.bShowOutput = !v.bShowOutput
+	default:
+		sCommand, found := tuiKeyCommands[sKey]
+		if !found || selectedIndex < 0 {
+			return
+		}
+		// the command may take until the process stopped, show what is going on
+		v.sMessage = fmt.Sprintf("%s <%s> ...", sCommand, v.sSelected)
+		v.draw()
+		if _, err := gpccontrol.SendCommand(v.sSocketPath, []string{sCommand, v.sSelected}); err != nil {
+			v.sMessage = fmt.Sprintf("%s <%s> failed: %s", sCommand, v.sSelected, err.Error())
+		} else {
+			v.sMessage = fmt.Sprintf("%s <%s> done", sCommand, v.sSelected)
+		}
+	}
+}
+
+// draw writes the screen: title, table of the processes, the output pane and the keys
+//------------------------------------------------------------------------------
+func (v *tuiView) draw() {
+	rows, cols := terminalSize()
+	var screen []string
+	screen = append(screen, ansiBold+fitLine(fmt.Sprintf("gpcctl tui  %s  %s", v.sSocketPath, time.Now().Format("15:04:05")), cols)+ansiReset)
+	screen = append(screen, ansiReverse+fitLine(fmt.Sprintf("%-18s %-11s %7s %6s %7s %8s %9s  %s", "NAME", "STATE", "PID", "CPU%", "MEM MB", "RESTARTS", "UPTIME", "EXIT / ERROR"), cols)+ansiReset)
+
+	tableRows := rows - 4
+	if v.bShowOutput {
+		tableRows -= len(v.outputLines) + 1
+	}
+	tableRows = max(tableRows, 1)
+	selectedIndex := v.selectedIndex()
+	if selectedIndex < v.firstRow {
+		v.firstRow = selectedIndex
+	}
+	if selectedIndex >= v.firstRow+tableRows {
+		v.firstRow = selectedIndex - tableRows + 1
+	}
+	v.firstRow = max(v.firstRow, 0)
+
+	for statusIndex := v.firstRow; statusIndex < len(v.statuses) && statusIndex < v.firstRow+tableRows; statusIndex++ {
+		procStatus := v.statuses[statusIndex]
+		sCPU, sMemory, sUptime := "", "", ""
+		if !procStatus.Usage.Sampled.IsZero() {
+			sCPU = fmt.Sprintf("%.1f", procStatus.Usage.CPUPercent)
+			sMemory = strconv.FormatUint(procStatus.Usage.MemoryBytes/(1024*1024), 10)
+		}
+		if procStatus.Uptime > 0 {
+			sUptime = procStatus.Uptime.Round(time.Second).String()
+		}
+		sExit := procStatus.LastError
+		if len(procStatus.WaitingOn) > 0 {
+			sExit = "waiting on: " + procStatus.WaitingOn
+		}
+		if len(sExit) == 0 && procStatus.LastExitCode >= 0 {
+			sExit = strconv.Itoa(procStatus.LastExitCode)
+		}
+		sLine := fitLine(fmt.Sprintf("%-18s %-11s %7d %6s %7s %8d %9s  %s", procStatus.Name, procStatus.State, procStatus.PID,
+			sCPU, sMemory, procStatus.RestartCount, sUptime, sExit), cols)
+		switch {
+		case statusIndex == selectedIndex:
+			sLine = ansiReverse + sLine + ansiReset
+		case len(tuiStateColors[procStatus.State]) > 0:
+			sLine = tuiStateColors[procStatus.State] + sLine + ansiReset
+		}
+		screen = append(screen, sLine)
+	}
+	for len(screen) < tableRows+2 {
+		screen = append(screen, "")
+	}
+
+	if v.bShowOutput {
+		screen = append(screen, ansiBold+fitLine(fmt.Sprintf("--- output of %s ---", v.sSelected), cols)+ansiReset)
+		for _, sLine := range v.outputLines {
+			screen = append(screen, fitLine(sLine, cols))
+		}
+	}
+	screen = append(screen, fitLine(v.sMessage, cols))
+	screen = append(screen, ansiBold+fitLine("up/down select  a start  s stop  r restart  z suspend  u resume  l output  q quit", cols)+ansiReset)
+
+	// raw mode: lines end in \r\n, each one clears what is left of the previous screen
+	fmt.Print("\x1b[H" + strings.Join(screen, "\x1b[K\r\n") + "\x1b[K\x1b[J")
+}
+
+// fitLine cuts a line to the width of the terminal. Control characters in the output of the
+// processes are dropped as they would mess up the screen, tabs become spaces.
+//------------------------------------------------------------------------------
+func fitLine(sLine string, cols int) string {
+	sLine = strings.Map(func(char rune) rune {
+		if char == '\t' {
+			return ' '
+		}
+		if char < ' ' {
+			return -1
+		}
+		return char
+	}, sLine)
+	if utf8.RuneCountInString(sLine) <= cols {
+		return sLine
+	}
+	return string([]rune(sLine)[:cols])
+}
@@ -0,0 +1,54 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// enterRawMode switches the terminal to read single keys without echo, with stty. Returns
+// the function that restores the previous mode.
+//------------------------------------------------------------------------------
+func enterRawMode() (func(), error) {
+	sSavedMode, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("tui needs a terminal: %s", err.Error())
+	}
+	if _, err = stty("raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("Can not switch the terminal to raw mode: %s", err.Error())
+	}
+	return func() { stty(strings.TrimSpace(sSavedMode)) }, nil
+}
+
+// terminalSize returns rows and columns of the terminal, 24x80 if unknown
+//------------------------------------------------------------------------------
+func terminalSize() (rows int, cols int) {
+	sSize, err := stty("size")
+	if err != nil {
+		return 24, 80
+	}
+	fields := strings.Fields(sSize)
+	if len(fields) != 2 {
+		return 24, 80
+	}
+	rows, rowsErr := strconv.Atoi(fields[0])
+	cols, colsErr := strconv.Atoi(fields[1])
+	if rowsErr != nil || colsErr != nil || rows <= 0 || cols <= 0 {
+		return 24, 80
+	}
+	return rows, cols
+}
+
+// stty runs stty on the terminal of stdin
+//------------------------------------------------------------------------------
+func stty(args ...string) (string, error) {
+	sttyCmd := exec.Command("stty", args...)
+	sttyCmd.Stdin = os.Stdin
+	output, err := sttyCmd.Output()
+	return string(output), err
+}
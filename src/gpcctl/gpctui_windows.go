@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// console modes
+const (
+	enableProcessedInput            = 0x1
+	enableLineInput                 = 0x2
+	enableEchoInput                 = 0x4
+	enableVirtualTerminalInput      = 0x200 // arrows are read as escape sequences
+	enableVirtualTerminalProcessing = 0x4   // escape sequences are interpreted on output
+)
+
+var (
+	modkernel32                    = syscall.NewLazyDLL("kernel32.dll")
+	procGetConsoleMode             = modkernel32.NewProc("GetConsoleMode")
+	procSetConsoleMode             = modkernel32.NewProc("SetConsoleMode")
+	procGetConsoleScreenBufferInfo = modkernel32.NewProc("GetConsoleScreenBufferInfo")
+)
+
+// consoleScreenBufferInfo is the CONSOLE_SCREEN_BUFFER_INFO of kernel32
+type consoleScreenBufferInfo struct {
+	size              [2]int16
+	cursorPosition    [2]int16
+	attributes        uint16
+	window            [4]int16 // left, top, right, bottom
+	maximumWindowSize [2]int16
+}
+
+// enterRawMode switches the console to read single keys without echo and to interpret escape
+// sequences. Returns the function that restores the previous modes.
+//------------------------------------------------------------------------------
+func enterRawMode() (func(), error) {
+	stdin, stdout := os.Stdin.Fd(), os.Stdout.Fd()
+	var inMode, outMode uint32
+	if result, _, err := procGetConsoleMode.Call(stdin, uintptr(unsafe.Pointer(&inMode))); result == 0 {
+		return nil, fmt.Errorf("tui needs a console: %s", err.Error())
+	}
+	if result, _, err := procGetConsoleMode.Call(stdout, uintptr(unsafe.Pointer(&outMode))); result == 0 {
+		return nil, fmt.Errorf("tui needs a console: %s", err.Error())
+	}
+
+	rawInMode := inMode&^(enableProcessedInput|enableLineInput|enableEchoInput) | enableVirtualTerminalInput
+	if result, _, err := procSetConsoleMode.Call(stdin, uintptr(rawInMode)); result == 0 {
+		return nil, fmt.Errorf("Can not switch the console to raw mode: %s", err.Error())
+	}
+	if result, _, err := procSetConsoleMode.Call(stdout, uintptr(outMode|enableVirtualTerminalProcessing)); result == 0 {
+		procSetConsoleMode.Call(stdin, uintptr(inMode))
+		return nil, fmt.Errorf("console does not support escape sequences: %s", err.Error())
+	}
+	return func() {
+		procSetConsoleMode.Call(stdin, uintptr(inMode))
+		procSetConsoleMode.Call(stdout, uintptr(outMode))
+	}, nil
+}
+
+// terminalSize returns rows and columns of the console window, 24x80 if unknown
+//------------------------------------------------------------------------------
+func terminalSize() (rows int, cols int) {
+	var info consoleScreenBufferInfo
+	if result, _, _ := procGetConsoleScreenBufferInfo.Call(os.Stdout.Fd(), uintptr(unsafe.Pointer(&info))); result == 0 {
+		return 24, 80
+	}
+	return int(info.window[3]-info.window[1]) + 1, int(info.window[2]-info.window[0]) + 1
+}
@@ -0,0 +1,64 @@
+package gpclogging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+// suffix of compressed log files, they are purged like the plain ones
+const compressedSuffix = ".gz"
+
+// SetCompressRotated sets whether log files are compressed with gzip once they were rotated out.
+// By default, they are kept as plain text.
+func SetCompressRotated(on bool) {
+	gConf.setFlags(logFlagCompressRotated, on)
+}
+
+func (conf *config) compressRotated() bool {
+	return (conf.logflags & logFlagCompressRotated) != 0
+}
+
+// compressLogFile replaces a log file by its gzip compressed copy. It is run in background,
+// the copy gets its final name only when it is complete.
+func compressLogFile(filename string) {
+	if err := gzipFile(filename, filename+compressedSuffix); err != nil {
+		gMetrics.writeErrors.Add(1)
+		Warn("Could not compress rotated log file <%s>: <%s>", filename, err.Error())
+		return
+	}
+	if err := os.Remove(filename); err != nil {
+		gMetrics.writeErrors.Add(1)
+		Warn("Could not remove rotated log file <%s> after compressing it: <%s>", filename, err.Error())
+		return
+	}
+	gMetrics.compressions.Add(1)
+}
+
+// gzipFile writes the compressed copy of a file, via a temporary file
+func gzipFile(source string, target string) error {
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	tmpName := target + ".tmp"
+	targetFile, err := os.OpenFile(tmpName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zipWriter := gzip.NewWriter(targetFile)
+	_, err = io.Copy(zipWriter, sourceFile)
+	if err == nil {
+		err = zipWriter.Close()
+	}
+	if errClose := targetFile.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return os.Rename(tmpName, target)
+}
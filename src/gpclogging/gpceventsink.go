@@ -0,0 +1,44 @@
+package gpclogging
+
+import (
+	"sync"
+)
+
+// EventSink receives the warnings and errors of the controller in addition to the log file,
+// e.g. the Windows Event Log while the controller runs as a service.
+type EventSink interface {
+	Warn(msg string)
+	Error(msg string)
+}
+
+var gEventSink EventSink
+var gEventSinkMux sync.Mutex
+
+// SetEventSink sets the sink warnings and errors are passed to, nil removes it.
+func SetEventSink(sink EventSink) {
+	gEventSinkMux.Lock()
+	defer gEventSinkMux.Unlock()
+	gEventSink = sink
+}
+
+// sendEvent passes a warning or error to the event sink, if there is one
+func sendEvent(logLevel int, task string, msg string) {
+	if logLevel < logLevelWarn {
+		return
+	}
+	gEventSinkMux.Lock()
+	sink := gEventSink
+	gEventSinkMux.Unlock()
+	if sink == nil {
+		return
+	}
+
+	if len(task) > 0 {
+		msg = "[" + task + "] " + msg
+	}
+	if logLevel == logLevelError {
+		sink.Error(msg)
+	} else {
+		sink.Warn(msg)
+	}
+}
@@ -0,0 +1,85 @@
+package gpclogging
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"runtime"
+	"time"
+)
+
+// jsonRecord is a single log record in JSON format
+type jsonRecord struct {
+	Time     string `json:"time"`
+	Level    string `json:"level"`
+	Message  string `json:"msg"`
+	Caller   string `json:"caller,omitempty"`
+	Function string `json:"func,omitempty"`
+	Task     string `json:"task,omitempty"`
+}
+
+// TaskLogger writes logs that belong to a single task. In JSON format the task
+// name is written as separate field, so the logs of a task can be filtered easily.
+type TaskLogger struct {
+	task string
+}
+
+// SetLogFormatJSON sets whether each log is written as a JSON object (one per line)
+// instead of the text format with a fixed prefix.
+func SetLogFormatJSON(on bool) {
+	gConf.setFlags(logFlagLogJSON, on)
+}
+
+// Task returns a logger for the given task name.
+func Task(task string) TaskLogger {
+	return TaskLogger{task: task}
+}
+
+// Debug logs down a log with Debug level for the task.
+func (tl TaskLogger) Debug(format string, args ...interface{}) {
+	if enabled(logLevelDebug) {
+		log(logLevelDebug, tl.task, format, args)
+	}
+}
+
+// Info logs down a log with info level for the task.
+func (tl TaskLogger) Info(format string, args ...interface{}) {
+	log(logLevelInfo, tl.task, format, args)
+}
+
+// Warn logs down a log with warning level for the task.
+func (tl TaskLogger) Warn(format string, args ...interface{}) {
+	log(logLevelWarn, tl.task, format, args)
+}
+
+// Error logs down a log with error level for the task.
+func (tl TaskLogger) Error(format string, args ...interface{}) {
+	log(logLevelError, tl.task, format, args)
+}
+
+// genLogJSON writes a complete log record as JSON object
+func genLogJSON(buf *buffer, logLevel, skip int, t time.Time, task string, msg string) {
+	record := jsonRecord{
+		Time:    t.Format(time.RFC3339Nano),
+		Level:   gLogLevelNames[logLevel],
+		Message: msg,
+		Task:    task,
+	}
+
+	if gConf.logFilenameLineNum() || gConf.logFuncName() {
+		pc, file, line, ok := runtime.Caller(skip)
+		if ok && gConf.logFilenameLineNum() {
+			record.Caller = fmt.Sprintf("%s:%d", path.Base(file), line)
+		}
+		if ok && gConf.logFuncName() {
+			record.Function = runtime.FuncForPC(pc).Name()
+		}
+	}
+
+	encoded, err := json.Marshal(&record)
+	if err != nil {
+		// can not happen for strings, but never lose the message
+		encoded, _ = json.Marshal(msg)
+	}
+	buf.Write(encoded)
+}
@@ -0,0 +1,41 @@
+package gpclogging
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// lowest level that is logged, changed at runtime by SetLogLevel
+var gMinLevel int32 = logLevelInfo
+
+// SetLogLevel sets the lowest severity that is logged: "debug", "info", "warn" or "error".
+// It can be changed at any time, e.g. to debug a running controller.
+func SetLogLevel(sLevel string) error {
+	for logLevel, sName := range gLogLevelNames {
+		if strings.EqualFold(sName, sLevel) {
+			atomic.StoreInt32(&gMinLevel, int32(logLevel))
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown log level <%s>, use debug, info, warn or error", sLevel)
+}
+
+// GetLogLevel returns the lowest severity that is logged, in lower case.
+func GetLogLevel() string {
+	return strings.ToLower(gLogLevelNames[atomic.LoadInt32(&gMinLevel)])
+}
+
+// SetLogDebug turns debug logs on (level debug) or off (level info).
+func SetLogDebug(on bool) {
+	if on {
+		atomic.StoreInt32(&gMinLevel, logLevelDebug)
+	} else {
+		atomic.StoreInt32(&gMinLevel, logLevelInfo)
+	}
+}
+
+// enabled reports whether logs of the level are written
+func enabled(logLevel int) bool {
+	return int32(logLevel) >= atomic.LoadInt32(&gMinLevel)
+}
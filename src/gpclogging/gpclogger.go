@@ -0,0 +1,95 @@
+package gpclogging
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Logger receives the logs of the controller. The messages are formatted already, task is the
+// name of the task a log is about, empty for logs of the controller itself. The default Logger
+// writes the rotating log files, an embedding application can pass the logs on to its own
+// logging instead, e.g. with an adapter to log/slog:
+//
+//	func (a slogAdapter) Info(task, msg string) { slog.Info(msg, "task", task) }
+//
+// Debug is only called while debug logs are enabled, see SetLogLevel.
+type Logger interface {
+	Debug(task string, msg string)
+	Info(task string, msg string)
+	Warn(task string, msg string)
+	Error(task string, msg string)
+}
+
+var gActiveLogger Logger = fileLogger{}
+var gActiveLoggerMux sync.RWMutex
+
+// SetLogger sets the Logger all logs go to, nil restores the default logging to files.
+// The log level and the event sink apply to any Logger.
+func SetLogger(logger Logger) {
+	gActiveLoggerMux.Lock()
+	defer gActiveLoggerMux.Unlock()
+	if logger == nil {
+		logger = fileLogger{}
+	}
+	gActiveLogger = logger
+}
+
+// log formats a log and passes it to the active Logger and the event sink
+func log(logLevel int, task string, format string, args []interface{}) {
+	if !enabled(logLevel) {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+
+	gActiveLoggerMux.RLock()
+	logger := gActiveLogger
+	gActiveLoggerMux.RUnlock()
+
+	switch logLevel {
+	case logLevelDebug:
+		logger.Debug(task, msg)
+	case logLevelInfo:
+		logger.Info(task, msg)
+	case logLevelWarn:
+		logger.Warn(task, msg)
+	default:
+		logger.Error(task, msg)
+	}
+	sendEvent(logLevel, task, msg)
+}
+
+// frames between the code calling Info etc. and fileLogger.write looking it up:
+// write, the fileLogger method, log and Info
+const callerSkip = 5
+
+// fileLogger is the default Logger, it writes the rotating log files set up by Init
+type fileLogger struct{}
+
+func (f fileLogger) Debug(task string, msg string) { f.write(logLevelDebug, task, msg) }
+func (f fileLogger) Info(task string, msg string)  { f.write(logLevelInfo, task, msg) }
+func (f fileLogger) Warn(task string, msg string)  { f.write(logLevelWarn, task, msg) }
+func (f fileLogger) Error(task string, msg string) { f.write(logLevelError, task, msg) }
+
+// write writes a log in the configured format to the log file and optionally the console
+func (fileLogger) write(logLevel int, task string, msg string) {
+	buf := gBufPool.getBuffer()
+
+	t := time.Now()
+	if gConf.logJSON() {
+		genLogJSON(buf, logLevel, callerSkip, t, task, msg)
+	} else {
+		genLogPrefix(buf, logLevel, callerSkip, t)
+		buf.WriteString(msg)
+	}
+	buf.WriteByte('\n')
+	output := buf.Bytes()
+
+	gLogger.log(t, output)
+
+	if gConf.logToConsole() {
+		fmt.Print(string(output))
+	}
+
+	gBufPool.putBuffer(buf)
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strings"
@@ -31,8 +32,7 @@ import (
 // consts
 const (
 	maxInt64          = int64(^uint64(0) >> 1)
-	logCreatedTimeLen = 24
-	logFilenameMinLen = 29
+	logCreatedTimeLen = 14 // YYYYMMDDhhmmss
 )
 
 // log level
@@ -46,10 +46,11 @@ const (
 
 // log flags
 const (
-	logFlagLogDebug = 1 << iota
-	logFlagLogFuncName
+	logFlagLogFuncName = 1 << iota
 	logFlagLogFilenameLineNum
 	logFlagLogToConsole
+	logFlagLogJSON
+	logFlagCompressRotated
 )
 
 // const strings
@@ -89,6 +90,7 @@ var gConf = config{
 
 var gBufPool bufferPool
 var gLogger logger
+var gAppendMux sync.Mutex // files written by AppendToLogFile
 
 // Init must be called first, otherwise this logger will not function properly!
 // It returns nil if all goes well, otherwise it returns the corresponding error.
@@ -96,7 +98,7 @@ var gLogger logger
 //   nfilesToDel: Number of files deleted when number of log files reaches `maxfiles`.
 //                Must be greater than 0 and less than or equal to `maxfiles`.
 //   maxsize: Maximum size of a log file in MB, 0 means unlimited.
-//   logDebug: If set to false, `logger.Debug("xxxx")` will be mute.
+//   logDebug: If set to false, `logger.Debug("xxxx")` will be mute. See SetLogLevel to change it later.
 func Init(logpath string, maxfiles, nfilesToDel int, maxsize uint32, logDebug bool) error {
 	err := os.MkdirAll(logpath, 0755)
 	if err != nil {
@@ -113,7 +115,7 @@ func Init(logpath string, maxfiles, nfilesToDel int, maxsize uint32, logDebug bo
 	}
 
 	gConf.logPath = logpath + "/"
-	gConf.setFlags(logFlagLogDebug, logDebug)
+	SetLogDebug(logDebug)
 	gConf.maxfiles = maxfiles
 	gConf.nfilesToDel = nfilesToDel
 	gConf.setMaxSize(maxsize)
@@ -157,24 +159,24 @@ func SetFilenamePrefix(logfilenamePrefix string) error {
 // Debug logs down a log with Debug level.
 // If parameter logDebug of logger.Init() is set to be false, no Debug logs will be logged down.
 func Debug(format string, args ...interface{}) {
-	if gConf.logDebug() {
-		log(logLevelDebug, format, args)
+	if enabled(logLevelDebug) {
+		log(logLevelDebug, "", format, args)
 	}
 }
 
 // Info logs down a log with info level.
 func Info(format string, args ...interface{}) {
-	log(logLevelInfo, format, args)
+	log(logLevelInfo, "", format, args)
 }
 
 // Warn logs down a log with warning level.
 func Warn(format string, args ...interface{}) {
-	log(logLevelWarn, format, args)
+	log(logLevelWarn, "", format, args)
 }
 
 // Error logs down a log with error level.
 func Error(format string, args ...interface{}) {
-	log(logLevelError, format, args)
+	log(logLevelError, "", format, args)
 }
 
 // logger configuration
@@ -197,10 +199,6 @@ func (conf *config) setFlags(flag uint32, on bool) {
 	}
 }
 
-func (conf *config) logDebug() bool {
-	return (conf.logflags & logFlagLogDebug) != 0
-}
-
 func (conf *config) logFuncName() bool {
 	return (conf.logflags & logFlagLogFuncName) != 0
 }
@@ -213,6 +211,10 @@ func (conf *config) logToConsole() bool {
 	return (conf.logflags & logFlagLogToConsole) != 0
 }
 
+func (conf *config) logJSON() bool {
+	return (conf.logflags & logFlagLogJSON) != 0
+}
+
 func (conf *config) setMaxSize(maxsize uint32) {
 	if maxsize > 0 {
 		conf.maxsize = int64(maxsize) * 1024 * 1024
@@ -278,6 +280,7 @@ func (l *logger) log(t time.Time, data []byte) {
 					err := os.RemoveAll(gConf.logPath + files[i])
 					if err == nil {
 						gConf.curfiles--
+						gMetrics.purgedFiles.Add(1)
 					} else {
 						l.errlog(t, nil, err)
 					}
@@ -299,17 +302,33 @@ func (l *logger) log(t time.Time, data []byte) {
 		gConf.purgeLock.Unlock()
 		hasLocked = false
 
-		l.file.Close()
+		if l.file != nil {
+			gMetrics.rotations.Add(1)
+			setFileOpen(l.file.Name(), false)
+			l.file.Close()
+			if gConf.compressRotated() {
+				go compressLogFile(l.file.Name())
+			}
+			checkQuotaSoon()
+		}
+		setFileOpen(newfile.Name(), true)
 		l.file = newfile
 		l.day = d
 		l.size = 0
 	}
-	n, _ := l.file.Write(data)
+	n, err := l.file.Write(data)
 	l.size += int64(n)
+	gMetrics.bytesWritten.Add(uint64(n))
+	if err != nil {
+		gMetrics.writeErrors.Add(1)
+	} else {
+		gMetrics.linesWritten.Add(1)
+	}
 }
 
 // (l *logger).errlog() should only be used within (l *logger).log()
 func (l *logger) errlog(t time.Time, originLog []byte, err error) {
+	gMetrics.writeErrors.Add(1)
 	buf := gBufPool.getBuffer()
 
 	genLogPrefix(buf, l.level, 2, t)
@@ -338,14 +357,24 @@ func (a byCreatedTime) Len() int {
 }
 
 func (a byCreatedTime) Less(i, j int) bool {
-	s1, s2 := a[i], a[j]
-	if len(s1) < logFilenameMinLen {
+	s1, s2 := logCreatedTime(a[i]), logCreatedTime(a[j])
+	if len(s1) == 0 {
 		return true
-	} else if len(s2) < logFilenameMinLen {
+	} else if len(s2) == 0 {
 		return false
 	} else {
-		return s1[len(s1)-logCreatedTimeLen:] < s2[len(s2)-logCreatedTimeLen:]
+		return s1 < s2
+	}
+}
+
+// logCreatedTime returns the time stamp in the name of a log file, compressed or not, e.g.
+// "20240131235959" of "pc.20240131235959.log.gz". Empty if the name has none.
+func logCreatedTime(filename string) string {
+	filename = strings.TrimSuffix(strings.TrimSuffix(filename, compressedSuffix), ".log")
+	if len(filename) < logCreatedTimeLen {
+		return ""
 	}
+	return filename[len(filename)-logCreatedTimeLen:]
 }
 
 func (a byCreatedTime) Swap(i, j int) {
@@ -417,31 +446,68 @@ func genLogPrefix(buf *buffer, logLevel, skip int, t time.Time) {
 	buf.WriteString("] ")
 }
 
-func log(logLevel int, format string, args []interface{}) {
-	buf := gBufPool.getBuffer()
-
-	t := time.Now()
-	genLogPrefix(buf, logLevel, 3, t)
-	fmt.Fprintf(buf, format, args...)
-	buf.WriteByte('\n')
-	output := buf.Bytes()
-
-	gLogger.log(t, output)
+// GetLogFilePath returns the path of a file with the given name in the log folder
+func GetLogFilePath(fileName string) string {
+	return gConf.logPath + fileName
+}
 
-	if gConf.logToConsole() {
-		fmt.Print(string(output))
+// AppendToLogFile appends data to a file of the log folder. The file is rotated like the log
+// of the controller: above the max size it is renamed to <name>.1, replacing the previous one,
+// and compressed if SetCompressRotated is on.
+func AppendToLogFile(fileName string, data []byte) error {
+	gAppendMux.Lock()
+	defer gAppendMux.Unlock()
+
+	filePath := GetLogFilePath(fileName)
+	if info, err := os.Stat(filePath); err == nil && info.Size() > 0 && info.Size()+int64(len(data)) > gConf.maxsize {
+		rotatedPath := filePath + ".1"
+		os.Remove(rotatedPath + compressedSuffix)
+		if err := os.Rename(filePath, rotatedPath); err != nil {
+			gMetrics.writeErrors.Add(1)
+			return err
+		}
+		gMetrics.rotations.Add(1)
+		if gConf.compressRotated() {
+			go compressLogFile(rotatedPath)
+		}
 	}
 
-	gBufPool.putBuffer(buf)
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		gMetrics.writeErrors.Add(1)
+		return err
+	}
+	_, err = file.Write(data)
+	if errClose := file.Close(); err == nil {
+		err = errClose
+	}
+	return err
 }
 
-// GetLogFileForProcess provides a opened file for logging process output
-func GetLogFileForProcess(execName string) (*os.File, error) {
+// GetLogFilesOfProcess returns the output log files of all launches of a process, oldest first
+func GetLogFilesOfProcess(execName string) ([]string, error) {
+	return processLogFiles(execName, outputLogSuffix)
+}
 
-	t := time.Now()
-	y, m, d := t.Date()
-	hour, min, sec := t.Clock()
+// processLogFiles returns the log files of a process with the given suffix, oldest first
+func processLogFiles(execName string, sSuffix string) ([]string, error) {
+	dirEntries, err := os.ReadDir(gConf.logPath)
+	if err != nil {
+		return nil, err
+	}
 
-	outFileName := fmt.Sprintf("%s/%s_%d%02d%02d%02d%02d%02d.log", gConf.logPath, execName, y, m, d, hour, min, sec)
-	return os.Create(outFileName)
+	// <execName>_YYYYMMDDhhmmss<suffix>, the time stamp keeps other processes with the same prefix apart
+	var logFiles []string
+	for _, dirEntry := range dirEntries {
+		timeStamp, found := strings.CutPrefix(dirEntry.Name(), execName+"_")
+		if !found || len(timeStamp) != len("20060102150405")+len(sSuffix) || !strings.HasSuffix(timeStamp, sSuffix) {
+			continue
+		}
+		if strings.Trim(strings.TrimSuffix(timeStamp, sSuffix), "0123456789") != "" {
+			continue
+		}
+		logFiles = append(logFiles, filepath.Join(gConf.logPath, dirEntry.Name()))
+	}
+	sort.Strings(logFiles)
+	return logFiles, nil
 }
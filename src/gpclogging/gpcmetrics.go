@@ -0,0 +1,38 @@
+package gpclogging
+
+import (
+	"sync/atomic"
+)
+
+// Metrics are counters of the logging itself since the program started. The output of
+// the started processes goes to its files directly and is not counted.
+type Metrics struct {
+	LinesWritten uint64 // log lines written to the log file
+	BytesWritten uint64 // bytes written to the log file
+	Rotations    uint64 // log files started because the day changed or the size limit was reached
+	PurgedFiles  uint64 // old log files deleted because there were too many
+	Compressions uint64 // rotated log files compressed with gzip
+	WriteErrors  uint64 // failures to write, open or purge log files
+}
+
+// the counters behind Metrics
+var gMetrics struct {
+	linesWritten atomic.Uint64
+	bytesWritten atomic.Uint64
+	rotations    atomic.Uint64
+	purgedFiles  atomic.Uint64
+	compressions atomic.Uint64
+	writeErrors  atomic.Uint64
+}
+
+// GetMetrics returns the current counters of the logging
+func GetMetrics() Metrics {
+	return Metrics{
+		LinesWritten: gMetrics.linesWritten.Load(),
+		BytesWritten: gMetrics.bytesWritten.Load(),
+		Rotations:    gMetrics.rotations.Load(),
+		PurgedFiles:  gMetrics.purgedFiles.Load(),
+		Compressions: gMetrics.compressions.Load(),
+		WriteErrors:  gMetrics.writeErrors.Load(),
+	}
+}
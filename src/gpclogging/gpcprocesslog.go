@@ -0,0 +1,148 @@
+package gpclogging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// suffixes of the log files of process output
+const (
+	outputLogSuffix = ".log"
+	errorLogSuffix  = ".err.log"
+)
+
+// ProcessLog is the log file of the output of a process, <execName>_YYYYMMDDhhmmss.log, or
+// .err.log for its error output. With a max size, writes go to a new file once the current
+// one is full. With max files, only the latest files are kept whenever a new one is started.
+// A process can only write to the file directly if it is not rotated.
+type ProcessLog struct {
+	execName string
+	sSuffix  string
+	maxSize  int64 // zero => no rotation
+	maxFiles int   // zero => all files are kept
+	file     *os.File
+	size     int64
+	lock     sync.Mutex
+}
+
+// OpenProcessLog starts a new output log file of a process. maxSizeMB zero disables the
+// rotation, maxFiles zero keeps all files.
+func OpenProcessLog(execName string, maxSizeMB uint32, maxFiles uint32) (*ProcessLog, error) {
+	return openProcessLog(execName, outputLogSuffix, time.Now(), maxSizeMB, maxFiles)
+}
+
+// OpenErrorLog starts the error output log file that belongs to an output log file, with the
+// same time stamp and limits
+func (l *ProcessLog) OpenErrorLog() (*ProcessLog, error) {
+	l.lock.Lock()
+	startTime, err := time.ParseInLocation("20060102150405", processLogTimeStamp(l.execName, l.sSuffix, l.file.Name()), time.Local)
+	l.lock.Unlock()
+	if err != nil {
+		startTime = time.Now()
+	}
+	return openProcessLog(l.execName, errorLogSuffix, startTime, uint32(l.maxSize/(1024*1024)), uint32(l.maxFiles))
+}
+
+// openProcessLog creates the first file of a process log
+func openProcessLog(execName string, sSuffix string, t time.Time, maxSizeMB uint32, maxFiles uint32) (*ProcessLog, error) {
+	l := &ProcessLog{execName: execName, sSuffix: sSuffix, maxSize: int64(maxSizeMB) * 1024 * 1024, maxFiles: int(maxFiles)}
+
+	file, err := os.Create(l.fileName(t))
+	if err != nil {
+		return nil, err
+	}
+	l.file = file
+	setFileOpen(file.Name(), true)
+	l.purge()
+	return l, nil
+}
+
+// File returns the current file, for a process that writes to it directly
+func (l *ProcessLog) File() *os.File {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.file
+}
+
+// Name returns the path of the current file
+func (l *ProcessLog) Name() string {
+	return l.File().Name()
+}
+
+// Write writes to the current file and starts a new one before if it is full
+func (l *ProcessLog) Write(data []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(data)) > l.maxSize {
+		l.rotate()
+	}
+	n, err := l.file.Write(data)
+	l.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file
+func (l *ProcessLog) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	setFileOpen(l.file.Name(), false)
+	return l.file.Close()
+}
+
+// rotate continues in a new file. Within the same second there is no new name, the current
+// file grows on until then. The lock must be held by the caller.
+func (l *ProcessLog) rotate() {
+	fileName := l.fileName(time.Now())
+	if fileName == l.file.Name() {
+		return
+	}
+	newFile, err := os.Create(fileName)
+	if err != nil {
+		Error("Could not rotate the log of process <%s>, it grows on: <%s>", l.execName, err.Error())
+		return
+	}
+	setFileOpen(l.file.Name(), false)
+	l.file.Close()
+	setFileOpen(newFile.Name(), true)
+	l.file = newFile
+	l.size = 0
+	l.purge()
+	checkQuotaSoon()
+}
+
+// purge deletes the oldest files of the process log beyond maxFiles
+func (l *ProcessLog) purge() {
+	if l.maxFiles <= 0 {
+		return
+	}
+	logFiles, err := processLogFiles(l.execName, l.sSuffix)
+	if err != nil {
+		Error("Could not list the log files of process <%s>: <%s>", l.execName, err.Error())
+		return
+	}
+	for fileIndex := 0; fileIndex < len(logFiles)-l.maxFiles; fileIndex++ {
+		if err := os.Remove(logFiles[fileIndex]); err != nil {
+			Error("Could not delete old log file <%s>: <%s>", logFiles[fileIndex], err.Error())
+		}
+	}
+}
+
+// fileName returns the path of a file of the process log started at the given time
+func (l *ProcessLog) fileName(t time.Time) string {
+	y, m, d := t.Date()
+	hour, min, sec := t.Clock()
+	return filepath.Join(gConf.logPath, fmt.Sprintf("%s_%d%02d%02d%02d%02d%02d%s", l.execName, y, m, d, hour, min, sec, l.sSuffix))
+}
+
+// processLogTimeStamp returns the YYYYMMDDhhmmss part of the path of a process log file
+func processLogTimeStamp(execName string, sSuffix string, sPath string) string {
+	sFileName := filepath.Base(sPath)
+	if len(sFileName) < len(execName)+1+len(sSuffix) {
+		return ""
+	}
+	return sFileName[len(execName)+1 : len(sFileName)-len(sSuffix)]
+}
@@ -0,0 +1,130 @@
+package gpclogging
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// How often the size of the log folder is checked, besides after each rotation
+const quotaCheckInterval = 30 * time.Second
+
+var (
+	// quota of the log folder in bytes, zero => none
+	gQuotaBytes atomic.Int64
+	// signals the quota goroutine to check now, started once by the first SetMaxTotalSize
+	gQuotaCheck     = make(chan bool, 1)
+	gQuotaStartOnce sync.Once
+
+	// files that are currently written to, they are never deleted for the quota
+	gOpenFiles    = make(map[string]bool)
+	gOpenFilesMux sync.Mutex
+)
+
+// SetMaxTotalSize limits the size of all files in the log folder, logs of the controller and
+// output of the processes alike, zero removes the limit. Above it, the oldest files are
+// deleted until the folder is below again. Files still written to are kept.
+func SetMaxTotalSize(maxSizeMB uint32) {
+	gQuotaBytes.Store(int64(maxSizeMB) * 1024 * 1024)
+	if maxSizeMB == 0 {
+		return
+	}
+	gQuotaStartOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(quotaCheckInterval)
+			defer ticker.Stop()
+			for {
+				enforceQuota()
+				select {
+				case <-ticker.C:
+				case <-gQuotaCheck:
+				}
+			}
+		}()
+	})
+	checkQuotaSoon()
+}
+
+// checkQuotaSoon makes the quota goroutine check the folder, e.g. after a rotation
+func checkQuotaSoon() {
+	select {
+	case gQuotaCheck <- true:
+	default:
+	}
+}
+
+// setFileOpen marks a file as written to, or not any more
+func setFileOpen(filename string, open bool) {
+	gOpenFilesMux.Lock()
+	defer gOpenFilesMux.Unlock()
+	if open {
+		gOpenFiles[filepath.Clean(filename)] = true
+	} else {
+		delete(gOpenFiles, filepath.Clean(filename))
+	}
+}
+
+// enforceQuota deletes the oldest closed files of the log folder while it is above the quota
+func enforceQuota() {
+	quota := gQuotaBytes.Load()
+	if quota <= 0 {
+		return
+	}
+	dirEntries, err := os.ReadDir(gConf.logPath)
+	if err != nil {
+		return
+	}
+
+	type logFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var logFiles []logFile
+	var total int64
+	for _, dirEntry := range dirEntries {
+		info, err := dirEntry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		logFiles = append(logFiles, logFile{filepath.Join(gConf.logPath, dirEntry.Name()), info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+	if total <= quota {
+		return
+	}
+	sort.Slice(logFiles, func(i, j int) bool { return logFiles[i].modTime.Before(logFiles[j].modTime) })
+
+	gOpenFilesMux.Lock()
+	openFiles := make(map[string]bool, len(gOpenFiles))
+	for filename := range gOpenFiles {
+		openFiles[filename] = true
+	}
+	gOpenFilesMux.Unlock()
+
+	deleted := 0
+	for _, file := range logFiles {
+		if total <= quota {
+			break
+		}
+		if openFiles[filepath.Clean(file.path)] {
+			continue
+		}
+		if err := os.Remove(file.path); err != nil {
+			gMetrics.writeErrors.Add(1)
+			continue
+		}
+		total -= file.size
+		deleted++
+		gMetrics.purgedFiles.Add(1)
+	}
+	if deleted > 0 {
+		Info("Deleted <%d> oldest log files to keep the log folder below <%d> MB.", deleted, quota/(1024*1024))
+	}
+	if total > quota {
+		Warn("Log folder holds <%d> MB in files still written to, above its quota of <%d> MB.", total/(1024*1024), quota/(1024*1024))
+	}
+}
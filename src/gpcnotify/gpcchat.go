@@ -0,0 +1,72 @@
+package gpcnotify
+
+import (
+	"bytes"
+	"encoding/json"
+	"gpcconfig"
+	"gpclogging"
+	"gpcprocessmgr"
+	"text/template"
+)
+
+// chat services with incoming webhooks
+const (
+	chatSlack = "slack"
+	chatTeams = "teams"
+)
+
+// message of a chat webhook without Template
+const defChatTemplate = `{{if .Task}}[{{.Host}}] Task {{.Task}}: {{.Event}} ({{.State}}, exit code {{.ExitCode}}){{else}}[{{.Host}}] {{.Event}}{{end}}` +
+	`{{if .Message}} - {{.Message}}{{end}}`
+
+// newChat creates the notifier of an incoming webhook of a Slack or Teams channel. It is a
+// webhook sending the message in the format of the service instead of the event as JSON.
+//------------------------------------------------------------------------------
+func newChat(sKind string, config gpcconfig.ChatConfig) *webhook {
+	w := newWebhook(sKind, gpcconfig.WebhookConfig{
+		URL:          config.URL,
+		Events:       config.Events,
+		FailuresOnly: config.FailuresOnly,
+		Timeout:      config.Timeout,
+		Retries:      config.Retries,
+		RetryDelay:   config.RetryDelay,
+	})
+
+	sTemplate := config.Template
+	if len(sTemplate) == 0 {
+		sTemplate = defChatTemplate
+	}
+	messageTemplate, err := template.New(sKind).Parse(sTemplate)
+	if err != nil {
+		// Reported by the preflight check
+		gpclogging.Warn("Invalid template of <%s>, using the default: <%s>", w.name(), err.Error())
+		messageTemplate = template.Must(template.New(sKind).Parse(defChatTemplate))
+	}
+
+	w.body = func(event gpcprocessmgr.Event) ([]byte, error) {
+		var message bytes.Buffer
+		if err := messageTemplate.Execute(&message, newPayload(event)); err != nil {
+			return nil, err
+		}
+		if sKind == chatTeams {
+			return teamsBody(message.String())
+		}
+		return json.Marshal(map[string]string{"text": message.String()})
+	}
+	return w
+}
+
+// teamsBody wraps a message into an Adaptive Card, as Teams workflows and connectors accept it
+//------------------------------------------------------------------------------
+func teamsBody(sMessage string) ([]byte, error) {
+	card := map[string]interface{}{
+		"type":    "AdaptiveCard",
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"version": "1.2",
+		"body":    []interface{}{map[string]interface{}{"type": "TextBlock", "text": sMessage, "wrap": true}},
+	}
+	return json.Marshal(map[string]interface{}{
+		"type":        "message",
+		"attachments": []interface{}{map[string]interface{}{"contentType": "application/vnd.microsoft.card.adaptive", "content": card}},
+	})
+}
@@ -0,0 +1,184 @@
+package gpcnotify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"gpcconfig"
+	"gpcprocessmgr"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaults of the email settings
+const (
+	defEmailOutputLines = 50
+
+	// bounds the whole SMTP conversation
+	emailTimeout = 30 * time.Second
+)
+
+// email mails the events through an SMTP server, with the latest output of the task attached
+type email struct {
+	config gpcconfig.EmailConfig
+	filter eventFilter
+}
+
+// newEmail creates the notifier of a configured SMTP account. Without Events it mails the
+// events that leave a process in the error or quarantined state.
+//------------------------------------------------------------------------------
+func newEmail(config gpcconfig.EmailConfig) *email {
+	e := &email{config: config}
+	if len(config.Events) > 0 {
+		e.filter = newEventFilter(e.name(), config.Events, false)
+	} else {
+		e.filter = eventFilter{states: map[string]bool{"error": true, "quarantined": true}}
+	}
+	return e
+}
+
+// name tells the SMTP server
+//------------------------------------------------------------------------------
+func (e *email) name() string {
+	return "email " + e.config.Server
+}
+
+// wants applies the event filter of the account
+//------------------------------------------------------------------------------
+func (e *email) wants(event gpcprocessmgr.Event) bool {
+	return e.filter.matches(event)
+}
+
+// notify mails the event to all recipients
+//------------------------------------------------------------------------------
+func (e *email) notify(event gpcprocessmgr.Event) error {
+	message, err := e.message(event)
+	if err != nil {
+		return err
+	}
+	return e.send(message)
+}
+
+// message composes the mail: the event as text, the output of the task as attachment
+//------------------------------------------------------------------------------
+func (e *email) message(event gpcprocessmgr.Event) ([]byte, error) {
+	payload := newPayload(event)
+	sSubject := fmt.Sprintf("[%s] %s", payload.Host, payload.Event)
+	if len(payload.Task) > 0 {
+		sSubject = fmt.Sprintf("[%s] %s: %s (%s)", payload.Host, payload.Task, payload.Event, payload.State)
+	}
+
+	var body bytes.Buffer
+	mimeWriter := multipart.NewWriter(&body)
+	fmt.Fprintf(&body, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%s\r\n\r\n",
+		e.config.From, strings.Join(e.config.To, ", "), sSubject, payload.Time.Format(time.RFC1123Z), mimeWriter.Boundary())
+
+	textPart, err := mimeWriter.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(textPart, "Event:     %s\r\nTime:      %s\r\nHost:      %s\r\n", payload.Event, payload.Time.Format(time.RFC3339), payload.Host)
+	if len(payload.Task) > 0 {
+		fmt.Fprintf(textPart, "Task:      %s\r\nState:     %s\r\nPID:       %d\r\nExit code: %d\r\n", payload.Task, payload.State, payload.PID, payload.ExitCode)
+	}
+	if len(payload.Message) > 0 {
+		fmt.Fprintf(textPart, "Details:   %s\r\n", payload.Message)
+	}
+
+	// The output is attached if there is one, a process that never started has none
+	numLines := int(e.config.OutputLines)
+	if numLines == 0 {
+		numLines = defEmailOutputLines
+	}
+	if len(payload.Task) > 0 {
+		if lines, err := gpcprocessmgr.TailProcessLog(payload.Task, numLines); err == nil && len(lines) > 0 {
+			attachment, err := mimeWriter.CreatePart(textproto.MIMEHeader{
+				"Content-Type":        {"text/plain; charset=utf-8"},
+				"Content-Disposition": {fmt.Sprintf("attachment; filename=%q", payload.Task+"-output.log")},
+			})
+			if err != nil {
+				return nil, err
+			}
+			attachment.Write([]byte(strings.Join(lines, "\r\n") + "\r\n"))
+		}
+	}
+
+	if err := mimeWriter.Close(); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+// send delivers a mail through the SMTP server of the account
+//------------------------------------------------------------------------------
+func (e *email) send(message []byte) error {
+	sHost, sPort, err := net.SplitHostPort(e.config.Server)
+	if err != nil {
+		return err
+	}
+	tlsConfig := &tls.Config{ServerName: sHost}
+
+	var conn net.Conn
+	dialer := &net.Dialer{Timeout: emailTimeout}
+	if sPort == "465" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", e.config.Server, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", e.config.Server)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(emailTimeout))
+
+	client, err := smtp.NewClient(conn, sHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if sHostName, err := os.Hostname(); err == nil {
+		if err := client.Hello(sHostName); err != nil {
+			return err
+		}
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok && sPort != "465" {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if len(e.config.User) > 0 {
+		sPassword, err := e.config.SMTPPassword()
+		if err != nil {
+			return err
+		}
+		if err := client.Auth(smtp.PlainAuth("", e.config.User, sPassword, sHost)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(e.config.From); err != nil {
+		return err
+	}
+	for _, sRecipient := range e.config.To {
+		if err := client.Rcpt(sRecipient); err != nil {
+			return err
+		}
+	}
+	dataWriter, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := dataWriter.Write(message); err != nil {
+		return err
+	}
+	if err := dataWriter.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,194 @@
+package gpcnotify
+
+/*
+Package gpcnotify passes the lifecycle events of the processes (see gpcprocessmgr.Subscribe)
+to notification targets, e.g. webhooks of an incident channel. Each event is delivered in
+background, a slow or unreachable target does not hold up the others or the controller.
+*/
+import (
+	"gpcconfig"
+	"gpclogging"
+	"gpcprocessmgr"
+	"os"
+	"sync"
+	"time"
+)
+
+//#######################################################
+//### GLOBAL VARIABLES, INIT, CONSTS
+//#######################################################
+
+// notifier is a notification target
+type notifier interface {
+	// name tells the target in log messages, without secrets like tokens in URLs
+	name() string
+	// wants reports whether the event shall be sent to the target
+	wants(event gpcprocessmgr.Event) bool
+	// notify sends the event, retries included
+	notify(event gpcprocessmgr.Event) error
+}
+
+var (
+	gNotifiers    []notifier
+	gSubscription *gpcprocessmgr.Subscription
+	gDispatchDone chan bool // closed when the dispatcher has passed on all events
+	gDeliveries   sync.WaitGroup
+	gNotifyMux    sync.Mutex
+)
+
+// consts
+const (
+	// events buffered while the dispatcher is busy
+	eventBufferSize = 256
+
+	// how long Stop waits for pending deliveries
+	stopTimeout = 15 * time.Second
+)
+
+// eventPayload is an event as sent to the targets
+type eventPayload struct {
+	Event    string
+	Time     time.Time
+	Host     string
+	Task     string // empty for controller events
+	State    string
+	PID      int
+	ExitCode int // ProcessExited only, -1 if unknown
+	Failed   bool
+	Message  string
+}
+
+//Configure sets up the notification targets of the configuration, replacing the previous ones.
+//Events are dispatched from the first call on until Stop.
+//#########################################################
+func Configure(tConfigData *gpcconfig.ConfigData) {
+	var notifiers []notifier
+	for i := range tConfigData.Notifications.Webhooks {
+		notifiers = append(notifiers, newWebhook("webhook", tConfigData.Notifications.Webhooks[i]))
+	}
+	for i := range tConfigData.Notifications.Emails {
+		notifiers = append(notifiers, newEmail(tConfigData.Notifications.Emails[i]))
+	}
+	for i := range tConfigData.Notifications.Slack {
+		notifiers = append(notifiers, newChat(chatSlack, tConfigData.Notifications.Slack[i]))
+	}
+	for i := range tConfigData.Notifications.Teams {
+		notifiers = append(notifiers, newChat(chatTeams, tConfigData.Notifications.Teams[i]))
+	}
+
+	gNotifyMux.Lock()
+	defer gNotifyMux.Unlock()
+	gNotifiers = notifiers
+	if gSubscription == nil && len(notifiers) > 0 {
+		gSubscription = gpcprocessmgr.Subscribe(eventBufferSize)
+		gDispatchDone = make(chan bool)
+		go dispatch(gSubscription, gDispatchDone)
+	}
+	gpclogging.Debug("Notifications configured for <%d> targets.", len(notifiers))
+}
+
+//Stop ends the dispatching and waits a while for the deliveries of the events published so far
+//#########################################################
+func Stop() {
+	gNotifyMux.Lock()
+	subscription, dispatchDone := gSubscription, gDispatchDone
+	gSubscription = nil
+	gNotifyMux.Unlock()
+	if subscription == nil {
+		return
+	}
+
+	subscription.Unsubscribe()
+	<-dispatchDone
+	delivered := make(chan bool)
+	go func() {
+		gDeliveries.Wait()
+		close(delivered)
+	}()
+	select {
+	case <-delivered:
+	case <-time.After(stopTimeout):
+		gpclogging.Warn("Notifications still pending after <%s> are dropped.", stopTimeout.String())
+	}
+	if dropped := subscription.Dropped(); dropped > 0 {
+		gpclogging.Warn("<%d> events were not notified, too many at once.", dropped)
+	}
+}
+
+// dispatch passes each event to the targets that want it
+//------------------------------------------------------------------------------
+func dispatch(subscription *gpcprocessmgr.Subscription, dispatchDone chan bool) {
+	defer close(dispatchDone)
+
+	for event := range subscription.C {
+		gNotifyMux.Lock()
+		notifiers := gNotifiers
+		gNotifyMux.Unlock()
+
+		for _, target := range notifiers {
+			if !target.wants(event) {
+				continue
+			}
+			gDeliveries.Add(1)
+			go func(target notifier, event gpcprocessmgr.Event) {
+				defer gDeliveries.Done()
+				if err := target.notify(event); err != nil {
+					gpclogging.Warn("Could not notify <%s> of event <%s> of process <%s>: <%s>", target.name(), event.Type, event.Process, err.Error())
+				}
+			}(target, event)
+		}
+	}
+}
+
+// newPayload converts an event for sending
+//------------------------------------------------------------------------------
+func newPayload(event gpcprocessmgr.Event) eventPayload {
+	sHost, _ := os.Hostname()
+	return eventPayload{
+		Event:    string(event.Type),
+		Time:     event.Time,
+		Host:     sHost,
+		Task:     event.Process,
+		State:    event.State,
+		PID:      event.PID,
+		ExitCode: event.ExitCode,
+		Failed:   event.Failed,
+		Message:  event.Message,
+	}
+}
+
+// eventFilter selects the events a target wants
+type eventFilter struct {
+	types        map[gpcprocessmgr.EventType]bool // empty => all
+	failuresOnly bool                             // ProcessExited only if the process failed
+	states       map[string]bool                  // states of the process after the event, empty => all
+}
+
+// newEventFilter creates the filter of a target, unknown event types are reported
+//------------------------------------------------------------------------------
+func newEventFilter(sTarget string, sEvents []string, failuresOnly bool) eventFilter {
+	filter := eventFilter{types: make(map[gpcprocessmgr.EventType]bool), failuresOnly: failuresOnly}
+	for _, sEvent := range sEvents {
+		known := false
+		for _, eventType := range gpcprocessmgr.EventTypes {
+			known = known || string(eventType) == sEvent
+		}
+		if !known {
+			gpclogging.Warn("Notification target <%s> subscribes to unknown event <%s>.", sTarget, sEvent)
+		}
+		filter.types[gpcprocessmgr.EventType(sEvent)] = true
+	}
+	return filter
+}
+
+// matches reports whether the event passes the filter
+//------------------------------------------------------------------------------
+func (f eventFilter) matches(event gpcprocessmgr.Event) bool {
+	if len(f.types) > 0 && !f.types[event.Type] {
+		return false
+	}
+	if len(f.states) > 0 && !f.states[event.State] {
+		return false
+	}
+	return !f.failuresOnly || event.Type != gpcprocessmgr.EventProcessExited || event.Failed
+}
@@ -0,0 +1,110 @@
+package gpcnotify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gpcconfig"
+	"gpclogging"
+	"gpcprocessmgr"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaults of the webhook settings
+const (
+	defWebhookTimeout    = 10 * time.Second
+	defWebhookRetryDelay = 5 * time.Second
+)
+
+// webhook POSTs the events as JSON to a URL
+type webhook struct {
+	config gpcconfig.WebhookConfig
+	filter eventFilter
+	client *http.Client
+	sKind  string                                          // "webhook", or the chat service of a chat webhook
+	body   func(event gpcprocessmgr.Event) ([]byte, error) // JSON sent for an event
+}
+
+// newWebhook creates the notifier of a configured webhook, sKind names it in the log
+//------------------------------------------------------------------------------
+func newWebhook(sKind string, config gpcconfig.WebhookConfig) *webhook {
+	timeout := config.Timeout.Duration
+	if timeout <= 0 {
+		timeout = defWebhookTimeout
+	}
+	w := &webhook{config: config, client: &http.Client{Timeout: timeout}, sKind: sKind, body: payloadJSON}
+	w.filter = newEventFilter(w.name(), config.Events, config.FailuresOnly)
+	return w
+}
+
+// payloadJSON is the body of a plain webhook: the event as JSON
+//------------------------------------------------------------------------------
+func payloadJSON(event gpcprocessmgr.Event) ([]byte, error) {
+	return json.Marshal(newPayload(event))
+}
+
+// name tells the host only, the path of a webhook URL often holds its secret
+//------------------------------------------------------------------------------
+func (w *webhook) name() string {
+	webhookURL, err := url.Parse(w.config.URL)
+	if err != nil {
+		return w.sKind
+	}
+	return w.sKind + " " + webhookURL.Host
+}
+
+// wants applies the event filter of the webhook
+//------------------------------------------------------------------------------
+func (w *webhook) wants(event gpcprocessmgr.Event) bool {
+	return w.filter.matches(event)
+}
+
+// notify POSTs the event, failed attempts are retried as configured
+//------------------------------------------------------------------------------
+func (w *webhook) notify(event gpcprocessmgr.Event) error {
+	body, err := w.body(event)
+	if err != nil {
+		return err
+	}
+	retryDelay := w.config.RetryDelay.Duration
+	if retryDelay <= 0 {
+		retryDelay = defWebhookRetryDelay
+	}
+
+	for attempt := uint32(0); ; attempt++ {
+		err = postJSON(w.client, w.config.URL, w.config.Headers, body)
+		if err == nil || attempt >= w.config.Retries {
+			return err
+		}
+		gpclogging.Debug("Notification to <%s> failed, retry <%d> of <%d> in <%s>: <%s>", w.name(), attempt+1, w.config.Retries,
+			retryDelay.String(), err.Error())
+		time.Sleep(retryDelay)
+	}
+}
+
+// postJSON POSTs a JSON body, any status but 2xx is an error
+//------------------------------------------------------------------------------
+func postJSON(client *http.Client, sURL string, headers map[string]string, body []byte) error {
+	request, err := http.NewRequest(http.MethodPost, sURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for sKey, sValue := range headers {
+		request.Header.Set(sKey, sValue)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("HTTP status %s", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package gpcprocessmgr
+
+import (
+	"fmt"
+	"gpclogging"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// adoptRunningProcess looks for an instance of a no-wait process that was started outside of
+// the controller, by its PID file or executable name, and monitors it instead of starting a new
+// one. Its output is not captured. Reports whether an instance was adopted.
+//------------------------------------------------------------------------------
+func adoptRunningProcess(procName string, runtimeData *GPCProcRuntimeData) bool {
+	runtimeData.procStatus.adopted = false
+	if len(runtimeData.procConfig.AdoptPIDFile) == 0 && len(runtimeData.procConfig.AdoptExecutable) == 0 {
+		return false
+	}
+
+	pid, source, err := findAdoptable(runtimeData)
+	if err != nil {
+		gpclogging.Task(procName).Warn("Could not look for a running instance of process <%s>: <%s>", procName, err.Error())
+		return false
+	}
+	if pid == 0 {
+		gpclogging.Debug("No running instance of process <%s> found, starting it.", procName)
+		return false
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		gpclogging.Task(procName).Warn("Could not adopt process <%s>, PID=<%d>: <%s>", procName, pid, err.Error())
+		return false
+	}
+	// The command is never started, it only carries the process
+	runtimeData.procCmd = exec.Command(runtimeData.procConfig.Executable())
+	runtimeData.procCmd.Process = process
+	runtimeData.procStatus.pid = pid
+	runtimeData.procStatus.startTime = time.Now()
+	runtimeData.procStatus.exitCode = -1
+	adoptTree(runtimeData)
+	runtimeData.procStatus.adopted = true
+	runtimeData.procStatus.active = true
+
+	gpclogging.Task(procName).Info("Adopted running process <%s>, PID=<%d>, found by %s.", procName, pid, source)
+	publishProcessEvent(EventProcessStarted, procName, runtimeData, "adopted, found by "+source)
+	return true
+}
+
+// findAdoptable returns the PID of a running instance of a process and how it was found,
+// zero if there is none. With both PID file and executable name the process of the PID
+// file must have that name, so a stale file with a reused PID is not adopted.
+//------------------------------------------------------------------------------
+func findAdoptable(runtimeData *GPCProcRuntimeData) (int, string, error) {
+	var namedPids []int
+	if sExecutable := runtimeData.procConfig.AdoptExecutable; len(sExecutable) > 0 {
+		pids, err := findProcessesByName(sExecutable)
+		if err != nil {
+			return 0, "", err
+		}
+		for _, pid := range pids {
+			if pid != os.Getpid() {
+				namedPids = append(namedPids, pid)
+			}
+		}
+	}
+
+	if sPIDFile := runtimeData.procConfig.AdoptPIDFile; len(sPIDFile) > 0 {
+		pid, err := readPIDFile(sPIDFile)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return 0, "", nil
+			}
+			return 0, "", err
+		}
+		if !processExists(runtimeData, pid) {
+			gpclogging.Debug("PID file <%s> of process <%s> is stale, PID <%d> is not running.", sPIDFile, runtimeData.procConfig.Name, pid)
+			return 0, "", nil
+		}
+		if len(runtimeData.procConfig.AdoptExecutable) > 0 && !containsPID(namedPids, pid) {
+			gpclogging.Debug("PID <%d> of PID file <%s> is not a <%s> process.", pid, sPIDFile, runtimeData.procConfig.AdoptExecutable)
+			return 0, "", nil
+		}
+		return pid, fmt.Sprintf("PID file <%s>", sPIDFile), nil
+	}
+
+	if len(namedPids) == 0 {
+		return 0, "", nil
+	}
+	sort.Ints(namedPids)
+	if len(namedPids) > 1 {
+		gpclogging.Warn("Found <%d> running <%s> processes for <%s>, adopting the one with the lowest PID.",
+			len(namedPids), runtimeData.procConfig.AdoptExecutable, runtimeData.procConfig.Name)
+	}
+	return namedPids[0], fmt.Sprintf("executable name <%s>", runtimeData.procConfig.AdoptExecutable), nil
+}
+
+// readPIDFile reads the process ID from a PID file
+//------------------------------------------------------------------------------
+func readPIDFile(sPIDFile string) (int, error) {
+	pidBytes, err := os.ReadFile(sPIDFile)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(pidBytes)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("PID file <%s> does not hold a process ID", sPIDFile)
+	}
+	return pid, nil
+}
+
+// processExists probes a PID the same way the monitor probes its processes
+//------------------------------------------------------------------------------
+func processExists(runtimeData *GPCProcRuntimeData, pid int) bool {
+	probe := GPCProcRuntimeData{procConfig: runtimeData.procConfig}
+	probe.procStatus.pid = pid
+	return gPlatform.isRunning(&probe) == nil
+}
+
+// containsPID reports whether a PID is in a list
+//------------------------------------------------------------------------------
+func containsPID(pids []int, pid int) bool {
+	for _, listedPid := range pids {
+		if listedPid == pid {
+			return true
+		}
+	}
+	return false
+}
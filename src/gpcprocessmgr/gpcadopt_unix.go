@@ -0,0 +1,79 @@
+//go:build !windows
+// +build !windows
+
+package gpcprocessmgr
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+//findProcessesByName returns the PIDs of all processes running the executable with the given
+//file name. It reads the proc filesystem where there is one (Linux) and asks ps otherwise (macOS).
+//-------------------------------------------------------------------
+func findProcessesByName(sExecutable string) ([]int, error) {
+	if _, err := os.Stat("/proc/self/cmdline"); err != nil {
+		return psProcessesByName(sExecutable)
+	}
+
+	cmdlineFiles, err := filepath.Glob("/proc/[0-9]*/cmdline")
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, cmdlineFile := range cmdlineFiles {
+		cmdline, err := os.ReadFile(cmdlineFile)
+		if err != nil || len(cmdline) == 0 {
+			// Process is gone meanwhile, or a kernel thread
+			continue
+		}
+		argv0, _, _ := bytes.Cut(cmdline, []byte{0})
+		if filepath.Base(string(argv0)) != sExecutable {
+			continue
+		}
+		pid, err := strconv.Atoi(filepath.Base(filepath.Dir(cmdlineFile)))
+		if err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// psProcessesByName lists the PIDs of an executable as reported by ps
+//------------------------------------------------------------------------------
+func psProcessesByName(sExecutable string) ([]int, error) {
+	psOut, err := exec.Command("ps", "-A", "-o", "pid=,comm=").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+	scanner := bufio.NewScanner(bytes.NewReader(psOut))
+	for scanner.Scan() {
+		sPid, sComm, found := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if !found || filepath.Base(strings.TrimSpace(sComm)) != sExecutable {
+			continue
+		}
+		pid, err := strconv.Atoi(sPid)
+		if err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+//adoptTree uses the process group of an adopted process as its tree if it leads one.
+//Otherwise only the process itself is killed on stop.
+//-------------------------------------------------------------------
+func adoptTree(runtimeData *GPCProcRuntimeData) {
+	pid := runtimeData.procStatus.pid
+	if pgid, err := syscall.Getpgid(pid); err == nil && pgid == pid {
+		runtimeData.treeHandle = uintptr(pgid)
+	}
+}
@@ -0,0 +1,40 @@
+package gpcprocessmgr
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+//findProcessesByName returns the PIDs of all processes running the executable with the given
+//file name, compared case insensitive
+//-------------------------------------------------------------------
+func findProcessesByName(sExecutable string) ([]int, error) {
+	hSnapshot, err := syscall.CreateToolhelp32Snapshot(syscall.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, os.NewSyscallError("CreateToolhelp32Snapshot", err)
+	}
+	defer syscall.CloseHandle(hSnapshot)
+
+	var entry syscall.ProcessEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	err = syscall.Process32First(hSnapshot, &entry)
+	var pids []int
+	for err == nil {
+		if strings.EqualFold(syscall.UTF16ToString(entry.ExeFile[:]), sExecutable) {
+			pids = append(pids, int(entry.ProcessID))
+		}
+		err = syscall.Process32Next(hSnapshot, &entry)
+	}
+	if err != syscall.ERROR_NO_MORE_FILES {
+		return nil, os.NewSyscallError("Process32Next", err)
+	}
+	return pids, nil
+}
+
+//adoptTree does nothing on Windows. The children an adopted process already has can not be
+//moved into a job afterwards, so it is stopped with taskkill /T instead of a job object.
+//-------------------------------------------------------------------
+func adoptTree(runtimeData *GPCProcRuntimeData) {
+}
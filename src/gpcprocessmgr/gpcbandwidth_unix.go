@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package gpcprocessmgr
+
+import (
+	"errors"
+)
+
+//applyBandwidthLimit is not supported on Unix: tc can only match the traffic of a process
+//through cgroups, which the controller does not manage.
+//-------------------------------------------------------------------
+func applyBandwidthLimit(runtimeData *GPCProcRuntimeData) error {
+	if runtimeData.procConfig.MaxBandwidthKbps == 0 {
+		return nil
+	}
+	return errors.New("MaxBandwidthKbps is only supported on Windows")
+}
+
+//removeBandwidthLimit does nothing on Unix
+//-------------------------------------------------------------------
+func removeBandwidthLimit(runtimeData *GPCProcRuntimeData) {
+}
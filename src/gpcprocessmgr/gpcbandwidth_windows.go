@@ -0,0 +1,60 @@
+package gpcprocessmgr
+
+import (
+	"fmt"
+	"gpclogging"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Bandwidth limits are Windows QoS policies matching the executable of the task. They are
+// kept in the active store only, so a crashed controller does not leave them behind a reboot.
+
+// qosPolicyName returns the name of the QoS policy of a task
+//------------------------------------------------------------------------------
+func qosPolicyName(runtimeData *GPCProcRuntimeData) string {
+	return "gpc-" + runtimeData.procConfig.Name
+}
+
+// psQuote quotes a string for PowerShell
+//------------------------------------------------------------------------------
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+//applyBandwidthLimit (re)creates the QoS policy throttling the egress of the executable of a task
+//-------------------------------------------------------------------
+func applyBandwidthLimit(runtimeData *GPCProcRuntimeData) error {
+	limitKbps := runtimeData.procConfig.MaxBandwidthKbps
+	if limitKbps == 0 {
+		return nil
+	}
+	execPath, err := exec.LookPath(runtimeData.procConfig.Executable())
+	if err != nil {
+		return err
+	}
+
+	script := fmt.Sprintf("Remove-NetQosPolicy -Name %s -PolicyStore ActiveStore -Confirm:$false -ErrorAction SilentlyContinue; "+
+		"New-NetQosPolicy -Name %s -PolicyStore ActiveStore -AppPathNameMatchCondition %s -ThrottleRateActionBitsPerSecond %d | Out-Null",
+		psQuote(qosPolicyName(runtimeData)), psQuote(qosPolicyName(runtimeData)), psQuote(filepath.Base(execPath)), uint64(limitKbps)*1000)
+	psOut, err := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", script).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(string(psOut)))
+	}
+	gpclogging.Debug("QoS policy <%s> limits process <%s> to <%d> kbit/s.", qosPolicyName(runtimeData), runtimeData.procConfig.Name, limitKbps)
+	return nil
+}
+
+//removeBandwidthLimit removes the QoS policy of a task, if it has one
+//-------------------------------------------------------------------
+func removeBandwidthLimit(runtimeData *GPCProcRuntimeData) {
+	if runtimeData.procConfig.MaxBandwidthKbps == 0 {
+		return
+	}
+	script := fmt.Sprintf("Remove-NetQosPolicy -Name %s -PolicyStore ActiveStore -Confirm:$false -ErrorAction SilentlyContinue",
+		psQuote(qosPolicyName(runtimeData)))
+	if err := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", script).Run(); err != nil {
+		gpclogging.Warn("Could not remove QoS policy <%s>: <%s>", qosPolicyName(runtimeData), err.Error())
+	}
+}
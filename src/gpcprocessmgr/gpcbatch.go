@@ -0,0 +1,55 @@
+package gpcprocessmgr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// wait runs started or waiting for their start delay, including retries and follow up tasks
+var gPendingWaitRuns sync.WaitGroup
+
+//BatchDone returns a channel that is closed once all wait tasks started so far have finished,
+//including their retries and the follow up tasks they triggered. Call it after
+//StartProcessesFromConfig, it is closed right away if there are no wait tasks.
+//#########################################################
+func BatchDone() <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		gPendingWaitRuns.Wait()
+		close(done)
+	}()
+	return done
+}
+
+//BatchError returns an error naming the tasks that failed: wait tasks that timed out, could not
+//be started or ended with an exit code other than 0, and no-wait tasks that could not be started,
+//reached their restart limit, exited with one of their NoRestartExitCodes or were quarantined. Nil if all went well. Tasks stopped on request
+//and follow up tasks that were not triggered do not count.
+//#########################################################
+func BatchError() error {
+	var failures []string
+	for procName, runtimeData := range currentRuntimeData() {
+		runtimeData.mux.Lock()
+		procStatus := &runtimeData.procStatus
+		switch {
+		case procStatus.stopped || procStatus.active:
+		case procStatus.lastError != nil:
+			failures = append(failures, fmt.Sprintf("<%s>: %s", procName, procStatus.lastError.Error()))
+		case procStatus.quarantined:
+			failures = append(failures, fmt.Sprintf("<%s>: quarantined", procName))
+		case runtimeData.procConfig.WaitForExitTimeout.Duration == 0:
+		case procStatus.timeout:
+			failures = append(failures, fmt.Sprintf("<%s>: timed out", procName))
+		case procStatus.done && procStatus.exitCode != 0:
+			failures = append(failures, fmt.Sprintf("<%s>: exit code %d", procName, procStatus.exitCode))
+		}
+		runtimeData.mux.Unlock()
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	sort.Strings(failures)
+	return fmt.Errorf("%d tasks failed: %s", len(failures), strings.Join(failures, ", "))
+}
@@ -0,0 +1,98 @@
+package gpcprocessmgr
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// consts of the console echo
+const (
+	consoleBufferLines = 4096 // lines waiting for the console before further ones are dropped
+	consoleReset       = "\x1b[0m"
+)
+
+// colors of the task names, in the order the tasks first write
+var consoleColors = []string{"\x1b[36m", "\x1b[35m", "\x1b[32m", "\x1b[33m", "\x1b[34m", "\x1b[96m", "\x1b[95m", "\x1b[92m", "\x1b[93m", "\x1b[94m"}
+
+// consoleLine is a line of output on its way to the console
+type consoleLine struct {
+	procName string
+	sStream  string
+	line     string
+}
+
+//ConsoleSink is an OutputSink that echoes the output of all processes to the console of the
+//controller, each line prefixed with the task name in a color of its own. Stderr lines go to
+//stderr. Lines are written in background, when the console falls behind they are dropped and
+//counted rather than slowing down the processes.
+type ConsoleSink struct {
+	stdout      io.Writer
+	stderr      io.Writer
+	bColor      bool             // prefixes are colored
+	lines       chan consoleLine // lines not yet written
+	written     chan struct{}    // closed once the lines are written after Close
+	dropped     atomic.Uint64    // lines dropped since the last written one
+	colors      map[string]string
+	prefixWidth int // longest task name so far, prefixes are padded to it
+}
+
+//NewConsoleSink returns a ConsoleSink writing to stdout and stderr. Prefixes are colored if
+//stdout is a terminal, unless NO_COLOR is set.
+//#########################################################
+func NewConsoleSink(stdout *os.File, stderr *os.File) *ConsoleSink {
+	sink := &ConsoleSink{stdout: stdout, stderr: stderr, lines: make(chan consoleLine, consoleBufferLines),
+		written: make(chan struct{}), colors: make(map[string]string)}
+	if fileInfo, err := stdout.Stat(); err == nil && fileInfo.Mode()&os.ModeCharDevice != 0 && len(os.Getenv("NO_COLOR")) == 0 {
+		sink.bColor = enableConsoleColors(stdout)
+	}
+	go sink.write()
+	return sink
+}
+
+//Output queues a line for the console, see OutputSink
+//#########################################################
+func (s *ConsoleSink) Output(procName string, sStream string, line string) {
+	select {
+	case s.lines <- consoleLine{procName: procName, sStream: sStream, line: line}:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+//Close removes the sink and returns once the queued lines are written. Call it after the
+//processes ended, their output is not passed to the sink any more.
+//#########################################################
+func (s *ConsoleSink) Close() {
+	RemoveOutputSink(s)
+	close(s.lines)
+	<-s.written
+}
+
+// write writes the queued lines to the console until the sink is closed
+//------------------------------------------------------------------------------
+func (s *ConsoleSink) write() {
+	defer close(s.written)
+	for outLine := range s.lines {
+		if dropped := s.dropped.Swap(0); dropped > 0 {
+			fmt.Fprintf(s.stderr, "... %d lines of output not shown, the console is too slow\n", dropped)
+		}
+		s.prefixWidth = max(s.prefixWidth, len(outLine.procName))
+		sPrefix := outLine.procName + strings.Repeat(" ", s.prefixWidth-len(outLine.procName)) + " | "
+		if s.bColor {
+			sColor, found := s.colors[outLine.procName]
+			if !found {
+				sColor = consoleColors[len(s.colors)%len(consoleColors)]
+				s.colors[outLine.procName] = sColor
+			}
+			sPrefix = sColor + sPrefix + consoleReset
+		}
+		output := s.stdout
+		if outLine.sStream == "stderr" {
+			output = s.stderr
+		}
+		fmt.Fprintln(output, sPrefix+outLine.line)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package gpcprocessmgr
+
+import "os"
+
+// enableConsoleColors reports whether the terminal shows colors, terminals do
+//------------------------------------------------------------------------------
+func enableConsoleColors(console *os.File) bool {
+	return true
+}
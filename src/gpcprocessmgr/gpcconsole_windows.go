@@ -0,0 +1,26 @@
+package gpcprocessmgr
+
+import (
+	"os"
+	"unsafe"
+)
+
+// console mode interpreting escape sequences, Windows 10 and later
+const enableVirtualTerminalProcessing = 0x4
+
+var (
+	procGetConsoleMode = modkernel32.NewProc("GetConsoleMode")
+	procSetConsoleMode = modkernel32.NewProc("SetConsoleMode")
+)
+
+// enableConsoleColors switches the console to interpret escape sequences. Reports false if it
+// can not, older consoles would show them as text.
+//------------------------------------------------------------------------------
+func enableConsoleColors(console *os.File) bool {
+	var mode uint32
+	if result, _, _ := procGetConsoleMode.Call(console.Fd(), uintptr(unsafe.Pointer(&mode))); result == 0 {
+		return false
+	}
+	result, _, _ := procSetConsoleMode.Call(console.Fd(), uintptr(mode|enableVirtualTerminalProcessing))
+	return result != 0
+}
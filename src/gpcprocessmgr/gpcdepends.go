@@ -0,0 +1,111 @@
+package gpcprocessmgr
+
+import (
+	"context"
+	"gpcconfig"
+	"gpclogging"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long a tier of the shutdown may take, zero => no limit
+var gShutdownTierTimeout time.Duration
+
+// setShutdownTierTimeout takes the tier timeout of the configuration
+//------------------------------------------------------------------------------
+func setShutdownTierTimeout(configData *gpcconfig.ConfigData) {
+	gStopMux.Lock()
+	gShutdownTierTimeout = configData.Shutdown.TierTimeout.Duration
+	gStopMux.Unlock()
+}
+
+// shutdownTiers groups the processes in the order they are stopped: every process comes in a
+// tier before the processes it depends on, so dependents are stopped first. Processes nothing
+// depends on are in the first tier. Dependencies on unknown tasks and cycles are ignored, the
+// preflight check reports them. The caller must hold gRuntimeDatatMux.
+//------------------------------------------------------------------------------
+func shutdownTiers() [][]string {
+	dependents := make(map[string][]string)
+	for procName, runtimeData := range gProcRuntimeData {
+		for _, sDependency := range runtimeData.procConfig.DependsOn {
+			if _, found := gProcRuntimeData[sDependency]; found && sDependency != procName {
+				dependents[sDependency] = append(dependents[sDependency], procName)
+			}
+		}
+	}
+
+	// the tier of a process is one after the last tier of its dependents
+	tierOf := make(map[string]int)
+	visiting := make(map[string]bool)
+	var tier func(procName string) int
+	tier = func(procName string) int {
+		if tierIndex, found := tierOf[procName]; found {
+			return tierIndex
+		}
+		if visiting[procName] {
+			return 0
+		}
+		visiting[procName] = true
+		tierIndex := 0
+		for _, sDependent := range dependents[procName] {
+			if dependentTier := tier(sDependent) + 1; dependentTier > tierIndex {
+				tierIndex = dependentTier
+			}
+		}
+		visiting[procName] = false
+		tierOf[procName] = tierIndex
+		return tierIndex
+	}
+
+	var tiers [][]string
+	for procName := range gProcRuntimeData {
+		tierIndex := tier(procName)
+		for len(tiers) <= tierIndex {
+			tiers = append(tiers, nil)
+		}
+		tiers[tierIndex] = append(tiers[tierIndex], procName)
+	}
+	for _, procNames := range tiers {
+		sort.Strings(procNames)
+	}
+	return tiers
+}
+
+// stopTier stops the processes of a shutdown tier in parallel. Once the tier timeout has passed
+// the grace periods end and what is left is killed. Returns the processes that had to be killed.
+//------------------------------------------------------------------------------
+func stopTier(tierIndex int, procNames []string, procRuntimeData map[string]*GPCProcRuntimeData) []string {
+	gpclogging.Debug("Stopping shutdown tier <%d>: <%s>", tierIndex, strings.Join(procNames, ", "))
+
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	gStopMux.Lock()
+	if gShutdownTierTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, gShutdownTierTimeout)
+	}
+	gStopMux.Unlock()
+	defer cancel()
+
+	var killedNames []string
+	var killedMux sync.Mutex
+	var stopping sync.WaitGroup
+	for _, procName := range procNames {
+		stopping.Add(1)
+		go func(procName string, runtimeData *GPCProcRuntimeData) {
+			defer stopping.Done()
+			if stopProcess(ctx, procName, runtimeData) {
+				killedMux.Lock()
+				killedNames = append(killedNames, procName)
+				killedMux.Unlock()
+			}
+		}(procName, procRuntimeData[procName])
+	}
+	stopping.Wait()
+
+	if ctx.Err() == context.DeadlineExceeded {
+		gpclogging.Warn("Shutdown tier <%d> (%s) did not stop within <%s>.", tierIndex, strings.Join(procNames, ", "), gShutdownTierTimeout.String())
+	}
+	sort.Strings(killedNames)
+	return killedNames
+}
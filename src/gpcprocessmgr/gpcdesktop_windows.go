@@ -0,0 +1,212 @@
+package gpcprocessmgr
+
+import (
+	"fmt"
+	"gpcconfig"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// os/exec can not set the desktop of a new process (lpDesktop of STARTUPINFO), so
+// processes with a Desktop or Session are created with CreateProcess directly.
+
+// consts
+const (
+	defaultInteractiveDesktop = "WinSta0\\Default"
+	noActiveConsoleSession    = 0xFFFFFFFF
+	resumeThreadFailed        = 0xFFFFFFFF
+)
+
+var (
+	modwtsapi32                      = syscall.NewLazyDLL("wtsapi32.dll")
+	procWTSQueryUserToken            = modwtsapi32.NewProc("WTSQueryUserToken")
+	procWTSGetActiveConsoleSessionID = modkernel32.NewProc("WTSGetActiveConsoleSessionId")
+	procResumeThread                 = modkernel32.NewProc("ResumeThread")
+)
+
+//startOnDesktop starts the process of the runtime data on its configured desktop, in its
+//configured session, inside a new job object. The log file is passed as stdout and stderr.
+//-------------------------------------------------------------------
+func startOnDesktop(runtimeData *GPCProcRuntimeData) error {
+	procCmd := runtimeData.procCmd
+	procConfig := runtimeData.procConfig
+	if procCmd.Err != nil {
+		return procCmd.Err
+	}
+	prepareSuspendedStart(runtimeData)
+
+	// The process runs as the user of the session, or as RunAsUser
+	if len(procConfig.Session) > 0 {
+		hToken, err := sessionUserToken(procConfig.Session)
+		if err != nil {
+			return err
+		}
+		defer hToken.Close()
+		procCmd.SysProcAttr.Token = hToken
+	} else {
+		closeToken, err := applyRunAs(runtimeData)
+		if err != nil {
+			return err
+		}
+		defer closeToken()
+	}
+
+	sDesktop := procConfig.Desktop
+	if len(sDesktop) == 0 {
+		sDesktop = defaultInteractiveDesktop
+	}
+	startupInfo := syscall.StartupInfo{}
+	startupInfo.Cb = uint32(unsafe.Sizeof(startupInfo))
+	var err error
+	if startupInfo.Desktop, err = syscall.UTF16PtrFromString(sDesktop); err != nil {
+		return err
+	}
+	if procCmd.SysProcAttr.HideWindow {
+		startupInfo.Flags |= syscall.STARTF_USESHOWWINDOW
+		startupInfo.ShowWindow = syscall.SW_HIDE
+	}
+
+	// Inheritable duplicates of the log files as stdout and stderr, like os/exec does it
+	hOut, err := inheritableHandle(procCmd.Stdout)
+	if err != nil {
+		return err
+	}
+	if hOut != 0 {
+		defer syscall.CloseHandle(hOut)
+	}
+	hErr, err := inheritableHandle(procCmd.Stderr)
+	if err != nil {
+		return err
+	}
+	if hErr != 0 {
+		defer syscall.CloseHandle(hErr)
+	}
+	inheritHandles := hOut != 0 || hErr != 0
+	if inheritHandles {
+		startupInfo.Flags |= syscall.STARTF_USESTDHANDLES
+		startupInfo.StdOutput, startupInfo.StdErr = hOut, hErr
+	}
+
+	appName, err := syscall.UTF16PtrFromString(procCmd.Path)
+	if err != nil {
+		return err
+	}
+	sCmdLine := procCmd.SysProcAttr.CmdLine
+	if len(sCmdLine) == 0 {
+		quotedArgs := make([]string, 0, len(procCmd.Args))
+		for _, arg := range procCmd.Args {
+			quotedArgs = append(quotedArgs, syscall.EscapeArg(arg))
+		}
+		sCmdLine = strings.Join(quotedArgs, " ")
+	}
+	cmdLine, err := syscall.UTF16PtrFromString(sCmdLine)
+	if err != nil {
+		return err
+	}
+	envBlock, err := environmentBlock(procCmd.Env)
+	if err != nil {
+		return err
+	}
+	var currentDir *uint16
+	if len(procCmd.Dir) > 0 {
+		if currentDir, err = syscall.UTF16PtrFromString(procCmd.Dir); err != nil {
+			return err
+		}
+	}
+
+	var procInfo syscall.ProcessInformation
+	flags := procCmd.SysProcAttr.CreationFlags | syscall.CREATE_UNICODE_ENVIRONMENT
+	if procCmd.SysProcAttr.Token != 0 {
+		err = syscall.CreateProcessAsUser(procCmd.SysProcAttr.Token, appName, cmdLine, nil, nil, inheritHandles, flags,
+			&envBlock[0], currentDir, &startupInfo, &procInfo)
+	} else {
+		err = syscall.CreateProcess(appName, cmdLine, nil, nil, inheritHandles, flags, &envBlock[0], currentDir, &startupInfo, &procInfo)
+	}
+	if err != nil {
+		return os.NewSyscallError("CreateProcess", err)
+	}
+	defer syscall.CloseHandle(procInfo.Thread)
+	defer syscall.CloseHandle(procInfo.Process)
+
+	if procCmd.Process, err = os.FindProcess(int(procInfo.ProcessId)); err != nil {
+		syscall.TerminateProcess(procInfo.Process, 1)
+		return err
+	}
+	assignToJob(runtimeData, procInfo.Process)
+
+	if r1, _, e1 := procResumeThread.Call(uintptr(procInfo.Thread)); r1 == resumeThreadFailed {
+		syscall.TerminateProcess(procInfo.Process, 1)
+		closeJob(runtimeData)
+		return os.NewSyscallError("ResumeThread", e1)
+	}
+	return nil
+}
+
+// sessionUserToken returns the token of the user logged on to a session, "console" is the
+// session attached to the physical console
+//------------------------------------------------------------------------------
+func sessionUserToken(sSession string) (syscall.Token, error) {
+	var sessionID uint32
+	if strings.EqualFold(sSession, gpcconfig.SessionConsole) {
+		r1, _, _ := procWTSGetActiveConsoleSessionID.Call()
+		if uint32(r1) == noActiveConsoleSession {
+			return 0, fmt.Errorf("there is no active console session")
+		}
+		sessionID = uint32(r1)
+	} else {
+		parsedID, err := strconv.ParseUint(sSession, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid session <%s>", sSession)
+		}
+		sessionID = uint32(parsedID)
+	}
+
+	var hToken syscall.Token
+	r1, _, e1 := procWTSQueryUserToken.Call(uintptr(sessionID), uintptr(unsafe.Pointer(&hToken)))
+	if r1 == 0 {
+		return 0, os.NewSyscallError("WTSQueryUserToken", e1)
+	}
+	return hToken, nil
+}
+
+// environmentBlock builds the environment block for CreateProcess, nil is the environment
+// of the controller
+//------------------------------------------------------------------------------
+func environmentBlock(env []string) ([]uint16, error) {
+	if env == nil {
+		env = os.Environ()
+	}
+	var block []uint16
+	for _, entry := range env {
+		entryUTF16, err := syscall.UTF16FromString(entry)
+		if err != nil {
+			return nil, err
+		}
+		block = append(block, entryUTF16...)
+	}
+	if len(block) == 0 {
+		block = append(block, 0)
+	}
+	return append(block, 0), nil
+}
+
+// inheritableHandle returns an inheritable duplicate of the handle of a log file, zero if the
+// writer is no file
+//------------------------------------------------------------------------------
+func inheritableHandle(w io.Writer) (syscall.Handle, error) {
+	logFile, ok := w.(*os.File)
+	if !ok {
+		return 0, nil
+	}
+	hCurrent, _ := syscall.GetCurrentProcess()
+	var hLog syscall.Handle
+	err := syscall.DuplicateHandle(hCurrent, syscall.Handle(logFile.Fd()), hCurrent, &hLog, 0, true, syscall.DUPLICATE_SAME_ACCESS)
+	if err != nil {
+		return 0, os.NewSyscallError("DuplicateHandle", err)
+	}
+	return hLog, nil
+}
@@ -0,0 +1,55 @@
+package gpcprocessmgr
+
+import (
+	"gpcconfig"
+	"gpclogging"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// starts that are due but not yet through: start delays, restarts and manual restarts
+	gPendingStarts atomic.Int32
+
+	// closed by the monitor once nothing runs any more, with Shutdown.ExitWhenDone only
+	gExitWhenDone bool // guarded by gStopMux, set once the initial starts are triggered
+	gAllDone      = make(chan struct{})
+	gAllDoneOnce  sync.Once
+)
+
+//AllDone returns a channel that is closed once no process is running or about to be started,
+//i.e. all processes ended and their restarts are used up, if Shutdown.ExitWhenDone is set.
+//Scheduled tasks are never done, cold standbys and follow up tasks that were not triggered are.
+//#########################################################
+func AllDone() <-chan struct{} {
+	return gAllDone
+}
+
+// armExitWhenDone lets the monitor check for AllDone, after the initial starts are counted
+//------------------------------------------------------------------------------
+func armExitWhenDone(configData *gpcconfig.ConfigData) {
+	gStopMux.Lock()
+	gExitWhenDone = configData.Shutdown.ExitWhenDone
+	gStopMux.Unlock()
+}
+
+// checkAllDone closes the AllDone channel when nothing runs or is about to. Called by the
+// monitor, which handles the exits of no-wait processes, so a restart is always pending or done.
+//------------------------------------------------------------------------------
+func checkAllDone() {
+	gStopMux.Lock()
+	bExitWhenDone := gExitWhenDone
+	gStopMux.Unlock()
+	if !bExitWhenDone || gPendingStarts.Load() > 0 {
+		return
+	}
+	for _, runtimeData := range currentRuntimeData() {
+		if runtimeData.procStatus.active || runtimeData.procStatus.heldForMaintenance || len(runtimeData.procConfig.Schedule) > 0 {
+			return
+		}
+	}
+	gAllDoneOnce.Do(func() {
+		gpclogging.Info("All processes ended and none of them is restarted.")
+		close(gAllDone)
+	})
+}
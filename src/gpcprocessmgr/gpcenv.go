@@ -0,0 +1,70 @@
+package gpcprocessmgr
+
+import (
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// processEnvironment returns the environment a process is started with: the one of the
+// controller plus the locale, the GPU selection, the heartbeat channel, the notify socket and the variables of the task. Returns nil (inherit everything)
+// if the task does not configure any.
+//------------------------------------------------------------------------------
+func processEnvironment(runtimeData *GPCProcRuntimeData) []string {
+	extraEnv := make(map[string]string)
+	if len(runtimeData.procConfig.Locale) > 0 {
+		extraEnv["LANG"] = runtimeData.procConfig.Locale
+		extraEnv["LC_ALL"] = runtimeData.procConfig.Locale
+	}
+	if len(runtimeData.procConfig.GPUs) > 0 {
+		gpuList := make([]string, 0, len(runtimeData.procConfig.GPUs))
+		for _, gpu := range runtimeData.procConfig.GPUs {
+			gpuList = append(gpuList, strconv.Itoa(gpu))
+		}
+		extraEnv["CUDA_VISIBLE_DEVICES"] = strings.Join(gpuList, ",")
+	}
+	for envName, envValue := range heartbeatEnvironment(runtimeData) {
+		extraEnv[envName] = envValue
+	}
+	for envName, envValue := range notifyEnvironment(runtimeData) {
+		extraEnv[envName] = envValue
+	}
+	// Explicit variables win over the locale, the GPU selection, the heartbeat channel and the notify socket
+	for envName, envValue := range runtimeData.procConfig.Env {
+		extraEnv[envName] = envValue
+	}
+	if len(extraEnv) == 0 {
+		return nil
+	}
+
+	envNames := make([]string, 0, len(extraEnv))
+	for envName := range extraEnv {
+		envNames = append(envNames, envName)
+	}
+	sort.Strings(envNames)
+
+	// Later entries override earlier ones with the same name
+	env := os.Environ()
+	for _, envName := range envNames {
+		env = append(env, envName+"="+extraEnv[envName])
+	}
+	return env
+}
+
+// appendEnv adds variables to the environment of a command, over the ones it has already
+//------------------------------------------------------------------------------
+func appendEnv(procCmd *exec.Cmd, extraEnv map[string]string) {
+	if procCmd.Env == nil {
+		procCmd.Env = os.Environ()
+	}
+	envNames := make([]string, 0, len(extraEnv))
+	for envName := range extraEnv {
+		envNames = append(envNames, envName)
+	}
+	sort.Strings(envNames)
+	for _, envName := range envNames {
+		procCmd.Env = append(procCmd.Env, envName+"="+extraEnv[envName])
+	}
+}
@@ -0,0 +1,62 @@
+package gpcprocessmgr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by the exported functions and recorded as last error of a process.
+// Check them with errors.Is, the returned errors are of type *ProcessError.
+var (
+	ErrUnknownProcess     = errors.New("unknown process")
+	ErrNoTaggedProcess    = errors.New("no process has the tag")
+	ErrNoInstances        = errors.New("task has no instances")
+	ErrNotReady           = errors.New("process did not get ready")
+	ErrAlreadyRunning     = errors.New("process is already running")
+	ErrNotRunning         = errors.New("process is not running")
+	ErrWaitingOnCondition = errors.New("process is waiting on a precondition")
+	ErrStartFailed        = errors.New("process could not be started")
+	ErrPortInUse          = errors.New("port of BindPorts in use")
+	ErrChecksumMismatch   = errors.New("executable checksum mismatch")
+	ErrRunFailed          = errors.New("process run failed")
+	ErrRestartLimit       = errors.New("process reached its restart limit")
+	ErrNoRestartExit      = errors.New("process exited with an exit code that is not restarted")
+	ErrMaxRuntime         = errors.New("process ran longer than its MaxRuntime")
+	ErrHung               = errors.New("process is hung")
+	ErrQuarantined        = errors.New("process is quarantined after a crash loop")
+	ErrNotQuarantined     = errors.New("process is neither quarantined nor suspended")
+	ErrAlreadySuspended   = errors.New("process is already suspended")
+	ErrNotInMaintenance   = errors.New("not in maintenance")
+	ErrSignalFailed       = errors.New("signal could not be sent to the process")
+	ErrNoLogFile          = errors.New("process has no output log file")
+	ErrNoStdin            = errors.New("process has no stdin pipe")
+	ErrNoRecentOutput     = errors.New("process keeps no recent output in memory")
+)
+
+// ProcessError is an error concerning a single process
+type ProcessError struct {
+	Process string // name of the process
+	Kind    error  // one of the Err... values above
+	Cause   error  // underlying error, nil if there is none
+}
+
+// newProcessError returns a *ProcessError as error
+func newProcessError(procName string, kind error, cause error) error {
+	return &ProcessError{Process: procName, Kind: kind, Cause: cause}
+}
+
+// Error implements the error interface
+func (e *ProcessError) Error() string {
+	if e.Cause == nil {
+		return fmt.Sprintf("%s <%s>", e.Kind.Error(), e.Process)
+	}
+	return fmt.Sprintf("%s <%s>: %s", e.Kind.Error(), e.Process, e.Cause.Error())
+}
+
+// Unwrap makes errors.Is and errors.As see both the kind and the cause
+func (e *ProcessError) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Cause}
+}
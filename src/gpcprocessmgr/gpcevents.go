@@ -0,0 +1,155 @@
+package gpcprocessmgr
+
+import (
+	"gpclogging"
+	"sync"
+	"time"
+)
+
+// EventType tells what happened to a process or the controller
+type EventType string
+
+// lifecycle events
+const (
+	EventProcessStarted      EventType = "ProcessStarted"             // a process was launched or adopted
+	EventProcessStartFailed  EventType = "ProcessStartFailed"         // a process could not be launched
+	EventProcessExited       EventType = "ProcessExited"              // a process ended, crashed, was stopped or timed out
+	EventProcessRestarted    EventType = "ProcessRestarted"           // a process is restarted, the ProcessStarted of the new run follows
+	EventProcessRestartLimit EventType = "ProcessRestartLimitReached" // a process ended after its last allowed restart or retry, or with an exit code that is not restarted, and stays down
+	EventProcessQuarantined  EventType = "ProcessQuarantined"         // a process in a crash loop is not restarted until resumed
+	EventProcessHung         EventType = "ProcessHung"                // a process wrote no output for its HungTimeout or sent no heartbeat for its ExpectHeartbeat, it is killed and the ProcessExited follows
+	EventProcessSuspended    EventType = "ProcessSuspended"           // a process was suspended on request and does not run until resumed
+	EventProcessResumed      EventType = "ProcessResumed"             // a suspended process runs again
+	EventMaintenanceStarted  EventType = "MaintenanceStarted"         // a process, or the controller if Process is empty, is in maintenance and nothing is restarted
+	EventMaintenanceEnded    EventType = "MaintenanceEnded"           // a maintenance was ended or expired, processes held back are restarted
+	EventControllerShutdown  EventType = "ControllerShutdown"         // the controller stops all processes and ends
+)
+
+// EventTypes lists all event types
+var EventTypes = []EventType{EventProcessStarted, EventProcessStartFailed, EventProcessExited, EventProcessRestarted,
+	EventProcessRestartLimit, EventProcessQuarantined, EventProcessHung, EventProcessSuspended, EventProcessResumed,
+	EventMaintenanceStarted, EventMaintenanceEnded, EventControllerShutdown}
+
+// Size of the channel of a subscription without an explicit one
+const defEventBufferSize = 64
+
+// Event is a lifecycle event of a process or of the controller
+type Event struct {
+	Type     EventType
+	Time     time.Time
+	Process  string // name of the process, empty for controller events
+	State    string // state of the process after the event, see ProcessStatus.State. Empty for controller events
+	PID      int    // PID of the process, zero if unknown or for controller events
+	ExitCode int    // ProcessExited only: exit code, -1 if unknown
+	Failed   bool   // ProcessExited only: the process ended on its own, not on request, and not successfully
+	Message  string // human readable details, e.g. why a process was restarted
+}
+
+// Subscription receives the events published after Subscribe on C until Unsubscribe.
+// Events are dropped instead of blocking the controller while C is full.
+type Subscription struct {
+	C       <-chan Event
+	channel chan Event
+	dropped uint64 // events not delivered because the channel was full, guarded by gEventsMux
+}
+
+var (
+	// open subscriptions and the number of events published per type
+	gSubscriptions = make(map[*Subscription]bool)
+	gEventCounts   = make(map[EventType]uint64)
+	gEventsMux     sync.Mutex
+)
+
+//Subscribe returns a subscription to all lifecycle events. The channel buffers bufferSize events,
+//zero => 64. Receive from it promptly: it is never blocked on, a full channel loses events.
+//#########################################################
+func Subscribe(bufferSize int) *Subscription {
+	if bufferSize <= 0 {
+		bufferSize = defEventBufferSize
+	}
+	channel := make(chan Event, bufferSize)
+	subscription := &Subscription{C: channel, channel: channel}
+
+	gEventsMux.Lock()
+	gSubscriptions[subscription] = true
+	gEventsMux.Unlock()
+	return subscription
+}
+
+//Unsubscribe ends the subscription and closes its channel
+//#########################################################
+func (s *Subscription) Unsubscribe() {
+	gEventsMux.Lock()
+	defer gEventsMux.Unlock()
+	if gSubscriptions[s] {
+		delete(gSubscriptions, s)
+		close(s.channel)
+	}
+}
+
+//Dropped returns how many events were lost because the channel was full
+//#########################################################
+func (s *Subscription) Dropped() uint64 {
+	gEventsMux.Lock()
+	defer gEventsMux.Unlock()
+	return s.dropped
+}
+
+//EventCounts returns how many events of each type were published since the start
+//#########################################################
+func EventCounts() map[EventType]uint64 {
+	gEventsMux.Lock()
+	defer gEventsMux.Unlock()
+
+	counts := make(map[EventType]uint64, len(gEventCounts))
+	for eventType, count := range gEventCounts {
+		counts[eventType] = count
+	}
+	return counts
+}
+
+// publish passes an event to all subscribers without waiting for them
+//------------------------------------------------------------------------------
+func publish(event Event) {
+	event.Time = time.Now()
+	recordStats(event)
+
+	gEventsMux.Lock()
+	defer gEventsMux.Unlock()
+	gEventCounts[event.Type]++
+	for subscription := range gSubscriptions {
+		select {
+		case subscription.channel <- event:
+		default:
+			subscription.dropped++
+			gpclogging.Debug("Event <%s> of process <%s> dropped, the subscriber is too slow.", event.Type, event.Process)
+		}
+	}
+}
+
+// publishProcessEvent publishes an event of a process, the caller holds the lock of the process
+//------------------------------------------------------------------------------
+func publishProcessEvent(eventType EventType, procName string, runtimeData *GPCProcRuntimeData, message string) {
+	publish(Event{
+		Type:     eventType,
+		Process:  procName,
+		State:    runtimeData.stateName(),
+		PID:      runtimeData.procStatus.pid,
+		ExitCode: runtimeData.procStatus.exitCode,
+		Message:  message,
+	})
+}
+
+// publishFailedExit publishes the exit of a process that ended on its own without success
+//------------------------------------------------------------------------------
+func publishFailedExit(procName string, runtimeData *GPCProcRuntimeData, message string) {
+	publish(Event{
+		Type:     EventProcessExited,
+		Process:  procName,
+		State:    runtimeData.stateName(),
+		PID:      runtimeData.procStatus.pid,
+		ExitCode: runtimeData.procStatus.exitCode,
+		Failed:   true,
+		Message:  message,
+	})
+}
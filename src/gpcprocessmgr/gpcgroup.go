@@ -0,0 +1,137 @@
+package gpcprocessmgr
+
+import (
+	"context"
+	"errors"
+	"gpcconfig"
+	"gpclogging"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//TaggedProcesses returns the names of the processes with a tag, sorted. The tag may have the
+//wildcards of path.Match like the tag filters of -only and -skip.
+//#########################################################
+func TaggedProcesses(sTag string) []string {
+	var procNames []string
+	for _, tier := range groupTiers(sTag) {
+		procNames = append(procNames, tier...)
+	}
+	sort.Strings(procNames)
+	return procNames
+}
+
+//StartGroup starts the processes with a tag that are not running, tier by tier in dependency
+//order: the tasks of their DependsOn before them, the ones of a tier in parallel. The next tier
+//waits for the tasks with NotifyReady to notify they are ready. Returns the errors of the
+//processes that could not be started or did not get ready, the others are started anyway.
+//#########################################################
+func StartGroup(ctx context.Context, sTag string) error {
+	gpclogging.Debug("Entering StartGroup() for tag <%s>", sTag)
+
+	tiers := groupTiers(sTag)
+	if len(tiers) == 0 {
+		return newProcessError(sTag, ErrNoTaggedProcess, nil)
+	}
+	var errs []error
+	for tierIndex := len(tiers) - 1; tierIndex >= 0; tierIndex-- {
+		errs = append(errs, groupAction(ctx, tiers[tierIndex], StartProcess, ErrAlreadyRunning)...)
+		errs = append(errs, waitNotifiedReady(ctx, tiers[tierIndex])...)
+	}
+
+	gpclogging.Debug("Leaving StartGroup()")
+	return errors.Join(errs...)
+}
+
+//StopGroup stops the running processes with a tag in the order of the shutdown: the tasks
+//that depend on others before them, the ones of a tier in parallel
+//#########################################################
+func StopGroup(ctx context.Context, sTag string) error {
+	gpclogging.Debug("Entering StopGroup() for tag <%s>", sTag)
+
+	tiers := groupTiers(sTag)
+	if len(tiers) == 0 {
+		return newProcessError(sTag, ErrNoTaggedProcess, nil)
+	}
+	var errs []error
+	for _, tier := range tiers {
+		errs = append(errs, groupAction(ctx, tier, StopProcess, ErrNotRunning)...)
+	}
+
+	gpclogging.Debug("Leaving StopGroup()")
+	return errors.Join(errs...)
+}
+
+//RestartGroup restarts the processes with a tag as a unit: all of them are stopped like by
+//StopGroup and then started again like by StartGroup, so no process of the group runs with a
+//dependency of an older run. Processes of the group that were not running are started as well.
+//#########################################################
+func RestartGroup(ctx context.Context, sTag string) error {
+	gpclogging.Debug("Entering RestartGroup() for tag <%s>", sTag)
+
+	// Nothing of the group runs between stop and start, the controller must not take that as all done
+	gPendingStarts.Add(1)
+	defer gPendingStarts.Add(-1)
+	restarted := make(map[string]*GPCProcRuntimeData)
+	for _, procName := range TaggedProcesses(sTag) {
+		if runtimeData, err := getRuntimeData(procName); err == nil && runtimeData.procStatus.active {
+			restarted[procName] = runtimeData
+		}
+	}
+	if err := StopGroup(ctx, sTag); err != nil {
+		return err
+	}
+	for procName, runtimeData := range restarted {
+		publishProcessEvent(EventProcessRestarted, procName, runtimeData, "group restart of tag <"+sTag+">")
+	}
+
+	gpclogging.Debug("Leaving RestartGroup()")
+	return StartGroup(ctx, sTag)
+}
+
+// groupTiers returns the shutdown tiers of the processes with a tag, without the empty ones
+//------------------------------------------------------------------------------
+func groupTiers(sTag string) [][]string {
+	filter := gpcconfig.TaskFilter{Kind: gpcconfig.FilterTag, Value: sTag}
+	gRuntimeDatatMux.Lock()
+	defer gRuntimeDatatMux.Unlock()
+
+	var tiers [][]string
+	for _, tier := range shutdownTiers() {
+		var procNames []string
+		for _, procName := range tier {
+			if filter.Matches(gProcRuntimeData[procName].procConfig) {
+				procNames = append(procNames, procName)
+			}
+		}
+		if len(procNames) > 0 {
+			tiers = append(tiers, procNames)
+		}
+	}
+	return tiers
+}
+
+// groupAction calls an action for the processes of a tier in parallel and returns their errors,
+// except the ignored one, e.g. ErrNotRunning for a stop
+//------------------------------------------------------------------------------
+func groupAction(ctx context.Context, procNames []string, action func(context.Context, string) error, ignored error) []error {
+	gpclogging.Debug("Group action on <%s>", strings.Join(procNames, ", "))
+
+	var errs []error
+	var errsMux sync.Mutex
+	var acting sync.WaitGroup
+	for _, procName := range procNames {
+		acting.Add(1)
+		go func(procName string) {
+			defer acting.Done()
+			if err := action(ctx, procName); err != nil && !errors.Is(err, ignored) {
+				errsMux.Lock()
+				errs = append(errs, err)
+				errsMux.Unlock()
+			}
+		}(procName)
+	}
+	acting.Wait()
+	return errs
+}
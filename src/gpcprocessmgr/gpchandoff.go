@@ -0,0 +1,50 @@
+package gpcprocessmgr
+
+import (
+	"encoding/json"
+	"gpclogging"
+)
+
+//HandOff prepares handing the running processes over to a new controller, e.g. to upgrade the
+//controller without downtime. The monitor, the schedulers and the start delays stop, so nothing
+//is started or restarted any more, the processes keep running. Returns the state of the processes
+//for SetHandoffState of the new controller, one JSON line per process. The controller must end
+//afterwards without ShutdownAll.
+//#########################################################
+func HandOff() []string {
+	gpclogging.Info("Handing the processes off to a new controller, nothing is started or restarted any more.")
+	gStopMux.Lock()
+	gStopMon = true
+	gShutdownCancel()
+	monitorDone := gMonitorDone
+	gStopMux.Unlock()
+	// no restart of the monitor must get past the state
+	if monitorDone != nil {
+		<-monitorDone
+	}
+
+	var lines []string
+	for _, procState := range processStates() {
+		if stateBytes, err := json.Marshal(&procState); err == nil {
+			lines = append(lines, string(stateBytes))
+		}
+	}
+	return lines
+}
+
+//SetHandoffState takes the state returned by HandOff of the previous controller. The next
+//StartProcessesFromConfig re-attaches to the processes that still run, instead of starting
+//them, and continues restart budgets and quarantines.
+//#########################################################
+func SetHandoffState(lines []string) error {
+	states := make([]processState, 0, len(lines))
+	for _, sLine := range lines {
+		var procState processState
+		if err := json.Unmarshal([]byte(sLine), &procState); err != nil {
+			return err
+		}
+		states = append(states, procState)
+	}
+	gHandoffState = states
+	return nil
+}
@@ -0,0 +1,142 @@
+package gpcprocessmgr
+
+import (
+	"errors"
+	"gpcconfig"
+	"gpclogging"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The heartbeats of the tasks with ExpectHeartbeat arrive as UDP datagrams holding the name of the
+// task, on a socket that is opened when the first such task is launched. A task learns where to
+// send them from its environment:
+//
+//	GPC_HEARTBEAT_ADDR  host:port of the socket
+//	GPC_HEARTBEAT_NAME  name of the task, the content of each datagram
+//	GPC_HEARTBEAT_FILE  path of its HeartbeatFile, if it has one
+//
+// e.g. in bash: printf %s "$GPC_HEARTBEAT_NAME" > /dev/udp/${GPC_HEARTBEAT_ADDR%:*}/${GPC_HEARTBEAT_ADDR##*:}
+
+// size of the datagrams read, longer ones are cut and do not match a task
+const maxHeartbeatBytes = 512
+
+var (
+	gHeartbeatAddress string         // Control.HeartbeatAddress, empty => a free port on 127.0.0.1
+	gHeartbeatConn    net.PacketConn // the socket, nil until the first task expecting heartbeats is launched
+	gHeartbeatMux     sync.Mutex     // guards both, never held while taking the lock of a process
+)
+
+// setHeartbeatAddress takes the heartbeat address of the configuration. A socket that is open
+// already keeps its address, the running processes send their heartbeats there.
+//------------------------------------------------------------------------------
+func setHeartbeatAddress(configData *gpcconfig.ConfigData) {
+	gHeartbeatMux.Lock()
+	defer gHeartbeatMux.Unlock()
+	if gHeartbeatConn != nil && configData.Control.HeartbeatAddress != gHeartbeatAddress {
+		gpclogging.Warn("Heartbeat address changed to <%s>, it is used after a restart of the controller.", configData.Control.HeartbeatAddress)
+	}
+	gHeartbeatAddress = configData.Control.HeartbeatAddress
+}
+
+// heartbeatAddress returns the address of the heartbeat socket, it is opened on the first call.
+// It is closed when the controller shuts down.
+//------------------------------------------------------------------------------
+func heartbeatAddress() (string, error) {
+	gHeartbeatMux.Lock()
+	defer gHeartbeatMux.Unlock()
+	if gHeartbeatConn != nil {
+		return gHeartbeatConn.LocalAddr().String(), nil
+	}
+
+	sAddress := gHeartbeatAddress
+	if len(sAddress) == 0 {
+		sAddress = "127.0.0.1:0"
+	}
+	conn, err := net.ListenPacket("udp", sAddress)
+	if err != nil {
+		return "", err
+	}
+	gHeartbeatConn = conn
+	gpclogging.Info("Listening for heartbeats on <%s>.", conn.LocalAddr().String())
+
+	ctx := shutdownContext()
+	go func() {
+		<-ctx.Done()
+		gHeartbeatMux.Lock()
+		gHeartbeatConn = nil
+		gHeartbeatMux.Unlock()
+		conn.Close()
+	}()
+	go receiveHeartbeats(conn)
+	return conn.LocalAddr().String(), nil
+}
+
+// receiveHeartbeats records the heartbeats arriving on the socket until it is closed
+//------------------------------------------------------------------------------
+func receiveHeartbeats(conn net.PacketConn) {
+	buffer := make([]byte, maxHeartbeatBytes)
+	for {
+		count, sender, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				gpclogging.Error("Heartbeat socket failed, no more heartbeats are received: <%s>", err.Error())
+			}
+			return
+		}
+		procName := strings.TrimSpace(string(buffer[:count]))
+		runtimeData, err := getRuntimeData(procName)
+		if err != nil || runtimeData.procConfig.ExpectHeartbeat.Duration == 0 {
+			gpclogging.Debug("Heartbeat of unknown task <%s> from <%s> ignored.", procName, sender.String())
+			continue
+		}
+		runtimeData.recordHeartbeat(time.Now())
+	}
+}
+
+// recordHeartbeat notes a heartbeat of the process, it also counts as activity for HungTimeout
+//------------------------------------------------------------------------------
+func (r *GPCProcRuntimeData) recordHeartbeat(beatTime time.Time) {
+	r.lastHeartbeat.Store(beatTime.UnixNano())
+	r.lastActivity.Store(beatTime.UnixNano())
+}
+
+// heartbeatEnvironment returns the variables telling a task with ExpectHeartbeat where to send its
+// heartbeats, nil for other tasks. If the socket can not be opened, the task is started anyway,
+// its HeartbeatFile may still do.
+//------------------------------------------------------------------------------
+func heartbeatEnvironment(runtimeData *GPCProcRuntimeData) map[string]string {
+	procConfig := runtimeData.procConfig
+	if procConfig.ExpectHeartbeat.Duration == 0 {
+		return nil
+	}
+	heartbeatEnv := map[string]string{"GPC_HEARTBEAT_NAME": procConfig.Name}
+	if sAddress, err := heartbeatAddress(); err != nil {
+		gpclogging.Error("Could not open the heartbeat socket for process <%s>: <%s>", procConfig.Name, err.Error())
+	} else {
+		heartbeatEnv["GPC_HEARTBEAT_ADDR"] = sAddress
+	}
+	if sFile := procConfig.HeartbeatFilePath(); len(sFile) > 0 {
+		heartbeatEnv["GPC_HEARTBEAT_FILE"] = sFile
+	}
+	return heartbeatEnv
+}
+
+// lastHeartbeatOf returns the time of the latest heartbeat of a process, by datagram or by
+// touching its HeartbeatFile, zero if there was none
+//------------------------------------------------------------------------------
+func lastHeartbeatOf(runtimeData *GPCProcRuntimeData) time.Time {
+	var lastBeat time.Time
+	if beatNanos := runtimeData.lastHeartbeat.Load(); beatNanos != 0 {
+		lastBeat = time.Unix(0, beatNanos)
+	}
+	if sFile := runtimeData.procConfig.HeartbeatFilePath(); len(sFile) > 0 {
+		if fileInfo, err := os.Stat(sFile); err == nil && fileInfo.ModTime().After(lastBeat) {
+			lastBeat = fileInfo.ModTime()
+		}
+	}
+	return lastBeat
+}
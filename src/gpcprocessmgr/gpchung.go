@@ -0,0 +1,74 @@
+package gpcprocessmgr
+
+import (
+	"errors"
+	"fmt"
+	"gpclogging"
+	"time"
+)
+
+// How often the monitor looks for processes without output for their HungTimeout or without
+// heartbeat for their ExpectHeartbeat
+const hungCheckInterval = time.Second
+
+// time of the last check for hung processes, only used by the monitor
+var gLastHungCheck time.Time
+
+// checkHungProcesses kills the process trees of the no-wait processes that wrote no output (nor
+// sent a heartbeat) for their HungTimeout or sent no heartbeat for their ExpectHeartbeat, counted
+// from their start. The monitor then handles the exit like a crash, so MaxRestarts, the crash loop
+// breaker and standbys apply. HungTimeout is not checked for processes whose output is not
+// captured (adopted ones); suspended processes and ones in maintenance are not checked at all.
+// It is called from the monitor loop.
+//------------------------------------------------------------------------------
+func checkHungProcesses() {
+	if time.Since(gLastHungCheck) < hungCheckInterval {
+		return
+	}
+	gLastHungCheck = time.Now()
+
+	watched := runningProcesses(func(runtimeData *GPCProcRuntimeData) bool {
+		return (runtimeData.procConfig.HungTimeout.Duration > 0 || runtimeData.procConfig.ExpectHeartbeat.Duration > 0) &&
+			runtimeData.procConfig.WaitForExitTimeout.Duration == 0
+	})
+	now := time.Now()
+	for procName, runtimeData := range watched {
+		hungTimeout := runtimeData.procConfig.HungTimeout.Duration
+		heartbeatTimeout := runtimeData.procConfig.ExpectHeartbeat.Duration
+		// The heartbeat file is read before taking the lock
+		lastBeat := lastHeartbeatOf(runtimeData)
+		lastActivity := time.Unix(0, runtimeData.lastActivity.Load())
+		if lastBeat.After(lastActivity) {
+			lastActivity = lastBeat
+		}
+
+		runtimeData.mux.Lock()
+		procStatus := &runtimeData.procStatus
+		if procStatus.startTime.After(lastActivity) {
+			lastActivity = procStatus.startTime
+		}
+		if procStatus.startTime.After(lastBeat) {
+			lastBeat = procStatus.startTime
+		}
+		var sWhy, sSince string
+		switch {
+		case !procStatus.active || procStatus.hung || procStatus.suspended || procStatus.startTime.IsZero() || inMaintenance(procName):
+		case hungTimeout > 0 && runtimeData.capture != nil && now.Sub(lastActivity) >= hungTimeout:
+			sWhy = fmt.Sprintf("no output for %s", hungTimeout)
+			sSince = lastActivity.Format(time.RFC3339)
+		case heartbeatTimeout > 0 && now.Sub(lastBeat) >= heartbeatTimeout:
+			sWhy = fmt.Sprintf("no heartbeat for %s", heartbeatTimeout)
+			sSince = lastBeat.Format(time.RFC3339)
+		}
+		if len(sWhy) > 0 {
+			procStatus.hung = true
+			procStatus.lastError = newProcessError(procName, ErrHung, errors.New(sWhy))
+			gpclogging.Task(procName).Error("Process <%s>, PID=<%d> is HUNG, %s (last one at <%s>). Killing it.",
+				procName, procStatus.pid, sWhy, sSince)
+			publishProcessEvent(EventProcessHung, procName, runtimeData, procStatus.lastError.Error())
+			// Killed in background like on a memory limit, the monitor must not wait for the grace period
+			killRunInBackground(procName, runtimeData)
+		}
+		runtimeData.mux.Unlock()
+	}
+}
@@ -0,0 +1,295 @@
+package gpcprocessmgr
+
+import (
+	"fmt"
+	"gpcconfig"
+	"gpclogging"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Windows Job Objects group a process with all children it spawns, so the whole
+// process tree can be terminated at once. A child is started suspended, assigned
+// to a new job and only then resumed, so not even its first children can escape.
+
+// consts
+const (
+	createSuspended                = 0x00000004
+	processSetQuota                = 0x0100
+	processTerminate               = 0x0001
+	processSuspendResume           = 0x0800
+	processQueryLimitedInformation = 0x1000
+	jobObjectBasicProcessIdList    = 3
+	maxJobProcessIds               = 1024
+	jobObjectCPURateControlInfo    = 15
+	jobObjectCPURateControlEnable  = 0x1
+	jobObjectCPURateControlHardCap = 0x4
+)
+
+// priority classes as process creation flags
+var priorityClasses = map[string]uint32{
+	gpcconfig.PriorityIdle:        0x00000040,
+	gpcconfig.PriorityBelowNormal: 0x00004000,
+	gpcconfig.PriorityNormal:      0x00000020,
+	gpcconfig.PriorityHigh:        0x00000080,
+}
+
+// JOBOBJECT_CPU_RATE_CONTROL_INFORMATION
+type jobObjectCPURateControlInformation struct {
+	ControlFlags uint32
+	CPURate      uint32 // in 1/100 percent
+}
+
+// JOBOBJECT_BASIC_PROCESS_ID_LIST with room for maxJobProcessIds entries
+type jobObjectBasicProcessIDList struct {
+	NumberOfAssignedProcesses uint32
+	NumberOfProcessIdsInList  uint32
+	ProcessIdList             [maxJobProcessIds]uintptr
+}
+
+// PROCESS_MEMORY_COUNTERS
+type processMemoryCounters struct {
+	Cb                         uint32
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+	PagefileUsage              uintptr
+	PeakPagefileUsage          uintptr
+}
+
+var (
+	modkernel32                   = syscall.NewLazyDLL("kernel32.dll")
+	modntdll                      = syscall.NewLazyDLL("ntdll.dll")
+	procCreateJobObjectW          = modkernel32.NewProc("CreateJobObjectW")
+	procAssignProcessToJobObject  = modkernel32.NewProc("AssignProcessToJobObject")
+	procTerminateJobObject        = modkernel32.NewProc("TerminateJobObject")
+	procQueryInformationJobObject = modkernel32.NewProc("QueryInformationJobObject")
+	procSetInformationJobObject   = modkernel32.NewProc("SetInformationJobObject")
+	procK32GetProcessMemoryInfo   = modkernel32.NewProc("K32GetProcessMemoryInfo")
+	procGetProcessHandleCount     = modkernel32.NewProc("GetProcessHandleCount")
+	procNtResumeProcess           = modntdll.NewProc("NtResumeProcess")
+	procNtSuspendProcess          = modntdll.NewProc("NtSuspendProcess")
+)
+
+//startInJob starts the process of the runtime data inside a new job object.
+//If the job can not be set up the process still runs, just without a job.
+//-------------------------------------------------------------------
+func startInJob(runtimeData *GPCProcRuntimeData) error {
+	procCmd := runtimeData.procCmd
+	prepareSuspendedStart(runtimeData)
+
+	closeToken, err := applyRunAs(runtimeData)
+	if err != nil {
+		return err
+	}
+	err = procCmd.Start()
+	closeToken()
+	if err != nil {
+		return err
+	}
+
+	hProcess, err := syscall.OpenProcess(processSetQuota|processTerminate|processSuspendResume, false, uint32(procCmd.Process.Pid))
+	if err != nil {
+		// Without a handle the process can not even be resumed
+		procCmd.Process.Kill()
+		return os.NewSyscallError("OpenProcess", err)
+	}
+	defer syscall.CloseHandle(hProcess)
+
+	assignToJob(runtimeData, hProcess)
+
+	status, _, _ := procNtResumeProcess.Call(uintptr(hProcess))
+	if status != 0 {
+		procCmd.Process.Kill()
+		closeJob(runtimeData)
+		return os.NewSyscallError("NtResumeProcess", syscall.Errno(status))
+	}
+	return nil
+}
+
+// setShellCommandLine passes the Command of a task to cmd.exe as it is written. Quoting it
+// like a normal argument would break pipelines and redirections.
+//------------------------------------------------------------------------------
+func setShellCommandLine(runtimeData *GPCProcRuntimeData) {
+	if !runtimeData.procConfig.IsShellCommand() || runtimeData.procConfig.ShellName() != gpcconfig.ShellCmd {
+		return
+	}
+	runtimeData.procCmd.SysProcAttr.CmdLine = fmt.Sprintf("%s /S /C \"%s\"",
+		syscall.EscapeArg(runtimeData.procCmd.Path), runtimeData.procConfig.Command)
+}
+
+// wrapWithCodePage runs the command through cmd.exe, which switches the console code page
+// with chcp first. The raw command line is set, cmd.exe does not follow the usual quoting rules.
+//------------------------------------------------------------------------------
+func wrapWithCodePage(procCmd *exec.Cmd, codePage uint32) {
+	sCommand := procCmd.SysProcAttr.CmdLine
+	if len(sCommand) == 0 {
+		quotedArgs := []string{syscall.EscapeArg(procCmd.Path)}
+		for _, arg := range procCmd.Args[1:] {
+			quotedArgs = append(quotedArgs, syscall.EscapeArg(arg))
+		}
+		sCommand = strings.Join(quotedArgs, " ")
+	}
+	innerCmd := fmt.Sprintf("chcp %d >nul & %s", codePage, sCommand)
+
+	comSpec := os.Getenv("ComSpec")
+	if len(comSpec) == 0 {
+		comSpec = "cmd.exe"
+	}
+	procCmd.Path = comSpec
+	procCmd.Args = []string{comSpec, "/S", "/C", innerCmd}
+	procCmd.SysProcAttr.CmdLine = fmt.Sprintf("%s /S /C \"%s\"", syscall.EscapeArg(comSpec), innerCmd)
+}
+
+// prepareSuspendedStart sets the creation flags of a process: it is started suspended until it
+// is in its job, with the configured priority class and wrapped to switch the code page
+//------------------------------------------------------------------------------
+func prepareSuspendedStart(runtimeData *GPCProcRuntimeData) {
+	procCmd := runtimeData.procCmd
+	if procCmd.SysProcAttr == nil {
+		procCmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	procCmd.SysProcAttr.CreationFlags |= createSuspended
+	setShellCommandLine(runtimeData)
+	if runtimeData.procConfig.CodePage > 0 {
+		wrapWithCodePage(procCmd, runtimeData.procConfig.CodePage)
+	}
+	// Children inherit the priority class
+	if priorityClass, found := priorityClasses[runtimeData.procConfig.Priority]; found {
+		procCmd.SysProcAttr.CreationFlags |= priorityClass
+	}
+}
+
+// assignToJob puts a suspended process into a new job object and applies the CPU rate cap.
+// Failing that the process runs without job, which is only logged.
+//------------------------------------------------------------------------------
+func assignToJob(runtimeData *GPCProcRuntimeData, hProcess syscall.Handle) {
+	hJob, err := createJobObject()
+	if err == nil {
+		r1, _, e1 := procAssignProcessToJobObject.Call(uintptr(hJob), uintptr(hProcess))
+		if r1 == 0 {
+			syscall.CloseHandle(hJob)
+			err = os.NewSyscallError("AssignProcessToJobObject", e1)
+		} else {
+			runtimeData.treeHandle = uintptr(hJob)
+			if rateErr := setJobCPURate(runtimeData); rateErr != nil {
+				gpclogging.Warn("Process <%s> runs without CPU rate cap: <%s>", runtimeData.procConfig.Name, rateErr.Error())
+			}
+		}
+	}
+	if err != nil {
+		gpclogging.Warn("Process <%s> runs without job object, child processes may survive a stop: <%s>",
+			runtimeData.procConfig.Name, err.Error())
+	}
+}
+
+// createJobObject creates an anonymous job object
+//------------------------------------------------------------------------------
+func createJobObject() (syscall.Handle, error) {
+	r1, _, e1 := procCreateJobObjectW.Call(0, 0)
+	if r1 == 0 {
+		return 0, os.NewSyscallError("CreateJobObject", e1)
+	}
+	return syscall.Handle(r1), nil
+}
+
+//setJobCPURate applies the CPU rate cap of a process to its job, if one is configured
+//-------------------------------------------------------------------
+func setJobCPURate(runtimeData *GPCProcRuntimeData) error {
+	ratePercent := runtimeData.procConfig.CPURatePercent
+	if ratePercent == 0 || ratePercent >= 100 {
+		return nil
+	}
+
+	rateInfo := jobObjectCPURateControlInformation{
+		ControlFlags: jobObjectCPURateControlEnable | jobObjectCPURateControlHardCap,
+		CPURate:      ratePercent * 100,
+	}
+	r1, _, e1 := procSetInformationJobObject.Call(runtimeData.treeHandle, jobObjectCPURateControlInfo,
+		uintptr(unsafe.Pointer(&rateInfo)), unsafe.Sizeof(rateInfo))
+	if r1 == 0 {
+		return os.NewSyscallError("SetInformationJobObject", e1)
+	}
+	return nil
+}
+
+//terminateJob kills all processes of the job of a process, i.e. the whole process tree
+//-------------------------------------------------------------------
+func terminateJob(runtimeData *GPCProcRuntimeData) error {
+	if runtimeData.treeHandle == 0 {
+		return nil
+	}
+	r1, _, e1 := procTerminateJobObject.Call(runtimeData.treeHandle, 1)
+	closeJob(runtimeData)
+	if r1 == 0 {
+		return os.NewSyscallError("TerminateJobObject", e1)
+	}
+	return nil
+}
+
+//jobProcessIds lists the PIDs of all processes currently in the job of a process
+//-------------------------------------------------------------------
+func jobProcessIds(runtimeData *GPCProcRuntimeData) ([]int, error) {
+	var idList jobObjectBasicProcessIDList
+	r1, _, e1 := procQueryInformationJobObject.Call(runtimeData.treeHandle, jobObjectBasicProcessIdList,
+		uintptr(unsafe.Pointer(&idList)), unsafe.Sizeof(idList), 0)
+	if r1 == 0 {
+		return nil, os.NewSyscallError("QueryInformationJobObject", e1)
+	}
+
+	pids := make([]int, 0, idList.NumberOfProcessIdsInList)
+	for i := uint32(0); i < idList.NumberOfProcessIdsInList; i++ {
+		pids = append(pids, int(idList.ProcessIdList[i]))
+	}
+	return pids, nil
+}
+
+//processUsage returns the CPU time, the working set in bytes and the handle count of a process
+//-------------------------------------------------------------------
+func processUsage(pid int) (treeUsage, error) {
+	var usage treeUsage
+	hProcess, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return usage, os.NewSyscallError("OpenProcess", err)
+	}
+	defer syscall.CloseHandle(hProcess)
+
+	var counters processMemoryCounters
+	counters.Cb = uint32(unsafe.Sizeof(counters))
+	r1, _, e1 := procK32GetProcessMemoryInfo.Call(uintptr(hProcess), uintptr(unsafe.Pointer(&counters)), uintptr(counters.Cb))
+	if r1 == 0 {
+		return usage, os.NewSyscallError("GetProcessMemoryInfo", e1)
+	}
+	usage.memoryBytes = uint64(counters.WorkingSetSize)
+
+	// Kernel and user time are in 100ns units
+	var creationTime, exitTime, kernelTime, userTime syscall.Filetime
+	if err := syscall.GetProcessTimes(hProcess, &creationTime, &exitTime, &kernelTime, &userTime); err == nil {
+		ticks := int64(kernelTime.HighDateTime)<<32 + int64(kernelTime.LowDateTime) +
+			int64(userTime.HighDateTime)<<32 + int64(userTime.LowDateTime)
+		usage.cpuTime = time.Duration(ticks * 100)
+	}
+
+	var handleCount uint32
+	if r1, _, _ := procGetProcessHandleCount.Call(uintptr(hProcess), uintptr(unsafe.Pointer(&handleCount))); r1 != 0 {
+		usage.handles = int(handleCount)
+	}
+	return usage, nil
+}
+
+//closeJob releases the job handle of a process without terminating it
+//-------------------------------------------------------------------
+func closeJob(runtimeData *GPCProcRuntimeData) {
+	if runtimeData.treeHandle != 0 {
+		syscall.CloseHandle(syscall.Handle(runtimeData.treeHandle))
+		runtimeData.treeHandle = 0
+	}
+}
@@ -0,0 +1,153 @@
+package gpcprocessmgr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"gpcconfig"
+	"gpclogging"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+)
+
+// consts
+const (
+	maxLaunchHistory = 10             // launch contexts kept in memory per process
+	launchLogFile    = "launches.log" // all launch contexts, one JSON object per line, rotated like the controller log
+	redactedValue    = "***"
+)
+
+// LaunchContext describes exactly how a process was launched
+type LaunchContext struct {
+	Task       string    // name of the task
+	Time       time.Time // time of the launch
+	PID        int       // process id
+	Path       string    // resolved path of the executable
+	Args       []string  // full argument vector, secrets are redacted
+	Env        []string  // environment as KEY=VALUE, values of secrets are redacted
+	WorkDir    string    // working directory
+	User       string    // user account the process runs as
+	ConfigHash string    // SHA256 of the task definition
+}
+
+//GetLaunchHistory returns the contexts of the latest launches of a process, oldest first
+//#########################################################
+func GetLaunchHistory(procName string) ([]LaunchContext, error) {
+	runtimeData, err := getRuntimeData(procName)
+	if err != nil {
+		return nil, err
+	}
+
+	runtimeData.historyMux.Lock()
+	defer runtimeData.historyMux.Unlock()
+	return append([]LaunchContext{}, runtimeData.launchHistory...), nil
+}
+
+// recordLaunchContext keeps the launch context of a just started process and appends it to the launch log
+//------------------------------------------------------------------------------
+func recordLaunchContext(runtimeData *GPCProcRuntimeData) {
+	procCmd := runtimeData.procCmd
+
+	launchCtx := LaunchContext{
+		Task:       runtimeData.procConfig.Name,
+		Time:       time.Now(),
+		Path:       procCmd.Path,
+		WorkDir:    procCmd.Dir,
+		ConfigHash: configHash(runtimeData),
+	}
+	if procCmd.Process != nil {
+		launchCtx.PID = procCmd.Process.Pid
+	}
+	for _, arg := range procCmd.Args {
+		launchCtx.Args = append(launchCtx.Args, redactArg(arg))
+	}
+
+	env := procCmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	for _, entry := range env {
+		launchCtx.Env = append(launchCtx.Env, redactEnvEntry(entry))
+	}
+
+	if len(launchCtx.WorkDir) == 0 {
+		launchCtx.WorkDir, _ = os.Getwd()
+	}
+	if len(runtimeData.procConfig.RunAsUser) > 0 {
+		launchCtx.User = runtimeData.procConfig.RunAsUser
+	} else if currentUser, err := user.Current(); err == nil {
+		launchCtx.User = currentUser.Username
+	}
+
+	runtimeData.historyMux.Lock()
+	runtimeData.launchHistory = append(runtimeData.launchHistory, launchCtx)
+	if len(runtimeData.launchHistory) > maxLaunchHistory {
+		runtimeData.launchHistory = runtimeData.launchHistory[1:]
+	}
+	runtimeData.historyMux.Unlock()
+
+	appendLaunchLog(&launchCtx)
+}
+
+// appendLaunchLog writes a launch context as JSON line to the launch log in the log folder
+//------------------------------------------------------------------------------
+func appendLaunchLog(launchCtx *LaunchContext) {
+	encoded, err := json.Marshal(launchCtx)
+	if err != nil {
+		gpclogging.Error("Could not encode launch context of <%s>: <%s>", launchCtx.Task, err.Error())
+		return
+	}
+
+	if err := gpclogging.AppendToLogFile(launchLogFile, append(encoded, '\n')); err != nil {
+		gpclogging.Error("Could not write launch log: <%s>", err.Error())
+	}
+}
+
+// configHash returns the SHA256 of the task definition as hex string
+//------------------------------------------------------------------------------
+func configHash(runtimeData *GPCProcRuntimeData) string {
+	encoded, err := json.Marshal(runtimeData.procConfig)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// redactEnvEntry hides the value of KEY=VALUE if the key looks like a secret, and any
+// decrypted secret of the configuration within it
+//------------------------------------------------------------------------------
+func redactEnvEntry(entry string) string {
+	key, _, found := strings.Cut(entry, "=")
+	if !found {
+		return gpcconfig.RedactSecrets(entry, redactedValue)
+	}
+	if isSecretKey(key) {
+		return key + "=" + redactedValue
+	}
+	return key + "=" + gpcconfig.RedactSecrets(strings.TrimPrefix(entry, key+"="), redactedValue)
+}
+
+// redactArg hides the decrypted secrets of the configuration in an argument, and the value
+// of an option like --password=VALUE
+//------------------------------------------------------------------------------
+func redactArg(arg string) string {
+	if option, _, found := strings.Cut(arg, "="); found && strings.HasPrefix(option, "-") && isSecretKey(strings.TrimLeft(option, "-/")) {
+		return option + "=" + redactedValue
+	}
+	return gpcconfig.RedactSecrets(arg, redactedValue)
+}
+
+// isSecretKey checks if the name of a variable or option looks like it holds a secret
+//------------------------------------------------------------------------------
+func isSecretKey(key string) bool {
+	upperKey := strings.ToUpper(key)
+	for _, marker := range []string{"PASSWORD", "PASSWD", "PWD", "SECRET", "TOKEN", "CREDENTIAL", "APIKEY", "API_KEY", "PRIVATE"} {
+		if strings.Contains(upperKey, marker) && upperKey != "PWD" && upperKey != "OLDPWD" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package gpcprocessmgr
+
+import (
+	"gpcconfig"
+	"gpclogging"
+	"net"
+	"strings"
+)
+
+// Socket activation: the controller listens on the ListenSockets of a task and the process
+// inherits the listening sockets. They are opened on the first launch and kept until the task
+// is stopped on request, removed by a reload or the controller shuts down, so connections
+// arriving while the process restarts wait in the backlog instead of being refused, and during
+// a start-first restart both runs accept them.
+
+// openListeners opens the ListenSockets of a process, unless they are open already. The caller
+// must hold the lock of the process.
+//------------------------------------------------------------------------------
+func openListeners(runtimeData *GPCProcRuntimeData) error {
+	if len(runtimeData.listeners) > 0 {
+		return nil
+	}
+	procName := runtimeData.procConfig.Name
+	listeners := make([]net.Listener, 0, len(runtimeData.procConfig.ListenSockets))
+	for _, sSocket := range runtimeData.procConfig.ListenSockets {
+		sNetwork, sAddress, err := gpcconfig.ListenNetwork(sSocket)
+		var listener net.Listener
+		if err == nil {
+			listener, err = net.Listen(sNetwork, sAddress)
+		}
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return err
+		}
+		gpclogging.Task(procName).Info("Listening on <%s> for process <%s>.", sSocket, procName)
+		listeners = append(listeners, listener)
+	}
+	runtimeData.listeners = listeners
+
+	// The processes keep their own copies of the sockets until they exit
+	ctx := shutdownContext()
+	go func() {
+		<-ctx.Done()
+		runtimeData.mux.Lock()
+		runtimeData.closeListeners()
+		runtimeData.mux.Unlock()
+	}()
+	return nil
+}
+
+// closeListeners closes the listening sockets of a process, if it has any. The caller must hold
+// the lock of the process.
+//------------------------------------------------------------------------------
+func (r *GPCProcRuntimeData) closeListeners() {
+	for _, listenFile := range r.listenFiles {
+		listenFile.Close()
+	}
+	for _, listener := range r.listeners {
+		listener.Close()
+	}
+	r.listenFiles = nil
+	r.listeners = nil
+}
+
+// inheritListeners opens the ListenSockets of a process and passes them to the command about to
+// be started, see passListeners. The caller must hold the lock of the process.
+//------------------------------------------------------------------------------
+func inheritListeners(runtimeData *GPCProcRuntimeData) error {
+	if len(runtimeData.procConfig.ListenSockets) == 0 {
+		return nil
+	}
+	if err := openListeners(runtimeData); err != nil {
+		return err
+	}
+	listenEnv, err := passListeners(runtimeData)
+	if err != nil {
+		return err
+	}
+
+	sAddresses := make([]string, len(runtimeData.listeners))
+	for i, listener := range runtimeData.listeners {
+		sAddresses[i] = listener.Addr().String()
+	}
+	listenEnv["GPC_LISTEN_ADDRS"] = strings.Join(sAddresses, ",")
+	appendEnv(runtimeData.procCmd, listenEnv)
+	return nil
+}
@@ -0,0 +1,41 @@
+//go:build !windows
+// +build !windows
+
+package gpcprocessmgr
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// passListeners hands the listening sockets to the command like systemd does: as file
+// descriptors 3 and up, counted by LISTEN_FDS and named by LISTEN_FDNAMES (the task name).
+// LISTEN_PID can not be set, the PID is not known before the start.
+//------------------------------------------------------------------------------
+func passListeners(runtimeData *GPCProcRuntimeData) (map[string]string, error) {
+	if len(runtimeData.listenFiles) == 0 {
+		for _, listener := range runtimeData.listeners {
+			filer, ok := listener.(interface{ File() (*os.File, error) })
+			if !ok {
+				return nil, fmt.Errorf("listener on <%s> has no file descriptor", listener.Addr().String())
+			}
+			listenFile, err := filer.File()
+			if err != nil {
+				return nil, err
+			}
+			runtimeData.listenFiles = append(runtimeData.listenFiles, listenFile)
+		}
+	}
+	runtimeData.procCmd.ExtraFiles = runtimeData.listenFiles
+
+	sNames := make([]string, len(runtimeData.listenFiles))
+	for i := range sNames {
+		sNames[i] = runtimeData.procConfig.Name
+	}
+	return map[string]string{
+		"LISTEN_FDS":     strconv.Itoa(len(runtimeData.listenFiles)),
+		"LISTEN_FDNAMES": strings.Join(sNames, ":"),
+	}, nil
+}
@@ -0,0 +1,49 @@
+package gpcprocessmgr
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// passListeners lets the command inherit the socket handles of the listeners, their values are
+// passed in GPC_LISTEN_HANDLES separated by commas
+//------------------------------------------------------------------------------
+func passListeners(runtimeData *GPCProcRuntimeData) (map[string]string, error) {
+	handles := make([]syscall.Handle, 0, len(runtimeData.listeners))
+	for _, listener := range runtimeData.listeners {
+		conner, ok := listener.(syscall.Conn)
+		if !ok {
+			return nil, fmt.Errorf("listener on <%s> has no socket handle", listener.Addr().String())
+		}
+		rawConn, err := conner.SyscallConn()
+		if err != nil {
+			return nil, err
+		}
+		var errInherit error
+		if err := rawConn.Control(func(fd uintptr) {
+			// Sockets are created not inheritable
+			errInherit = syscall.SetHandleInformation(syscall.Handle(fd), syscall.HANDLE_FLAG_INHERIT, syscall.HANDLE_FLAG_INHERIT)
+			handles = append(handles, syscall.Handle(fd))
+		}); err != nil {
+			return nil, err
+		}
+		if errInherit != nil {
+			return nil, os.NewSyscallError("SetHandleInformation", errInherit)
+		}
+	}
+
+	procCmd := runtimeData.procCmd
+	if procCmd.SysProcAttr == nil {
+		procCmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	procCmd.SysProcAttr.AdditionalInheritedHandles = append(procCmd.SysProcAttr.AdditionalInheritedHandles, handles...)
+
+	sHandles := make([]string, len(handles))
+	for i, handle := range handles {
+		sHandles[i] = strconv.FormatUint(uint64(handle), 10)
+	}
+	return map[string]string{"GPC_LISTEN_HANDLES": strings.Join(sHandles, ",")}, nil
+}
@@ -0,0 +1,172 @@
+package gpcprocessmgr
+
+import (
+	"gpclogging"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MaintenanceWindow is a maintenance of the controller or of a single process, see SetMaintenance
+type MaintenanceWindow struct {
+	Process string    // name of the process, empty for the whole controller
+	Since   time.Time // when the maintenance was set
+	Until   time.Time // when it ends by itself, zero if it lasts until it is ended
+}
+
+var (
+	// maintenances by process name, "" is the one of the controller
+	gMaintenances   = make(map[string]MaintenanceWindow)
+	gMaintenanceMux sync.Mutex // never held while taking the lock of a process
+)
+
+//SetMaintenance puts a process, or with an empty name the whole controller, into maintenance for
+//the given duration, zero => until EndMaintenance. Processes that exit meanwhile are not restarted
+//(the exits are logged and published as usual), nor are running ones restarted by RestartEvery,
+//MaxMemoryRestartMB or WatchPaths. Once the maintenance ends, the processes held back are
+//restarted. Setting it again replaces the duration.
+//#########################################################
+func SetMaintenance(procName string, duration time.Duration) error {
+	if len(procName) > 0 {
+		if _, err := getRuntimeData(procName); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	window := MaintenanceWindow{Process: procName, Since: now}
+	if duration > 0 {
+		window.Until = now.Add(duration)
+	}
+
+	gMaintenanceMux.Lock()
+	if previous, found := gMaintenances[procName]; found {
+		window.Since = previous.Since
+	}
+	gMaintenances[procName] = window
+	gMaintenanceMux.Unlock()
+
+	sUntil := "turned off"
+	if !window.Until.IsZero() {
+		sUntil = window.Until.Format(time.RFC3339)
+	}
+	if len(procName) == 0 {
+		gpclogging.Warn("Controller is in MAINTENANCE until <%s>, processes that exit are not restarted.", sUntil)
+	} else {
+		gpclogging.Task(procName).Warn("Process <%s> is in MAINTENANCE until <%s>, it is not restarted if it exits.", procName, sUntil)
+	}
+	publish(Event{Type: EventMaintenanceStarted, Process: procName, Message: "until " + sUntil})
+	return nil
+}
+
+//EndMaintenance ends the maintenance of a process, or with an empty name the one of the controller,
+//and restarts the processes that exited meanwhile and are not in maintenance any more
+//#########################################################
+func EndMaintenance(procName string) error {
+	gMaintenanceMux.Lock()
+	_, found := gMaintenances[procName]
+	delete(gMaintenances, procName)
+	gMaintenanceMux.Unlock()
+	if !found {
+		if len(procName) > 0 {
+			if _, err := getRuntimeData(procName); err != nil {
+				return err
+			}
+		}
+		if len(procName) == 0 {
+			return newProcessError("controller", ErrNotInMaintenance, nil)
+		}
+		return newProcessError(procName, ErrNotInMaintenance, nil)
+	}
+
+	maintenanceEnded(procName, "ended on request")
+	releaseHeldProcesses()
+	return nil
+}
+
+//GetMaintenance returns the current maintenances, the one of the controller first, then by process name
+//#########################################################
+func GetMaintenance() []MaintenanceWindow {
+	gMaintenanceMux.Lock()
+	defer gMaintenanceMux.Unlock()
+
+	windows := make([]MaintenanceWindow, 0, len(gMaintenances))
+	for _, window := range gMaintenances {
+		windows = append(windows, window)
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].Process < windows[j].Process })
+	return windows
+}
+
+// inMaintenance reports whether a process or the whole controller is in maintenance. Expired
+// maintenances count until checkMaintenance has ended them.
+//------------------------------------------------------------------------------
+func inMaintenance(procName string) bool {
+	gMaintenanceMux.Lock()
+	defer gMaintenanceMux.Unlock()
+	_, bController := gMaintenances[""]
+	_, bProcess := gMaintenances[procName]
+	return bController || bProcess
+}
+
+// checkMaintenance ends the maintenances whose duration is over and restarts the processes held
+// back by them. It is called from the monitor loop.
+//------------------------------------------------------------------------------
+func checkMaintenance() {
+	now := time.Now()
+	var expired []string
+	gMaintenanceMux.Lock()
+	for procName, window := range gMaintenances {
+		if !window.Until.IsZero() && !now.Before(window.Until) {
+			expired = append(expired, procName)
+			delete(gMaintenances, procName)
+		}
+	}
+	gMaintenanceMux.Unlock()
+	if len(expired) == 0 {
+		return
+	}
+
+	for _, procName := range expired {
+		maintenanceEnded(procName, "expired")
+	}
+	releaseHeldProcesses()
+}
+
+// maintenanceEnded logs and publishes the end of a maintenance
+//------------------------------------------------------------------------------
+func maintenanceEnded(procName string, sReason string) {
+	if len(procName) == 0 {
+		gpclogging.Warn("Maintenance of the controller %s.", sReason)
+	} else {
+		gpclogging.Task(procName).Warn("Maintenance of process <%s> %s.", procName, sReason)
+	}
+	publish(Event{Type: EventMaintenanceEnded, Process: procName, Message: sReason})
+}
+
+// holdForMaintenance keeps a process that exited during a maintenance from being restarted until
+// the maintenance ends
+//------------------------------------------------------------------------------
+func holdForMaintenance(procName string, runtimeData *GPCProcRuntimeData) {
+	gpclogging.Task(procName).Warn("Process <%s> exited during MAINTENANCE, it is restarted once the maintenance ends.", procName)
+	runtimeData.mux.Lock()
+	runtimeData.procStatus.heldForMaintenance = true
+	runtimeData.mux.Unlock()
+}
+
+// releaseHeldProcesses restarts the processes held back by a maintenance that is over for them.
+// Processes started, stopped or removed meanwhile are left as they are.
+//------------------------------------------------------------------------------
+func releaseHeldProcesses() {
+	for procName, runtimeData := range currentRuntimeData() {
+		if inMaintenance(procName) {
+			continue
+		}
+		runtimeData.mux.Lock()
+		bRestart := runtimeData.procStatus.heldForMaintenance && !runtimeData.procStatus.active && !runtimeData.procStatus.stopped
+		runtimeData.procStatus.heldForMaintenance = false
+		runtimeData.mux.Unlock()
+		if bRestart {
+			restartExited(procName, runtimeData)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package gpcprocessmgr
+
+import (
+	"fmt"
+	"gpclogging"
+	"time"
+)
+
+// How often the monitor looks for processes running longer than their MaxRuntime
+const maxRuntimeCheckInterval = time.Second
+
+// time of the last check of the run times, only used by the monitor
+var gLastMaxRuntimeCheck time.Time
+
+// checkMaxRuntimes terminates the no-wait processes that run longer than their MaxRuntime, like
+// a stop on request. They are not restarted and flagged as timed out, with MaxRuntimeIsError they
+// fail and their standbys take over. It is called from the monitor loop.
+//------------------------------------------------------------------------------
+func checkMaxRuntimes() {
+	if time.Since(gLastMaxRuntimeCheck) < maxRuntimeCheckInterval {
+		return
+	}
+	gLastMaxRuntimeCheck = time.Now()
+
+	limited := runningProcesses(func(runtimeData *GPCProcRuntimeData) bool {
+		return runtimeData.procConfig.MaxRuntime.Duration > 0 && runtimeData.procConfig.WaitForExitTimeout.Duration == 0
+	})
+	now := gClock.Now()
+	for procName, runtimeData := range limited {
+		maxRuntime := runtimeData.procConfig.MaxRuntime.Duration
+		runtimeData.mux.Lock()
+		procStatus := &runtimeData.procStatus
+		runtime := now.Sub(procStatus.startTime)
+		bExceeded := procStatus.active && !procStatus.timeout && !procStatus.startTime.IsZero() && runtime >= maxRuntime
+		if bExceeded {
+			// stopProcess publishes the exit with this state
+			procStatus.timeout = true
+			if runtimeData.procConfig.MaxRuntimeIsError {
+				procStatus.lastError = newProcessError(procName, ErrMaxRuntime, fmt.Errorf("ran longer than %s", maxRuntime))
+			}
+		}
+		runtimeData.mux.Unlock()
+		if !bExceeded {
+			continue
+		}
+
+		gpclogging.Task(procName).Warn("Process <%s>, PID=<%d> runs for <%s>, longer than its MaxRuntime of <%s>. Terminating it, it is not restarted.",
+			procName, runtimeData.procStatus.pid, runtime.Round(time.Second).String(), maxRuntime.String())
+		gShutdownWaitGroup.Add(1)
+		go func(procName string, runtimeData *GPCProcRuntimeData) {
+			defer gShutdownWaitGroup.Done()
+			stopProcess(shutdownContext(), procName, runtimeData)
+			// Not stopped on request, the state tells the timeout
+			runtimeData.mux.Lock()
+			runtimeData.procStatus.stopped = false
+			runtimeData.mux.Unlock()
+			if runtimeData.procConfig.MaxRuntimeIsError {
+				promoteStandbys(runtimeData)
+			}
+		}(procName, runtimeData)
+	}
+}
@@ -0,0 +1,95 @@
+package gpcprocessmgr
+
+import (
+	"gpcconfig"
+	"gpclogging"
+	"time"
+)
+
+// consts
+const (
+	// How often the memory of the process trees is sampled
+	memoryCheckInterval = 2 * time.Second
+
+	// consecutive samples above MaxMemoryRestartMB without MaxMemoryRestartSamples
+	defMaxMemoryRestartSamples = 3
+)
+
+// time of the last memory sample, only used by the monitor
+var gLastMemoryCheck time.Time
+
+// checkMemoryLimits samples the memory of all running processes with a memory limit and
+// applies the configured action to those above. It is called from the monitor loop.
+//------------------------------------------------------------------------------
+func checkMemoryLimits() {
+	if time.Since(gLastMemoryCheck) < memoryCheckInterval {
+		return
+	}
+	gLastMemoryCheck = time.Now()
+
+	limited := runningProcesses(func(runtimeData *GPCProcRuntimeData) bool { return runtimeData.procConfig.MemoryLimitMB > 0 })
+	usages, err := sampleTrees(limited)
+	if err != nil {
+		return
+	}
+	for procName, runtimeData := range limited {
+		limitMB := runtimeData.procConfig.MemoryLimitMB
+		usage, found := usages[runtimeData]
+		if !found {
+			continue
+		}
+		usageMB := usage.memoryBytes / (1024 * 1024)
+		runtimeData.mux.Lock()
+		bReported := runtimeData.procStatus.overMemory
+		runtimeData.procStatus.overMemory = usageMB >= uint64(limitMB)
+		if !runtimeData.procStatus.overMemory || bReported {
+			runtimeData.mux.Unlock()
+			continue
+		}
+
+		switch runtimeData.procConfig.MemoryLimitAction {
+		case gpcconfig.MemoryActionLog:
+			gpclogging.Task(procName).Warn("Process <%s> uses <%d> MB, above its memory limit of <%d> MB.", procName, usageMB, limitMB)
+		case gpcconfig.MemoryActionAlert:
+			gpclogging.Task(procName).Error("ALERT: Process <%s> uses <%d> MB, above its memory limit of <%d> MB.", procName, usageMB, limitMB)
+		default:
+			// The monitor sees the process exit and restarts it like a crashed one. Killed in background,
+			// the monitor must not wait for the grace period.
+			gpclogging.Task(procName).Error("Process <%s> uses <%d> MB, above its memory limit of <%d> MB. Killing it.", procName, usageMB, limitMB)
+			killRunInBackground(procName, runtimeData)
+		}
+		runtimeData.mux.Unlock()
+	}
+}
+
+// checkMemoryRestart restarts a process gracefully, like a manual restart, once its latest resource
+// samples were above its MaxMemoryRestartMB MaxMemoryRestartSamples times in a row. Unlike a crash
+// the restart does not count against MaxRestarts. It is called by the resource sampler.
+//------------------------------------------------------------------------------
+func checkMemoryRestart(procName string, runtimeData *GPCProcRuntimeData) {
+	thresholdMB := runtimeData.procConfig.MaxMemoryRestartMB
+	// A suspended process is restarted once it runs again and still holds the memory
+	if thresholdMB == 0 || runtimeData.procConfig.WaitForExitTimeout.Duration > 0 || runtimeData.procStatus.suspended {
+		return
+	}
+	usageMB := runtimeData.procStatus.usage.MemoryBytes / (1024 * 1024)
+	if usageMB < uint64(thresholdMB) {
+		runtimeData.procStatus.overMemoryRestartSamples = 0
+		return
+	}
+	runtimeData.procStatus.overMemoryRestartSamples++
+	samples := runtimeData.procConfig.MaxMemoryRestartSamples
+	if samples == 0 {
+		samples = defMaxMemoryRestartSamples
+	}
+	// In maintenance it is restarted once the maintenance ends
+	if runtimeData.procStatus.overMemoryRestartSamples < samples || inMaintenance(procName) {
+		return
+	}
+	runtimeData.procStatus.overMemoryRestartSamples = 0
+
+	gpclogging.Task(procName).Warn("Process <%s> used more than <%d> MB in <%d> samples in a row (now <%d> MB). Restarting it.",
+		procName, thresholdMB, samples, usageMB)
+	// stopProcess marks the process inactive at once, so it is not sampled again until it runs again
+	restartGracefully(procName, runtimeData, "memory above MaxMemoryRestartMB")
+}
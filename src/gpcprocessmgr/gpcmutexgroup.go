@@ -0,0 +1,91 @@
+package gpcprocessmgr
+
+import (
+	"gpcconfig"
+	"gpclogging"
+	"sync"
+)
+
+//#######################################################
+//### GLOBAL VARIABLES, INIT, CONSTS
+//#######################################################
+
+// One token channel per group, holding a token means running. The lock also guards
+// procStatus.holdsGroup, which is set with and without the lock of the process.
+var gMutexGroups = make(map[string]chan struct{})
+var gMutexGroupsMux sync.Mutex
+
+// mutexGroupChannel returns the token channel of a group, creating it if needed
+//------------------------------------------------------------------------------
+func mutexGroupChannel(groupName string) chan struct{} {
+	gMutexGroupsMux.Lock()
+	defer gMutexGroupsMux.Unlock()
+
+	groupChan, found := gMutexGroups[groupName]
+	if !found {
+		groupChan = make(chan struct{}, 1)
+		gMutexGroups[groupName] = groupChan
+	}
+	return groupChan
+}
+
+// acquireMutexGroup blocks until the process may run with respect to its mutex group.
+// Returns false if the process must not be launched (group busy with skip policy, or shutdown).
+//------------------------------------------------------------------------------
+func acquireMutexGroup(runtimeData *GPCProcRuntimeData) bool {
+	groupName := runtimeData.procConfig.MutexGroup
+	if len(groupName) == 0 {
+		return true
+	}
+	groupChan := mutexGroupChannel(groupName)
+
+	if runtimeData.procConfig.MutexPolicy == gpcconfig.MutexSkip {
+		select {
+		case groupChan <- struct{}{}:
+			setHoldsGroup(runtimeData)
+			return true
+		default:
+			gpclogging.Warn("Process <%s> is skipped, another process of mutex group <%s> is running.",
+				runtimeData.procConfig.Name, groupName)
+			return false
+		}
+	}
+
+	gpclogging.Debug("Process <%s> is waiting for mutex group <%s>.", runtimeData.procConfig.Name, groupName)
+	select {
+	case groupChan <- struct{}{}:
+		setHoldsGroup(runtimeData)
+		gpclogging.Debug("Process <%s> acquired mutex group <%s>.", runtimeData.procConfig.Name, groupName)
+		return true
+	case <-shutdownContext().Done():
+		// Give up, the controller is shutting down
+		return false
+	}
+}
+
+// setHoldsGroup records that the process got the token of its mutex group
+//------------------------------------------------------------------------------
+func setHoldsGroup(runtimeData *GPCProcRuntimeData) {
+	gMutexGroupsMux.Lock()
+	defer gMutexGroupsMux.Unlock()
+	runtimeData.procStatus.holdsGroup = true
+}
+
+// releaseMutexGroup frees the mutex group of the process, if it holds it. Checking and
+// clearing holdsGroup in one step makes sure the token is given back only once.
+//------------------------------------------------------------------------------
+func releaseMutexGroup(runtimeData *GPCProcRuntimeData) {
+	gMutexGroupsMux.Lock()
+	defer gMutexGroupsMux.Unlock()
+
+	if !runtimeData.procStatus.holdsGroup {
+		return
+	}
+	runtimeData.procStatus.holdsGroup = false
+	// the token of the process is in the channel, never block under the lock anyway
+	select {
+	case <-gMutexGroups[runtimeData.procConfig.MutexGroup]:
+	default:
+	}
+	gpclogging.Debug("Process <%s> released mutex group <%s>.", runtimeData.procConfig.Name, runtimeData.procConfig.MutexGroup)
+}
@@ -0,0 +1,208 @@
+package gpcprocessmgr
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OutputSink receives each line of output of the processes, e.g. to forward it to central logging.
+// Output is called from the goroutines copying the output and must not block.
+type OutputSink interface {
+	Output(procName string, sStream string, line string)
+}
+
+var (
+	gOutputSinks   []OutputSink
+	gOutputSinkMux sync.Mutex
+)
+
+// outputCapture copies the output of a process through pipes into its logs line by line, so
+// that the logs can be rotated and the lines prefixed with the time they were read (and
+// optionally the task name)
+type outputCapture struct {
+	procName    string
+	recent      *outputRing   // gets each line too, nil if the process keeps no recent output
+	activity    *atomic.Int64 // set to the time of each line, see GPCProcRuntimeData.lastActivity
+	sPrefix     string        // put in front of each line after the time
+	bTimestamp  bool          // lines are prefixed, false => copied as they are
+	pipeWriters []*os.File    // ends the process writes to, closed in the controller once it started
+	pipeReaders []*os.File
+	copying     sync.WaitGroup
+	closeMux    sync.Mutex
+}
+
+// consts
+const (
+	// time of the line prefix, sortable across the logs of all tasks
+	outputTimeFormat = "2006-01-02 15:04:05.000"
+
+	// how long closing the logs waits for output still in the pipes, e.g. of children that outlive the process
+	outputDrainTimeout = 2 * time.Second
+)
+
+//AddOutputSink adds a sink the output lines of the processes are passed to. Only processes
+//launched while a sink is set pass their output to it.
+//#########################################################
+func AddOutputSink(sink OutputSink) {
+	gOutputSinkMux.Lock()
+	defer gOutputSinkMux.Unlock()
+	gOutputSinks = append(gOutputSinks, sink)
+}
+
+//RemoveOutputSink removes a sink added by AddOutputSink
+//#########################################################
+func RemoveOutputSink(sink OutputSink) {
+	gOutputSinkMux.Lock()
+	defer gOutputSinkMux.Unlock()
+	for sinkIndex, existingSink := range gOutputSinks {
+		if existingSink == sink {
+			gOutputSinks = append(gOutputSinks[:sinkIndex:sinkIndex], gOutputSinks[sinkIndex+1:]...)
+			return
+		}
+	}
+}
+
+// outputSinks returns the sinks of the output lines
+//------------------------------------------------------------------------------
+func outputSinks() []OutputSink {
+	gOutputSinkMux.Lock()
+	defer gOutputSinkMux.Unlock()
+	return gOutputSinks
+}
+
+// captureOutput puts pipes between a process and its logs when its output is timestamped,
+// rotated, kept in memory, passed to the output sink or watched by HungTimeout. Stdout and stderr share a pipe if they
+// share a log, as they share the file otherwise.
+//------------------------------------------------------------------------------
+func captureOutput(proc *GPCProcRuntimeData) error {
+	proc.capture = nil
+	proc.recentOutput.setLimits(proc.procConfig.RecentOutputLines, proc.procConfig.RecentOutputKB)
+	if proc.procLog == nil || (!proc.procConfig.TimestampOutput && proc.procConfig.OutputMaxSizeMB == 0 &&
+		!proc.recentOutput.enabled() && len(outputSinks()) == 0 && proc.procConfig.HungTimeout.Duration == 0) {
+		return nil
+	}
+
+	capture := &outputCapture{procName: proc.procConfig.Name, bTimestamp: proc.procConfig.TimestampOutput, activity: &proc.lastActivity}
+	if proc.recentOutput.enabled() {
+		capture.recent = proc.recentOutput
+	}
+	if proc.procConfig.TaskNameInOutput {
+		capture.sPrefix = "[" + proc.procConfig.Name + "] "
+	}
+	sOutStream := "output"
+	if proc.procErrLog != nil {
+		sOutStream = "stdout"
+	}
+	outWriter, err := capture.pipe(proc.procLog, sOutStream)
+	if err != nil {
+		capture.close()
+		return err
+	}
+	errWriter := outWriter
+	if proc.procErrLog != nil {
+		if errWriter, err = capture.pipe(proc.procErrLog, "stderr"); err != nil {
+			capture.close()
+			return err
+		}
+	}
+
+	proc.procCmd.Stdout, proc.procCmd.Stderr = outWriter, errWriter
+	proc.capture = capture
+	return nil
+}
+
+// pipe creates a pipe whose lines are copied to a log in background, returns the end to write to.
+// sStream names the output in the output sink: stdout, stderr, or output for both.
+//------------------------------------------------------------------------------
+func (c *outputCapture) pipe(logFile io.Writer, sStream string) (*os.File, error) {
+	pipeReader, pipeWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	c.pipeReaders = append(c.pipeReaders, pipeReader)
+	c.pipeWriters = append(c.pipeWriters, pipeWriter)
+
+	c.copying.Add(1)
+	go func() {
+		defer c.copying.Done()
+		c.copyLines(pipeReader, logFile, sStream)
+	}()
+	return pipeWriter, nil
+}
+
+// copyLines copies lines until the end of the input, with the time and the prefix in front.
+// A last line without line break gets one. The sink and the recent output get the lines as they are.
+//------------------------------------------------------------------------------
+func (c *outputCapture) copyLines(input io.Reader, output io.Writer, sStream string) {
+	reader := bufio.NewReader(input)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			c.activity.Store(time.Now().UnixNano())
+			if line[len(line)-1] != '\n' {
+				line = append(line, '\n')
+			}
+			sinks := outputSinks()
+			if len(sinks) > 0 || c.recent != nil {
+				sLine := strings.TrimRight(string(line), "\r\n")
+				for _, sink := range sinks {
+					sink.Output(c.procName, sStream, sLine)
+				}
+				if c.recent != nil {
+					c.recent.add(time.Now(), sStream, sLine)
+				}
+			}
+			if c.bTimestamp {
+				line = append([]byte(time.Now().Format(outputTimeFormat)+" "+c.sPrefix), line...)
+			}
+			output.Write(line)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// started closes the pipe ends of the process in the controller. The copying ends once the
+// process and its children closed theirs. Does nothing without capture.
+//------------------------------------------------------------------------------
+func (c *outputCapture) started() {
+	if c == nil {
+		return
+	}
+	c.closeMux.Lock()
+	defer c.closeMux.Unlock()
+
+	for _, pipeWriter := range c.pipeWriters {
+		pipeWriter.Close()
+	}
+	c.pipeWriters = nil
+}
+
+// close waits a moment for the rest of the output and ends the copying, before the log files are closed
+//------------------------------------------------------------------------------
+func (c *outputCapture) close() {
+	c.started()
+
+	copied := make(chan struct{})
+	go func() {
+		c.copying.Wait()
+		close(copied)
+	}()
+	select {
+	case <-copied:
+	case <-time.After(outputDrainTimeout):
+	}
+
+	c.closeMux.Lock()
+	defer c.closeMux.Unlock()
+	for _, pipeReader := range c.pipeReaders {
+		pipeReader.Close()
+	}
+	c.pipeReaders = nil
+}
@@ -0,0 +1,74 @@
+package gpcprocessmgr
+
+import (
+	"gpclogging"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// writePIDFile writes the PID of a just started process to its PID file, if it has one.
+// Adopted processes keep the PID file they came with.
+//------------------------------------------------------------------------------
+func writePIDFile(runtimeData *GPCProcRuntimeData) {
+	sPIDFile := runtimeData.procConfig.PIDFilePath()
+	if len(sPIDFile) == 0 || runtimeData.procStatus.adopted {
+		return
+	}
+
+	// Write a temporary file first, readers never see a half written PID
+	if dir := filepath.Dir(sPIDFile); len(dir) > 0 {
+		os.MkdirAll(dir, 0755)
+	}
+	sTempFile := sPIDFile + ".tmp"
+	err := os.WriteFile(sTempFile, []byte(strconv.Itoa(runtimeData.procStatus.pid)+"\n"), 0644)
+	if err == nil {
+		err = os.Rename(sTempFile, sPIDFile)
+	}
+	if err != nil {
+		gpclogging.Warn("Could not write PID file <%s> of process <%s>: <%s>", sPIDFile, runtimeData.procConfig.Name, err.Error())
+		os.Remove(sTempFile)
+	}
+}
+
+// removePIDFile removes the PID file of an ended process, unless it holds another PID by now
+//------------------------------------------------------------------------------
+func removePIDFile(runtimeData *GPCProcRuntimeData) {
+	sPIDFile := runtimeData.procConfig.PIDFilePath()
+	if len(sPIDFile) == 0 || runtimeData.procStatus.adopted {
+		return
+	}
+
+	pid, err := readPIDFile(sPIDFile)
+	if err != nil || pid != runtimeData.procStatus.pid {
+		return
+	}
+	if err := os.Remove(sPIDFile); err != nil {
+		gpclogging.Warn("Could not remove PID file <%s> of process <%s>: <%s>", sPIDFile, runtimeData.procConfig.Name, err.Error())
+	}
+}
+
+// removeStalePIDFile removes the PID file of a process that is not started yet if the PID
+// in it does not run any more, e.g. after a crash of the controller
+//------------------------------------------------------------------------------
+func removeStalePIDFile(runtimeData *GPCProcRuntimeData) {
+	sPIDFile := runtimeData.procConfig.PIDFilePath()
+	if len(sPIDFile) == 0 {
+		return
+	}
+
+	pid, err := readPIDFile(sPIDFile)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil && processExists(runtimeData, pid) {
+		gpclogging.Task(runtimeData.procConfig.Name).Warn("PID file <%s> of process <%s> belongs to the running PID <%d>.",
+			sPIDFile, runtimeData.procConfig.Name, pid)
+		return
+	}
+
+	gpclogging.Task(runtimeData.procConfig.Name).Info("Removing stale PID file <%s> of process <%s>.", sPIDFile, runtimeData.procConfig.Name)
+	if err := os.Remove(sPIDFile); err != nil {
+		gpclogging.Warn("Could not remove PID file <%s> of process <%s>: <%s>", sPIDFile, runtimeData.procConfig.Name, err.Error())
+	}
+}
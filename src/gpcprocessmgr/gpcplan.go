@@ -0,0 +1,101 @@
+package gpcprocessmgr
+
+import (
+	"fmt"
+	"gpcconfig"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+//StartupPlan describes what StartProcessesFromConfig would do with a configuration, one line
+//per task: the tasks started at startup in the order of their StartDelay, followed by the ones
+//that wait for a schedule, a predecessor or the failure of their primary. Executables are
+//resolved like at launch. Nothing is started.
+//#########################################################
+func StartupPlan(configData *gpcconfig.ConfigData) []string {
+	var startTasks, otherTasks []*gpcconfig.ProcessConfig
+	for taskIndex := range configData.Tasks {
+		task := &configData.Tasks[taskIndex]
+		if configData.IsFollowUpTask(task.Name) || task.IsColdStandby() || len(task.Schedule) > 0 {
+			otherTasks = append(otherTasks, task)
+		} else {
+			startTasks = append(startTasks, task)
+		}
+	}
+	// tasks with the same delay are started at the same time, the name makes the order repeatable
+	sort.SliceStable(startTasks, func(i, j int) bool {
+		if startTasks[i].StartDelay.Duration != startTasks[j].StartDelay.Duration {
+			return startTasks[i].StartDelay.Duration < startTasks[j].StartDelay.Duration
+		}
+		return startTasks[i].Name < startTasks[j].Name
+	})
+	sort.SliceStable(otherTasks, func(i, j int) bool {
+		return otherTasks[i].Name < otherTasks[j].Name
+	})
+
+	var plan []string
+	for stepIndex, task := range startTasks {
+		sWhen := "at startup"
+		if task.StartDelay.Duration > 0 {
+			sWhen = "after " + task.StartDelay.Duration.String()
+		}
+		if len(task.AdoptPIDFile) > 0 || len(task.AdoptExecutable) > 0 {
+			sWhen += ", unless a running instance is adopted"
+		}
+		plan = append(plan, fmt.Sprintf("%3d. %s: %s, %s", stepIndex+1, task.Name, sWhen, planExecutable(task)))
+	}
+	for _, task := range otherTasks {
+		plan = append(plan, fmt.Sprintf("   - %s: %s, %s", task.Name, plannedTrigger(configData, task), planExecutable(task)))
+	}
+	return plan
+}
+
+// plannedTrigger describes what starts a task that is not started at startup
+//------------------------------------------------------------------------------
+func plannedTrigger(configData *gpcconfig.ConfigData, task *gpcconfig.ProcessConfig) string {
+	if task.IsColdStandby() {
+		return fmt.Sprintf("on takeover from <%s>", task.StandbyFor)
+	}
+	if configData.IsFollowUpTask(task.Name) {
+		var predecessors []string
+		for _, predecessor := range configData.Tasks {
+			for _, sFollowUp := range predecessor.OnSuccess {
+				if sFollowUp == task.Name {
+					predecessors = append(predecessors, predecessor.Name+" succeeded")
+				}
+			}
+			for _, sFollowUp := range predecessor.OnFailure {
+				if sFollowUp == task.Name {
+					predecessors = append(predecessors, predecessor.Name+" failed")
+				}
+			}
+		}
+		return "when " + strings.Join(predecessors, " or ")
+	}
+
+	schedule, err := gpcconfig.ParseSchedule(task.Schedule)
+	if err != nil {
+		return fmt.Sprintf("never, schedule <%s> is invalid", task.Schedule)
+	}
+	location, err := task.Location()
+	if err != nil {
+		location = time.Local
+	}
+	nextRun := nextScheduledRun(schedule, location, gClock)
+	if nextRun.IsZero() {
+		return fmt.Sprintf("never, schedule <%s> is never due", task.Schedule)
+	}
+	return fmt.Sprintf("on schedule <%s>, next at %s", task.Schedule, nextRun.Format("2006-01-02 15:04 MST"))
+}
+
+// planExecutable returns the executable of a task as it is resolved at launch
+//------------------------------------------------------------------------------
+func planExecutable(task *gpcconfig.ProcessConfig) string {
+	sPath, err := exec.LookPath(task.Executable())
+	if err != nil {
+		return fmt.Sprintf("runs <%s> (not found)", task.Executable())
+	}
+	return fmt.Sprintf("runs <%s>", sPath)
+}
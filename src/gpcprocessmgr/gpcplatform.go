@@ -0,0 +1,43 @@
+package gpcprocessmgr
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// procPlatform hides the operating system specific parts of starting, watching
+// and killing a process. There is a Windows implementation using job objects and
+// a Unix implementation using process groups and signals.
+type procPlatform interface {
+	// prepare sets the platform attributes of a command before it is started
+	prepare(procCmd *exec.Cmd, hideWindow bool)
+	// start starts the command of the runtime data so its whole process tree can be killed later
+	start(runtimeData *GPCProcRuntimeData) error
+	// isRunning returns nil if the process of the runtime data is still active
+	isRunning(runtimeData *GPCProcRuntimeData) error
+	// killTree asks the process of the runtime data and all its children to exit, and kills what
+	// is left after the grace period. A cancelled context ends the grace period early. Reports
+	// whether anything had to be killed.
+	killTree(ctx context.Context, runtimeData *GPCProcRuntimeData, gracePeriod time.Duration) (bool, error)
+	// treeUsages samples the resources used by the processes of the runtime data and their
+	// children, in one pass over the process table where the platform has one. Processes that
+	// could not be sampled are missing from the result.
+	treeUsages(runtimeDataList []*GPCProcRuntimeData) (map[*GPCProcRuntimeData]treeUsage, error)
+	// closeTree releases the process tree of the runtime data without killing it
+	closeTree(runtimeData *GPCProcRuntimeData)
+	// suspendTree freezes the process of the runtime data and all its children
+	suspendTree(runtimeData *GPCProcRuntimeData) error
+	// resumeTree lets a process tree frozen by suspendTree continue
+	resumeTree(runtimeData *GPCProcRuntimeData) error
+}
+
+// treeUsage is a sample of the resources used by the processes currently in a process tree
+type treeUsage struct {
+	cpuTime     time.Duration // user and kernel time of the processes, since they started
+	memoryBytes uint64        // resident memory (working sets on Windows)
+	handles     int           // open handles on Windows, file descriptors on Linux, zero where unknown
+}
+
+// the implementation for the platform we were built for
+var gPlatform procPlatform = newPlatform()
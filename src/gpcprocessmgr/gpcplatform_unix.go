@@ -0,0 +1,327 @@
+//go:build !windows
+// +build !windows
+
+package gpcprocessmgr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"gpcconfig"
+	"gpclogging"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// nice values of the priority classes
+var niceValues = map[string]int{
+	gpcconfig.PriorityIdle:        19,
+	gpcconfig.PriorityBelowNormal: 10,
+	gpcconfig.PriorityNormal:      0,
+	gpcconfig.PriorityHigh:        -10,
+}
+
+// unixPlatform starts every process as leader of its own process group, so the
+// group can be signalled as a whole. The group ID is kept as tree handle.
+type unixPlatform struct{}
+
+func newPlatform() procPlatform {
+	return unixPlatform{}
+}
+
+func (unixPlatform) prepare(procCmd *exec.Cmd, hideWindow bool) {
+	// There are no windows to hide
+	procCmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func (unixPlatform) start(runtimeData *GPCProcRuntimeData) error {
+	procCmd := runtimeData.procCmd
+	if procCmd.SysProcAttr == nil {
+		procCmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	procCmd.SysProcAttr.Setpgid = true
+
+	if _, err := applyRunAs(runtimeData); err != nil {
+		return err
+	}
+	err := procCmd.Start()
+	if err != nil {
+		return err
+	}
+	runtimeData.treeHandle = uintptr(procCmd.Process.Pid)
+
+	// Children started later inherit the nice value. Raising the priority needs privileges.
+	if niceValue, found := niceValues[runtimeData.procConfig.Priority]; found && niceValue != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PGRP, procCmd.Process.Pid, niceValue); err != nil {
+			gpclogging.Warn("Could not set priority <%s> of process <%s>: <%s>", runtimeData.procConfig.Priority,
+				runtimeData.procConfig.Name, err.Error())
+		}
+	}
+	if runtimeData.procConfig.CodePage > 0 {
+		gpclogging.Debug("Process <%s>: CodePage is only used on Windows, use Locale instead.", runtimeData.procConfig.Name)
+	}
+	if len(runtimeData.procConfig.Desktop) > 0 || len(runtimeData.procConfig.Session) > 0 {
+		gpclogging.Warn("Process <%s>: Desktop and Session are only supported on Windows, ignored.", runtimeData.procConfig.Name)
+	}
+	if runtimeData.procConfig.CPURatePercent > 0 {
+		gpclogging.Warn("Process <%s>: CPURatePercent is only supported on Windows, ignored.", runtimeData.procConfig.Name)
+	}
+	return nil
+}
+
+//isRunning checks if the process of the runtime data is still active. Children that
+//were released are never waited for by anybody else, so they are reaped here first,
+//otherwise they would stay around as zombies and look alive forever.
+//#########################################################
+func (unixPlatform) isRunning(runtimeData *GPCProcRuntimeData) error {
+	pid := runtimeData.procStatus.pid
+	if pid <= 0 {
+		// Never started, kill(0) would probe our own process group
+		return os.NewSyscallError("kill", syscall.ESRCH)
+	}
+	if runtimeData.procConfig.WaitForExitTimeout.Duration == 0 {
+		if reaped, exitCode, err := reap(pid); reaped {
+			if exitCode >= 0 {
+				runtimeData.procStatus.exitCode = exitCode
+			}
+			return err
+		}
+	}
+
+	// Not a released child (or not ours any more), just probe it
+	err := syscall.Kill(pid, 0)
+	if err == nil || err == syscall.EPERM {
+		return nil
+	}
+	return os.NewSyscallError("kill", err)
+}
+
+// reap collects the exit status of a released child, if it has exited, along with its exit
+// code (-1 if it has not exited or was killed by a signal). It reports false if the PID is
+// not a child of ours, so the caller has to check otherwise.
+//------------------------------------------------------------------------------
+func reap(pid int) (bool, int, error) {
+	var status syscall.WaitStatus
+	wpid, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil)
+	switch {
+	case err == syscall.ECHILD:
+		return false, -1, nil
+	case err != nil:
+		return true, -1, os.NewSyscallError("wait4", err)
+	case wpid == pid:
+		return true, status.ExitStatus(), os.NewSyscallError("wait4", syscall.ESRCH)
+	}
+	return true, -1, nil
+}
+
+//killTree sends SIGTERM to the process group and SIGKILL to whatever is left after the grace
+//period, or as soon as the context is cancelled
+//-------------------------------------------------------------------
+func (unixPlatform) killTree(ctx context.Context, runtimeData *GPCProcRuntimeData, gracePeriod time.Duration) (bool, error) {
+	pgid := int(runtimeData.treeHandle)
+	if pgid == 0 {
+		if runtimeData.procCmd == nil || runtimeData.procCmd.Process == nil {
+			return false, nil
+		}
+		return true, runtimeData.procCmd.Process.Kill()
+	}
+
+	gpclogging.Debug("Sending SIGTERM to process group <%d>.", pgid)
+	err := syscall.Kill(-pgid, syscall.SIGTERM)
+	if err == syscall.ESRCH {
+		return false, nil
+	}
+
+	deadline := time.Now().Add(gracePeriod)
+	for time.Now().Before(deadline) {
+		if runtimeData.procConfig.WaitForExitTimeout.Duration == 0 {
+			reap(pgid)
+		}
+		if syscall.Kill(-pgid, 0) == syscall.ESRCH {
+			return false, nil
+		}
+		if !sleepContext(ctx, 100*time.Millisecond) {
+			gpclogging.Debug("Waiting for process group <%d> was cancelled.", pgid)
+			break
+		}
+	}
+
+	gpclogging.Debug("Process group <%d> is still alive, sending SIGKILL.", pgid)
+	err = syscall.Kill(-pgid, syscall.SIGKILL)
+	if err == syscall.ESRCH {
+		return false, nil
+	}
+	if err != nil {
+		return true, os.NewSyscallError("kill", err)
+	}
+	return true, nil
+}
+
+// clock ticks per second of the CPU times in /proc, USER_HZ is 100 on all common Linux platforms
+const procClockTicks = 100
+
+//treeUsages sums the CPU time, resident memory and file descriptors of all processes in the
+//process groups. It reads the proc filesystem where there is one (Linux) and asks ps otherwise
+//(macOS), which does not tell the file descriptors. Either is read once for all groups.
+//-------------------------------------------------------------------
+func (unixPlatform) treeUsages(runtimeDataList []*GPCProcRuntimeData) (map[*GPCProcRuntimeData]treeUsage, error) {
+	groupOf := make(map[*GPCProcRuntimeData]int, len(runtimeDataList))
+	pgids := make(map[int]bool, len(runtimeDataList))
+	for _, runtimeData := range runtimeDataList {
+		pgid := int(runtimeData.treeHandle)
+		if pgid == 0 {
+			pgid = runtimeData.procStatus.pid
+		}
+		groupOf[runtimeData] = pgid
+		pgids[pgid] = true
+	}
+
+	var groupUsages map[int]treeUsage
+	var err error
+	if _, errStat := os.Stat("/proc/self/stat"); errStat == nil {
+		groupUsages, err = procGroupUsages(pgids)
+	} else {
+		groupUsages, err = psGroupUsages(pgids)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	usages := make(map[*GPCProcRuntimeData]treeUsage, len(runtimeDataList))
+	for runtimeData, pgid := range groupOf {
+		usages[runtimeData] = groupUsages[pgid]
+	}
+	return usages, nil
+}
+
+// procGroupUsages sums the usage of the given process groups from /proc
+//------------------------------------------------------------------------------
+func procGroupUsages(pgids map[int]bool) (map[int]treeUsage, error) {
+	totals := make(map[int]treeUsage, len(pgids))
+	statFiles, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return nil, err
+	}
+	pageSize := uint64(os.Getpagesize())
+
+	for _, statFile := range statFiles {
+		statData, err := os.ReadFile(statFile)
+		if err != nil {
+			// Process is gone meanwhile
+			continue
+		}
+		// The command name may contain blanks, the fields after it do not
+		closeParen := bytes.LastIndexByte(statData, ')')
+		if closeParen < 0 {
+			continue
+		}
+		// state ppid pgrp ... utime, stime and rss are fields 14, 15 and 24 of the whole line,
+		// i.e. index 11, 12 and 21 after the name
+		fields := strings.Fields(string(statData[closeParen+1:]))
+		if len(fields) < 22 {
+			continue
+		}
+		pgid, err := strconv.Atoi(fields[2])
+		if err != nil || !pgids[pgid] {
+			continue
+		}
+		total := totals[pgid]
+		if rssPages, err := strconv.ParseUint(fields[21], 10, 64); err == nil {
+			total.memoryBytes += rssPages * pageSize
+		}
+		userTicks, errUser := strconv.ParseUint(fields[11], 10, 64)
+		systemTicks, errSystem := strconv.ParseUint(fields[12], 10, 64)
+		if errUser == nil && errSystem == nil {
+			total.cpuTime += time.Duration(userTicks+systemTicks) * time.Second / procClockTicks
+		}
+		// The descriptors of processes of other users can not be listed
+		if fdEntries, err := os.ReadDir(filepath.Join(filepath.Dir(statFile), "fd")); err == nil {
+			total.handles += len(fdEntries)
+		}
+		totals[pgid] = total
+	}
+	return totals, nil
+}
+
+// psGroupUsages sums the CPU time and RSS of the given process groups as reported by ps
+//------------------------------------------------------------------------------
+func psGroupUsages(pgids map[int]bool) (map[int]treeUsage, error) {
+	totals := make(map[int]treeUsage, len(pgids))
+	psOut, err := exec.Command("ps", "-A", "-o", "pgid=,rss=,time=").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(psOut))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		pgid, err := strconv.Atoi(fields[0])
+		if err != nil || !pgids[pgid] {
+			continue
+		}
+		total := totals[pgid]
+		if rssKB, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			total.memoryBytes += rssKB * 1024
+		}
+		total.cpuTime += parsePsTime(fields[2])
+		totals[pgid] = total
+	}
+	return totals, nil
+}
+
+// parsePsTime parses the CPU time column of ps, "[[dd-]hh:]mm:ss[.ss]". Zero if it can not be parsed.
+//------------------------------------------------------------------------------
+func parsePsTime(sTime string) time.Duration {
+	var days float64
+	if sDays, sRest, found := strings.Cut(sTime, "-"); found {
+		days, _ = strconv.ParseFloat(sDays, 64)
+		sTime = sRest
+	}
+	var seconds float64
+	for _, sPart := range strings.Split(sTime, ":") {
+		part, err := strconv.ParseFloat(sPart, 64)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + part
+	}
+	return time.Duration((days*86400 + seconds) * float64(time.Second))
+}
+
+func (unixPlatform) closeTree(runtimeData *GPCProcRuntimeData) {
+	runtimeData.treeHandle = 0
+}
+
+//suspendTree stops the process group with SIGSTOP, which can not be caught or ignored
+//-------------------------------------------------------------------
+func (unixPlatform) suspendTree(runtimeData *GPCProcRuntimeData) error {
+	return signalTree(runtimeData, syscall.SIGSTOP)
+}
+
+//resumeTree continues the stopped process group with SIGCONT
+//-------------------------------------------------------------------
+func (unixPlatform) resumeTree(runtimeData *GPCProcRuntimeData) error {
+	return signalTree(runtimeData, syscall.SIGCONT)
+}
+
+// signalTree sends a signal to the process group of the runtime data, or to the process alone
+// if it has no group of its own, e.g. when it was adopted
+//------------------------------------------------------------------------------
+func signalTree(runtimeData *GPCProcRuntimeData, signal syscall.Signal) error {
+	pid := runtimeData.procStatus.pid
+	if runtimeData.treeHandle != 0 {
+		pid = -int(runtimeData.treeHandle)
+	}
+	if err := syscall.Kill(pid, signal); err != nil {
+		return os.NewSyscallError("kill", err)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package gpcprocessmgr
+
+import (
+	"context"
+	"gpclogging"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// exit code of a process that has not exited yet
+const stillActive = 259
+
+// windowsPlatform keeps the process tree in a job object, see gpcjobobject_windows.go
+type windowsPlatform struct{}
+
+func newPlatform() procPlatform {
+	return windowsPlatform{}
+}
+
+func (windowsPlatform) prepare(procCmd *exec.Cmd, hideWindow bool) {
+	// A process group of its own can be sent CTRL_BREAK alone, see SignalProcess. It also keeps
+	// a Ctrl+C at the console of the controller from reaching the task, the controller stops it.
+	procCmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: hideWindow, CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+func (windowsPlatform) start(runtimeData *GPCProcRuntimeData) error {
+	var err error
+	if len(runtimeData.procConfig.Desktop) > 0 || len(runtimeData.procConfig.Session) > 0 {
+		err = startOnDesktop(runtimeData)
+	} else {
+		err = startInJob(runtimeData)
+	}
+	if err != nil {
+		return err
+	}
+	// The handle of the command is released after the start, a handle of our own keeps the
+	// exit code readable once the process is gone
+	hProcess, err := syscall.OpenProcess(processQueryLimitedInformation|syscall.SYNCHRONIZE, false, uint32(runtimeData.procCmd.Process.Pid))
+	if err == nil {
+		runtimeData.exitHandle = uintptr(hProcess)
+	}
+	return nil
+}
+
+//isRunning checks if a program for a given PID is still active
+//this a manual fix for https://github.com/golang/go/issues/33814
+//#########################################################
+func (windowsPlatform) isRunning(runtimeData *GPCProcRuntimeData) error {
+	// The handle of the started process tells the exit code as well
+	if runtimeData.exitHandle != 0 {
+		var exitCode uint32
+		if err := syscall.GetExitCodeProcess(syscall.Handle(runtimeData.exitHandle), &exitCode); err != nil {
+			return os.NewSyscallError("GetExitCodeProcess", err)
+		}
+		if exitCode == stillActive {
+			return nil
+		}
+		runtimeData.procStatus.exitCode = int(exitCode)
+		return os.ErrProcessDone
+	}
+
+	pid := runtimeData.procStatus.pid
+	const da = syscall.STANDARD_RIGHTS_READ | syscall.PROCESS_QUERY_INFORMATION | syscall.SYNCHRONIZE
+	h, e := syscall.OpenProcess(da, true, uint32(pid))
+	defer syscall.CloseHandle(h)
+
+	if e != nil {
+		return os.NewSyscallError("OpenProcess", e)
+	}
+	return nil
+}
+
+//killTree posts WM_CLOSE to the windows of the process tree and terminates the job of the
+//process after the grace period, or falls back to taskkill if it has none. Without windows
+//there is nothing to ask gently, the tree is killed at once.
+//-------------------------------------------------------------------
+func (windowsPlatform) killTree(ctx context.Context, runtimeData *GPCProcRuntimeData, gracePeriod time.Duration) (bool, error) {
+	// A process that was already waited for is gone, its PID may be reused
+	if runtimeData.treeHandle == 0 && (runtimeData.procCmd == nil || runtimeData.procCmd.Process == nil || runtimeData.procCmd.ProcessState != nil) {
+		return false, nil
+	}
+
+	if closeTreeWindows(runtimeData) > 0 {
+		deadline := time.Now().Add(gracePeriod)
+		for time.Now().Before(deadline) {
+			if !treeAlive(runtimeData) {
+				return false, nil
+			}
+			if !sleepContext(ctx, 100*time.Millisecond) {
+				break
+			}
+		}
+	}
+
+	if runtimeData.treeHandle != 0 {
+		return true, terminateJob(runtimeData)
+	}
+	return true, killProcess(runtimeData.procCmd)
+}
+
+//treeAlive reports whether a process of the tree is still running
+//-------------------------------------------------------------------
+func treeAlive(runtimeData *GPCProcRuntimeData) bool {
+	if runtimeData.treeHandle != 0 {
+		pids, err := jobProcessIds(runtimeData)
+		return err != nil || len(pids) > 0
+	}
+
+	hProcess, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(runtimeData.procStatus.pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(hProcess)
+	var exitCode uint32
+	if err := syscall.GetExitCodeProcess(hProcess, &exitCode); err != nil {
+		return false
+	}
+	return exitCode == stillActive
+}
+
+//treeUsages samples the jobs one by one, each job tells its own processes
+//-------------------------------------------------------------------
+func (windowsPlatform) treeUsages(runtimeDataList []*GPCProcRuntimeData) (map[*GPCProcRuntimeData]treeUsage, error) {
+	usages := make(map[*GPCProcRuntimeData]treeUsage, len(runtimeDataList))
+	for _, runtimeData := range runtimeDataList {
+		usage, err := jobUsage(runtimeData)
+		if err != nil {
+			gpclogging.Debug("Could not sample resources of process <%s>: <%s>", runtimeData.procConfig.Name, err.Error())
+			continue
+		}
+		usages[runtimeData] = usage
+	}
+	return usages, nil
+}
+
+//jobUsage sums CPU times, working sets and handles of all processes in the job, or of the
+//process alone without job
+//-------------------------------------------------------------------
+func jobUsage(runtimeData *GPCProcRuntimeData) (treeUsage, error) {
+	var total treeUsage
+	pids := []int{runtimeData.procStatus.pid}
+	if runtimeData.treeHandle != 0 {
+		jobPids, err := jobProcessIds(runtimeData)
+		if err != nil {
+			return total, err
+		}
+		pids = jobPids
+	}
+
+	for _, pid := range pids {
+		usage, err := processUsage(pid)
+		if err != nil {
+			// Process is gone meanwhile
+			continue
+		}
+		total.cpuTime += usage.cpuTime
+		total.memoryBytes += usage.memoryBytes
+		total.handles += usage.handles
+	}
+	return total, nil
+}
+
+func (windowsPlatform) closeTree(runtimeData *GPCProcRuntimeData) {
+	closeJob(runtimeData)
+	if runtimeData.exitHandle != 0 {
+		syscall.CloseHandle(syscall.Handle(runtimeData.exitHandle))
+		runtimeData.exitHandle = 0
+	}
+}
+
+//suspendTree suspends the threads of all processes of the job, or of the process alone if it
+//has no job
+//-------------------------------------------------------------------
+func (windowsPlatform) suspendTree(runtimeData *GPCProcRuntimeData) error {
+	return forEachTreeProcess(runtimeData, procNtSuspendProcess, "NtSuspendProcess")
+}
+
+//resumeTree resumes the processes suspended by suspendTree
+//-------------------------------------------------------------------
+func (windowsPlatform) resumeTree(runtimeData *GPCProcRuntimeData) error {
+	return forEachTreeProcess(runtimeData, procNtResumeProcess, "NtResumeProcess")
+}
+
+// forEachTreeProcess calls NtSuspendProcess or NtResumeProcess for each process of the tree.
+// Processes that ended meanwhile are skipped, the first other error is returned.
+//------------------------------------------------------------------------------
+func forEachTreeProcess(runtimeData *GPCProcRuntimeData, proc *syscall.LazyProc, sProcName string) error {
+	pids := []int{runtimeData.procStatus.pid}
+	if runtimeData.treeHandle != 0 {
+		jobPids, err := jobProcessIds(runtimeData)
+		if err != nil {
+			return err
+		}
+		pids = jobPids
+	}
+	var firstErr error
+	for _, pid := range pids {
+		hProcess, err := syscall.OpenProcess(processSuspendResume, false, uint32(pid))
+		if err != nil {
+			continue
+		}
+		status, _, _ := proc.Call(uintptr(hProcess))
+		syscall.CloseHandle(hProcess)
+		if status != 0 && firstErr == nil {
+			firstErr = os.NewSyscallError(sProcName, syscall.Errno(status))
+		}
+	}
+	return firstErr
+}
+
+//killProcess will try to kill the given process (windows specfic)
+//-------------------------------------------------------------------
+func killProcess(proc *exec.Cmd) error {
+	gpclogging.Debug("Enter KillProcess()")
+
+	proc.Process.Release()
+	proc.Process.Signal(syscall.SIGTERM)
+	proc.Process.Signal(syscall.SIGKILL)
+
+	kill := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(proc.Process.Pid))
+	err := kill.Run()
+
+	gpclogging.Debug("Leaving KillProcess()")
+
+	return err
+}
@@ -0,0 +1,138 @@
+package gpcprocessmgr
+
+import (
+	"gpcconfig"
+	"time"
+)
+
+// The decisions when to start a process again are kept here, apart from starting and stopping
+// processes, so that Simulate takes them the same way as the controller does.
+
+// Clock tells the time to the scheduling policies
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the Clock of a running controller
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// The clock of the scheduler
+var gClock Clock = systemClock{}
+
+// FakeClock is a Clock that only moves when it is set, it drives a simulation
+type FakeClock struct {
+	now time.Time
+}
+
+//NewFakeClock returns a FakeClock standing at the given time
+//#########################################################
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+//Now returns the time the clock was set to
+//#########################################################
+func (c *FakeClock) Now() time.Time {
+	return c.now
+}
+
+//Set moves the clock to the given time
+//#########################################################
+func (c *FakeClock) Set(now time.Time) {
+	c.now = now
+}
+
+// shouldRestart reports whether a no-wait process that exited is restarted once more.
+// Without MaxRestarts it is never restarted.
+//------------------------------------------------------------------------------
+func shouldRestart(procConfig *gpcconfig.ProcessConfig, restartCount uint32) bool {
+	return restartCount < procConfig.MaxRestarts
+}
+
+// the CrashLoopWindow of tasks that do not set one
+const defCrashLoopWindow = 10 * time.Minute
+
+// crashLoopDetected adds a restart at the given time to the restarts of a process and reports
+// whether there are more than CrashLoopRestarts within the CrashLoopWindow. Restarts that left
+// the window are dropped from the list.
+//------------------------------------------------------------------------------
+func crashLoopDetected(procConfig *gpcconfig.ProcessConfig, restartTimes *[]time.Time, now time.Time) bool {
+	if procConfig.CrashLoopRestarts == 0 {
+		return false
+	}
+	window := crashLoopWindow(procConfig)
+
+	recentTimes := (*restartTimes)[:0]
+	for _, restartTime := range *restartTimes {
+		if now.Sub(restartTime) < window {
+			recentTimes = append(recentTimes, restartTime)
+		}
+	}
+	*restartTimes = append(recentTimes, now)
+	return uint32(len(*restartTimes)) > procConfig.CrashLoopRestarts
+}
+
+// crashLoopWindow returns the CrashLoopWindow of a task
+//------------------------------------------------------------------------------
+func crashLoopWindow(procConfig *gpcconfig.ProcessConfig) time.Duration {
+	if procConfig.CrashLoopWindow.Duration == 0 {
+		return defCrashLoopWindow
+	}
+	return procConfig.CrashLoopWindow.Duration
+}
+
+// nextScheduledRun returns when a schedule is due next after the time of the clock, the zero
+// time if it is never due
+//------------------------------------------------------------------------------
+func nextScheduledRun(schedule *gpcconfig.Schedule, location *time.Location, clock Clock) time.Time {
+	return schedule.Next(clock.Now().In(location))
+}
+
+// scheduledRestartAt returns when RestartEvery restarts a run of a no-wait process that started at
+// the given time, the zero time if it is not restarted
+//------------------------------------------------------------------------------
+func scheduledRestartAt(procConfig *gpcconfig.ProcessConfig, startTime time.Time) time.Time {
+	every, schedule, err := procConfig.RestartPeriod()
+	switch {
+	case err != nil || startTime.IsZero():
+		return time.Time{}
+	case schedule != nil:
+		location, err := procConfig.Location()
+		if err != nil {
+			location = time.Local
+		}
+		return schedule.Next(startTime.In(location))
+	case every > 0:
+		return startTime.Add(every)
+	}
+	return time.Time{}
+}
+
+// holidayOf reports whether a run falls on a day of one of the skip calendars of a task,
+// along with the calendar and the description of the holiday
+//------------------------------------------------------------------------------
+func holidayOf(calendars map[string]*gpcconfig.Calendar, procConfig *gpcconfig.ProcessConfig, runTime time.Time) (bool, string) {
+	for _, calName := range procConfig.SkipCalendars {
+		cal, found := calendars[calName]
+		if !found {
+			continue
+		}
+		if holiday, description := cal.IsHoliday(runTime); holiday {
+			return true, calName + ": " + description
+		}
+	}
+	return false, ""
+}
+
+// followUpsOf returns the tasks started after a wait task ended, and the outcome for the log
+//------------------------------------------------------------------------------
+func followUpsOf(procConfig *gpcconfig.ProcessConfig, succeeded bool) ([]string, string) {
+	if succeeded {
+		return procConfig.OnSuccess, "success"
+	}
+	return procConfig.OnFailure, "failure"
+}
@@ -0,0 +1,106 @@
+package gpcprocessmgr
+
+import (
+	"fmt"
+	"gpcconfig"
+	"gpclogging"
+	"os"
+	"strings"
+	"time"
+)
+
+// How long a launch waits for the ports to get free after killing the processes holding them
+const portConflictKillWait = 5 * time.Second
+
+// portInUse is a port of BindPorts some other process listens on
+type portInUse struct {
+	port int
+	pid  int    // process listening on it, zero if not known
+	name string // its executable, empty if not known
+}
+
+// String describes the conflict, e.g. "port 8080 is in use by PID 4711 (nginx)"
+func (u portInUse) String() string {
+	switch {
+	case u.pid == os.Getpid():
+		return fmt.Sprintf("port %d is in use by the controller", u.port)
+	case u.pid > 0 && len(u.name) > 0:
+		return fmt.Sprintf("port %d is in use by PID %d (%s)", u.port, u.pid, u.name)
+	case u.pid > 0:
+		return fmt.Sprintf("port %d is in use by PID %d", u.port, u.pid)
+	}
+	return fmt.Sprintf("port %d is in use by an unknown process", u.port)
+}
+
+// portsInUse returns the ports of BindPorts of a process that can not be listened on. A start-first
+// restart is not checked, the old run holds the ports until the new one is ready.
+//------------------------------------------------------------------------------
+func portsInUse(runtimeData *GPCProcRuntimeData) []portInUse {
+	runtimeData.mux.Lock()
+	bStartingFirst := runtimeData.procStatus.startingFirst
+	runtimeData.mux.Unlock()
+	if bStartingFirst {
+		return nil
+	}
+	var used []portInUse
+	for _, port := range runtimeData.procConfig.BindPorts {
+		if !portFree(int(port)) {
+			pid, sName := portOwner(int(port))
+			used = append(used, portInUse{port: int(port), pid: pid, name: sName})
+		}
+	}
+	return used
+}
+
+// waitsForPorts reports whether the launch of a process waits for its BindPorts to get free
+//------------------------------------------------------------------------------
+func waitsForPorts(procConfig *gpcconfig.ProcessConfig) bool {
+	return len(procConfig.BindPorts) > 0 && (len(procConfig.PortConflict) == 0 || procConfig.PortConflict == gpcconfig.PortConflictWait)
+}
+
+// resolvePortConflicts handles the ports of BindPorts that are in use right before a launch by
+// the PortConflict of the task: "fail" returns an error naming the processes holding them, "kill"
+// kills these processes (never the controller) and waits for the ports to get free. With "wait"
+// the preconditions waited for them already.
+//------------------------------------------------------------------------------
+func resolvePortConflicts(procName string, runtimeData *GPCProcRuntimeData) error {
+	if len(runtimeData.procConfig.BindPorts) == 0 || waitsForPorts(runtimeData.procConfig) {
+		return nil
+	}
+	used := portsInUse(runtimeData)
+	if len(used) == 0 {
+		return nil
+	}
+	if runtimeData.procConfig.PortConflict == gpcconfig.PortConflictFail {
+		return fmt.Errorf("%w: %s", ErrPortInUse, describePorts(used))
+	}
+
+	for _, conflict := range used {
+		if conflict.pid == 0 || conflict.pid == os.Getpid() {
+			return fmt.Errorf("%w: %s", ErrPortInUse, conflict.String())
+		}
+		gpclogging.Task(procName).Warn("Killing PID=<%d> (%s) holding port <%d> of process <%s>.", conflict.pid, conflict.name, conflict.port, procName)
+		if squatter, err := os.FindProcess(conflict.pid); err == nil {
+			if err := squatter.Kill(); err != nil {
+				return fmt.Errorf("%w: %s, it could not be killed: %s", ErrPortInUse, conflict.String(), err.Error())
+			}
+		}
+	}
+	deadline := time.Now().Add(portConflictKillWait)
+	for used = portsInUse(runtimeData); len(used) > 0; used = portsInUse(runtimeData) {
+		if time.Now().After(deadline) || !sleepUnlessStopping(100*time.Millisecond) {
+			return fmt.Errorf("%w: %s", ErrPortInUse, describePorts(used))
+		}
+	}
+	return nil
+}
+
+// describePorts joins the descriptions of ports in use
+//------------------------------------------------------------------------------
+func describePorts(used []portInUse) string {
+	sConflicts := make([]string, len(used))
+	for i, conflict := range used {
+		sConflicts[i] = conflict.String()
+	}
+	return strings.Join(sConflicts, ", ")
+}
@@ -0,0 +1,74 @@
+//go:build !windows
+// +build !windows
+
+package gpcprocessmgr
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+//portOwner returns PID and name of the process listening on a local TCP port, zero PID if it is
+//not known. It reads the proc filesystem where there is one (Linux) and asks lsof otherwise
+//(macOS). Processes of other users are only found when running as root.
+//-------------------------------------------------------------------
+func portOwner(port int) (int, string) {
+	if _, err := os.Stat("/proc/net/tcp"); err != nil {
+		return lsofPortOwner(port)
+	}
+
+	// Sockets listening on the port by inode, state 0A is LISTEN
+	inodes := make(map[string]bool)
+	for _, sTable := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		tableData, err := os.ReadFile(sTable)
+		if err != nil {
+			continue
+		}
+		for _, sLine := range strings.Split(string(tableData), "\n") {
+			fields := strings.Fields(sLine)
+			if len(fields) < 10 || fields[3] != "0A" {
+				continue
+			}
+			_, sPort, _ := strings.Cut(fields[1], ":")
+			if linePort, err := strconv.ParseInt(sPort, 16, 32); err == nil && int(linePort) == port {
+				inodes["socket:["+fields[9]+"]"] = true
+			}
+		}
+	}
+	if len(inodes) == 0 {
+		return 0, ""
+	}
+
+	fdLinks, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, sLink := range fdLinks {
+		if sTarget, err := os.Readlink(sLink); err == nil && inodes[sTarget] {
+			pid, _ := strconv.Atoi(strings.Split(sLink, "/")[2])
+			commData, _ := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+			return pid, strings.TrimSpace(string(commData))
+		}
+	}
+	return 0, ""
+}
+
+// lsofPortOwner asks lsof for the process listening on a local TCP port
+//------------------------------------------------------------------------------
+func lsofPortOwner(port int) (int, string) {
+	lsofOut, err := exec.Command("lsof", "-nP", "-iTCP:"+strconv.Itoa(port), "-sTCP:LISTEN", "-Fpc").Output()
+	if err != nil {
+		return 0, ""
+	}
+	pid, sName := 0, ""
+	for _, sLine := range strings.Split(string(lsofOut), "\n") {
+		switch {
+		case strings.HasPrefix(sLine, "p") && pid == 0:
+			pid, _ = strconv.Atoi(sLine[1:])
+		case strings.HasPrefix(sLine, "c") && len(sName) == 0:
+			sName = sLine[1:]
+		}
+	}
+	return pid, sName
+}
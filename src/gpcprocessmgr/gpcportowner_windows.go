@@ -0,0 +1,83 @@
+package gpcprocessmgr
+
+import (
+	"encoding/binary"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	afInet                   = 2
+	afInet6                  = 23
+	tcpTableOwnerPIDListener = 3  // TCP_TABLE_OWNER_PID_LISTENER
+	tcpRowSize               = 24 // MIB_TCPROW_OWNER_PID
+	tcp6RowSize              = 56 // MIB_TCP6ROW_OWNER_PID
+)
+
+var (
+	modiphlpapi             = syscall.NewLazyDLL("iphlpapi.dll")
+	procGetExtendedTcpTable = modiphlpapi.NewProc("GetExtendedTcpTable")
+)
+
+//portOwner returns PID and name of the process listening on a local TCP port, zero PID if it is
+//not known. It looks through the IPv4 and IPv6 listener tables of GetExtendedTcpTable.
+//-------------------------------------------------------------------
+func portOwner(port int) (int, string) {
+	for _, family := range []struct {
+		af, rowSize, portOffset, pidOffset int
+	}{{afInet, tcpRowSize, 8, 20}, {afInet6, tcp6RowSize, 20, 52}} {
+		table := listenerTable(family.af)
+		if len(table) < 4 {
+			continue
+		}
+		entries := int(binary.LittleEndian.Uint32(table))
+		for i := 0; i < entries && 4+(i+1)*family.rowSize <= len(table); i++ {
+			row := table[4+i*family.rowSize:]
+			// The port is in network byte order in the low word
+			if int(binary.BigEndian.Uint16(row[family.portOffset:])) == port {
+				pid := int(binary.LittleEndian.Uint32(row[family.pidOffset:]))
+				return pid, processName(pid)
+			}
+		}
+	}
+	return 0, ""
+}
+
+// listenerTable returns the raw TCP_TABLE_OWNER_PID_LISTENER of an address family, nil if it
+// could not be read
+//------------------------------------------------------------------------------
+func listenerTable(af int) []byte {
+	var size uint32
+	procGetExtendedTcpTable.Call(0, uintptr(unsafe.Pointer(&size)), 0, uintptr(af), tcpTableOwnerPIDListener, 0)
+	for tries := 0; tries < 3 && size > 0; tries++ {
+		table := make([]byte, size)
+		status, _, _ := procGetExtendedTcpTable.Call(uintptr(unsafe.Pointer(&table[0])), uintptr(unsafe.Pointer(&size)), 0,
+			uintptr(af), tcpTableOwnerPIDListener, 0)
+		if status == 0 {
+			return table
+		}
+		if syscall.Errno(status) != syscall.ERROR_INSUFFICIENT_BUFFER {
+			return nil
+		}
+	}
+	return nil
+}
+
+// processName returns the executable file name of a process, empty if it is not found
+//------------------------------------------------------------------------------
+func processName(pid int) string {
+	hSnapshot, err := syscall.CreateToolhelp32Snapshot(syscall.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return ""
+	}
+	defer syscall.CloseHandle(hSnapshot)
+
+	var entry syscall.ProcessEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	for err = syscall.Process32First(hSnapshot, &entry); err == nil; err = syscall.Process32Next(hSnapshot, &entry) {
+		if int(entry.ProcessID) == pid {
+			return syscall.UTF16ToString(entry.ExeFile[:])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,128 @@
+package gpcprocessmgr
+
+import (
+	"fmt"
+	"gpcconfig"
+	"gpclogging"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	gPortFirst, gPortLast int            // Control.PortRange
+	gAssignedPorts        map[int]string // port => name of the process it is assigned to
+	gPortMux              sync.Mutex     // guards all three, never held while taking the lock of a process
+)
+
+// setPortRange takes the port range of the configuration. Ports assigned already are kept,
+// also when they are outside of a new range.
+//------------------------------------------------------------------------------
+func setPortRange(configData *gpcconfig.ConfigData) {
+	first, last, err := configData.PortRange()
+	if err != nil {
+		// Reported by the preflight check
+		first, last, _ = (&gpcconfig.ConfigData{}).PortRange()
+	}
+	gPortMux.Lock()
+	gPortFirst, gPortLast = first, last
+	if gAssignedPorts == nil {
+		gAssignedPorts = make(map[int]string)
+	}
+	gPortMux.Unlock()
+}
+
+// assignPorts gives each variable of the AssignPorts of a process a free port and passes them in
+// its environment. A process keeps its ports for its restarts, unless a port was taken by
+// someone else meanwhile. The caller must hold the lock of the process.
+//------------------------------------------------------------------------------
+func assignPorts(runtimeData *GPCProcRuntimeData) error {
+	procConfig := runtimeData.procConfig
+	if len(procConfig.AssignPorts) == 0 {
+		return nil
+	}
+	if runtimeData.ports == nil {
+		runtimeData.ports = make(map[string]int)
+	}
+
+	gPortMux.Lock()
+	defer gPortMux.Unlock()
+	portEnv := make(map[string]string, len(procConfig.AssignPorts))
+	for _, sEnvName := range procConfig.AssignPorts {
+		port, found := runtimeData.ports[sEnvName]
+		if found && !portFree(port) {
+			gpclogging.Task(procConfig.Name).Warn("Port <%d> of process <%s> (%s) is in use by someone else now, assigning another one.", port,
+				procConfig.Name, sEnvName)
+			delete(gAssignedPorts, port)
+			found = false
+		}
+		if !found {
+			port = 0
+			for candidate := gPortFirst; candidate <= gPortLast; candidate++ {
+				if _, taken := gAssignedPorts[candidate]; !taken && portFree(candidate) {
+					port = candidate
+					break
+				}
+			}
+			if port == 0 {
+				return fmt.Errorf("no free port left in <%d-%d> for %s", gPortFirst, gPortLast, sEnvName)
+			}
+			gAssignedPorts[port] = procConfig.Name
+			runtimeData.ports[sEnvName] = port
+			gpclogging.Task(procConfig.Name).Info("Assigned port <%d> to process <%s> as %s.", port, procConfig.Name, sEnvName)
+		}
+		portEnv[sEnvName] = strconv.Itoa(port)
+	}
+	appendEnv(runtimeData.procCmd, portEnv)
+	return nil
+}
+
+// releasePorts gives the ports assigned to a process back. The caller must hold the lock of
+// the process.
+//------------------------------------------------------------------------------
+func (r *GPCProcRuntimeData) releasePorts() {
+	gPortMux.Lock()
+	for _, port := range r.ports {
+		delete(gAssignedPorts, port)
+	}
+	gPortMux.Unlock()
+	r.ports = nil
+}
+
+// portFree reports whether a TCP port can be listened on
+//------------------------------------------------------------------------------
+func portFree(port int) bool {
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return false
+	}
+	listener.Close()
+	return true
+}
+
+// portsOf returns a copy of the ports assigned to a process, nil if it has none. The caller
+// must hold the lock of the process.
+//------------------------------------------------------------------------------
+func (r *GPCProcRuntimeData) portsOf() map[string]int {
+	if len(r.ports) == 0 {
+		return nil
+	}
+	ports := make(map[string]int, len(r.ports))
+	for sEnvName, port := range r.ports {
+		ports[sEnvName] = port
+	}
+	return ports
+}
+
+// portsText returns the assigned ports as "NAME=port" sorted by name, separated by commas
+//------------------------------------------------------------------------------
+func portsText(ports map[string]int) string {
+	sPorts := make([]string, 0, len(ports))
+	for sEnvName, port := range ports {
+		sPorts = append(sPorts, fmt.Sprintf("%s=%d", sEnvName, port))
+	}
+	sort.Strings(sPorts)
+	return strings.Join(sPorts, ",")
+}
@@ -0,0 +1,104 @@
+package gpcprocessmgr
+
+import (
+	"gpcconfig"
+	"gpclogging"
+	"os"
+)
+
+// waitForPreconditions blocks the launch of a process until all its Preconditions hold (and its
+// BindPorts are free with PortConflict wait), checking them again every PreconditionInterval.
+// The process shows the state "condition" meanwhile.
+// Returns false if the launch must not happen any more: the controller shuts down, the process
+// was stopped on request or replaced by a reload.
+//------------------------------------------------------------------------------
+func waitForPreconditions(procName string, runtimeData *GPCProcRuntimeData) bool {
+	if len(runtimeData.procConfig.Preconditions) == 0 && !waitsForPorts(runtimeData.procConfig) {
+		return true
+	}
+	sWaitingOn := ""
+	defer func() {
+		runtimeData.mux.Lock()
+		runtimeData.procStatus.waitingOn = ""
+		runtimeData.mux.Unlock()
+	}()
+
+	for {
+		sUnmet := unmetPrecondition(runtimeData)
+		if len(sUnmet) == 0 {
+			if len(sWaitingOn) > 0 {
+				gpclogging.Task(procName).Info("Preconditions of process <%s> hold now, launching it.", procName)
+			}
+			return true
+		}
+		if sUnmet != sWaitingOn {
+			gpclogging.Task(procName).Info("Process <%s> is waiting on condition: %s. Checking again every <%s>.", procName, sUnmet,
+				runtimeData.procConfig.PreconditionRetryInterval().String())
+			sWaitingOn = sUnmet
+		}
+		runtimeData.mux.Lock()
+		runtimeData.procStatus.waitingOn = sUnmet
+		runtimeData.mux.Unlock()
+
+		if !sleepUnlessStopping(runtimeData.procConfig.PreconditionRetryInterval()) {
+			return false
+		}
+		runtimeData.mux.Lock()
+		bStopped := runtimeData.procStatus.stopped
+		runtimeData.mux.Unlock()
+		if bStopped || currentRuntimeData()[procName] != runtimeData {
+			gpclogging.Debug("Process <%s> was stopped or replaced while waiting on its preconditions.", procName)
+			return false
+		}
+	}
+}
+
+// unmetPrecondition returns the description of the first precondition of a task that does not
+// hold, empty if all of them do. With PortConflict wait its BindPorts must be free as well.
+//------------------------------------------------------------------------------
+func unmetPrecondition(runtimeData *GPCProcRuntimeData) string {
+	for _, condition := range runtimeData.procConfig.Preconditions {
+		if !preconditionHolds(condition) {
+			return condition.String()
+		}
+	}
+	if waitsForPorts(runtimeData.procConfig) {
+		if used := portsInUse(runtimeData); len(used) > 0 {
+			return used[0].String()
+		}
+	}
+	return ""
+}
+
+// preconditionHolds checks a single precondition
+//------------------------------------------------------------------------------
+func preconditionHolds(condition gpcconfig.Precondition) bool {
+	switch {
+	case len(condition.FileExists) > 0:
+		_, err := os.Stat(condition.FileExists)
+		return err == nil
+	case condition.PortFree > 0:
+		return portFree(int(condition.PortFree))
+	case len(condition.TaskReady) > 0:
+		return taskReady(condition.TaskReady)
+	case len(condition.EnvSet) > 0:
+		return len(os.Getenv(condition.EnvSet)) > 0
+	}
+	return true
+}
+
+// taskReady reports whether a task is running, or ended with exit code 0 if it is waited for
+//------------------------------------------------------------------------------
+func taskReady(procName string) bool {
+	runtimeData, found := currentRuntimeData()[procName]
+	if !found {
+		return false
+	}
+	runtimeData.mux.Lock()
+	defer runtimeData.mux.Unlock()
+	procStatus := &runtimeData.procStatus
+	if runtimeData.procConfig.WaitForExitTimeout.Duration > 0 {
+		return procStatus.done && !procStatus.timeout && procStatus.lastError == nil && procStatus.exitCode == 0
+	}
+	return procStatus.active && runtimeData.isNotifiedReady()
+}
@@ -1,16 +1,19 @@
 package gpcprocessmgr
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"gpcconfig"
 	"gpclogging"
 	"io"
 	"os"
 	"os/exec"
-	"strconv"
+	"reflect"
+	"sort"
+	"strings"
 	"sync"
-	"syscall"
 	"time"
 )
 
@@ -18,66 +21,221 @@ import (
 //### GLOBAL VARIABLES, INIT, CONSTS
 //#######################################################
 
+// The map is replaced as a whole and never changed once set, gRuntimeDatatMux guards only the
+// map itself. The state of a process is guarded by its own lock, see GPCProcRuntimeData.
 var gProcRuntimeData map[string]*GPCProcRuntimeData
 var gRuntimeDatatMux sync.Mutex
 var gStopMon bool
 var gStopMux sync.Mutex
+var gShutdownWaitGroup *sync.WaitGroup
+var gMonitorDone chan struct{} // closed when the monitor ended, guarded by gStopMux
+
+// Cancelled by ShutdownAll, ends start delays and other waits of the controller at once
+var gShutdownCtx = context.Background()
+var gShutdownCancel context.CancelFunc = func() {}
+var gShutdownOnce sync.Once // the signal handler, ExitWhenDone and -once may all shut down
+
+// How often the monitor checks the processes
+const monitorInterval = 100 * time.Millisecond
 
 /*ShutdownAll will stop the monitoring routine and will
-then try to terminate all started processes if configured so
+then try to terminate all started processes if configured so.
+Only the first call shuts down, later calls wait until it is done.
 ---------------------------------------------------------------------------------------*/
 func ShutdownAll() {
+	gShutdownOnce.Do(shutdownAll)
+}
+
+// shutdownAll stops the monitor and all processes, see ShutdownAll
+//------------------------------------------------------------------------------
+func shutdownAll() {
 	gpclogging.Debug("Entering ShutdownAll()")
 
+	publish(Event{Type: EventControllerShutdown})
+
 	// Stop the monitoring routine
 	gStopMux.Lock()
 	gStopMon = true
+	gShutdownCancel()
 	gStopMux.Unlock()
 
 	// Terminate all started processes (gracefully)
 	gRuntimeDatatMux.Lock()
-	defer gRuntimeDatatMux.Unlock()
+	tiers := shutdownTiers()
+	procRuntimeData := gProcRuntimeData
+	gRuntimeDatatMux.Unlock()
 
-	for procName, runtimeData := range gProcRuntimeData {
-		gpclogging.Debug("Start to shut donw all running processes...")
+	gpclogging.Debug("Start to shut donw all running processes...")
+	// Dependents first, so nothing is stopped while a process using it is still running.
+	// Not the shutdown context, every process still gets its grace period.
+	var killedNames []string
+	for tierIndex, procNames := range tiers {
+		killedNames = append(killedNames, stopTier(tierIndex, procNames, procRuntimeData)...)
+	}
+	if len(killedNames) > 0 {
+		gpclogging.Warn("Processes force-killed at shutdown after their grace period: <%s>", strings.Join(killedNames, ", "))
+	}
+	logStatsSummary()
 
-		//gpclogging.Debug("Checking process <%s>.", procName)
-		err := checkProcessRunning(runtimeData.procStatus.pid)
+	gpclogging.Debug("Leave ShutdownAll()")
+}
+
+//stopProcess tries to stop a single process gently, via the stop command if there is one and
+//SIGTERM/WM_CLOSE otherwise, and kills it if it did not exit within its grace period. The process
+//is marked inactive so it is not restarted. Cancelling the context cuts the waits for the process
+//to exit short. Reports whether the process had to be killed.
+//-------------------------------------------------------------------
+func stopProcess(ctx context.Context, procName string, runtimeData *GPCProcRuntimeData) bool {
+	gpclogging.Debug("Entering stopProcess() for process <%s>", procName)
+
+	// Mark inactive first, so the monitor does not treat the exit as a crash
+	runtimeData.mux.Lock()
+	runtimeData.procStatus.active = false
+	runtimeData.procStatus.stopPending = true
+	bStarted := runtimeData.procCmd != nil
+	bSuspended := runtimeData.procStatus.suspended
+	runtimeData.procStatus.suspended = false
+	bStopping := runtimeData.procStatus.notifiedStopping
+	runtimeData.mux.Unlock()
+	gracePeriod := runtimeData.procConfig.GracePeriod()
+	bKilled := false
+
+	// A frozen process could neither run its shutdown nor react to the stop command
+	if bSuspended {
+		if err := gPlatform.resumeTree(runtimeData); err != nil {
+			gpclogging.Task(procName).Warn("Suspended process <%s>, PID=<%d> could not be resumed before stopping it: <%s>", procName,
+				runtimeData.procStatus.pid, err.Error())
+		}
+	}
 
+	if !bStarted {
+		gpclogging.Debug("Process <%s> was never started. Nothing to do.", procName)
+	} else if err := gPlatform.isRunning(runtimeData); err != nil {
+		// Process has exited
+		gpclogging.Debug("Process <%s>, PID=<%d> has exited. Nothing to do.", procName, runtimeData.procStatus.pid)
+	} else {
+		// A process that notified STOPPING=1 is on its way out already, it only gets its grace period
+		if bStopping {
+			gpclogging.Debug("Process <%s>, PID=<%d> notified it is stopping, waiting for it to exit.", procName, runtimeData.procStatus.pid)
+			waitForExit(ctx, runtimeData, gracePeriod)
+			gracePeriod = 0
+		}
+
+		// Ask the process itself first, e.g. a server console that saves its state on "stop"
+		if len(runtimeData.procConfig.StopInput) > 0 && !bStopping {
+			gpclogging.Debug("Process <%s>, PID=<%d> is still active and has a StopInput, writing it to its stdin.", procName, runtimeData.procStatus.pid)
+			// A process that does not read its input must not hold up the stop
+			inputCtx, cancel := context.WithTimeout(ctx, gracePeriod)
+			err := writeInput(inputCtx, runtimeData, strings.Join(runtimeData.procConfig.StopInput, "\n")+"\n")
+			cancel()
+			if err != nil {
+				gpclogging.Task(procName).Warn("StopInput could not be written to process <%s>: <%s>", procName, err.Error())
+			} else {
+				waitForExit(ctx, runtimeData, gracePeriod)
+				gracePeriod = 0
+			}
+		}
+
+		// Try to stop process via Stop Command
+		if len(runtimeData.procConfig.StopPath) > 0 && !bStopping && gPlatform.isRunning(runtimeData) == nil {
+			gpclogging.Debug("Process <%s>, PID=<%d> is still active and a stop command is defined, try to stop it via command.", procName, runtimeData.procStatus.pid)
+			tryStopCommand(runtimeData)
+			waitForExit(ctx, runtimeData, gracePeriod)
+			// The stop command was the gentle way, the grace period is used up
+			gracePeriod = 0
+		}
+
+		// CHECK AGAIN
+		err := gPlatform.isRunning(runtimeData)
 		if err != nil {
 			// Process has exited
-			gpclogging.Debug("Process <%s>, PID=<%d> has exited. Nothing to do.", procName, runtimeData.procStatus.pid)
+			gpclogging.Debug("Process <%s>, PID=<%d> has exited after running stop command.", procName, runtimeData.procStatus.pid)
 		} else {
-			// Try to stop process via Stop Command
-			if len(runtimeData.procConfig.StopPath) > 0 {
-				gpclogging.Debug("Process <%s>, PID=<%d> is still active and a stop command is defined, try to stop it via command.", procName, runtimeData.procStatus.pid)
-				tryStopCommand(runtimeData)
+
+			gpclogging.Task(procName).Info("Will now try to terminate Process <%s>, PID=<%d>.", procName, runtimeData.procStatus.pid)
+			// Process is still active - terminate the whole process tree
+			var errKill error
+			bKilled, errKill = gPlatform.killTree(ctx, runtimeData, gracePeriod)
+			if errKill != nil {
+				gpclogging.Task(procName).Error("Process <%s>, PID=<%d> could not be killed!! <%s>", procName, runtimeData.procStatus.pid, errKill.Error())
+			} else if bKilled && ctx.Err() != nil {
+				gpclogging.Task(procName).Warn("Process <%s>, PID=<%d> was killed, the wait for it to exit was cut short.", procName, runtimeData.procStatus.pid)
+			} else if bKilled {
+				gpclogging.Task(procName).Warn("Process <%s>, PID=<%d> did not exit within its grace period of <%s> and was killed.", procName,
+					runtimeData.procStatus.pid, runtimeData.procConfig.GracePeriod().String())
+			}
+		}
+		// The end of a wait process is published by runProcessAndWait
+		if runtimeData.procConfig.WaitForExitTimeout.Duration == 0 {
+			message := "stopped"
+			if bKilled {
+				message = "killed on stop"
+			}
+			runtimeData.mux.Lock()
+			switch {
+			case runtimeData.procStatus.timeout && runtimeData.procStatus.lastError != nil:
+				publishFailedExit(procName, runtimeData, "terminated after its MaxRuntime")
+			case runtimeData.procStatus.timeout:
+				publishProcessEvent(EventProcessExited, procName, runtimeData, "terminated after its MaxRuntime")
+			default:
+				publishProcessEvent(EventProcessExited, procName, runtimeData, message)
 			}
+			runtimeData.mux.Unlock()
+		}
+	}
 
-			// CHECK AGAIN
-			err := checkProcessRunning(runtimeData.procStatus.pid)
-			if err != nil {
-				// Process has exited
-				gpclogging.Debug("Process <%s>, PID=<%d> has exited after running stop command.", procName, runtimeData.procStatus.pid)
-			} else {
+	// Set flags and close log file
+	runtimeData.mux.Lock()
+	runtimeData.procStatus.stopped = true
+	runtimeData.procStatus.stopPending = false
+	runtimeData.closeLogs()
+	runtimeData.closeStdin()
+	releaseMutexGroup(runtimeData)
+	gPlatform.closeTree(runtimeData)
+	removeBandwidthLimit(runtimeData)
+	removePIDFile(runtimeData)
+	runtimeData.closeNotifySocket()
+	runtimeData.mux.Unlock()
+
+	gpclogging.Debug("Leaving stopProcess()")
+	return bKilled
+}
 
-				gpclogging.Info("Will now try to kill Process <%s>, PID=<%d>.", procName, runtimeData.procStatus.pid)
-				// Process is still active - send termination signal
-				errKill := killProcess(runtimeData.procCmd)
-				if errKill != nil {
-					gpclogging.Error("Process <%s>, PID=<%d> could not be killed!! <%s>", procName, runtimeData.procStatus.pid, errKill.Error())
-				}
-			}
+// killRunInBackground kills the process tree of the current run of a process without waiting for
+// it, the monitor then handles the exit like a crash. The kill works on a copy of the handles of
+// the run and is skipped if the run ended meanwhile, so it never hits the next run of the task.
+// The caller must hold the lock of the process.
+//------------------------------------------------------------------------------
+func killRunInBackground(procName string, runtimeData *GPCProcRuntimeData) {
+	run := &GPCProcRuntimeData{procConfig: runtimeData.procConfig, procCmd: runtimeData.procCmd, treeHandle: runtimeData.treeHandle,
+		exitHandle: runtimeData.exitHandle}
+	run.procStatus.pid = runtimeData.procStatus.pid
+	run.procStatus.active = true
+	startTime := runtimeData.procStatus.startTime
+	go func() {
+		runtimeData.mux.Lock()
+		bSameRun := runtimeData.procStatus.active && runtimeData.procStatus.startTime.Equal(startTime)
+		runtimeData.mux.Unlock()
+		if !bSameRun {
+			gpclogging.Debug("Process <%s>, PID=<%d> has exited before it was killed.", procName, run.procStatus.pid)
+			return
+		}
+		if _, err := gPlatform.killTree(context.Background(), run, run.procConfig.GracePeriod()); err != nil {
+			gpclogging.Task(procName).Error("Process <%s>, PID=<%d> could not be killed!! <%s>", procName, run.procStatus.pid, err.Error())
 		}
+	}()
+}
 
-		// Set flags and close log file
-		runtimeData.procStatus.active = false
-		if runtimeData.procLog != nil {
-			runtimeData.procLog.Close()
+//waitForExit waits up to the given time for a process to exit, returns false if it did not
+//-------------------------------------------------------------------
+func waitForExit(ctx context.Context, runtimeData *GPCProcRuntimeData, d time.Duration) bool {
+	deadline := time.Now().Add(d)
+	for gPlatform.isRunning(runtimeData) == nil {
+		if time.Now().After(deadline) || !sleepContext(ctx, 100*time.Millisecond) {
+			return false
 		}
 	}
-
-	gpclogging.Debug("Leave ShutdownAll()")
+	return true
 }
 
 //StartProcessesFromConfig reads the configuration and starts processes
@@ -93,123 +251,690 @@ func StartProcessesFromConfig(configData *gpcconfig.ConfigData, shutdownWaitGrou
 		gProcRuntimeData[configData.Tasks[configIndex].Name] = NewProcRuntimeData(&configData.Tasks[configIndex])
 		gpclogging.Debug("Config check for prog <%s>: ProcPath =<%s>.", gProcRuntimeData[configData.Tasks[configIndex].Name].procConfig.Name, gProcRuntimeData[configData.Tasks[configIndex].Name].procConfig.StartPath)
 	}
+	// Restart budgets and processes still running from the previous controller
+	restoreState(configData)
+	for _, runtimeData := range gProcRuntimeData {
+		if !runtimeData.procStatus.active {
+			removeStalePIDFile(runtimeData)
+		}
+	}
 	gRuntimeDatatMux.Unlock()
 
 	// Start a goroutine that checks the running processes in background
 	gStopMux.Lock()
 	gStopMon = false
+	gShutdownCtx, gShutdownCancel = context.WithCancel(context.Background())
+	monitorDone := make(chan struct{})
+	gMonitorDone = monitorDone
 	gStopMux.Unlock()
-
+	gShutdownWaitGroup = shutdownWaitGroup
+	setCalendars(configData)
+	setShutdownTierTimeout(configData)
+	setResourceSampling(configData)
+	setStartLimit(configData)
+	setHeartbeatAddress(configData)
+	setPortRange(configData)
+
+	shutdownWaitGroup.Add(1)
+	go func() {
+		monitorProcesses()
+		close(monitorDone)
+		shutdownWaitGroup.Done()
+	}()
+	shutdownWaitGroup.Add(1)
 	go func() {
-		shutdownWaitGroup.Add(1)
-		monitorProcesses(shutdownWaitGroup)
+		watchTaskPaths()
 		shutdownWaitGroup.Done()
 	}()
 
 	for procName, runtimeData := range gProcRuntimeData {
-		gpclogging.Debug("Working on inital start for <%s>. WaitForExitTimeout = <%d>", procName, runtimeData.procConfig.WaitForExitTimeoutS)
-		// now start all configured processes, differentiate wait and nowait here
-		if runtimeData.procConfig.WaitForExitTimeoutS > 0 {
-			go func(procName string, runtimeData *GPCProcRuntimeData, shutdownWaitGroup *sync.WaitGroup) {
-				// Pause here until Start delay is reached
-				gpclogging.Debug("Process <%s> is configured with start delay <%d>s. Will now wait if configured so.",
-					procName, runtimeData.procConfig.StartDelayS)
-				// TODO - Change to a select statement that waits for termination or timeout
-				time.Sleep(time.Duration(runtimeData.procConfig.StartDelayS) * time.Second)
-				// Now go ahead
-				gpclogging.Debug("Launching wait process...")
-				shutdownWaitGroup.Add(1)
-				launchProcessAndWait(procName)
-				shutdownWaitGroup.Done()
-			}(procName, runtimeData, shutdownWaitGroup)
+		gpclogging.Debug("Working on inital start for <%s>. WaitForExitTimeout = <%s>", procName, runtimeData.procConfig.WaitForExitTimeout)
+		// Re-attached, still running or quarantined according to the state file
+		if runtimeData.procStatus.active || runtimeData.procStatus.stopped || runtimeData.procStatus.quarantined {
+			gpclogging.Debug("Process <%s> is not started, its state was restored.", procName)
+			continue
+		}
+		// Tasks of a chain are only started by their predecessor
+		if configData.IsFollowUpTask(procName) {
+			gpclogging.Debug("Process <%s> is a follow up task, it is started by its predecessor.", procName)
+			continue
+		}
+		// Cold standbys wait for their primary to fail
+		if runtimeData.procConfig.IsColdStandby() {
+			gpclogging.Debug("Process <%s> is a cold standby for <%s>, it is started on takeover.", procName, runtimeData.procConfig.StandbyFor)
+			continue
+		}
+		// Scheduled tasks wait for their time
+		if len(runtimeData.procConfig.Schedule) > 0 {
+			startScheduler(procName, runtimeData)
+			continue
+		}
+		startWithDelay(procName, runtimeData, shutdownWaitGroup)
+	}
+	armExitWhenDone(configData)
+	gpclogging.Debug("Leaving StartProcessesFromConfig()")
+}
+
+//startWithDelay launches a process in background once its configured start delay has passed
+//-------------------------------------------------------------------
+func startWithDelay(procName string, runtimeData *GPCProcRuntimeData, shutdownWaitGroup *sync.WaitGroup) {
 
+	// Counted right away, a follow up task is counted before its predecessor ends
+	bWait := runtimeData.procConfig.WaitForExitTimeout.Duration > 0
+	if bWait {
+		gPendingWaitRuns.Add(1)
+	}
+	gPendingStarts.Add(1)
+	shutdownWaitGroup.Add(1)
+	go func() {
+		defer shutdownWaitGroup.Done()
+		defer gPendingStarts.Add(-1)
+		if bWait {
+			defer gPendingWaitRuns.Done()
+		}
+
+		// Pause here until Start delay is reached
+		gpclogging.Debug("Process <%s> is configured with start delay <%s>. Will now wait if configured so.",
+			procName, runtimeData.procConfig.StartDelay)
+		if !sleepUnlessStopping(runtimeData.procConfig.StartDelay.Duration) {
+			return
+		}
+
+		// Now go ahead, differentiate wait and nowait here
+		if bWait {
+			gpclogging.Debug("Launching wait process...")
+			launchProcessAndWait(procName)
 		} else {
-			go func(procName string, runtimeData *GPCProcRuntimeData, shutdownWaitGroup *sync.WaitGroup) {
-				// Pause here until Start delay is reached
-				gpclogging.Debug("Process <%s> is configured with start delay <%d>s. Will now wait if configured so.",
-					procName, runtimeData.procConfig.StartDelayS)
-				// TODO - Change to a select statement that waits for termination or timeout
-				time.Sleep(time.Duration(runtimeData.procConfig.StartDelayS) * time.Second)
-				// Now go ahead
-				gpclogging.Debug("Launching no-wait process...")
-				shutdownWaitGroup.Add(1)
-				launchProcess(procName)
-				shutdownWaitGroup.Done()
-			}(procName, runtimeData, shutdownWaitGroup)
+			gpclogging.Debug("Launching no-wait process...")
+			launchProcess(procName)
+		}
+	}()
+}
+
+//ApplyConfig compares a new configuration against the running processes. New tasks are
+//started, removed tasks are stopped and tasks with a changed definition are restarted.
+//Untouched tasks keep running. Returns a description of the actions taken.
+//#########################################################
+func ApplyConfig(configData *gpcconfig.ConfigData) []string {
+	return applyConfig(configData, nil)
+}
+
+// applyConfig is ApplyConfig, the processes named in stoppedNames are stopped and not started
+//-------------------------------------------------------------------
+func applyConfig(configData *gpcconfig.ConfigData, stoppedNames map[string]bool) []string {
+	gpclogging.Debug("Entering ApplyConfig()")
+
+	setCalendars(configData)
+	setShutdownTierTimeout(configData)
+	setResourceSampling(configData)
+	setStartLimit(configData)
+	setHeartbeatAddress(configData)
+	setPortRange(configData)
+
+	var actions []string
+	var toStop, toStart []string
+	oldRuntimeData := make(map[string]*GPCProcRuntimeData)
+
+	gRuntimeDatatMux.Lock()
+	// Build a new map instead of changing the current one, the monitor might be iterating it
+	newProcRuntimeData := make(map[string]*GPCProcRuntimeData)
+	for configIndex := range configData.Tasks {
+		newTask := &configData.Tasks[configIndex]
+		current, found := gProcRuntimeData[newTask.Name]
+
+		switch {
+		case !found:
+			newProcRuntimeData[newTask.Name] = NewProcRuntimeData(newTask)
+			toStart = append(toStart, newTask.Name)
+			actions = append(actions, fmt.Sprintf("added   %s", newTask.Name))
+		case current.keepConfig(newTask):
+			newProcRuntimeData[newTask.Name] = current
+		default:
+			oldRuntimeData[newTask.Name] = current
+			newProcRuntimeData[newTask.Name] = NewProcRuntimeData(newTask)
+			toStop = append(toStop, newTask.Name)
+			toStart = append(toStart, newTask.Name)
+			actions = append(actions, fmt.Sprintf("changed %s", newTask.Name))
 		}
 	}
-	gpclogging.Debug("Leaving StartProcessesFromConfig()")
+	for procName, runtimeData := range gProcRuntimeData {
+		if _, found := newProcRuntimeData[procName]; !found {
+			oldRuntimeData[procName] = runtimeData
+			toStop = append(toStop, procName)
+			actions = append(actions, fmt.Sprintf("removed %s", procName))
+		}
+	}
+	gProcRuntimeData = newProcRuntimeData
+	gRuntimeDatatMux.Unlock()
+
+	for _, procName := range toStop {
+		gpclogging.Info("Config reload: stopping process <%s>.", procName)
+		stopProcess(context.Background(), procName, oldRuntimeData[procName])
+		oldRuntimeData[procName].mux.Lock()
+		oldRuntimeData[procName].closeListeners()
+		oldRuntimeData[procName].releasePorts()
+		oldRuntimeData[procName].mux.Unlock()
+	}
+	for procName := range stoppedNames {
+		runtimeData, found := newProcRuntimeData[procName]
+		if !found {
+			continue
+		}
+		runtimeData.mux.Lock()
+		bActive := runtimeData.procStatus.active
+		runtimeData.procStatus.stopped = true
+		runtimeData.mux.Unlock()
+		if bActive {
+			gpclogging.Info("Config reload: stopping process <%s> as requested.", procName)
+			stopProcess(context.Background(), procName, runtimeData)
+			actions = append(actions, fmt.Sprintf("stopped %s", procName))
+		}
+	}
+	for _, procName := range toStart {
+		newProcRuntimeData[procName].mux.Lock()
+		bStopped := newProcRuntimeData[procName].procStatus.stopped
+		newProcRuntimeData[procName].mux.Unlock()
+		if bStopped {
+			continue
+		}
+		if configData.IsFollowUpTask(procName) || newProcRuntimeData[procName].procConfig.IsColdStandby() {
+			continue
+		}
+		if len(newProcRuntimeData[procName].procConfig.Schedule) > 0 {
+			gpclogging.Info("Config reload: scheduling process <%s>.", procName)
+			startScheduler(procName, newProcRuntimeData[procName])
+			continue
+		}
+		gpclogging.Info("Config reload: starting process <%s>.", procName)
+		startWithDelay(procName, newProcRuntimeData[procName], gShutdownWaitGroup)
+	}
+
+	sort.Strings(actions)
+	gpclogging.Info("Config reload done, <%d> changes applied.", len(actions))
+	gpclogging.Debug("Leaving ApplyConfig()")
+	return actions
+}
+
+// keepConfig switches a process to the reloaded config of its task if that is unchanged.
+// Reports false if the config changed and the process has to be replaced.
+//-------------------------------------------------------------------
+func (r *GPCProcRuntimeData) keepConfig(newTask *gpcconfig.ProcessConfig) bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if !reflect.DeepEqual(*r.procConfig, *newTask) {
+		return false
+	}
+	r.procConfig = newTask
+	return true
+}
+
+//StartProcess starts a single configured process that is currently not running.
+//Wait processes are run in background, so this returns once the launch was triggered.
+//The context only bounds the call, the started process is not tied to it.
+//#########################################################
+func StartProcess(ctx context.Context, procName string) error {
+	gpclogging.Debug("Entering StartProcess() for process <%s>", procName)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	runtimeData, err := getRuntimeData(procName)
+	if err != nil {
+		return err
+	}
+	// Checked and reset at once, of concurrent starts only one gets through
+	runtimeData.mux.Lock()
+	switch {
+	case runtimeData.procStatus.active:
+		runtimeData.mux.Unlock()
+		return newProcessError(procName, ErrAlreadyRunning, nil)
+	case len(runtimeData.procStatus.waitingOn) > 0:
+		sWaitingOn := runtimeData.procStatus.waitingOn
+		runtimeData.mux.Unlock()
+		return newProcessError(procName, ErrWaitingOnCondition, fmt.Errorf("waiting on: %s", sWaitingOn))
+	case runtimeData.procStatus.launchPending:
+		runtimeData.mux.Unlock()
+		return newProcessError(procName, ErrAlreadyRunning, errors.New("it is being started"))
+	case runtimeData.procStatus.stopPending:
+		runtimeData.mux.Unlock()
+		return newProcessError(procName, ErrAlreadyRunning, errors.New("it is being stopped"))
+	}
+
+	// A manual start resets the error state, the restart budget, the quarantine and a maintenance hold
+	runtimeData.procStatus.stopped = false
+	runtimeData.procStatus.lastError = nil
+	runtimeData.procStatus.timeout = false
+	runtimeData.procStatus.done = false
+	runtimeData.procStatus.restartCount = 0
+	runtimeData.procStatus.restartTimes = nil
+	runtimeData.procStatus.quarantined = false
+	runtimeData.procStatus.heldForMaintenance = false
+	runtimeData.procStatus.launchPending = true
+	runtimeData.mux.Unlock()
+
+	if runtimeData.procConfig.WaitForExitTimeout.Duration > 0 {
+		gPendingStarts.Add(1)
+		gShutdownWaitGroup.Add(1)
+		go func() {
+			launchProcessAndWait(procName)
+			runtimeData.mux.Lock()
+			runtimeData.procStatus.launchPending = false
+			runtimeData.mux.Unlock()
+			gPendingStarts.Add(-1)
+			gShutdownWaitGroup.Done()
+		}()
+	} else {
+		launchProcess(procName)
+		runtimeData.mux.Lock()
+		runtimeData.procStatus.launchPending = false
+		err = runtimeData.procStatus.lastError
+		runtimeData.mux.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	gpclogging.Debug("Leaving StartProcess()")
+	return nil
+}
+
+//StopProcess stops a single running process. It will not be restarted automatically.
+//If the context is cancelled while waiting for the process to exit, it is killed at once.
+//#########################################################
+func StopProcess(ctx context.Context, procName string) error {
+	gpclogging.Debug("Entering StopProcess() for process <%s>", procName)
+
+	runtimeData, err := getRuntimeData(procName)
+	if err != nil {
+		return err
+	}
+	runtimeData.mux.Lock()
+	bActive := runtimeData.procStatus.active
+	runtimeData.mux.Unlock()
+	if !bActive {
+		return newProcessError(procName, ErrNotRunning, nil)
+	}
+	stopProcess(ctx, procName, runtimeData)
+	// Its ListenSockets were kept for the restarts, nobody serves them now
+	runtimeData.mux.Lock()
+	runtimeData.closeListeners()
+	runtimeData.releasePorts()
+	runtimeData.mux.Unlock()
+
+	gpclogging.Debug("Leaving StopProcess()")
+	return nil
+}
+
+//RestartProcess stops a process (if running) and starts it again. It is not started
+//again if the context was cancelled meanwhile. With RestartStrategy start-first the new run
+//is started first and the old one stopped once the new one is ready.
+//#########################################################
+func RestartProcess(ctx context.Context, procName string) error {
+	gpclogging.Debug("Entering RestartProcess() for process <%s>", procName)
+
+	runtimeData, err := getRuntimeData(procName)
+	if err != nil {
+		return err
+	}
+	runtimeData.mux.Lock()
+	bActive := runtimeData.procStatus.active
+	bStartsFirst := startsFirst(runtimeData)
+	runtimeData.mux.Unlock()
+	if bActive && bStartsFirst {
+		return restartStartFirst(ctx, procName, runtimeData, "manual restart")
+	}
+	// Nothing runs between stop and start, the controller must not take that as all done
+	gPendingStarts.Add(1)
+	defer gPendingStarts.Add(-1)
+	if bActive {
+		stopProcess(ctx, procName, runtimeData)
+		runtimeData.mux.Lock()
+		publishProcessEvent(EventProcessRestarted, procName, runtimeData, "manual restart")
+		runtimeData.mux.Unlock()
+	}
+
+	gpclogging.Debug("Leaving RestartProcess()")
+	return StartProcess(ctx, procName)
+}
+
+//ResumeProcess lets a suspended process continue, or starts a process again that was quarantined
+//for a crash loop, with a fresh restart budget. Fails with ErrNotQuarantined for any other process.
+//#########################################################
+func ResumeProcess(ctx context.Context, procName string) error {
+	gpclogging.Debug("Entering ResumeProcess() for process <%s>", procName)
+
+	runtimeData, err := getRuntimeData(procName)
+	if err != nil {
+		return err
+	}
+	runtimeData.mux.Lock()
+	bSuspended := runtimeData.procStatus.suspended
+	bQuarantined := runtimeData.procStatus.quarantined
+	runtimeData.mux.Unlock()
+	if bSuspended {
+		return resumeSuspended(procName, runtimeData)
+	}
+	if !bQuarantined {
+		return newProcessError(procName, ErrNotQuarantined, nil)
+	}
+	gpclogging.Task(procName).Info("Process <%s> is resumed from quarantine.", procName)
+
+	gpclogging.Debug("Leaving ResumeProcess()")
+	return StartProcess(ctx, procName)
+}
+
+//GetStatusText returns a human readable status line for each configured process
+//#########################################################
+func GetStatusText() []string {
+	var lines []string
+	for _, procStatus := range GetStatus() {
+		line := fmt.Sprintf("%-24s %-11s PID=%-8d Restarts=%d", procStatus.Name, procStatus.State,
+			procStatus.PID, procStatus.RestartCount)
+		if procStatus.Adopted {
+			line += "  adopted"
+		}
+		if !procStatus.Usage.Sampled.IsZero() {
+			line += fmt.Sprintf("  CPU=%.1f%% Mem=%dMB Handles=%d", procStatus.Usage.CPUPercent,
+				procStatus.Usage.MemoryBytes/(1024*1024), procStatus.Usage.Handles)
+		}
+		if len(procStatus.Ports) > 0 {
+			line += "  ports: " + portsText(procStatus.Ports)
+		}
+		if len(procStatus.WaitingOn) > 0 {
+			line += "  waiting on: " + procStatus.WaitingOn
+		}
+		if len(procStatus.LastError) > 0 {
+			line += "  " + procStatus.LastError
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+//GetProcessLogFile returns the path of the current output log file of a process
+//#########################################################
+func GetProcessLogFile(procName string) (string, error) {
+	runtimeData, err := getRuntimeData(procName)
+	if err != nil {
+		return "", err
+	}
+	runtimeData.mux.Lock()
+	defer runtimeData.mux.Unlock()
+
+	if runtimeData.procLog == nil {
+		return "", newProcessError(procName, ErrNoLogFile, nil)
+	}
+	return runtimeData.procLog.Name(), nil
+}
+
+//TailProcessLog returns the last lines of the current output log file of a process
+//#########################################################
+func TailProcessLog(procName string, numLines int) ([]string, error) {
+	logFile, err := GetProcessLogFile(procName)
+	if err != nil {
+		return nil, err
+	}
+	return tailFile(logFile, numLines)
+}
+
+// tailFile returns the last numLines lines of a file
+//------------------------------------------------------------------------------
+func tailFile(filePath string, numLines int) ([]string, error) {
+	const maxTailBytes = 1024 * 1024
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Only look at the end of big files
+	if info, err := f.Stat(); err == nil && info.Size() > maxTailBytes {
+		f.Seek(-maxTailBytes, io.SeekEnd)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > numLines {
+			lines = lines[1:]
+		}
+	}
+	return lines, scanner.Err()
+}
+
+//LastError returns why a process failed, nil if it did not fail (yet). The error is a *ProcessError,
+//e.g. errors.Is(err, ErrRestartLimit) tells that the process will not be restarted any more.
+//For an unknown process the error is ErrUnknownProcess.
+//#########################################################
+func LastError(procName string) error {
+	runtimeData, err := getRuntimeData(procName)
+	if err != nil {
+		return err
+	}
+	runtimeData.mux.Lock()
+	defer runtimeData.mux.Unlock()
+	return runtimeData.procStatus.lastError
+}
+
+//sleepUnlessStopping waits for the given time. Returns false if the controller
+//started to shut down in the meantime.
+//-------------------------------------------------------------------
+func sleepUnlessStopping(d time.Duration) bool {
+	return sleepContext(shutdownContext(), d)
+}
+
+//sleepContext waits for the given time. Returns false at once if the context is cancelled.
+//-------------------------------------------------------------------
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+//ShutdownContext returns the context that is cancelled when the controller shuts down,
+//for requests that must not hold up the shutdown
+//#########################################################
+func ShutdownContext() context.Context {
+	return shutdownContext()
+}
+
+//shutdownContext returns the context that is cancelled when the controller shuts down
+//-------------------------------------------------------------------
+func shutdownContext() context.Context {
+	gStopMux.Lock()
+	defer gStopMux.Unlock()
+	return gShutdownCtx
+}
+
+//isStopping reports whether the controller is shutting down
+//-------------------------------------------------------------------
+func isStopping() bool {
+	gStopMux.Lock()
+	defer gStopMux.Unlock()
+	return gStopMon
+}
+
+//getRuntimeData looks up the runtime data of a process by name
+//-------------------------------------------------------------------
+func getRuntimeData(procName string) (*GPCProcRuntimeData, error) {
+	gRuntimeDatatMux.Lock()
+	defer gRuntimeDatatMux.Unlock()
+
+	runtimeData, found := gProcRuntimeData[procName]
+	if !found {
+		return nil, newProcessError(procName, ErrUnknownProcess, nil)
+	}
+	return runtimeData, nil
+}
+
+//currentRuntimeData returns the runtime data of all processes. The map is never changed, a
+//reload replaces it, so it can be iterated without holding the lock.
+//-------------------------------------------------------------------
+func currentRuntimeData() map[string]*GPCProcRuntimeData {
+	gRuntimeDatatMux.Lock()
+	defer gRuntimeDatatMux.Unlock()
+	return gProcRuntimeData
 }
 
 //monitorProcesses checks the status of each process every 100 ms
 //#########################################################
-func monitorProcesses(shutdownWaitGroup *sync.WaitGroup) {
+func monitorProcesses() {
 	gpclogging.Debug("Entering monitorProcesses().")
 
-	// run forever until application is closed
-	for gStopMon == false {
+	// run until the controller shuts down or hands off, which ends the wait between the checks at once
+	ctx := shutdownContext()
+	for ctx.Err() == nil {
 		// gpclogging.Debug("Now checking process status.")
 
-		for procName, runtimeData := range gProcRuntimeData {
+		for procName, runtimeData := range currentRuntimeData() {
 			// Do this only for active processes that were started with No-Wait
-			if runtimeData.procConfig.WaitForExitTimeoutS < 1 &&
-				runtimeData.procCmd != nil &&
-				runtimeData.procStatus.active {
-
-				// Check if the process is still running
-				//gpclogging.Debug("Checking process <%s>.", procName)
-				err := checkProcessRunning(runtimeData.procStatus.pid)
-
-				if err != nil {
-					// Process has exited
-					gpclogging.Warn("Process <%s>, PID=<%d> has exited.", procName,
-						runtimeData.procStatus.pid)
-
-					// Set flags and close log file
-					runtimeData.procStatus.active = false
-					if runtimeData.procLog != nil {
-						runtimeData.procLog.Close()
-					}
-
-					// Now should check if the process shall be automatically restarted
-					if runtimeData.procConfig.MaxRestarts > 0 {
-						if runtimeData.procStatus.restartCount < runtimeData.procConfig.MaxRestarts {
-							go func(procName string, runtimeData *GPCProcRuntimeData, shutdownWaitGroup *sync.WaitGroup) {
-								runtimeData.procStatus.restartCount++
-								gpclogging.Info("Will now try to restart no-wait process <%s>. This is attempt No <%d>..", procName, runtimeData.procStatus.restartCount)
-								shutdownWaitGroup.Add(1)
-								launchProcess(procName)
-								shutdownWaitGroup.Done()
-							}(procName, runtimeData, shutdownWaitGroup)
-						} else {
-							gpclogging.Error("Process <%s> has reached the max restart count of <%d>. WILL NOT RESTART THE PROCESS.",
-								procName, runtimeData.procConfig.MaxRestarts)
-							runtimeData.procStatus.error = true
-						}
-					}
+			if runtimeData.procConfig.WaitForExitTimeout.Duration != 0 || !handleExit(procName, runtimeData) {
+				continue
+			}
+
+			// A manual start may reset the restart budget meanwhile
+			runtimeData.mux.Lock()
+			bDone := runtimeData.procStatus.done
+			exitCode := runtimeData.procStatus.exitCode
+			restartCount := runtimeData.procStatus.restartCount
+			runtimeData.mux.Unlock()
+
+			// A deliberate end is not restarted and no standby takes over, a failure a restart does not fix
+			// is not restarted either
+			if bDone {
+				continue
+			}
+			if runtimeData.procConfig.IsNoRestartExit(exitCode) {
+				gpclogging.Task(procName).Error("Process <%s> exited with exit code <%d>, which is configured to not restart. WILL NOT RESTART THE PROCESS.",
+					procName, exitCode)
+				runtimeData.mux.Lock()
+				runtimeData.procStatus.lastError = newProcessError(procName, ErrNoRestartExit, fmt.Errorf("exit code %d", exitCode))
+				publishProcessEvent(EventProcessRestartLimit, procName, runtimeData, runtimeData.procStatus.lastError.Error())
+				runtimeData.mux.Unlock()
+				promoteStandbys(runtimeData)
+				continue
+			}
+
+			// Now should check if the process shall be automatically restarted, otherwise a standby takes over
+			if runtimeData.procConfig.MaxRestarts == 0 {
+				promoteStandbys(runtimeData)
+			} else {
+				if !shouldRestart(runtimeData.procConfig, restartCount) {
+					gpclogging.Task(procName).Error("Process <%s> has reached the max restart count of <%d>. WILL NOT RESTART THE PROCESS.",
+						procName, runtimeData.procConfig.MaxRestarts)
+					runtimeData.mux.Lock()
+					runtimeData.procStatus.lastError = newProcessError(procName, ErrRestartLimit, nil)
+					publishProcessEvent(EventProcessRestartLimit, procName, runtimeData, runtimeData.procStatus.lastError.Error())
+					runtimeData.mux.Unlock()
+					promoteStandbys(runtimeData)
+				} else if runtimeData.crashLoopDetected() {
+					quarantine(procName, runtimeData)
+				} else if inMaintenance(procName) {
+					holdForMaintenance(procName, runtimeData)
+				} else {
+					restartExited(procName, runtimeData)
 				}
 			}
 		}
 
-		// Sleep for 100ms
-		// TODO - Change to a select statement that waits for termination or timeout
-		time.Sleep(100 * time.Millisecond)
+		checkMaintenance()
+		checkMemoryLimits()
+		checkScheduledRestarts()
+		checkMaxRuntimes()
+		checkHungProcesses()
+		sampleResources()
+		checkAllDone()
+		saveState()
+
+		sleepContext(ctx, monitorInterval)
 	}
 	gpclogging.Debug("Leaving monitorProcesses().")
 }
 
-//checkProcessRunning checks if a program for a given PID is still active
-//this a manual fix for https://github.com/golang/go/issues/33814
-//#########################################################
-func checkProcessRunning(pid int) (err error) {
-	const da = syscall.STANDARD_RIGHTS_READ | syscall.PROCESS_QUERY_INFORMATION | syscall.SYNCHRONIZE
-	h, e := syscall.OpenProcess(da, true, uint32(pid))
-	defer syscall.CloseHandle(h)
+//restartExited restarts a no-wait process that exited on its own in background, as the next of its
+//MaxRestarts attempts
+//-------------------------------------------------------------------
+func restartExited(procName string, runtimeData *GPCProcRuntimeData) {
+	gPendingStarts.Add(1)
+	gShutdownWaitGroup.Add(1)
+	go func() {
+		runtimeData.mux.Lock()
+		runtimeData.procStatus.restartCount++
+		restartCount := runtimeData.procStatus.restartCount
+		publishProcessEvent(EventProcessRestarted, procName, runtimeData, fmt.Sprintf("restart attempt %d", restartCount))
+		runtimeData.mux.Unlock()
+		gpclogging.Task(procName).Info("Will now try to restart no-wait process <%s>. This is attempt No <%d>..", procName, restartCount)
+		launchProcess(procName)
+		gPendingStarts.Add(-1)
+		gShutdownWaitGroup.Done()
+	}()
+}
+
+//handleExit checks whether an active no-wait process has exited and if so, marks it inactive and
+//cleans up after it. Reports whether it exited.
+//-------------------------------------------------------------------
+func handleExit(procName string, runtimeData *GPCProcRuntimeData) bool {
+	runtimeData.mux.Lock()
+	defer runtimeData.mux.Unlock()
 
-	if e != nil {
-		return os.NewSyscallError("OpenProcess", e)
+	// Check if the process is still running
+	if runtimeData.procCmd == nil || !runtimeData.procStatus.active || gPlatform.isRunning(runtimeData) == nil {
+		return false
 	}
-	return nil
+	// Process has exited, one of its SuccessExitCodes means it is done
+	runtimeData.procStatus.active = false
+	runtimeData.procStatus.suspended = false
+	if runtimeData.procConfig.IsSuccessExit(runtimeData.procStatus.exitCode) {
+		gpclogging.Task(procName).Info("Process <%s>, PID=<%d> has exited with success exit code <%d>, it is done.", procName,
+			runtimeData.procStatus.pid, runtimeData.procStatus.exitCode)
+		runtimeData.procStatus.done = true
+		publishProcessEvent(EventProcessExited, procName, runtimeData, "exited with a success exit code")
+	} else {
+		gpclogging.Task(procName).Warn("Process <%s>, PID=<%d> has exited.", procName,
+			runtimeData.procStatus.pid)
+		publishFailedExit(procName, runtimeData, "exited")
+	}
+
+	// Close log file
+	runtimeData.closeLogs()
+	runtimeData.closeStdin()
+	releaseMutexGroup(runtimeData)
+	gPlatform.closeTree(runtimeData)
+	removePIDFile(runtimeData)
+	return true
+}
+
+//crashLoopDetected records a restart of a process and reports whether it is in a crash loop
+//-------------------------------------------------------------------
+func (r *GPCProcRuntimeData) crashLoopDetected() bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return crashLoopDetected(r.procConfig, &r.procStatus.restartTimes, gClock.Now())
+}
+
+//quarantine stops the restarts of a process in a crash loop until it is resumed, a standby takes over
+//-------------------------------------------------------------------
+func quarantine(procName string, runtimeData *GPCProcRuntimeData) {
+	gpclogging.Task(procName).Error("Process <%s> crashed more than <%d> times within <%s>. QUARANTINED, it is not restarted until resumed.",
+		procName, runtimeData.procConfig.CrashLoopRestarts, crashLoopWindow(runtimeData.procConfig).String())
+	runtimeData.mux.Lock()
+	runtimeData.procStatus.quarantined = true
+	runtimeData.procStatus.lastError = newProcessError(procName, ErrQuarantined, nil)
+	publishProcessEvent(EventProcessQuarantined, procName, runtimeData, runtimeData.procStatus.lastError.Error())
+	runtimeData.mux.Unlock()
+	promoteStandbys(runtimeData)
 }
 
 //launchProcess launches a process, no waiting here
@@ -217,27 +942,99 @@ func checkProcessRunning(pid int) (err error) {
 func launchProcess(procName string) {
 	gpclogging.Debug("Entering launchProcess()")
 
-	// Lock configuration until function ended
-	gRuntimeDatatMux.Lock()
-	gRuntimeDatatMux.Unlock()
+	runtimeData, err := getRuntimeData(procName)
+	if err != nil {
+		gpclogging.Debug("Leaving launchProcess()")
+		return
+	}
+
+	// Not holding a mutex group or start slot while waiting
+	if !waitForPreconditions(procName, runtimeData) {
+		gpclogging.Debug("Leaving launchProcess()")
+		return
+	}
+
+	// Processes sharing a mutex group never run at the same time
+	if !acquireMutexGroup(runtimeData) {
+		gpclogging.Debug("Leaving launchProcess()")
+		return
+	}
+
+	// An instance started outside of the controller is taken over instead of starting another one
+	if adoptRunningProcess(procName, runtimeData) {
+		gpclogging.Debug("Leaving launchProcess()")
+		return
+	}
 
-	gpclogging.Info("Will now try to launch process <%s>.", procName)
+	gpclogging.Task(procName).Info("Will now try to launch process <%s>.", procName)
+
+	// Refuse to start a binary that does not match its checksum, or while its ports are taken
+	err = verifyExecutable(runtimeData)
+	if err == nil {
+		err = resolvePortConflicts(procName, runtimeData)
+	}
+	if err != nil {
+		gpclogging.Task(procName).Error("REFUSING TO START process <%s>: <%s>", procName, err.Error())
+		runtimeData.mux.Lock()
+		runtimeData.procStatus.lastError = newProcessError(procName, ErrStartFailed, err)
+		publishProcessEvent(EventProcessStartFailed, procName, runtimeData, err.Error())
+		runtimeData.mux.Unlock()
+		releaseMutexGroup(runtimeData)
+		promoteStandbys(runtimeData)
+		gpclogging.Debug("Leaving launchProcess()")
+		return
+	}
+
+	// Startup.MaxConcurrentStarts counts the launch itself, not the time the process runs
+	if !gStartSlots.acquire(shutdownContext(), procName) {
+		releaseMutexGroup(runtimeData)
+		gpclogging.Debug("Leaving launchProcess()")
+		return
+	}
 
 	// Start process - fire and forget
-	gProcRuntimeData[procName].procCmd = exec.Command(gProcRuntimeData[procName].procConfig.StartPath)
-	doProcessSettings(gProcRuntimeData[procName])
+	runtimeData.mux.Lock()
+	if runtimeData.procStatus.active {
+		// Started by another launch meanwhile, its command must not be replaced
+		runtimeData.mux.Unlock()
+		gStartSlots.release()
+		gpclogging.Task(procName).Warn("Process <%s> was started meanwhile, not launching it again.", procName)
+		gpclogging.Debug("Leaving launchProcess()")
+		return
+	}
+	runtimeData.procCmd = exec.Command(runtimeData.procConfig.Executable())
+	doProcessSettings(runtimeData)
 
-	err := gProcRuntimeData[procName].procCmd.Start()
+	err = gPlatform.start(runtimeData)
+	runtimeData.capture.started()
 
 	if err != nil {
-		gpclogging.Error("Could not start process <%s>, Error message is <%s>", procName, err)
-		gProcRuntimeData[procName].procStatus.error = true
+		gpclogging.Task(procName).Error("Could not start process <%s>, Error message is <%s>", procName, err)
+		runtimeData.procStatus.lastError = newProcessError(procName, ErrStartFailed, err)
+		publishProcessEvent(EventProcessStartFailed, procName, runtimeData, err.Error())
 	} else {
-		gpclogging.Info("Starting process <%s> OK!", procName)
-		gProcRuntimeData[procName].procStatus.pid = gProcRuntimeData[procName].procCmd.Process.Pid
-		gProcRuntimeData[procName].procStatus.active = true
+		gpclogging.Task(procName).Info("Starting process <%s> OK!", procName)
+		runtimeData.procStatus.pid = runtimeData.procCmd.Process.Pid
+		runtimeData.procStatus.startTime = time.Now()
+		runtimeData.procStatus.exitCode = -1
+		runtimeData.procStatus.active = true
+		runtimeData.procStatus.hung = false
+		runtimeData.procStatus.notifiedReady = false
+		runtimeData.procStatus.notifiedStopping = false
+		recordLaunchContext(runtimeData)
+		writePIDFile(runtimeData)
+		applyWindowSettings(runtimeData)
+		runtimeData.procCmd.Process.Release()
+		publishProcessEvent(EventProcessStarted, procName, runtimeData, "")
+	}
+	runtimeData.mux.Unlock()
+	gStartSlots.release()
+
+	// Not under the lock of the process, promoting a standby launches another one
+	if err != nil {
+		releaseMutexGroup(runtimeData)
+		promoteStandbys(runtimeData)
 	}
-	gProcRuntimeData[procName].procCmd.Process.Release()
 
 	gpclogging.Debug("Leaving launchProcess()")
 }
@@ -247,50 +1044,197 @@ func launchProcess(procName string) {
 func launchProcessAndWait(procName string) {
 	gpclogging.Debug("Entering launchProcess()")
 
-	// Lock configuration until function ended
-	gRuntimeDatatMux.Lock()
-	defer gRuntimeDatatMux.Unlock()
+	// Processes sharing a mutex group never run at the same time, the preconditions are waited for first
+	runtimeData, err := getRuntimeData(procName)
+	if err != nil || !waitForPreconditions(procName, runtimeData) || !acquireMutexGroup(runtimeData) {
+		gpclogging.Debug("Leaving launchProcessAndWait()")
+		return
+	}
+	defer releaseMutexGroup(runtimeData)
+
+	// Run the process, one-shot jobs may be retried when they failed
+	succeeded, exitCode := runProcessAndWait(procName, runtimeData)
+	retries := uint32(0)
+	for !succeeded && retries < runtimeData.procConfig.Retries {
+		if !runtimeData.procConfig.ShouldRetry(exitCode) {
+			gpclogging.Info("Process <%s> failed with exit code <%d>, which is not configured for a retry.", procName, exitCode)
+			break
+		}
+		retries++
+		gpclogging.Info("Process <%s> failed, retry <%d> of <%d> in <%s>.", procName, retries, runtimeData.procConfig.Retries,
+			runtimeData.procConfig.RetryDelay)
+		if !sleepUnlessStopping(runtimeData.procConfig.RetryDelay.Duration) {
+			break
+		}
+		runtimeData.mux.Lock()
+		runtimeData.procStatus.timeout = false
+		runtimeData.procStatus.done = false
+		runtimeData.procStatus.lastError = nil
+		runtimeData.mux.Unlock()
+		succeeded, exitCode = runProcessAndWait(procName, runtimeData)
+	}
+	if !succeeded && runtimeData.procConfig.Retries > 0 {
+		gpclogging.Task(procName).Error("Process <%s> failed, giving up after <%d> retries.", procName, retries)
+		runtimeData.mux.Lock()
+		if runtimeData.procStatus.lastError == nil {
+			runtimeData.procStatus.lastError = newProcessError(procName, ErrRunFailed, fmt.Errorf("exit code %d after %d retries", exitCode, retries))
+		}
+		publishProcessEvent(EventProcessRestartLimit, procName, runtimeData, runtimeData.procStatus.lastError.Error())
+		runtimeData.mux.Unlock()
+	}
+
+	// Start the follow up tasks of a chain
+	triggerFollowUps(runtimeData, succeeded)
+	if !succeeded {
+		promoteStandbys(runtimeData)
+	}
+
+	gpclogging.Debug("Leaving launchProcessAndWait()")
+}
 
-	gpclogging.Info("Will now try to launch process <%s> with wait option, timeout is <%d>s.", procName, gProcRuntimeData[procName].procConfig.WaitForExitTimeoutS)
+//runProcessAndWait runs a wait process once and reports if it succeeded, along with its exit code
+//(-1 if it could not be started). The lock of the process is taken for the start and for the
+//bookkeeping after the exit only, status requests and a stop are served while it runs.
+//-------------------------------------------------------------------
+func runProcessAndWait(procName string, runtimeData *GPCProcRuntimeData) (succeeded bool, exitCode int) {
+	gpclogging.Task(procName).Info("Will now try to launch process <%s> with wait option, timeout is <%s>.", procName, runtimeData.procConfig.WaitForExitTimeout)
 
 	// Run process and wait for a max amount of time for exit
-	sDurationString := fmt.Sprintf("%ds", gProcRuntimeData[procName].procConfig.WaitForExitTimeoutS)
-	timeoutDur, parseErr := time.ParseDuration(sDurationString)
-	if parseErr != nil {
-		gpclogging.Error("Could not parse execution wait timeout config <%s>, Error message is <%d>", sDurationString, parseErr.Error())
-		gProcRuntimeData[procName].procStatus.error = true
-		return
+	timeoutDur := runtimeData.procConfig.WaitForExitTimeout.Duration
+
+	// Refuse to start a binary that does not match its checksum, or while its ports are taken
+	err := verifyExecutable(runtimeData)
+	if err == nil {
+		err = resolvePortConflicts(procName, runtimeData)
+	}
+	if err != nil {
+		gpclogging.Task(procName).Error("REFUSING TO START process <%s>: <%s>", procName, err.Error())
+		runtimeData.mux.Lock()
+		runtimeData.procStatus.lastError = newProcessError(procName, ErrStartFailed, err)
+		publishProcessEvent(EventProcessStartFailed, procName, runtimeData, err.Error())
+		runtimeData.mux.Unlock()
+		return false, -1
 	}
 
+	if !gStartSlots.acquire(shutdownContext(), procName) {
+		return false, -1
+	}
 	progContext, cancel := context.WithTimeout(context.Background(), timeoutDur)
 	defer cancel()
 
-	gProcRuntimeData[procName].procCmd = exec.CommandContext(progContext, gProcRuntimeData[procName].procConfig.StartPath)
-	doProcessSettings(gProcRuntimeData[procName])
+	runtimeData.mux.Lock()
+	procCmd := exec.CommandContext(progContext, runtimeData.procConfig.Executable())
+	runtimeData.procCmd = procCmd
+	doProcessSettings(runtimeData)
+
+	err = gPlatform.start(runtimeData)
+	runtimeData.capture.started()
+	if err == nil {
+		runtimeData.procStatus.pid = procCmd.Process.Pid
+		runtimeData.procStatus.startTime = time.Now()
+		runtimeData.procStatus.exitCode = -1
+		runtimeData.procStatus.active = true
+		recordLaunchContext(runtimeData)
+		writePIDFile(runtimeData)
+		applyWindowSettings(runtimeData)
+		publishProcessEvent(EventProcessStarted, procName, runtimeData, "")
+	}
+	runtimeData.mux.Unlock()
+	gStartSlots.release()
 
-	err := gProcRuntimeData[procName].procCmd.Run()
-	gProcRuntimeData[procName].procStatus.active = true
+	if err == nil {
+		err = procCmd.Wait()
+	}
+	if progContext.Err() != nil {
+		// The context only kills the process itself, take down its children as well
+		gPlatform.killTree(context.Background(), runtimeData, runtimeData.procConfig.GracePeriod())
+	}
 
+	runtimeData.mux.Lock()
+	defer runtimeData.mux.Unlock()
+	runtimeData.procStatus.active = false
+	runtimeData.procStatus.suspended = false
+	gPlatform.closeTree(runtimeData)
+	removePIDFile(runtimeData)
+	runtimeData.closeLogs()
+	runtimeData.closeStdin()
+
+	succeeded = false
+	exitCode = -1
 	if err != nil {
 		switch err.(type) {
 		default:
 			// STARTUP ERROR
-			gpclogging.Error("Could not run process <%s>, Error message is: %s", gProcRuntimeData[procName].procConfig.StartPath, err.Error())
-			gProcRuntimeData[procName].procStatus.error = true
+			gpclogging.Task(procName).Error("Could not run process <%s>, Error message is: %s", runtimeData.procConfig.Executable(), err.Error())
+			runtimeData.procStatus.lastError = newProcessError(procName, ErrStartFailed, err)
+			publishProcessEvent(EventProcessStartFailed, procName, runtimeData, err.Error())
 		case *exec.ExitError:
-			// TIMEOUT
-			gpclogging.Warn("Running process <%s> OK but it was termined after configured timeout! Exit code was <%d>",
-				gProcRuntimeData[procName].procConfig.StartPath, gProcRuntimeData[procName].procCmd.ProcessState.ExitCode())
-			gProcRuntimeData[procName].procStatus.timeout = true
-			gProcRuntimeData[procName].procStatus.done = true
+			if progContext.Err() != nil {
+				// TIMEOUT
+				gpclogging.Task(procName).Warn("Running process <%s> OK but it was termined after configured timeout! Exit code was <%d>",
+					runtimeData.procConfig.Executable(), procCmd.ProcessState.ExitCode())
+				runtimeData.procStatus.timeout = true
+			} else {
+				// FAILED
+				gpclogging.Task(procName).Warn("Running process <%s> OK but it failed with exit code <%d>",
+					runtimeData.procConfig.Executable(), procCmd.ProcessState.ExitCode())
+			}
+			runtimeData.procStatus.done = true
 		}
 	} else {
-		gpclogging.Info("Running process <%s> OK! Exit code was <%d>", gProcRuntimeData[procName].procConfig.StartPath,
-			gProcRuntimeData[procName].procCmd.ProcessState.ExitCode())
-		gProcRuntimeData[procName].procStatus.done = true
+		gpclogging.Task(procName).Info("Running process <%s> OK! Exit code was <%d>", runtimeData.procConfig.Executable(),
+			procCmd.ProcessState.ExitCode())
+		runtimeData.procStatus.done = true
+		succeeded = true
+	}
+	if procCmd.ProcessState != nil {
+		exitCode = procCmd.ProcessState.ExitCode()
+	}
+	runtimeData.procStatus.exitCode = exitCode
+	if procCmd.ProcessState != nil {
+		switch {
+		case runtimeData.procStatus.timeout:
+			publishFailedExit(procName, runtimeData, "timed out")
+		case succeeded:
+			publishProcessEvent(EventProcessExited, procName, runtimeData, "succeeded")
+		case runtimeData.procStatus.stopped:
+			publishProcessEvent(EventProcessExited, procName, runtimeData, "stopped")
+		default:
+			publishFailedExit(procName, runtimeData, "failed")
+		}
 	}
 
-	gpclogging.Debug("Leaving launchProcessAndWait()")
+	return succeeded, exitCode
+}
+
+// triggerFollowUps starts the tasks configured in OnSuccess or OnFailure of a finished wait process
+//------------------------------------------------------------------------------
+func triggerFollowUps(runtimeData *GPCProcRuntimeData, succeeded bool) {
+	followUps, outcome := followUpsOf(runtimeData.procConfig, succeeded)
+
+	procRuntimeData := currentRuntimeData()
+	for _, followUpName := range followUps {
+		followUp, found := procRuntimeData[followUpName]
+		if !found {
+			gpclogging.Error("Process <%s> ended with %s, but follow up task <%s> is unknown.", runtimeData.procConfig.Name, outcome, followUpName)
+			continue
+		}
+		followUp.mux.Lock()
+		if followUp.procStatus.active {
+			followUp.mux.Unlock()
+			gpclogging.Warn("Process <%s> ended with %s, but follow up task <%s> is still running. Not starting it again.",
+				runtimeData.procConfig.Name, outcome, followUpName)
+			continue
+		}
+		followUp.procStatus.stopped = false
+		followUp.procStatus.lastError = nil
+		followUp.procStatus.timeout = false
+		followUp.procStatus.done = false
+		followUp.mux.Unlock()
+
+		gpclogging.Info("Process <%s> ended with %s, triggering follow up task <%s>.", runtimeData.procConfig.Name, outcome, followUpName)
+		startWithDelay(followUpName, followUp, gShutdownWaitGroup)
+	}
 }
 
 // doProcessSettings will tweak the Cmd structure with specific runtime settings
@@ -298,58 +1242,77 @@ func launchProcessAndWait(procName string) {
 func doProcessSettings(proc *GPCProcRuntimeData) {
 	gpclogging.Debug("Entering doProcessSettings() for process <%s>", proc.procConfig.Name)
 
-	sysProcSettings := syscall.SysProcAttr{}
-
 	// Setting input, output and error streams
 	proc.procCmd.Stdin = nil
+	proc.stdin = nil
+	if proc.procConfig.StdinPipe {
+		stdin, err := proc.procCmd.StdinPipe()
+		if err != nil {
+			gpclogging.Error("Could not create the stdin pipe of process <%s>, it runs without stdin: <%s>", proc.procConfig.Name, err.Error())
+		} else {
+			proc.stdin = stdin
+		}
+	}
 
 	gpclogging.Debug("Process <%s>, Redirecting standard out and error to logfiles.", proc.procConfig.Name)
-	logOut, err := gpclogging.GetLogFileForProcess(proc.procConfig.Name)
+	logOut, err := gpclogging.OpenProcessLog(proc.procConfig.Name, proc.procConfig.OutputMaxSizeMB, proc.procConfig.OutputMaxFiles)
 	if err != nil {
 		gpclogging.Error("Could not open log file for process <%s> with error <%s>", proc.procConfig.Name, err.Error())
 	} else {
-		outWriter := io.Writer(logOut)
-		proc.procCmd.Stderr, proc.procCmd.Stdout = outWriter, outWriter
+		outWriter := io.Writer(logOut.File())
+		errWriter := outWriter
 
 		// Store for later closing
 		proc.procLog = logOut
+		proc.procErrLog = nil
+
+		if proc.procConfig.SeparateStderr {
+			logErr, err := logOut.OpenErrorLog()
+			if err != nil {
+				gpclogging.Error("Could not open error log file for process <%s>, standard error goes to the output log: <%s>", proc.procConfig.Name, err.Error())
+			} else {
+				errWriter = logErr.File()
+				proc.procErrLog = logErr
+			}
+		}
+		proc.procCmd.Stderr, proc.procCmd.Stdout = errWriter, outWriter
+
+		if err := captureOutput(proc); err != nil {
+			gpclogging.Error("Could not capture the output of process <%s>, it is logged as it is: <%s>", proc.procConfig.Name, err.Error())
+		}
 	}
 
-	// Hide the window
+	// Hide the window (where there are windows)
 	if proc.procConfig.HideWindow {
 		gpclogging.Debug("Process <%s>, HideWindow enabled, setting SysProcAttributes.", proc.procConfig.Name)
-		sysProcSettings.HideWindow = true
 	} else {
 		gpclogging.Debug("Process <%s>, HideWindow disabled, setting SysProcAttributes.", proc.procConfig.Name)
-		sysProcSettings.HideWindow = false
 	}
+	gPlatform.prepare(proc.procCmd, proc.procConfig.HideWindow)
 
-	// Command line parameters
-	for argIndex := range proc.procConfig.StartArgs {
-		gpclogging.Debug("Process <%s>, Adding command line argument to execution config: <%s>", proc.procConfig.Name, proc.procConfig.StartArgs[argIndex])
-		proc.procCmd.Args = append(proc.procCmd.Args, proc.procConfig.StartArgs[argIndex])
-	}
-
-	proc.procCmd.SysProcAttr = &sysProcSettings
-
-	gpclogging.Debug("Leaving doProcessSettings()")
-}
-
-//killProcess will try to kill the given process (windows specfic)
-//-------------------------------------------------------------------
-func killProcess(proc *exec.Cmd) error {
-	gpclogging.Debug("Enter KillProcess()")
+	// Environment, locale
+	proc.procCmd.Env = processEnvironment(proc)
 
-	proc.Process.Release()
-	proc.Process.Signal(syscall.SIGTERM)
-	proc.Process.Signal(syscall.SIGKILL)
+	// Socket activation, without its sockets the process could not serve
+	if err := inheritListeners(proc); err != nil && proc.procCmd.Err == nil {
+		proc.procCmd.Err = err
+	}
+	if err := assignPorts(proc); err != nil && proc.procCmd.Err == nil {
+		proc.procCmd.Err = err
+	}
 
-	kill := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(proc.Process.Pid))
-	err := kill.Run()
+	// Network throttling
+	if err := applyBandwidthLimit(proc); err != nil {
+		gpclogging.Warn("Process <%s> runs without bandwidth limit: <%s>", proc.procConfig.Name, err.Error())
+	}
 
-	gpclogging.Debug("Leaving KillProcess()")
+	// Command line parameters, for a Command task the options of its shell
+	for _, sArg := range proc.procConfig.Arguments() {
+		gpclogging.Debug("Process <%s>, Adding command line argument to execution config: <%s>", proc.procConfig.Name, sArg)
+		proc.procCmd.Args = append(proc.procCmd.Args, sArg)
+	}
 
-	return err
+	gpclogging.Debug("Leaving doProcessSettings()")
 }
 
 //tryStopCommand will try to stop the given process via a command
@@ -360,29 +1323,23 @@ func tryStopCommand(proc *GPCProcRuntimeData) {
 	gpclogging.Info("Will now try to stop process <%s>.", proc.procConfig.Name)
 
 	// Start process - fire and forget
-	sysProcSettings := syscall.SysProcAttr{}
 	procCmd := exec.Command(proc.procConfig.StopPath)
-
-	sysProcSettings.HideWindow = true
+	gPlatform.prepare(procCmd, true)
 
 	// Command line parameters
 	for argIndex := range proc.procConfig.StopArgs {
 		gpclogging.Debug("Process <%s>, Adding command line argument to execution config: <%s>", proc.procConfig.Name, proc.procConfig.StopArgs[argIndex])
-		procCmd.Args = append(proc.procCmd.Args, proc.procConfig.StopArgs[argIndex])
+		procCmd.Args = append(procCmd.Args, proc.procConfig.StopArgs[argIndex])
 	}
 
-	procCmd.SysProcAttr = &sysProcSettings
-
 	err := procCmd.Start()
 
 	if err != nil {
 		gpclogging.Error("Could not start process <%s>, Error message is <%s>", proc.procConfig.Name, err.Error())
 	} else {
 		gpclogging.Info("Starting process <%s> OK!", proc.procConfig.Name)
+		procCmd.Process.Release()
 	}
-	procCmd.Process.Release()
 
-	// Wait a moment for the process to take effect
-	time.Sleep(500 * time.Millisecond)
 	gpclogging.Debug("Leaving tryStopCommand()")
 }
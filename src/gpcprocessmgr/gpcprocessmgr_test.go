@@ -0,0 +1,144 @@
+package gpcprocessmgr
+
+import (
+	"errors"
+	"fmt"
+	"gpcconfig"
+	"testing"
+	"time"
+)
+
+// setTestRuntimeData replaces the runtime data with the given processes until the test ends
+//------------------------------------------------------------------------------
+func setTestRuntimeData(t *testing.T, procRuntimeData map[string]*GPCProcRuntimeData) {
+	gRuntimeDatatMux.Lock()
+	savedRuntimeData := gProcRuntimeData
+	gProcRuntimeData = procRuntimeData
+	gRuntimeDatatMux.Unlock()
+	t.Cleanup(func() {
+		gRuntimeDatatMux.Lock()
+		gProcRuntimeData = savedRuntimeData
+		gRuntimeDatatMux.Unlock()
+	})
+}
+
+func TestLastError(t *testing.T) {
+	procRuntimeData := map[string]*GPCProcRuntimeData{
+		"fine":      {procConfig: &gpcconfig.ProcessConfig{Name: "fine"}},
+		"limit":     {procConfig: &gpcconfig.ProcessConfig{Name: "limit"}},
+		"noRestart": {procConfig: &gpcconfig.ProcessConfig{Name: "noRestart"}},
+	}
+	procRuntimeData["limit"].procStatus.lastError = newProcessError("limit", ErrRestartLimit, nil)
+	procRuntimeData["noRestart"].procStatus.lastError = newProcessError("noRestart", ErrNoRestartExit, fmt.Errorf("exit code %d", 3))
+	setTestRuntimeData(t, procRuntimeData)
+
+	tests := []struct {
+		sProcName string
+		kind      error // nil if the process did not fail
+		notKind   error
+		sMessage  string
+	}{
+		{"fine", nil, nil, ""},
+		{"limit", ErrRestartLimit, ErrNoRestartExit, "process reached its restart limit <limit>"},
+		{"noRestart", ErrNoRestartExit, ErrRestartLimit, "process exited with an exit code that is not restarted <noRestart>: exit code 3"},
+		{"missing", ErrUnknownProcess, ErrRestartLimit, "unknown process <missing>"},
+	}
+	for _, test := range tests {
+		err := LastError(test.sProcName)
+		if test.kind == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error <%s>", test.sProcName, err.Error())
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", test.sProcName)
+			continue
+		}
+		if !errors.Is(err, test.kind) {
+			t.Errorf("%s: <%s> is not <%s>", test.sProcName, err.Error(), test.kind.Error())
+		}
+		if errors.Is(err, test.notKind) {
+			t.Errorf("%s: <%s> must not be <%s>", test.sProcName, err.Error(), test.notKind.Error())
+		}
+		var processErr *ProcessError
+		if !errors.As(err, &processErr) || processErr.Process != test.sProcName {
+			t.Errorf("%s: <%s> is not a *ProcessError of the process", test.sProcName, err.Error())
+		}
+		if err.Error() != test.sMessage {
+			t.Errorf("%s: got <%s>, expected <%s>", test.sProcName, err.Error(), test.sMessage)
+		}
+	}
+}
+
+func TestProcessErrorCause(t *testing.T) {
+	cause := errors.New("permission denied")
+	err := newProcessError("svc", ErrStartFailed, fmt.Errorf("open log: %w", cause))
+	if !errors.Is(err, ErrStartFailed) || !errors.Is(err, cause) {
+		t.Errorf("<%s> must be both the kind and the cause", err.Error())
+	}
+}
+
+func TestStateName(t *testing.T) {
+	tests := []struct {
+		sName     string
+		setStatus func(r *GPCProcRuntimeData)
+		sExpected string
+	}{
+		{"never started", func(r *GPCProcRuntimeData) {}, "waiting"},
+		{"scheduled", func(r *GPCProcRuntimeData) { r.procConfig.Schedule = "0 3 * * *" }, "scheduled"},
+		{"running", func(r *GPCProcRuntimeData) { r.procStatus.active = true }, "running"},
+		{"suspended", func(r *GPCProcRuntimeData) { r.procStatus.active, r.procStatus.suspended = true, true }, "suspended"},
+		{"stopped", func(r *GPCProcRuntimeData) { r.procStatus.stopped = true }, "stopped"},
+		{"stopped after an error", func(r *GPCProcRuntimeData) {
+			r.procStatus.stopped = true
+			r.procStatus.lastError = newProcessError("p", ErrRestartLimit, nil)
+		}, "stopped"},
+		{"restart limit", func(r *GPCProcRuntimeData) { r.procStatus.lastError = newProcessError("p", ErrRestartLimit, nil) }, "error"},
+		{"quarantined", func(r *GPCProcRuntimeData) {
+			r.procStatus.quarantined = true
+			r.procStatus.lastError = newProcessError("p", ErrQuarantined, nil)
+		}, "quarantined"},
+		{"waiting on a condition", func(r *GPCProcRuntimeData) { r.procStatus.waitingOn = "file /tmp/x" }, "condition"},
+		{"maintenance", func(r *GPCProcRuntimeData) { r.procStatus.heldForMaintenance = true }, "maintenance"},
+		{"timeout", func(r *GPCProcRuntimeData) { r.procStatus.timeout = true }, "timeout"},
+		{"done", func(r *GPCProcRuntimeData) { r.procStatus.done = true }, "done"},
+	}
+	for _, test := range tests {
+		runtimeData := &GPCProcRuntimeData{procConfig: &gpcconfig.ProcessConfig{Name: "p"}}
+		test.setStatus(runtimeData)
+		if sState := runtimeData.stateName(); sState != test.sExpected {
+			t.Errorf("%s: got <%s>, expected <%s>", test.sName, sState, test.sExpected)
+		}
+	}
+}
+
+func TestCrashLoopDetected(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		sName     string
+		restarts  uint32
+		window    time.Duration
+		offsets   []time.Duration // of the restarts from start, the last one is checked
+		bExpected bool
+	}{
+		{"no limit", 0, 0, []time.Duration{0, time.Second, 2 * time.Second}, false},
+		{"within the limit", 2, time.Minute, []time.Duration{0, time.Second}, false},
+		{"over the limit", 2, time.Minute, []time.Duration{0, time.Second, 2 * time.Second}, true},
+		{"old restarts left the window", 2, time.Minute, []time.Duration{0, time.Second, 2 * time.Minute}, false},
+		{"default window", 2, 0, []time.Duration{0, 5 * time.Minute, 9 * time.Minute}, true},
+		{"default window passed", 2, 0, []time.Duration{0, 5 * time.Minute, 11 * time.Minute}, false},
+	}
+	for _, test := range tests {
+		procConfig := &gpcconfig.ProcessConfig{Name: "p", CrashLoopRestarts: test.restarts}
+		procConfig.CrashLoopWindow.Duration = test.window
+		var restartTimes []time.Time
+		bDetected := false
+		for _, offset := range test.offsets {
+			bDetected = crashLoopDetected(procConfig, &restartTimes, start.Add(offset))
+		}
+		if bDetected != test.bExpected {
+			t.Errorf("%s: got <%t>, expected <%t>", test.sName, bDetected, test.bExpected)
+		}
+	}
+}
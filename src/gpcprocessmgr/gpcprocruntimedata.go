@@ -2,22 +2,70 @@ package gpcprocessmgr
 
 import (
 	"gpcconfig"
+	"gpclogging"
+	"io"
+	"net"
 	"os"
 	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // GPCProcRuntimeData holds runtime data
 type GPCProcRuntimeData struct {
-	procConfig *gpcconfig.ProcessConfig
-	procCmd    *exec.Cmd
-	procLog    *os.File
-	procStatus struct {
-		pid          int
-		active       bool
-		error        bool
-		timeout      bool
-		done         bool
-		restartCount uint32
+	// guards procCmd and procStatus while the process is launched, has exited or is stopped,
+	// never held while the process runs or while waiting for it
+	mux           sync.Mutex
+	procConfig    *gpcconfig.ProcessConfig
+	procCmd       *exec.Cmd
+	procLog       *gpclogging.ProcessLog
+	procErrLog    *gpclogging.ProcessLog // log of stderr with SeparateStderr, nil if stderr goes to procLog
+	capture       *outputCapture         // copies the output into the logs (timestamps, rotation), nil if the process writes to them directly
+	recentOutput  *outputRing            // latest output lines of all runs, empty if the task keeps none
+	treeHandle    uintptr                // job object or process group holding the process tree, zero if none
+	exitHandle    uintptr                // Windows: handle of the started process to read its exit code, zero if none
+	stdin         io.WriteCloser         // write end of the stdin pipe of a StdinPipe task, nil if it has none
+	stdinMux      sync.Mutex             // keeps the input of concurrent writers apart, held while writing
+	lastActivity  atomic.Int64           // time of the latest output line or heartbeat, unix nanoseconds, zero if there was none yet
+	lastHeartbeat atomic.Int64           // time of the latest heartbeat datagram, unix nanoseconds, zero if there was none yet
+	notifyConn    *net.UnixConn          // NOTIFY_SOCKET of a NotifyReady task, nil until its first launch or if it could not be opened
+	listeners     []net.Listener         // the open ListenSockets of the task, empty until its first launch
+	listenFiles   []*os.File             // Unix: the descriptors of the listeners passed to the process
+	ports         map[string]int         // ports of AssignPorts by variable name, nil until its first launch
+	// latest launch contexts, oldest first
+	launchHistory []LaunchContext
+	historyMux    sync.Mutex
+	procStatus    struct {
+		pid                      int
+		active                   bool
+		lastError                error // why the process failed, nil if it did not
+		timeout                  bool
+		done                     bool
+		stopped                  bool // stopped on request, must not be restarted
+		holdsGroup               bool // owns the token of its mutex group
+		overMemory               bool // memory limit exceeded, reported once until it drops below again
+		adopted                  bool // started outside of the controller and taken over, its output is not captured
+		restartCount             uint32
+		startTime                time.Time     // start of the current or last run
+		exitCode                 int           // exit code of the last run, -1 if unknown
+		restartTimes             []time.Time   // automatic restarts within the CrashLoopWindow, oldest first
+		quarantined              bool          // restarted too often within the CrashLoopWindow, not restarted until resumed
+		usage                    ResourceUsage // latest resource sample, zero if not sampled yet
+		usageCPUTime             time.Duration // CPU time of the process tree at the latest sample
+		usagePID                 int           // PID the latest sample was taken of, the CPU time starts over with a new PID
+		overMemoryRestartSamples uint32        // consecutive samples above MaxMemoryRestartMB
+		restartDue               time.Time     // RestartEvery restart of the run started at restartDueFor, zero if none is due
+		restartDueFor            time.Time     // start time of the run restartDue was computed for
+		waitingOn                string        // unmet precondition the launch waits for, empty if it does not wait
+		suspended                bool          // process tree is frozen on request until resumed, still counts as active
+		heldForMaintenance       bool          // exited during a maintenance, restarted once it ends
+		hung                     bool          // no output for HungTimeout, its process tree is being killed
+		notifiedReady            bool          // NotifyReady: the run sent READY=1 (and no RELOADING=1 since)
+		notifiedStopping         bool          // NotifyReady: the run sent STOPPING=1, it is shutting down by itself
+		startingFirst            bool          // a start-first restart launches the new run, the old one still holds the BindPorts
+		launchPending            bool          // a manual start was accepted and its launch is not done yet
+		stopPending              bool          // stopProcess is taking the process down, it must not be started meanwhile
 	}
 }
 
@@ -28,12 +76,68 @@ func NewProcRuntimeData(configData *gpcconfig.ProcessConfig) *GPCProcRuntimeData
 	out.procCmd = nil
 	out.procConfig = configData
 	out.procLog = nil
+	out.procErrLog = nil
+	out.recentOutput = &outputRing{}
 	out.procStatus.pid = 0
 	out.procStatus.active = false
-	out.procStatus.error = false
+	out.procStatus.lastError = nil
 	out.procStatus.timeout = false
 	out.procStatus.done = false
+	out.procStatus.stopped = false
+	out.procStatus.holdsGroup = false
+	out.procStatus.overMemory = false
+	out.procStatus.adopted = false
 	out.procStatus.restartCount = 0
+	out.procStatus.exitCode = -1
+	out.procStatus.restartTimes = nil
+	out.procStatus.quarantined = false
 
 	return &out
 }
+
+// closeLogs closes the log files of the process output
+func (r *GPCProcRuntimeData) closeLogs() {
+	if r.capture != nil {
+		r.capture.close()
+	}
+	if r.procLog != nil {
+		r.procLog.Close()
+	}
+	if r.procErrLog != nil {
+		r.procErrLog.Close()
+	}
+}
+
+// stateName returns a short human readable state of the process
+func (r *GPCProcRuntimeData) stateName() string {
+	switch {
+	case r.procStatus.active && r.procStatus.suspended:
+		return "suspended"
+	case r.procStatus.active && r.procStatus.notifiedStopping:
+		return "stopping"
+	case r.procStatus.active && !r.isNotifiedReady():
+		return "starting"
+	case r.procStatus.active:
+		return "running"
+	case r.procStatus.stopped:
+		return "stopped"
+	case len(r.procStatus.waitingOn) > 0:
+		return "condition"
+	case r.procStatus.quarantined:
+		return "quarantined"
+	case r.procStatus.heldForMaintenance:
+		return "maintenance"
+	case r.procStatus.lastError != nil:
+		return "error"
+	case r.procStatus.timeout:
+		return "timeout"
+	case r.procStatus.done:
+		return "done"
+	case r.procCmd == nil && len(r.procConfig.Schedule) > 0:
+		return "scheduled"
+	case r.procCmd == nil:
+		return "waiting"
+	default:
+		return "exited"
+	}
+}
@@ -0,0 +1,96 @@
+package gpcprocessmgr
+
+import (
+	"sync"
+	"time"
+)
+
+// outputRing keeps the latest output lines of a process in memory, across its restarts, so a
+// crash can be looked into without the log files on the host
+type outputRing struct {
+	maxLines int // zero => only bounded by maxBytes
+	maxBytes int // zero => only bounded by maxLines
+	lines    []string
+	size     int // bytes of all lines
+	mux      sync.Mutex
+}
+
+// setLimits changes the bounds, zero for both disables the buffer and empties it
+//------------------------------------------------------------------------------
+func (o *outputRing) setLimits(maxLines uint32, maxKB uint32) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	o.maxLines, o.maxBytes = int(maxLines), int(maxKB)*1024
+	o.trim()
+}
+
+// enabled reports whether lines are kept
+//------------------------------------------------------------------------------
+func (o *outputRing) enabled() bool {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	return o.maxLines > 0 || o.maxBytes > 0
+}
+
+// add appends a line read at the given time, error output is marked
+//------------------------------------------------------------------------------
+func (o *outputRing) add(t time.Time, sStream string, line string) {
+	sLine := t.Format(outputTimeFormat) + " " + line
+	if sStream == "stderr" {
+		sLine = t.Format(outputTimeFormat) + " [stderr] " + line
+	}
+
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	if o.maxLines == 0 && o.maxBytes == 0 {
+		return
+	}
+	o.lines = append(o.lines, sLine)
+	o.size += len(sLine)
+	o.trim()
+}
+
+// trim drops the oldest lines beyond the bounds, the lock must be held by the caller
+//------------------------------------------------------------------------------
+func (o *outputRing) trim() {
+	if o.maxLines == 0 && o.maxBytes == 0 {
+		o.lines, o.size = nil, 0
+		return
+	}
+	dropLines := 0
+	for dropLines < len(o.lines) &&
+		((o.maxLines > 0 && len(o.lines)-dropLines > o.maxLines) || (o.maxBytes > 0 && o.size > o.maxBytes)) {
+		o.size -= len(o.lines[dropLines])
+		dropLines++
+	}
+	if dropLines > 0 {
+		// Copy instead of reslicing, the dropped lines are freed and the array does not creep forward
+		o.lines = append([]string(nil), o.lines[dropLines:]...)
+	}
+}
+
+// last returns copies of the latest numLines lines, oldest first, all with numLines zero
+//------------------------------------------------------------------------------
+func (o *outputRing) last(numLines int) []string {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	if numLines <= 0 || numLines > len(o.lines) {
+		numLines = len(o.lines)
+	}
+	return append([]string(nil), o.lines[len(o.lines)-numLines:]...)
+}
+
+//RecentOutput returns the latest numLines lines of output of a process kept in memory (see
+//RecentOutputLines and RecentOutputKB), of stdout and stderr in the order they were read, all of
+//them with numLines zero. The lines of the runs before a restart are included.
+//#########################################################
+func RecentOutput(procName string, numLines int) ([]string, error) {
+	runtimeData, err := getRuntimeData(procName)
+	if err != nil {
+		return nil, err
+	}
+	if !runtimeData.recentOutput.enabled() {
+		return nil, newProcessError(procName, ErrNoRecentOutput, nil)
+	}
+	return runtimeData.recentOutput.last(numLines), nil
+}
@@ -0,0 +1,123 @@
+package gpcprocessmgr
+
+import (
+	"gpcconfig"
+	"gpclogging"
+	"time"
+)
+
+// How often the resources of the process trees are sampled without Resources.SampleInterval
+const defResourceSampleInterval = 10 * time.Second
+
+// ResourceUsage is a sample of the resources used by the process tree of a process
+type ResourceUsage struct {
+	CPUPercent  float64   // CPU time used since the previous sample per wall time, 100 is one CPU busy. Zero at the first sample
+	MemoryBytes uint64    // resident memory, the working sets on Windows
+	Handles     int       // open handles on Windows, file descriptors on Linux, zero where unknown (macOS)
+	Sampled     time.Time // time of the sample
+}
+
+var (
+	// sample and log intervals from the configuration
+	gResourceSampleInterval = defResourceSampleInterval
+	gResourceLogInterval    time.Duration
+
+	// time of the last sample and of the last log of the samples, only used by the monitor
+	gLastResourceSample time.Time
+	gLastResourceLog    time.Time
+)
+
+// setResourceSampling takes the sample and log intervals of the configuration
+//------------------------------------------------------------------------------
+func setResourceSampling(configData *gpcconfig.ConfigData) {
+	gStopMux.Lock()
+	defer gStopMux.Unlock()
+	gResourceSampleInterval = configData.Resources.SampleInterval.Duration
+	if gResourceSampleInterval <= 0 {
+		gResourceSampleInterval = defResourceSampleInterval
+	}
+	gResourceLogInterval = configData.Resources.LogInterval.Duration
+}
+
+// sampleResources samples CPU, memory and handles of all running processes and writes them to
+// the log every LogInterval. It is called from the monitor loop.
+//------------------------------------------------------------------------------
+func sampleResources() {
+	gStopMux.Lock()
+	sampleInterval, logInterval := gResourceSampleInterval, gResourceLogInterval
+	gStopMux.Unlock()
+
+	now := time.Now()
+	if now.Sub(gLastResourceSample) < sampleInterval {
+		return
+	}
+	gLastResourceSample = now
+	writeLog := logInterval > 0 && now.Sub(gLastResourceLog) >= logInterval
+	if writeLog {
+		gLastResourceLog = now
+	}
+
+	running := runningProcesses(nil)
+	treeUsages, err := sampleTrees(running)
+	if err != nil {
+		return
+	}
+	for procName, runtimeData := range running {
+		treeUsage, found := treeUsages[runtimeData]
+		if !found {
+			continue
+		}
+
+		usage := ResourceUsage{MemoryBytes: treeUsage.memoryBytes, Handles: treeUsage.handles, Sampled: now}
+		// Children that ended meanwhile take their CPU time with them, the delta can get negative
+		previous := runtimeData.procStatus.usage
+		if runtimeData.procStatus.usagePID != runtimeData.procStatus.pid {
+			runtimeData.procStatus.overMemoryRestartSamples = 0
+		}
+		if runtimeData.procStatus.usagePID == runtimeData.procStatus.pid && !previous.Sampled.IsZero() &&
+			treeUsage.cpuTime >= runtimeData.procStatus.usageCPUTime {
+			usage.CPUPercent = float64(treeUsage.cpuTime-runtimeData.procStatus.usageCPUTime) * 100 / float64(now.Sub(previous.Sampled))
+		}
+		runtimeData.procStatus.usage = usage
+		runtimeData.procStatus.usageCPUTime = treeUsage.cpuTime
+		runtimeData.procStatus.usagePID = runtimeData.procStatus.pid
+
+		if writeLog {
+			gpclogging.Task(procName).Info("Process <%s> uses CPU=<%.1f%%>, memory=<%d> MB, handles=<%d>.",
+				procName, usage.CPUPercent, usage.MemoryBytes/(1024*1024), usage.Handles)
+		}
+		checkMemoryRestart(procName, runtimeData)
+	}
+}
+
+// runningProcesses returns the running processes the filter accepts (all without filter), by name
+//------------------------------------------------------------------------------
+func runningProcesses(filter func(runtimeData *GPCProcRuntimeData) bool) map[string]*GPCProcRuntimeData {
+	running := make(map[string]*GPCProcRuntimeData)
+	for procName, runtimeData := range currentRuntimeData() {
+		runtimeData.mux.Lock()
+		bRunning := runtimeData.procCmd != nil && runtimeData.procStatus.active
+		runtimeData.mux.Unlock()
+		if bRunning && (filter == nil || filter(runtimeData)) {
+			running[procName] = runtimeData
+		}
+	}
+	return running
+}
+
+// sampleTrees samples the process trees of the given processes in one go
+//------------------------------------------------------------------------------
+func sampleTrees(procRuntimeData map[string]*GPCProcRuntimeData) (map[*GPCProcRuntimeData]treeUsage, error) {
+	if len(procRuntimeData) == 0 {
+		return nil, nil
+	}
+	runtimeDataList := make([]*GPCProcRuntimeData, 0, len(procRuntimeData))
+	for _, runtimeData := range procRuntimeData {
+		runtimeDataList = append(runtimeDataList, runtimeData)
+	}
+	treeUsages, err := gPlatform.treeUsages(runtimeDataList)
+	if err != nil {
+		gpclogging.Debug("Could not sample the resources of the processes: <%s>", err.Error())
+	}
+	return treeUsages, err
+}
@@ -0,0 +1,67 @@
+//go:build !windows
+// +build !windows
+
+package gpcprocessmgr
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+// number of process trees sampled by the benchmarks
+const benchmarkProcesses = 1000
+
+// startSleepers starts processes that sleep in process groups of their own, like started tasks,
+// and returns their runtime data. They are killed when the benchmark ends.
+//------------------------------------------------------------------------------
+func startSleepers(b *testing.B, count int) map[string]*GPCProcRuntimeData {
+	procRuntimeData := make(map[string]*GPCProcRuntimeData, count)
+	for index := 0; index < count; index++ {
+		procCmd := exec.Command("sleep", "3600")
+		procCmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		if err := procCmd.Start(); err != nil {
+			b.Skipf("could not start %d processes: %s", count, err.Error())
+		}
+		runtimeData := &GPCProcRuntimeData{procCmd: procCmd, treeHandle: uintptr(procCmd.Process.Pid)}
+		runtimeData.procStatus.pid = procCmd.Process.Pid
+		runtimeData.procStatus.active = true
+		procRuntimeData[fmt.Sprintf("sleeper%04d", index)] = runtimeData
+		b.Cleanup(func() {
+			procCmd.Process.Kill()
+			procCmd.Wait()
+		})
+	}
+	return procRuntimeData
+}
+
+// BenchmarkSampleTrees samples the trees of 1000 processes in one pass, and one by one as it was
+// done before treeUsages, which read all of /proc for each process. One by one only samples 100 of
+// them, all 1000 take minutes per pass; the whole pass costs ten times as much.
+func BenchmarkSampleTrees(b *testing.B) {
+	procRuntimeData := startSleepers(b, benchmarkProcesses)
+
+	b.Run("together", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			usages, err := sampleTrees(procRuntimeData)
+			if err != nil || len(usages) != len(procRuntimeData) {
+				b.Fatalf("sampled %d of %d trees: %v", len(usages), len(procRuntimeData), err)
+			}
+		}
+	})
+	b.Run("oneByOne", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			count := 0
+			for _, runtimeData := range procRuntimeData {
+				if count == benchmarkProcesses/10 {
+					break
+				}
+				if _, err := gPlatform.treeUsages([]*GPCProcRuntimeData{runtimeData}); err != nil {
+					b.Fatal(err)
+				}
+				count++
+			}
+		}
+	})
+}
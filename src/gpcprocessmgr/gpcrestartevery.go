@@ -0,0 +1,74 @@
+package gpcprocessmgr
+
+import (
+	"gpclogging"
+	"time"
+)
+
+// How often the monitor looks for due RestartEvery restarts
+const restartEveryCheckInterval = time.Second
+
+// time of the last check for due RestartEvery restarts, only used by the monitor
+var gLastRestartEveryCheck time.Time
+
+// checkScheduledRestarts restarts the running no-wait processes whose RestartEvery is due. The due
+// time is taken from the start of the current run, so a crash or a manual restart in between
+// starts over. A process that was started before a due time it missed, e.g. while the controller
+// was down, is restarted at once. It is called from the monitor loop.
+//------------------------------------------------------------------------------
+func checkScheduledRestarts() {
+	if time.Since(gLastRestartEveryCheck) < restartEveryCheckInterval {
+		return
+	}
+	gLastRestartEveryCheck = time.Now()
+
+	recycled := runningProcesses(func(runtimeData *GPCProcRuntimeData) bool {
+		return len(runtimeData.procConfig.RestartEvery) > 0 && runtimeData.procConfig.WaitForExitTimeout.Duration == 0
+	})
+	now := gClock.Now()
+	for procName, runtimeData := range recycled {
+		runtimeData.mux.Lock()
+		procStatus := &runtimeData.procStatus
+		if !procStatus.restartDueFor.Equal(procStatus.startTime) {
+			procStatus.restartDue = scheduledRestartAt(runtimeData.procConfig, procStatus.startTime)
+			procStatus.restartDueFor = procStatus.startTime
+		}
+		restartDue := procStatus.restartDue
+		// A suspended process is restarted as soon as it is resumed, one in maintenance once it ends
+		bDue := procStatus.active && !procStatus.suspended && !restartDue.IsZero() && !now.Before(restartDue) && !inMaintenance(procName)
+		if bDue {
+			// once per run, until the restart has marked the process inactive
+			procStatus.restartDue = time.Time{}
+		}
+		runtimeData.mux.Unlock()
+		if !bDue {
+			continue
+		}
+
+		gpclogging.Task(procName).Info("Scheduled restart of process <%s> is due (RestartEvery <%s>, due at <%s>). Restarting it.",
+			procName, runtimeData.procConfig.RestartEvery, restartDue.Format(time.RFC3339))
+		restartGracefully(procName, runtimeData, "scheduled")
+	}
+}
+
+// restartGracefully stops a process in background, like a manual restart, and launches it again.
+// Unlike a crash the restart does not count against MaxRestarts. Tasks with the start-first
+// RestartStrategy launch the new run first. The reason is the message of the restart event.
+//------------------------------------------------------------------------------
+func restartGracefully(procName string, runtimeData *GPCProcRuntimeData, sReason string) {
+	gShutdownWaitGroup.Add(1)
+	go func() {
+		defer gShutdownWaitGroup.Done()
+		if startsFirst(runtimeData) {
+			restartStartFirst(shutdownContext(), procName, runtimeData, sReason)
+			return
+		}
+		stopProcess(shutdownContext(), procName, runtimeData)
+		if isStopping() {
+			return
+		}
+		runtimeData.procStatus.stopped = false
+		publishProcessEvent(EventProcessRestarted, procName, runtimeData, sReason)
+		launchProcess(procName)
+	}()
+}
@@ -0,0 +1,130 @@
+package gpcprocessmgr
+
+import (
+	"context"
+	"fmt"
+	"gpcconfig"
+	"gpclogging"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// consts of the readiness probe
+const (
+	readinessProbeInterval = 500 * time.Millisecond
+	readinessProbeTimeout  = 2 * time.Second
+)
+
+//RollingRestart restarts the instances of a task with Instances one at a time, each one only
+//once the one before is ready again (see ReadinessURL), so the others keep serving meanwhile.
+//Stops at the first instance that does not get ready within its ReadinessTimeout or ends, the
+//instances after it keep running as they are.
+//#########################################################
+func RollingRestart(ctx context.Context, sTemplate string) error {
+	gpclogging.Debug("Entering RollingRestart() for task <%s>", sTemplate)
+
+	var instanceNames []string
+	for procName, runtimeData := range currentRuntimeData() {
+		if runtimeData.procConfig.Template == sTemplate {
+			instanceNames = append(instanceNames, procName)
+		}
+	}
+	if len(instanceNames) == 0 {
+		return newProcessError(sTemplate, ErrNoInstances, nil)
+	}
+	// "web-2" before "web-10"
+	sort.Slice(instanceNames, func(i, j int) bool {
+		if len(instanceNames[i]) != len(instanceNames[j]) {
+			return len(instanceNames[i]) < len(instanceNames[j])
+		}
+		return instanceNames[i] < instanceNames[j]
+	})
+
+	gpclogging.Info("Rolling restart of task <%s>: <%s>", sTemplate, strings.Join(instanceNames, ", "))
+	for instanceIndex, procName := range instanceNames {
+		if err := RestartProcess(ctx, procName); err != nil {
+			return err
+		}
+		if err := waitReady(ctx, procName); err != nil {
+			gpclogging.Task(procName).Error("Rolling restart of task <%s> stopped at instance <%s>, <%d> instances were not restarted: <%s>",
+				sTemplate, procName, len(instanceNames)-instanceIndex-1, err.Error())
+			return err
+		}
+		gpclogging.Task(procName).Info("Instance <%s> is ready (%d of %d).", procName, instanceIndex+1, len(instanceNames))
+	}
+
+	gpclogging.Debug("Leaving RollingRestart()")
+	return nil
+}
+
+// waitReady waits until a restarted process passes its readiness probe, up to its ReadinessTimeout.
+// A process without ReadinessURL is ready once it runs. Fails if it ends meanwhile.
+//------------------------------------------------------------------------------
+func waitReady(ctx context.Context, procName string) error {
+	runtimeData, err := getRuntimeData(procName)
+	if err != nil {
+		return err
+	}
+	procConfig := runtimeData.procConfig
+	ctx, cancel := context.WithTimeout(ctx, procConfig.ReadinessWait())
+	defer cancel()
+
+	for {
+		runtimeData.mux.Lock()
+		bActive := runtimeData.procStatus.active
+		bWaiting := len(runtimeData.procStatus.waitingOn) > 0
+		bNotified := runtimeData.isNotifiedReady()
+		runtimeData.mux.Unlock()
+		if !bActive && !bWaiting {
+			return newProcessError(procName, ErrNotReady, fmt.Errorf("process is not running"))
+		}
+		errProbe := fmt.Errorf("waiting on its preconditions")
+		if bActive && !bNotified {
+			errProbe = fmt.Errorf("no READY=1 notification yet")
+		} else if bActive {
+			if errProbe = probeReadiness(ctx, procConfig); errProbe == nil {
+				return nil
+			}
+		}
+		if !sleepContext(ctx, readinessProbeInterval) {
+			return newProcessError(procName, ErrNotReady, fmt.Errorf("not ready within %s: %s", procConfig.ReadinessWait(), errProbe.Error()))
+		}
+	}
+}
+
+// probeReadiness checks the ReadinessURL of a task once: an HTTP answer below 400, or a TCP
+// connection that can be opened
+//------------------------------------------------------------------------------
+func probeReadiness(ctx context.Context, procConfig *gpcconfig.ProcessConfig) error {
+	if len(procConfig.ReadinessURL) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, readinessProbeTimeout)
+	defer cancel()
+
+	if sAddress, found := strings.CutPrefix(procConfig.ReadinessURL, "tcp://"); found {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", sAddress)
+		if err != nil {
+			return err
+		}
+		conn.Close()
+		return nil
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, procConfig.ReadinessURL, nil)
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode >= 400 {
+		return fmt.Errorf("<%s> answered <%s>", procConfig.ReadinessURL, response.Status)
+	}
+	return nil
+}
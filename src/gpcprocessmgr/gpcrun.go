@@ -0,0 +1,54 @@
+package gpcprocessmgr
+
+import (
+	"context"
+	"fmt"
+	"gpcconfig"
+	"math"
+	"time"
+)
+
+//RunTask runs a single task of the configuration once and waits for it to end, e.g. to debug
+//its definition. Start delay, schedule, restarts, retries, follow up tasks and standbys do not
+//apply, the timeout of a wait task does. Its output goes to its logs and the output sinks as
+//usual, ShutdownAll stops it. Returns the exit code, -1 if it is unknown, and an error if the
+//task could not be started, timed out or was stopped. A task that ran and failed returns its
+//exit code without error.
+//#########################################################
+func RunTask(configData *gpcconfig.ConfigData, procName string) (exitCode int, err error) {
+	var runTask *gpcconfig.ProcessConfig
+	for taskIndex := range configData.Tasks {
+		if configData.Tasks[taskIndex].Name == procName {
+			task := configData.Tasks[taskIndex]
+			runTask = &task
+		}
+	}
+	if runTask == nil {
+		return -1, newProcessError(procName, ErrUnknownProcess, nil)
+	}
+	// a no-wait task runs until it ends on its own or is stopped
+	if runTask.WaitForExitTimeout.Duration == 0 {
+		runTask.WaitForExitTimeout.Duration = time.Duration(math.MaxInt64)
+	}
+
+	runtimeData := NewProcRuntimeData(runTask)
+	gRuntimeDatatMux.Lock()
+	gProcRuntimeData = map[string]*GPCProcRuntimeData{procName: runtimeData}
+	gRuntimeDatatMux.Unlock()
+	gStopMux.Lock()
+	gStopMon = false
+	gShutdownCtx, gShutdownCancel = context.WithCancel(context.Background())
+	gStopMux.Unlock()
+	setShutdownTierTimeout(configData)
+
+	_, exitCode = runProcessAndWait(procName, runtimeData)
+	switch {
+	case runtimeData.procStatus.lastError != nil:
+		return exitCode, runtimeData.procStatus.lastError
+	case runtimeData.procStatus.timeout:
+		return exitCode, fmt.Errorf("process <%s> timed out after <%s>", procName, runTask.WaitForExitTimeout)
+	case runtimeData.procStatus.stopped:
+		return exitCode, fmt.Errorf("process <%s> was stopped", procName)
+	}
+	return exitCode, nil
+}
@@ -0,0 +1,49 @@
+//go:build !windows
+// +build !windows
+
+package gpcprocessmgr
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+//applyRunAs sets the user and group IDs of the RunAsUser of a process for its start. This
+//needs the controller to run as root. The environment gets HOME, USER and LOGNAME of that user.
+//-------------------------------------------------------------------
+func applyRunAs(runtimeData *GPCProcRuntimeData) (func(), error) {
+	if len(runtimeData.procConfig.RunAsUser) == 0 {
+		return func() {}, nil
+	}
+
+	runAsUser, err := user.Lookup(runtimeData.procConfig.RunAsUser)
+	if err != nil {
+		return nil, err
+	}
+	uid, errUID := strconv.ParseUint(runAsUser.Uid, 10, 32)
+	gid, errGID := strconv.ParseUint(runAsUser.Gid, 10, 32)
+	if errUID != nil || errGID != nil {
+		return nil, fmt.Errorf("user <%s> has no numeric user/group ID", runAsUser.Username)
+	}
+
+	credential := &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+	if groupIds, err := runAsUser.GroupIds(); err == nil {
+		for _, groupID := range groupIds {
+			if gid, err := strconv.ParseUint(groupID, 10, 32); err == nil {
+				credential.Groups = append(credential.Groups, uint32(gid))
+			}
+		}
+	}
+	runtimeData.procCmd.SysProcAttr.Credential = credential
+
+	procCmd := runtimeData.procCmd
+	if procCmd.Env == nil {
+		procCmd.Env = os.Environ()
+	}
+	procCmd.Env = append(procCmd.Env, "HOME="+runAsUser.HomeDir, "USER="+runAsUser.Username, "LOGNAME="+runAsUser.Username)
+
+	return func() {}, nil
+}
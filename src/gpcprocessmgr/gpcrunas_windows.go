@@ -0,0 +1,57 @@
+package gpcprocessmgr
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// consts
+const (
+	logon32LogonInteractive = 2
+	logon32ProviderDefault  = 0
+)
+
+var (
+	modadvapi32    = syscall.NewLazyDLL("advapi32.dll")
+	procLogonUserW = modadvapi32.NewProc("LogonUserW")
+)
+
+//applyRunAs logs on the RunAsUser of a process and sets the token for its start. Starting a
+//process with a token needs the controller to hold the "Replace a process level token" right,
+//which services running as LocalSystem have. The returned function closes the token.
+//-------------------------------------------------------------------
+func applyRunAs(runtimeData *GPCProcRuntimeData) (func(), error) {
+	if len(runtimeData.procConfig.RunAsUser) == 0 {
+		return func() {}, nil
+	}
+
+	sUser, sDomain, sPassword, err := runtimeData.procConfig.RunAsCredentials()
+	if err != nil {
+		return nil, err
+	}
+	pUser, err := syscall.UTF16PtrFromString(sUser)
+	if err != nil {
+		return nil, err
+	}
+	var pDomain *uint16
+	if len(sDomain) > 0 {
+		if pDomain, err = syscall.UTF16PtrFromString(sDomain); err != nil {
+			return nil, err
+		}
+	}
+	pPassword, err := syscall.UTF16PtrFromString(sPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	var hToken syscall.Token
+	r1, _, e1 := procLogonUserW.Call(uintptr(unsafe.Pointer(pUser)), uintptr(unsafe.Pointer(pDomain)), uintptr(unsafe.Pointer(pPassword)),
+		logon32LogonInteractive, logon32ProviderDefault, uintptr(unsafe.Pointer(&hToken)))
+	if r1 == 0 {
+		return nil, os.NewSyscallError("LogonUser", e1)
+	}
+
+	runtimeData.procCmd.SysProcAttr.Token = hToken
+	return func() { hToken.Close() }, nil
+}
@@ -0,0 +1,120 @@
+package gpcprocessmgr
+
+import (
+	"gpcconfig"
+	"gpclogging"
+	"sync"
+	"time"
+)
+
+//#######################################################
+//### GLOBAL VARIABLES, INIT, CONSTS
+//#######################################################
+
+// Holiday calendars by name, scheduled runs are skipped on their days
+var gCalendars map[string]*gpcconfig.Calendar
+var gCalendarsMux sync.Mutex
+
+// setCalendars (re)loads the holiday calendars of the configuration
+//------------------------------------------------------------------------------
+func setCalendars(configData *gpcconfig.ConfigData) {
+	calendars, err := gpcconfig.LoadCalendars(configData)
+	if err != nil {
+		gpclogging.Error("Could not load holiday calendars, scheduled tasks run on every day: <%s>", err.Error())
+	}
+
+	gCalendarsMux.Lock()
+	gCalendars = calendars
+	gCalendarsMux.Unlock()
+}
+
+// isHoliday reports whether a scheduled run of a process falls on a day of one of its skip calendars
+//------------------------------------------------------------------------------
+func isHoliday(runtimeData *GPCProcRuntimeData, runTime time.Time) (bool, string) {
+	gCalendarsMux.Lock()
+	defer gCalendarsMux.Unlock()
+
+	return holidayOf(gCalendars, runtimeData.procConfig, runTime)
+}
+
+// startScheduler starts the background loop launching a scheduled process whenever its schedule
+// is due. The loop ends on shutdown or when a reload replaced or removed the process.
+//------------------------------------------------------------------------------
+func startScheduler(procName string, runtimeData *GPCProcRuntimeData) {
+	schedule, err := gpcconfig.ParseSchedule(runtimeData.procConfig.Schedule)
+	if err != nil {
+		gpclogging.Task(procName).Error("Process <%s> will never run: <%s>", procName, err.Error())
+		runtimeData.procStatus.lastError = newProcessError(procName, ErrStartFailed, err)
+		publishProcessEvent(EventProcessStartFailed, procName, runtimeData, err.Error())
+		return
+	}
+	location, err := runtimeData.procConfig.Location()
+	if err != nil {
+		gpclogging.Task(procName).Warn("Process <%s> is scheduled in local time: <%s>", procName, err.Error())
+		location = time.Local
+	}
+
+	gShutdownWaitGroup.Add(1)
+	go func() {
+		defer gShutdownWaitGroup.Done()
+
+		for {
+			nextRun := nextScheduledRun(schedule, location, gClock)
+			if nextRun.IsZero() {
+				gpclogging.Task(procName).Warn("Schedule <%s> of process <%s> is never due.", runtimeData.procConfig.Schedule, procName)
+				return
+			}
+			gpclogging.Debug("Process <%s> is scheduled next at <%s>.", procName, nextRun.Format(time.RFC3339))
+			if !sleepUnlessStopping(nextRun.Sub(gClock.Now())) {
+				return
+			}
+			if currentRuntimeData()[procName] != runtimeData {
+				gpclogging.Debug("Process <%s> was replaced by a reload, ending its schedule.", procName)
+				return
+			}
+
+			if holiday, description := isHoliday(runtimeData, nextRun); holiday {
+				gpclogging.Task(procName).Info("Process <%s> is not started, <%s> is a holiday (%s).", procName,
+					nextRun.Format("2006-01-02"), description)
+				continue
+			}
+			runScheduled(procName, runtimeData)
+		}
+	}()
+}
+
+// runScheduled starts a due run of a scheduled process, applying the overlap policy if
+// the previous run is still active
+//------------------------------------------------------------------------------
+func runScheduled(procName string, runtimeData *GPCProcRuntimeData) {
+	if runtimeData.procStatus.active {
+		switch runtimeData.procConfig.OverlapPolicy {
+		case gpcconfig.OverlapQueue:
+			gpclogging.Task(procName).Info("Process <%s> is still running, the scheduled run waits for it.", procName)
+			for runtimeData.procStatus.active {
+				if !sleepUnlessStopping(100 * time.Millisecond) {
+					return
+				}
+			}
+		case gpcconfig.OverlapKill:
+			gpclogging.Task(procName).Warn("Process <%s> is still running, stopping it for the scheduled run.", procName)
+			stopProcess(shutdownContext(), procName, runtimeData)
+		default:
+			gpclogging.Task(procName).Warn("Process <%s> is still running, skipping the scheduled run.", procName)
+			return
+		}
+	}
+
+	if runtimeData.procStatus.quarantined {
+		gpclogging.Task(procName).Warn("Process <%s> is quarantined, skipping the scheduled run.", procName)
+		return
+	}
+
+	gpclogging.Task(procName).Info("Scheduled run of process <%s> is due.", procName)
+	runtimeData.procStatus.stopped = false
+	runtimeData.procStatus.lastError = nil
+	runtimeData.procStatus.timeout = false
+	runtimeData.procStatus.done = false
+	runtimeData.procStatus.restartCount = 0
+	startWithDelay(procName, runtimeData, gShutdownWaitGroup)
+}
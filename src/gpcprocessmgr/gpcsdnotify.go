@@ -0,0 +1,204 @@
+package gpcprocessmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gpclogging"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A task with NotifyReady gets a unix datagram socket of its own, passed as NOTIFY_SOCKET like
+// systemd does, so sd_notify(3) and systemd-notify work unchanged. The messages understood are
+//
+//	READY=1      the process is ready, it satisfies TaskReady and readiness waits from now on
+//	RELOADING=1  the process is not ready until its next READY=1
+//	STOPPING=1   the process is shutting down by itself, it is not ready and not asked to stop
+//	WATCHDOG=1   counts as heartbeat for ExpectHeartbeat
+//	STATUS=...   logged
+//
+// The messages concern the task, not a run: during a start-first restart both runs send to the
+// same socket.
+
+// size of the datagrams read, longer ones are cut
+const maxNotifyBytes = 4096
+
+var (
+	gNotifyDir   string // directory of the notify sockets, created with the first one
+	gNotifyMux   sync.Mutex
+	gNotifyCount atomic.Uint32 // numbers the sockets, a task of a reloaded configuration gets a new one
+)
+
+// notifySocketPath returns the path for a new notify socket of a task. The directory is removed
+// when the controller shuts down.
+//------------------------------------------------------------------------------
+func notifySocketPath(procName string) (string, error) {
+	gNotifyMux.Lock()
+	defer gNotifyMux.Unlock()
+	if len(gNotifyDir) == 0 {
+		sDir, err := os.MkdirTemp("", "gpc-notify-")
+		if err != nil {
+			return "", err
+		}
+		gNotifyDir = sDir
+		ctx := shutdownContext()
+		go func() {
+			<-ctx.Done()
+			gNotifyMux.Lock()
+			defer gNotifyMux.Unlock()
+			os.RemoveAll(gNotifyDir)
+			gNotifyDir = ""
+		}()
+	}
+	// Task names may hold characters a file name must not
+	sName := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' {
+			return '_'
+		}
+		return r
+	}, procName)
+	return filepath.Join(gNotifyDir, fmt.Sprintf("%d-%s.sock", gNotifyCount.Add(1), sName)), nil
+}
+
+// notifyEnvironment returns NOTIFY_SOCKET for a task with NotifyReady, nil for other tasks. The
+// socket is opened on the first launch and kept for the restarts, it is closed when the task is
+// stopped or the controller shuts down. If it can not be opened (e.g. on Windows), the task is
+// started anyway and counts as ready once started. The caller must hold the lock of the process.
+//------------------------------------------------------------------------------
+func notifyEnvironment(runtimeData *GPCProcRuntimeData) map[string]string {
+	procName := runtimeData.procConfig.Name
+	if !runtimeData.procConfig.NotifyReady {
+		return nil
+	}
+	if runtimeData.notifyConn == nil {
+		sPath, err := notifySocketPath(procName)
+		var conn *net.UnixConn
+		if err == nil {
+			conn, err = net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sPath, Net: "unixgram"})
+		}
+		if err != nil {
+			gpclogging.Task(procName).Error("Could not open the notify socket for process <%s>, it counts as ready once started: <%s>",
+				procName, err.Error())
+			return nil
+		}
+		runtimeData.notifyConn = conn
+		go receiveNotifications(procName, runtimeData, conn)
+	}
+	return map[string]string{"NOTIFY_SOCKET": runtimeData.notifyConn.LocalAddr().String()}
+}
+
+// closeNotifySocket closes the notify socket of a process, if it has one. The caller must hold
+// the lock of the process.
+//------------------------------------------------------------------------------
+func (r *GPCProcRuntimeData) closeNotifySocket() {
+	if r.notifyConn != nil {
+		r.notifyConn.Close()
+		os.Remove(r.notifyConn.LocalAddr().String())
+		r.notifyConn = nil
+	}
+}
+
+// receiveNotifications handles the messages arriving on the notify socket of a process until it
+// is closed
+//------------------------------------------------------------------------------
+func receiveNotifications(procName string, runtimeData *GPCProcRuntimeData, conn *net.UnixConn) {
+	ctx := shutdownContext()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buffer := make([]byte, maxNotifyBytes)
+	for {
+		count, _, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				gpclogging.Task(procName).Error("Notify socket of process <%s> failed, no more notifications are received: <%s>", procName, err.Error())
+			}
+			return
+		}
+		for _, sLine := range strings.Split(string(buffer[:count]), "\n") {
+			sKey, sValue, _ := strings.Cut(strings.TrimSpace(sLine), "=")
+			runtimeData.notify(procName, sKey, sValue)
+		}
+	}
+}
+
+// notify applies one message of a process received on its notify socket
+//------------------------------------------------------------------------------
+func (r *GPCProcRuntimeData) notify(procName string, sKey string, sValue string) {
+	switch {
+	case sKey == "READY" && sValue == "1":
+		r.mux.Lock()
+		bChanged := !r.procStatus.notifiedReady
+		r.procStatus.notifiedReady = true
+		r.mux.Unlock()
+		if bChanged {
+			gpclogging.Task(procName).Info("Process <%s>, PID=<%d> notified it is READY.", procName, r.procStatus.pid)
+		}
+	case sKey == "RELOADING" && sValue == "1":
+		r.mux.Lock()
+		r.procStatus.notifiedReady = false
+		r.mux.Unlock()
+		gpclogging.Task(procName).Info("Process <%s>, PID=<%d> notified it is reloading.", procName, r.procStatus.pid)
+	case sKey == "STOPPING" && sValue == "1":
+		r.mux.Lock()
+		r.procStatus.notifiedStopping = true
+		r.mux.Unlock()
+		gpclogging.Task(procName).Info("Process <%s>, PID=<%d> notified it is stopping.", procName, r.procStatus.pid)
+	case sKey == "WATCHDOG" && sValue == "1":
+		r.recordHeartbeat(time.Now())
+	case sKey == "STATUS":
+		gpclogging.Task(procName).Debug("Process <%s> status: <%s>", procName, sValue)
+	case len(sKey) > 0:
+		gpclogging.Debug("Notification <%s> of process <%s> ignored.", sKey, procName)
+	}
+}
+
+// isNotifiedReady reports whether a process counts as ready by its notifications: it sent READY=1
+// and not STOPPING=1 since. Processes without NotifyReady, without a notify socket or adopted
+// ones always do. The caller must hold the lock of the process.
+//------------------------------------------------------------------------------
+func (r *GPCProcRuntimeData) isNotifiedReady() bool {
+	if !r.procConfig.NotifyReady || r.notifyConn == nil || r.procStatus.adopted {
+		return true
+	}
+	return r.procStatus.notifiedReady && !r.procStatus.notifiedStopping
+}
+
+// waitNotifiedReady waits up to the ReadinessTimeout of the tasks for the ones with NotifyReady to
+// notify they are ready. Tasks that are not running are not waited for. Returns the errors of
+// the tasks that did not get ready.
+//------------------------------------------------------------------------------
+func waitNotifiedReady(ctx context.Context, procNames []string) []error {
+	var errs []error
+	for _, procName := range procNames {
+		runtimeData, err := getRuntimeData(procName)
+		if err != nil || !runtimeData.procConfig.NotifyReady {
+			continue
+		}
+		readyCtx, cancel := context.WithTimeout(ctx, runtimeData.procConfig.ReadinessWait())
+		for {
+			runtimeData.mux.Lock()
+			bActive := runtimeData.procStatus.active
+			bReady := runtimeData.isNotifiedReady()
+			runtimeData.mux.Unlock()
+			if !bActive || bReady {
+				break
+			}
+			if !sleepContext(readyCtx, readinessProbeInterval) {
+				errs = append(errs, newProcessError(procName, ErrNotReady,
+					fmt.Errorf("no READY=1 within %s", runtimeData.procConfig.ReadinessWait())))
+				break
+			}
+		}
+		cancel()
+	}
+	return errs
+}
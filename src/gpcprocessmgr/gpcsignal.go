@@ -0,0 +1,43 @@
+package gpcprocessmgr
+
+import (
+	"gpclogging"
+	"strings"
+)
+
+//SignalProcess delivers a signal to the main process of a running task, e.g. HUP to make it
+//reload its configuration. The signal is given by name, with or without SIG prefix, see
+//Signals for the names of the platform. Unlike a stop the controller does not expect the
+//process to end: if the signal ends it, it is restarted like a crashed one.
+//#########################################################
+func SignalProcess(procName string, sSignal string) error {
+	gpclogging.Debug("Entering SignalProcess() for process <%s> with signal <%s>", procName, sSignal)
+
+	runtimeData, err := getRuntimeData(procName)
+	if err != nil {
+		return err
+	}
+	sName := signalName(sSignal)
+	runtimeData.mux.Lock()
+	if !runtimeData.procStatus.active {
+		runtimeData.mux.Unlock()
+		return newProcessError(procName, ErrNotRunning, nil)
+	}
+	err = sendSignal(runtimeData, sName)
+	pid := runtimeData.procStatus.pid
+	runtimeData.mux.Unlock()
+	if err != nil {
+		return newProcessError(procName, ErrSignalFailed, err)
+	}
+	gpclogging.Task(procName).Info("Sent <%s> to process <%s>, PID=<%d>.", sName, procName, pid)
+
+	gpclogging.Debug("Leaving SignalProcess()")
+	return nil
+}
+
+// signalName returns the name of a signal as listed by Signals, i.e. in upper case and without SIG prefix
+//------------------------------------------------------------------------------
+func signalName(sSignal string) string {
+	sName := strings.ToUpper(strings.TrimSpace(sSignal))
+	return strings.TrimPrefix(sName, "SIG")
+}
@@ -0,0 +1,47 @@
+//go:build !windows
+// +build !windows
+
+package gpcprocessmgr
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"syscall"
+)
+
+// signals that can be sent to a task. STOP and CONT are left to suspend and resume, KILL to stop.
+var unixSignals = map[string]syscall.Signal{
+	"HUP":   syscall.SIGHUP,
+	"INT":   syscall.SIGINT,
+	"QUIT":  syscall.SIGQUIT,
+	"TERM":  syscall.SIGTERM,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"WINCH": syscall.SIGWINCH,
+}
+
+//Signals returns the names of the signals SignalProcess can send, sorted
+//#########################################################
+func Signals() []string {
+	var names []string
+	for sName := range unixSignals {
+		names = append(names, sName)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sendSignal sends the named signal to the main process of a task, not to its whole group: a
+// process that forwards a reload to its workers expects to be the only one getting it
+//------------------------------------------------------------------------------
+func sendSignal(runtimeData *GPCProcRuntimeData, sName string) error {
+	signal, found := unixSignals[sName]
+	if !found {
+		return fmt.Errorf("unknown signal <%s>, use one of %v", sName, Signals())
+	}
+	if err := syscall.Kill(runtimeData.procStatus.pid, signal); err != nil {
+		return os.NewSyscallError("kill", err)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package gpcprocessmgr
+
+import (
+	"fmt"
+	"os"
+)
+
+// console control event of GenerateConsoleCtrlEvent
+const ctrlBreakEvent = 1
+
+var procGenerateConsoleCtrlEvent = modkernel32.NewProc("GenerateConsoleCtrlEvent")
+
+//Signals returns the names of the events SignalProcess can send. CTRL_C can not be sent to
+//a single process group, only CTRL_BREAK.
+//#########################################################
+func Signals() []string {
+	return []string{"CTRL_BREAK"}
+}
+
+// sendSignal sends CTRL_BREAK to the process group of the task. Each task is started as leader of
+// a process group of its own, see prepare. The event only reaches processes that share the
+// console of the controller, so not the ones of a controller running as service or of tasks
+// with a console window of their own.
+//------------------------------------------------------------------------------
+func sendSignal(runtimeData *GPCProcRuntimeData, sName string) error {
+	if sName != "CTRL_BREAK" && sName != "CTRL_BREAK_EVENT" && sName != "BREAK" {
+		return fmt.Errorf("unknown console event <%s>, use one of %v", sName, Signals())
+	}
+	ok, _, err := procGenerateConsoleCtrlEvent.Call(ctrlBreakEvent, uintptr(runtimeData.procStatus.pid))
+	if ok == 0 {
+		return os.NewSyscallError("GenerateConsoleCtrlEvent", err)
+	}
+	return nil
+}
@@ -0,0 +1,448 @@
+package gpcprocessmgr
+
+import (
+	"bufio"
+	"fmt"
+	"gpcconfig"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kinds of simulation events
+const (
+	simStart     = iota // a task is started
+	simRetry            // a failed wait task is run again
+	simExit             // a run ends with an exit code
+	simTimeout          // a wait task exceeds its WaitForExitTimeout, a no-wait task its MaxRuntime
+	simScheduled        // the schedule of a task is due
+	simRecycle          // the RestartEvery of a running no-wait task is due
+)
+
+// simEvent is something that happens at a point of time of a simulation
+type simEvent struct {
+	at    time.Time
+	seq   int // events of the same time are handled in the order they were added
+	kind  int
+	task  string
+	code  int // exit code of simExit
+	runID int // run the event belongs to, zero => the run active at that time
+}
+
+// simScriptLine is a line of a failure script
+type simScriptLine struct {
+	at   time.Time
+	task string
+	hang bool // the wait task started at that time runs into its timeout
+	code int  // exit code otherwise
+	used bool // consumed by a run of a wait task
+}
+
+// simTask is the state of a task during a simulation
+type simTask struct {
+	config       *gpcconfig.ProcessConfig
+	schedule     *gpcconfig.Schedule
+	location     *time.Location
+	running      bool
+	runID        int
+	restartCount uint32
+	restartTimes []time.Time
+	quarantined  bool
+	retries      uint32
+	queued       bool // a scheduled run waits for the active one (OverlapPolicy queue)
+}
+
+// simulation is the state of a run of Simulate
+type simulation struct {
+	clock     *FakeClock
+	begin     time.Time
+	events    []simEvent // ordered by time
+	seq       int
+	tasks     map[string]*simTask
+	taskNames []string // sorted, to make the output repeatable
+	script    []*simScriptLine
+	calendars map[string]*gpcconfig.Calendar
+	out       io.Writer
+}
+
+//Simulate prints the actions the controller would take for a configuration over the given
+//duration, without starting anything. A fake clock starts at begin. The failure script tells
+//when processes end, one line each:
+//
+//	<offset> <task> exit <code>    the task running at begin+offset ends with the exit code
+//	<offset> <task> hang           the wait task started at begin+offset runs into its timeout
+//
+//Offsets are durations like "90s" or "2h30m", lines starting with # are comments. No-wait tasks
+//run until the script ends them or their MaxRuntime is up. A run of a wait task ends at the first
+//line of the task within its WaitForExitTimeout, without one it ends with exit code 0 at once.
+//Mutex groups, memory limits, preconditions and adopted processes are not simulated.
+//#########################################################
+func Simulate(configData *gpcconfig.ConfigData, script io.Reader, begin time.Time, duration time.Duration, out io.Writer) error {
+	sim := &simulation{clock: NewFakeClock(begin), begin: begin, tasks: make(map[string]*simTask), out: out}
+
+	var err error
+	if sim.calendars, err = gpcconfig.LoadCalendars(configData); err != nil {
+		return err
+	}
+	if sim.script, err = parseSimScript(script, begin); err != nil {
+		return err
+	}
+	for configIndex := range configData.Tasks {
+		sim.tasks[configData.Tasks[configIndex].Name] = &simTask{config: &configData.Tasks[configIndex]}
+		sim.taskNames = append(sim.taskNames, configData.Tasks[configIndex].Name)
+	}
+	sort.Strings(sim.taskNames)
+
+	// No-wait tasks end when the script says so, wait tasks look at the script when they start
+	for _, line := range sim.script {
+		task, found := sim.tasks[line.task]
+		if !found {
+			return fmt.Errorf("failure script: unknown task <%s>", line.task)
+		}
+		if task.config.WaitForExitTimeout.Duration == 0 {
+			if line.hang {
+				return fmt.Errorf("failure script: task <%s> is no wait task, it can not hang", line.task)
+			}
+			sim.push(simEvent{at: line.at, kind: simExit, task: line.task, code: line.code})
+		}
+	}
+
+	// Startup, as StartProcessesFromConfig does it
+	for _, taskName := range sim.taskNames {
+		task := sim.tasks[taskName]
+		switch {
+		case configData.IsFollowUpTask(taskName) || task.config.IsColdStandby():
+			continue
+		case len(task.config.Schedule) > 0:
+			sim.scheduleNext(taskName, task)
+		default:
+			sim.startWithDelay(taskName)
+		}
+	}
+
+	end := begin.Add(duration)
+	for len(sim.events) > 0 && !sim.events[0].at.After(end) {
+		event := sim.events[0]
+		sim.events = sim.events[1:]
+		sim.clock.Set(event.at)
+		sim.handle(event)
+	}
+	return nil
+}
+
+// parseSimScript reads a failure script, see Simulate. The lines are returned in time order.
+//------------------------------------------------------------------------------
+func parseSimScript(script io.Reader, begin time.Time) ([]*simScriptLine, error) {
+	var lines []*simScriptLine
+
+	lineNum := 0
+	scanner := bufio.NewScanner(script)
+	for scanner.Scan() {
+		lineNum++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("failure script, line %d: expected <offset> <task> exit <code> or <offset> <task> hang", lineNum)
+		}
+		offset, err := time.ParseDuration(fields[0])
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("failure script, line %d: invalid offset <%s>", lineNum, fields[0])
+		}
+
+		line := &simScriptLine{at: begin.Add(offset), task: fields[1]}
+		switch {
+		case fields[2] == "hang" && len(fields) == 3:
+			line.hang = true
+		case fields[2] == "exit" && len(fields) == 4:
+			if line.code, err = strconv.Atoi(fields[3]); err != nil {
+				return nil, fmt.Errorf("failure script, line %d: invalid exit code <%s>", lineNum, fields[3])
+			}
+		default:
+			return nil, fmt.Errorf("failure script, line %d: unknown action <%s>", lineNum, strings.Join(fields[2:], " "))
+		}
+		lines = append(lines, line)
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].at.Before(lines[j].at) })
+	return lines, scanner.Err()
+}
+
+// push adds an event to the queue, after the events of the same time
+//------------------------------------------------------------------------------
+func (sim *simulation) push(event simEvent) {
+	sim.seq++
+	event.seq = sim.seq
+	index := sort.Search(len(sim.events), func(i int) bool { return sim.events[i].at.After(event.at) })
+	sim.events = append(sim.events, simEvent{})
+	copy(sim.events[index+1:], sim.events[index:])
+	sim.events[index] = event
+}
+
+// report prints an action of the simulated controller
+//------------------------------------------------------------------------------
+func (sim *simulation) report(taskName string, format string, args ...interface{}) {
+	now := sim.clock.Now()
+	fmt.Fprintf(sim.out, "%s  %-10s  %-20s  %s\n", now.Format("2006-01-02 15:04:05"), "+"+now.Sub(sim.begin).String(),
+		taskName, fmt.Sprintf(format, args...))
+}
+
+// handle applies an event to the simulated controller
+//------------------------------------------------------------------------------
+func (sim *simulation) handle(event simEvent) {
+	task := sim.tasks[event.task]
+
+	switch event.kind {
+	case simStart, simRetry:
+		sim.start(event.task, task, event.kind == simRetry)
+	case simExit, simTimeout:
+		if !task.running || (event.runID != 0 && event.runID != task.runID) {
+			if event.runID == 0 {
+				sim.report(event.task, "script: exit code <%d> ignored, the process is not running", event.code)
+			}
+			return
+		}
+		sim.ended(event.task, task, event.code, event.kind == simTimeout)
+	case simScheduled:
+		if holiday, description := holidayOf(sim.calendars, task.config, sim.clock.Now().In(task.location)); holiday {
+			sim.report(event.task, "not started, holiday (%s)", description)
+		} else {
+			sim.runScheduled(event.task, task)
+		}
+		sim.scheduleNext(event.task, task)
+	case simRecycle:
+		if !task.running || event.runID != task.runID {
+			return
+		}
+		sim.report(event.task, "scheduled restart (RestartEvery <%s>)", task.config.RestartEvery)
+		task.running = false
+		sim.start(event.task, task, false)
+	}
+}
+
+// startWithDelay starts a task once its StartDelay has passed
+//------------------------------------------------------------------------------
+func (sim *simulation) startWithDelay(taskName string) {
+	sim.push(simEvent{at: sim.clock.Now().Add(sim.tasks[taskName].config.StartDelay.Duration), kind: simStart, task: taskName})
+}
+
+// start starts a run of a task. The end of a wait task is known from the script right away.
+//------------------------------------------------------------------------------
+func (sim *simulation) start(taskName string, task *simTask, bRetry bool) {
+	if task.running {
+		sim.report(taskName, "still running, not started again")
+		return
+	}
+	if !bRetry {
+		task.retries = 0
+	}
+	task.running = true
+	task.runID++
+
+	timeout := task.config.WaitForExitTimeout.Duration
+	if timeout == 0 {
+		sim.report(taskName, "started")
+		if restartAt := scheduledRestartAt(task.config, sim.clock.Now()); !restartAt.IsZero() {
+			sim.push(simEvent{at: restartAt, kind: simRecycle, task: taskName, runID: task.runID})
+		}
+		if maxRuntime := task.config.MaxRuntime.Duration; maxRuntime > 0 {
+			sim.push(simEvent{at: sim.clock.Now().Add(maxRuntime), kind: simTimeout, task: taskName, code: -1, runID: task.runID})
+		}
+		return
+	}
+	sim.report(taskName, "started, waiting up to %s", timeout)
+
+	now := sim.clock.Now()
+	line := sim.scriptLineOf(taskName, now, now.Add(timeout))
+	switch {
+	case line == nil:
+		sim.push(simEvent{at: now, kind: simExit, task: taskName, code: 0, runID: task.runID})
+	case line.hang:
+		sim.push(simEvent{at: now.Add(timeout), kind: simTimeout, task: taskName, code: -1, runID: task.runID})
+	default:
+		sim.push(simEvent{at: line.at, kind: simExit, task: taskName, code: line.code, runID: task.runID})
+	}
+}
+
+// scriptLineOf returns the first unused script line of a task between two times, nil if there is none
+//------------------------------------------------------------------------------
+func (sim *simulation) scriptLineOf(taskName string, from time.Time, to time.Time) *simScriptLine {
+	for _, line := range sim.script {
+		if line.used || line.task != taskName || line.at.Before(from) || line.at.After(to) {
+			continue
+		}
+		line.used = true
+		return line
+	}
+	return nil
+}
+
+// ended handles the end of a run like the monitor (no-wait tasks) or launchProcessAndWait does
+//------------------------------------------------------------------------------
+func (sim *simulation) ended(taskName string, task *simTask, exitCode int, bTimedOut bool) {
+	task.running = false
+
+	if task.config.WaitForExitTimeout.Duration == 0 && bTimedOut {
+		sim.report(taskName, "terminated after its MaxRuntime of %s, will not restart", task.config.MaxRuntime)
+		if task.config.MaxRuntimeIsError {
+			sim.promoteStandbys(taskName)
+		}
+	} else if task.config.WaitForExitTimeout.Duration == 0 {
+		sim.report(taskName, "exited with code <%d>", exitCode)
+		switch {
+		case task.config.IsSuccessExit(exitCode):
+			sim.report(taskName, "exit code <%d> is a success exit code, the task is done", exitCode)
+		case task.config.IsNoRestartExit(exitCode):
+			sim.report(taskName, "exit code <%d> is configured to not restart, will not restart", exitCode)
+			sim.promoteStandbys(taskName)
+		case task.config.MaxRestarts == 0:
+			sim.promoteStandbys(taskName)
+		case !shouldRestart(task.config, task.restartCount):
+			sim.report(taskName, "reached the max restart count of <%d>, will not restart", task.config.MaxRestarts)
+			sim.promoteStandbys(taskName)
+		case crashLoopDetected(task.config, &task.restartTimes, sim.clock.Now()):
+			sim.report(taskName, "crashed more than <%d> times within <%s>, quarantined", task.config.CrashLoopRestarts,
+				crashLoopWindow(task.config).String())
+			task.quarantined = true
+			sim.promoteStandbys(taskName)
+		default:
+			task.restartCount++
+			sim.report(taskName, "restart attempt <%d> of <%d>", task.restartCount, task.config.MaxRestarts)
+			sim.push(simEvent{at: sim.clock.Now(), kind: simStart, task: taskName})
+		}
+	} else {
+		sim.waitEnded(taskName, task, exitCode, bTimedOut)
+	}
+
+	if task.queued && task.quarantined {
+		task.queued = false
+		sim.report(taskName, "quarantined, skipping the queued scheduled run")
+	}
+	if task.queued {
+		task.queued = false
+		sim.report(taskName, "queued scheduled run is due")
+		task.restartCount = 0
+		sim.startWithDelay(taskName)
+	}
+}
+
+// waitEnded retries a failed wait task or triggers its follow up tasks
+//------------------------------------------------------------------------------
+func (sim *simulation) waitEnded(taskName string, task *simTask, exitCode int, bTimedOut bool) {
+	succeeded := !bTimedOut && exitCode == 0
+	switch {
+	case bTimedOut:
+		sim.report(taskName, "terminated after the timeout of %s", task.config.WaitForExitTimeout)
+	case succeeded:
+		sim.report(taskName, "ended with exit code <0>")
+	default:
+		sim.report(taskName, "failed with exit code <%d>", exitCode)
+	}
+
+	if !succeeded && task.retries < task.config.Retries {
+		if task.config.ShouldRetry(exitCode) {
+			task.retries++
+			sim.report(taskName, "retry <%d> of <%d> in %s", task.retries, task.config.Retries, task.config.RetryDelay)
+			sim.push(simEvent{at: sim.clock.Now().Add(task.config.RetryDelay.Duration), kind: simRetry, task: taskName})
+			return
+		}
+		sim.report(taskName, "exit code <%d> is not configured for a retry", exitCode)
+	}
+	if !succeeded && task.config.Retries > 0 {
+		sim.report(taskName, "giving up after <%d> retries", task.retries)
+	}
+
+	followUps, outcome := followUpsOf(task.config, succeeded)
+	for _, followUpName := range followUps {
+		followUp, found := sim.tasks[followUpName]
+		switch {
+		case !found:
+			sim.report(taskName, "%s, but follow up task <%s> is unknown", outcome, followUpName)
+		case followUp.running:
+			sim.report(taskName, "%s, but follow up task <%s> is still running", outcome, followUpName)
+		default:
+			sim.report(taskName, "%s, triggering follow up task <%s>", outcome, followUpName)
+			sim.startWithDelay(followUpName)
+		}
+	}
+	if !succeeded {
+		sim.promoteStandbys(taskName)
+	}
+}
+
+// promoteStandbys lets the standbys of a failed task take over
+//------------------------------------------------------------------------------
+func (sim *simulation) promoteStandbys(primaryName string) {
+	for _, standbyName := range sim.taskNames {
+		standby := sim.tasks[standbyName]
+		if standby.config.StandbyFor != primaryName {
+			continue
+		}
+		if standby.running {
+			if standby.config.StandbyMode == gpcconfig.StandbyWarm {
+				sim.report(standbyName, "warm standby is promoted, <%s> failed", primaryName)
+			}
+			continue
+		}
+		if standby.quarantined {
+			sim.report(standbyName, "standby is quarantined, does not take over from <%s>", primaryName)
+			continue
+		}
+		sim.report(standbyName, "standby takes over, <%s> failed", primaryName)
+		standby.restartCount = 0
+		sim.startWithDelay(standbyName)
+	}
+}
+
+// scheduleNext queues the next due time of a scheduled task
+//------------------------------------------------------------------------------
+func (sim *simulation) scheduleNext(taskName string, task *simTask) {
+	if task.schedule == nil {
+		var err error
+		if task.schedule, err = gpcconfig.ParseSchedule(task.config.Schedule); err != nil {
+			sim.report(taskName, "will never run: %s", err.Error())
+			return
+		}
+		if task.location, err = task.config.Location(); err != nil {
+			sim.report(taskName, "scheduled in local time: %s", err.Error())
+			task.location = time.Local
+		}
+	}
+
+	nextRun := nextScheduledRun(task.schedule, task.location, sim.clock)
+	if nextRun.IsZero() {
+		sim.report(taskName, "schedule <%s> is never due", task.config.Schedule)
+		return
+	}
+	sim.push(simEvent{at: nextRun, kind: simScheduled, task: taskName})
+}
+
+// runScheduled starts a due run of a scheduled task, applying the overlap policy like runScheduled
+//------------------------------------------------------------------------------
+func (sim *simulation) runScheduled(taskName string, task *simTask) {
+	if task.running {
+		switch task.config.OverlapPolicy {
+		case gpcconfig.OverlapQueue:
+			sim.report(taskName, "still running, the scheduled run waits for it")
+			task.queued = true
+			return
+		case gpcconfig.OverlapKill:
+			sim.report(taskName, "still running, stopping it for the scheduled run")
+			task.running = false
+		default:
+			sim.report(taskName, "still running, skipping the scheduled run")
+			return
+		}
+	}
+
+	if task.quarantined {
+		sim.report(taskName, "quarantined, skipping the scheduled run")
+		return
+	}
+	sim.report(taskName, "scheduled run is due")
+	task.restartCount = 0
+	sim.startWithDelay(taskName)
+}
@@ -0,0 +1,222 @@
+package gpcprocessmgr
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"gpcconfig"
+	"gpclogging"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Snapshot is the desired state of a controller: the active configuration including the
+// tasks added at runtime, and the processes that were stopped by an operator or adopted
+type Snapshot struct {
+	Host      string               // host the snapshot was taken on
+	Created   time.Time            // time the snapshot was taken
+	Config    gpcconfig.ConfigData `json:"-"` // stored as a configuration file of its own in the archive
+	Processes []ProcessSnapshot
+}
+
+// ProcessSnapshot is the state of one process in a Snapshot
+type ProcessSnapshot struct {
+	Name    string
+	Stopped bool // stopped on request, it is not started on restore
+	Adopted bool // running instance taken over from outside of the controller
+	PID     int  // PID of an adopted instance, only meaningful on the same host
+}
+
+// entries of a snapshot archive
+const (
+	snapshotConfigEntry    = "config.json"
+	snapshotStateEntry     = "state.json"
+	snapshotCalendarFolder = "calendars/"
+)
+
+//ExportSnapshot writes the configuration and the state of all processes to a zip archive,
+//together with the holiday calendar files the configuration refers to. Encrypted values are
+//written encrypted, as in the configuration file.
+//#########################################################
+func ExportSnapshot(sArchivePath string, configData *gpcconfig.ConfigData) error {
+	gpclogging.Debug("Entering ExportSnapshot() with archive <%s>", sArchivePath)
+
+	tConfigData, err := configData.EncryptedCopy()
+	if err != nil {
+		return err
+	}
+	snapshot := Snapshot{Created: time.Now(), Config: tConfigData, Processes: processSnapshots()}
+	snapshot.Host, _ = os.Hostname()
+
+	archiveFile, err := os.Create(sArchivePath)
+	if err != nil {
+		return err
+	}
+	err = writeSnapshot(zip.NewWriter(archiveFile), &snapshot)
+	if closeErr := archiveFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(sArchivePath)
+		return err
+	}
+
+	gpclogging.Info("Snapshot of <%d> processes written to <%s>", len(snapshot.Processes), sArchivePath)
+	return nil
+}
+
+// writeSnapshot writes the entries of a snapshot archive and closes the zip writer
+//------------------------------------------------------------------------------
+func writeSnapshot(zipWriter *zip.Writer, snapshot *Snapshot) error {
+	configBytes, err := json.MarshalIndent(&snapshot.Config, "", "  ")
+	if err != nil {
+		return err
+	}
+	stateBytes, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeZipEntry(zipWriter, snapshotConfigEntry, configBytes, snapshot.Created); err != nil {
+		return err
+	}
+	if err := writeZipEntry(zipWriter, snapshotStateEntry, stateBytes, snapshot.Created); err != nil {
+		return err
+	}
+	for sName, sPath := range snapshot.Config.Calendars {
+		calendarBytes, err := os.ReadFile(sPath)
+		if err != nil {
+			return fmt.Errorf("holiday calendar <%s>: %s", sName, err.Error())
+		}
+		sEntryName := snapshotCalendarFolder + calendarFileName(sName, sPath)
+		if err := writeZipEntry(zipWriter, sEntryName, calendarBytes, snapshot.Created); err != nil {
+			return err
+		}
+	}
+	return zipWriter.Close()
+}
+
+// writeZipEntry adds a compressed entry to an archive
+//------------------------------------------------------------------------------
+func writeZipEntry(zipWriter *zip.Writer, sEntryName string, content []byte, modified time.Time) error {
+	entryWriter, err := zipWriter.CreateHeader(&zip.FileHeader{Name: sEntryName, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = entryWriter.Write(content)
+	return err
+}
+
+// calendarFileName is the file name of a holiday calendar in an archive and after a restore
+//------------------------------------------------------------------------------
+func calendarFileName(sName string, sPath string) string {
+	return sName + filepath.Ext(sPath)
+}
+
+//ReadSnapshot reads a snapshot archive. The holiday calendars are extracted to sCalendarDir
+//and the calendar paths of the configuration point there.
+//#########################################################
+func ReadSnapshot(sArchivePath string, sCalendarDir string) (*Snapshot, error) {
+	gpclogging.Debug("Entering ReadSnapshot() with archive <%s>", sArchivePath)
+
+	zipReader, err := zip.OpenReader(sArchivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer zipReader.Close()
+
+	var snapshot Snapshot
+	if err := readZipJSON(&zipReader.Reader, snapshotStateEntry, &snapshot); err != nil {
+		return nil, err
+	}
+	if err := readZipJSON(&zipReader.Reader, snapshotConfigEntry, &snapshot.Config); err != nil {
+		return nil, err
+	}
+
+	for sName, sPath := range snapshot.Config.Calendars {
+		sFileName := calendarFileName(sName, sPath)
+		if filepath.Base(sFileName) != sFileName {
+			return nil, fmt.Errorf("holiday calendar <%s>: invalid name", sName)
+		}
+		calendarBytes, err := readZipEntry(&zipReader.Reader, snapshotCalendarFolder+sFileName)
+		if err != nil {
+			return nil, fmt.Errorf("holiday calendar <%s>: %s", sName, err.Error())
+		}
+		if err := os.MkdirAll(sCalendarDir, 0755); err != nil {
+			return nil, err
+		}
+		sCalendarPath := filepath.Join(sCalendarDir, sFileName)
+		if err := os.WriteFile(sCalendarPath, calendarBytes, 0644); err != nil {
+			return nil, err
+		}
+		snapshot.Config.Calendars[sName] = sCalendarPath
+	}
+
+	return &snapshot, nil
+}
+
+// readZipJSON decodes the JSON of an archive entry
+//------------------------------------------------------------------------------
+func readZipJSON(zipReader *zip.Reader, sEntryName string, value interface{}) error {
+	entryBytes, err := readZipEntry(zipReader, sEntryName)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(entryBytes, value); err != nil {
+		return fmt.Errorf("%s: %s", sEntryName, err.Error())
+	}
+	return nil
+}
+
+// readZipEntry returns the content of an archive entry
+//------------------------------------------------------------------------------
+func readZipEntry(zipReader *zip.Reader, sEntryName string) ([]byte, error) {
+	entryFile, err := zipReader.Open(sEntryName)
+	if err != nil {
+		return nil, fmt.Errorf("archive has no entry <%s>", sEntryName)
+	}
+	defer entryFile.Close()
+	return io.ReadAll(entryFile)
+}
+
+//RestoreSnapshot applies the configuration of a snapshot like ApplyConfig. Processes that
+//were stopped when the snapshot was taken are not started, running ones are stopped.
+//Adopted processes are taken over again by their AdoptPIDFile/AdoptExecutable.
+//configData must be the configuration of the snapshot as kept by the caller.
+//#########################################################
+func RestoreSnapshot(snapshot *Snapshot, configData *gpcconfig.ConfigData) []string {
+	gpclogging.Info("Restoring snapshot of host <%s> from <%s>", snapshot.Host, snapshot.Created.Format(time.RFC3339))
+
+	stoppedNames := make(map[string]bool)
+	for _, procSnapshot := range snapshot.Processes {
+		if procSnapshot.Stopped {
+			stoppedNames[procSnapshot.Name] = true
+		}
+		if procSnapshot.Adopted {
+			gpclogging.Info("Process <%s> had adopted PID <%d> on host <%s>.", procSnapshot.Name, procSnapshot.PID, snapshot.Host)
+		}
+	}
+	return applyConfig(configData, stoppedNames)
+}
+
+// processSnapshots returns the state of all processes, sorted by name
+//------------------------------------------------------------------------------
+func processSnapshots() []ProcessSnapshot {
+	procRuntimeData := currentRuntimeData()
+	procSnapshots := make([]ProcessSnapshot, 0, len(procRuntimeData))
+	for procName, runtimeData := range procRuntimeData {
+		procSnapshot := ProcessSnapshot{Name: procName}
+		runtimeData.mux.Lock()
+		procSnapshot.Stopped = runtimeData.procStatus.stopped && !runtimeData.procStatus.active
+		if runtimeData.procStatus.adopted && runtimeData.procStatus.active {
+			procSnapshot.Adopted = true
+			procSnapshot.PID = runtimeData.procStatus.pid
+		}
+		runtimeData.mux.Unlock()
+		procSnapshots = append(procSnapshots, procSnapshot)
+	}
+	sort.Slice(procSnapshots, func(i, j int) bool { return procSnapshots[i].Name < procSnapshots[j].Name })
+	return procSnapshots
+}
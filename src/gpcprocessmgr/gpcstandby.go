@@ -0,0 +1,65 @@
+package gpcprocessmgr
+
+import (
+	"gpcconfig"
+	"gpclogging"
+	"os/exec"
+)
+
+// promoteStandbys lets the standby tasks of a primary take over once the primary is declared
+// failed, i.e. it can not be started or it ended and will not be restarted. Cold standbys are
+// started, warm standbys get their promote command run (or are started if they are not running).
+// Nothing happens if the primary was stopped on request.
+//------------------------------------------------------------------------------
+func promoteStandbys(primary *GPCProcRuntimeData) {
+	if primary.procStatus.stopped || isStopping() {
+		return
+	}
+	primaryName := primary.procConfig.Name
+
+	for standbyName, standby := range currentRuntimeData() {
+		if standby.procConfig.StandbyFor != primaryName {
+			continue
+		}
+
+		if standby.procStatus.active {
+			if standby.procConfig.StandbyMode == gpcconfig.StandbyWarm {
+				gpclogging.Task(standbyName).Warn("Process <%s> failed, promoting warm standby <%s>.", primaryName, standbyName)
+				runPromoteCommand(standby)
+			}
+			continue
+		}
+		if standby.procStatus.quarantined {
+			gpclogging.Task(standbyName).Warn("Process <%s> failed, standby <%s> is quarantined and does not take over.", primaryName, standbyName)
+			continue
+		}
+
+		gpclogging.Task(standbyName).Warn("Process <%s> failed, standby <%s> takes over.", primaryName, standbyName)
+		standby.procStatus.stopped = false
+		standby.procStatus.lastError = nil
+		standby.procStatus.timeout = false
+		standby.procStatus.done = false
+		standby.procStatus.restartCount = 0
+		startWithDelay(standbyName, standby, gShutdownWaitGroup)
+	}
+}
+
+// runPromoteCommand starts the promote command of a warm standby - fire and forget
+//------------------------------------------------------------------------------
+func runPromoteCommand(standby *GPCProcRuntimeData) {
+	if len(standby.procConfig.PromotePath) == 0 {
+		return
+	}
+
+	procCmd := exec.Command(standby.procConfig.PromotePath, standby.procConfig.PromoteArgs...)
+	gPlatform.prepare(procCmd, true)
+
+	err := procCmd.Start()
+	if err != nil {
+		gpclogging.Task(standby.procConfig.Name).Error("Could not run promote command <%s> of process <%s>, Error message is <%s>",
+			standby.procConfig.PromotePath, standby.procConfig.Name, err.Error())
+		return
+	}
+	// Reap it in background, nobody is interested in the result
+	go procCmd.Wait()
+}
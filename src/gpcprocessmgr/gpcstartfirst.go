@@ -0,0 +1,116 @@
+package gpcprocessmgr
+
+import (
+	"context"
+	"gpcconfig"
+	"gpclogging"
+	"strings"
+)
+
+// startsFirst reports whether a restart of a running process starts the new run before stopping
+// the old one. Adopted processes were not started by the controller and are stopped first.
+//------------------------------------------------------------------------------
+func startsFirst(runtimeData *GPCProcRuntimeData) bool {
+	return runtimeData.procConfig.RestartStrategy == gpcconfig.RestartStartFirst && !runtimeData.procStatus.adopted
+}
+
+// restartStartFirst restarts a running process with the start-first strategy: the current run is
+// set aside, a new run is launched next to it and the old run is only stopped once the new one
+// is ready (see waitReady). If the new run can not be launched or does not get ready, it is
+// stopped instead and the old run stays in place. The reason is the message of the restart event.
+//------------------------------------------------------------------------------
+func restartStartFirst(ctx context.Context, procName string, runtimeData *GPCProcRuntimeData, sReason string) error {
+	// Nothing may look like a crash or like all done while the runs are swapped
+	gPendingStarts.Add(1)
+	defer gPendingStarts.Add(-1)
+
+	oldRun := &GPCProcRuntimeData{procConfig: runtimeData.procConfig}
+	runtimeData.mux.Lock()
+	if !runtimeData.procStatus.active {
+		runtimeData.mux.Unlock()
+		return newProcessError(procName, ErrNotRunning, nil)
+	}
+	swapRuns(runtimeData, oldRun)
+	runtimeData.procStatus.startingFirst = true
+	runtimeData.mux.Unlock()
+
+	gpclogging.Task(procName).Info("Restarting process <%s> start-first, PID=<%d> keeps running until the new run is ready.", procName,
+		oldRun.procStatus.pid)
+	publishProcessEvent(EventProcessRestarted, procName, oldRun, sReason)
+	launchProcess(procName)
+	runtimeData.mux.Lock()
+	runtimeData.procStatus.startingFirst = false
+	runtimeData.mux.Unlock()
+
+	err := ctx.Err()
+	if runtimeData.procStatus.active {
+		err = waitReady(ctx, procName)
+	} else if err == nil {
+		err = newProcessError(procName, ErrStartFailed, runtimeData.procStatus.lastError)
+	}
+	if err != nil {
+		gpclogging.Task(procName).Error("New run of process <%s> did not get ready, keeping PID=<%d>: <%s>", procName, oldRun.procStatus.pid,
+			err.Error())
+		// Back to the old run, the new one is the one to retire now
+		runtimeData.mux.Lock()
+		swapRuns(runtimeData, oldRun)
+		writePIDFile(runtimeData)
+		runtimeData.mux.Unlock()
+	}
+
+	retireRun(procName, oldRun)
+	return err
+}
+
+// swapRuns exchanges the running process, with its handles, logs and state, between two runtime
+// data of the same task. The caller must hold the lock of a, b is not shared yet.
+//------------------------------------------------------------------------------
+func swapRuns(a *GPCProcRuntimeData, b *GPCProcRuntimeData) {
+	a.procCmd, b.procCmd = b.procCmd, a.procCmd
+	a.procLog, b.procLog = b.procLog, a.procLog
+	a.procErrLog, b.procErrLog = b.procErrLog, a.procErrLog
+	a.capture, b.capture = b.capture, a.capture
+	a.stdin, b.stdin = b.stdin, a.stdin
+	a.treeHandle, b.treeHandle = b.treeHandle, a.treeHandle
+	a.exitHandle, b.exitHandle = b.exitHandle, a.exitHandle
+	a.procStatus.pid, b.procStatus.pid = b.procStatus.pid, a.procStatus.pid
+	a.procStatus.active, b.procStatus.active = b.procStatus.active, a.procStatus.active
+	a.procStatus.startTime, b.procStatus.startTime = b.procStatus.startTime, a.procStatus.startTime
+	a.procStatus.exitCode, b.procStatus.exitCode = b.procStatus.exitCode, a.procStatus.exitCode
+	a.procStatus.notifiedReady, b.procStatus.notifiedReady = b.procStatus.notifiedReady, a.procStatus.notifiedReady
+	a.procStatus.notifiedStopping, b.procStatus.notifiedStopping = b.procStatus.notifiedStopping, a.procStatus.notifiedStopping
+}
+
+// retireRun stops a run that was set aside by restartStartFirst. The task keeps running with its
+// other run, so the stop command (which concerns the task) is not used and nothing of the task,
+// like its PID file or mutex group, is released. StopInput is written to the stdin of the run.
+//------------------------------------------------------------------------------
+func retireRun(procName string, run *GPCProcRuntimeData) {
+	ctx := shutdownContext()
+	gracePeriod := run.procConfig.GracePeriod()
+	if run.procStatus.active && gPlatform.isRunning(run) == nil {
+		if len(run.procConfig.StopInput) > 0 {
+			inputCtx, cancel := context.WithTimeout(ctx, gracePeriod)
+			err := writeInput(inputCtx, run, strings.Join(run.procConfig.StopInput, "\n")+"\n")
+			cancel()
+			if err == nil {
+				waitForExit(ctx, run, gracePeriod)
+				gracePeriod = 0
+			}
+		}
+		bKilled, err := gPlatform.killTree(ctx, run, gracePeriod)
+		switch {
+		case err != nil:
+			gpclogging.Task(procName).Error("Retired run of process <%s>, PID=<%d> could not be killed!! <%s>", procName, run.procStatus.pid, err.Error())
+		case bKilled:
+			gpclogging.Task(procName).Warn("Retired run of process <%s>, PID=<%d> did not exit within its grace period and was killed.", procName,
+				run.procStatus.pid)
+		default:
+			gpclogging.Task(procName).Info("Retired run of process <%s>, PID=<%d> is stopped.", procName, run.procStatus.pid)
+		}
+	}
+	run.procStatus.active = false
+	run.closeLogs()
+	run.closeStdin()
+	gPlatform.closeTree(run)
+}
@@ -0,0 +1,95 @@
+package gpcprocessmgr
+
+import (
+	"context"
+	"gpcconfig"
+	"gpclogging"
+	"sync"
+)
+
+//#######################################################
+//### GLOBAL VARIABLES, INIT, CONSTS
+//#######################################################
+
+// Launches that may run at the same time, Startup.MaxConcurrentStarts
+var gStartSlots = &startSlots{}
+
+// startSlots limits the launches running at the same time, the others wait in a queue and get
+// their slot in the order they asked for it
+type startSlots struct {
+	mux     sync.Mutex
+	limit   int             // zero => no limit
+	running int             // launches holding a slot
+	queue   []chan struct{} // waiting launches, oldest first, closed when the slot is handed over
+}
+
+// setStartLimit takes the limit of concurrent starts of the configuration, waiting launches
+// get the slots a higher limit frees
+//------------------------------------------------------------------------------
+func setStartLimit(configData *gpcconfig.ConfigData) {
+	gStartSlots.mux.Lock()
+	defer gStartSlots.mux.Unlock()
+	gStartSlots.limit = int(configData.Startup.MaxConcurrentStarts)
+	for len(gStartSlots.queue) > 0 && (gStartSlots.limit == 0 || gStartSlots.running < gStartSlots.limit) {
+		gStartSlots.handOver()
+	}
+}
+
+// acquire blocks until the launch of a process may go ahead. Returns false if the context was
+// cancelled while waiting, the launch must not happen then.
+//------------------------------------------------------------------------------
+func (s *startSlots) acquire(ctx context.Context, procName string) bool {
+	s.mux.Lock()
+	if s.limit == 0 || (s.running < s.limit && len(s.queue) == 0) {
+		s.running++
+		s.mux.Unlock()
+		return true
+	}
+	ready := make(chan struct{})
+	s.queue = append(s.queue, ready)
+	gpclogging.Debug("Process <%s> is queued for launch, <%d> launches are running, <%d> waiting.", procName, s.running, len(s.queue))
+	s.mux.Unlock()
+
+	select {
+	case <-ready:
+		return true
+	case <-ctx.Done():
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for queueIndex, waiting := range s.queue {
+		if waiting == ready {
+			s.queue = append(s.queue[:queueIndex], s.queue[queueIndex+1:]...)
+			return false
+		}
+	}
+	// the slot was handed over meanwhile, pass it on
+	s.releaseLocked()
+	return false
+}
+
+// release frees the slot of a launch that is through
+//------------------------------------------------------------------------------
+func (s *startSlots) release() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.releaseLocked()
+}
+
+// releaseLocked frees a slot, the oldest waiting launch gets it. The lock must be held by the caller.
+//------------------------------------------------------------------------------
+func (s *startSlots) releaseLocked() {
+	s.running--
+	if len(s.queue) > 0 && (s.limit == 0 || s.running < s.limit) {
+		s.handOver()
+	}
+}
+
+// handOver gives a slot to the oldest waiting launch. The lock must be held by the caller.
+//------------------------------------------------------------------------------
+func (s *startSlots) handOver() {
+	s.running++
+	close(s.queue[0])
+	s.queue = s.queue[1:]
+}
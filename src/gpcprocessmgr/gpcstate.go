@@ -0,0 +1,182 @@
+package gpcprocessmgr
+
+import (
+	"encoding/json"
+	"gpcconfig"
+	"gpclogging"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// how far the start time of a process may be off the one in the state file, the platforms
+// tell it to the second or less
+const stateStartTolerance = 2 * time.Second
+
+// controllerState is the content of the state file
+type controllerState struct {
+	Processes []processState
+}
+
+// processState is the state of one process in the state file
+type processState struct {
+	Name         string
+	PID          int         `json:",omitempty"` // running process, zero if none
+	StartTime    time.Time   // start of the current or last run
+	RestartCount uint32      // automatic restarts since the last manual start
+	RestartTimes []time.Time `json:",omitempty"` // automatic restarts within the CrashLoopWindow
+	Quarantined  bool        `json:",omitempty"`
+}
+
+var (
+	gStateFile    string         // Control.StateFile, empty => no state is kept
+	gLastStates   []processState // state last written, the file is only written on changes
+	gHandoffState []processState // state handed over by the previous controller, used instead of the file
+)
+
+// restoreState reads the state of the previous controller into the runtime data, the one it
+// handed over or the state file: restart budgets and quarantines continue, processes that still
+// run are re-attached and monitored instead of starting them again. The caller must hold
+// gRuntimeDatatMux.
+//------------------------------------------------------------------------------
+func restoreState(configData *gpcconfig.ConfigData) {
+	gStateFile = configData.Control.StateFile
+	gLastStates = nil
+	state := controllerState{Processes: gHandoffState}
+	sSource := "the previous controller"
+	gHandoffState = nil
+	if state.Processes == nil {
+		if len(gStateFile) == 0 {
+			return
+		}
+		stateBytes, err := os.ReadFile(gStateFile)
+		if os.IsNotExist(err) {
+			return
+		}
+		if err == nil {
+			err = json.Unmarshal(stateBytes, &state)
+		}
+		if err != nil {
+			gpclogging.Warn("Could not read state file <%s>, starting with a fresh state: <%s>", gStateFile, err.Error())
+			return
+		}
+		sSource = gStateFile
+	}
+
+	for _, procState := range state.Processes {
+		runtimeData, found := gProcRuntimeData[procState.Name]
+		if !found {
+			continue
+		}
+		runtimeData.procStatus.restartCount = procState.RestartCount
+		runtimeData.procStatus.restartTimes = procState.RestartTimes
+		runtimeData.procStatus.quarantined = procState.Quarantined
+		if procState.Quarantined {
+			runtimeData.procStatus.lastError = newProcessError(procState.Name, ErrQuarantined, nil)
+		}
+		if procState.PID > 0 && isSameProcess(procState.PID, procState.StartTime) {
+			reattachProcess(procState.Name, runtimeData, procState)
+		}
+	}
+	gpclogging.Info("Restored the state of <%d> processes from <%s>", len(state.Processes), sSource)
+}
+
+// isSameProcess reports whether a PID still runs the process started at startTime, and not
+// another one that got the PID after it ended
+//------------------------------------------------------------------------------
+func isSameProcess(pid int, startTime time.Time) bool {
+	processStart, err := processStartTime(pid)
+	if err != nil {
+		return false
+	}
+	offset := processStart.Sub(startTime)
+	return offset < stateStartTolerance && offset > -stateStartTolerance
+}
+
+// reattachProcess monitors a process the previous controller started. Its output is not
+// captured any more, a wait task is left alone as its end can not be waited for.
+//------------------------------------------------------------------------------
+func reattachProcess(procName string, runtimeData *GPCProcRuntimeData, procState processState) {
+	if runtimeData.procConfig.WaitForExitTimeout.Duration > 0 {
+		gpclogging.Task(procName).Warn("Wait process <%s>, PID=<%d> of the previous controller still runs, it is not started again.",
+			procName, procState.PID)
+		runtimeData.procStatus.stopped = true
+		return
+	}
+	process, err := os.FindProcess(procState.PID)
+	if err != nil {
+		return
+	}
+	// The command is never started, it only carries the process
+	runtimeData.procCmd = exec.Command(runtimeData.procConfig.Executable())
+	runtimeData.procCmd.Process = process
+	runtimeData.procStatus.pid = procState.PID
+	runtimeData.procStatus.startTime = procState.StartTime
+	runtimeData.procStatus.exitCode = -1
+	adoptTree(runtimeData)
+	runtimeData.procStatus.adopted = true
+	runtimeData.procStatus.active = true
+
+	gpclogging.Task(procName).Info("Re-attached process <%s>, PID=<%d>, running since <%s>.", procName, procState.PID,
+		procState.StartTime.Format(time.RFC3339))
+	publishProcessEvent(EventProcessStarted, procName, runtimeData, "re-attached from the previous controller")
+}
+
+// saveState writes the state file if the state changed since it was last written. Called by
+// the monitor, it stops before the shutdown stops the processes.
+//------------------------------------------------------------------------------
+func saveState() {
+	if len(gStateFile) == 0 {
+		return
+	}
+	// compared before encoding, with many processes the encoding is what costs
+	states := processStates()
+	if gLastStates != nil && reflect.DeepEqual(states, gLastStates) {
+		return
+	}
+	state := controllerState{Processes: states}
+	stateBytes, err := json.MarshalIndent(&state, "", "  ")
+	if err != nil {
+		return
+	}
+	// Write a temporary file first, a crash never leaves a half written state
+	if dir := filepath.Dir(gStateFile); len(dir) > 0 {
+		os.MkdirAll(dir, 0755)
+	}
+	sTempFile := gStateFile + ".tmp"
+	err = os.WriteFile(sTempFile, stateBytes, 0644)
+	if err == nil {
+		err = os.Rename(sTempFile, gStateFile)
+	}
+	if err != nil {
+		// reported once per change
+		gpclogging.Warn("Could not write state file <%s>: <%s>", gStateFile, err.Error())
+		os.Remove(sTempFile)
+	}
+	gLastStates = states
+}
+
+// processStates returns the state of all processes, sorted by name
+//------------------------------------------------------------------------------
+func processStates() []processState {
+	procRuntimeData := currentRuntimeData()
+	states := make([]processState, 0, len(procRuntimeData))
+	for procName, runtimeData := range procRuntimeData {
+		procState := processState{
+			Name:         procName,
+			StartTime:    runtimeData.procStatus.startTime,
+			RestartCount: runtimeData.procStatus.restartCount,
+			RestartTimes: append([]time.Time(nil), runtimeData.procStatus.restartTimes...), // filtered in place by crashLoopDetected
+			Quarantined:  runtimeData.procStatus.quarantined,
+		}
+		if runtimeData.procStatus.active {
+			procState.PID = runtimeData.procStatus.pid
+		}
+		states = append(states, procState)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
@@ -0,0 +1,66 @@
+package gpcprocessmgr
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setBenchmarkRuntimeData replaces the runtime data with the given number of processes that
+// were restarted a few times, until the benchmark ends
+//------------------------------------------------------------------------------
+func setBenchmarkRuntimeData(b *testing.B, count int) {
+	procRuntimeData := make(map[string]*GPCProcRuntimeData, count)
+	startTime := time.Now().Add(-time.Hour)
+	for index := 0; index < count; index++ {
+		runtimeData := &GPCProcRuntimeData{}
+		runtimeData.procStatus.pid = 10000 + index
+		runtimeData.procStatus.active = true
+		runtimeData.procStatus.startTime = startTime
+		runtimeData.procStatus.restartCount = 3
+		runtimeData.procStatus.restartTimes = []time.Time{startTime.Add(-time.Minute), startTime.Add(-time.Second)}
+		procRuntimeData[fmt.Sprintf("task%04d", index)] = runtimeData
+	}
+
+	gRuntimeDatatMux.Lock()
+	savedRuntimeData := gProcRuntimeData
+	gProcRuntimeData = procRuntimeData
+	gRuntimeDatatMux.Unlock()
+	savedStateFile, savedLastStates := gStateFile, gLastStates
+	gStateFile = filepath.Join(b.TempDir(), "state.json")
+	gLastStates = nil
+	b.Cleanup(func() {
+		gRuntimeDatatMux.Lock()
+		gProcRuntimeData = savedRuntimeData
+		gRuntimeDatatMux.Unlock()
+		gStateFile, gLastStates = savedStateFile, savedLastStates
+	})
+}
+
+// BenchmarkProcessStates collects the states of 1000 processes, as the monitor does every 100 ms
+func BenchmarkProcessStates(b *testing.B) {
+	setBenchmarkRuntimeData(b, 1000)
+	for i := 0; i < b.N; i++ {
+		processStates()
+	}
+}
+
+// BenchmarkSaveState saves the state of 1000 processes, unchanged as on most passes of the
+// monitor, and changed, which encodes and writes the file
+func BenchmarkSaveState(b *testing.B) {
+	setBenchmarkRuntimeData(b, 1000)
+
+	b.Run("unchanged", func(b *testing.B) {
+		saveState()
+		for i := 0; i < b.N; i++ {
+			saveState()
+		}
+	})
+	b.Run("changed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			gLastStates = nil
+			saveState()
+		}
+	})
+}
@@ -0,0 +1,74 @@
+//go:build !windows
+// +build !windows
+
+package gpcprocessmgr
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//processStartTime returns when a process was started. It reads the proc filesystem where there
+//is one (Linux) and asks ps otherwise (macOS), which tells it to the second.
+//-------------------------------------------------------------------
+func processStartTime(pid int) (time.Time, error) {
+	statData, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if os.IsNotExist(err) {
+		if _, statErr := os.Stat("/proc/self/stat"); statErr != nil {
+			return psStartTime(pid)
+		}
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	// starttime is field 22 of the whole line, index 19 after the name, in clock ticks since boot
+	closeParen := bytes.LastIndexByte(statData, ')')
+	fields := strings.Fields(string(statData[closeParen+1:]))
+	if closeParen < 0 || len(fields) < 20 {
+		return time.Time{}, fmt.Errorf("unexpected format of /proc/%d/stat", pid)
+	}
+	startTicks, err := strconv.ParseInt(fields[19], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	bootTime, err := procBootTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return bootTime.Add(time.Duration(startTicks) * time.Second / procClockTicks), nil
+}
+
+// procBootTime returns the boot time of the system, line btime of /proc/stat
+//------------------------------------------------------------------------------
+func procBootTime() (time.Time, error) {
+	statData, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, sLine := range strings.Split(string(statData), "\n") {
+		if sSeconds, found := strings.CutPrefix(sLine, "btime "); found {
+			seconds, err := strconv.ParseInt(strings.TrimSpace(sSeconds), 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(seconds, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no btime in /proc/stat")
+}
+
+// psStartTime returns the start time of a process as reported by ps
+//------------------------------------------------------------------------------
+func psStartTime(pid int) (time.Time, error) {
+	psOut, err := exec.Command("ps", "-o", "lstart=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return time.Time{}, err
+	}
+	// e.g. "Thu Oct  1 10:43:29 2026", the day is padded
+	return time.ParseInLocation("Mon Jan 2 15:04:05 2006", strings.Join(strings.Fields(string(psOut)), " "), time.Local)
+}
@@ -0,0 +1,23 @@
+package gpcprocessmgr
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+//processStartTime returns when a process was started
+//-------------------------------------------------------------------
+func processStartTime(pid int) (time.Time, error) {
+	hProcess, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return time.Time{}, os.NewSyscallError("OpenProcess", err)
+	}
+	defer syscall.CloseHandle(hProcess)
+
+	var creationTime, exitTime, kernelTime, userTime syscall.Filetime
+	if err := syscall.GetProcessTimes(hProcess, &creationTime, &exitTime, &kernelTime, &userTime); err != nil {
+		return time.Time{}, os.NewSyscallError("GetProcessTimes", err)
+	}
+	return time.Unix(0, creationTime.Nanoseconds()), nil
+}
@@ -0,0 +1,144 @@
+package gpcprocessmgr
+
+import (
+	"fmt"
+	"gpclogging"
+	"sort"
+	"sync"
+	"time"
+)
+
+// exits kept per task in ProcessStats.Exits
+const maxExitHistory = 20
+
+// ExitRecord is one end of a run of a process
+type ExitRecord struct {
+	Time     time.Time
+	ExitCode int    // -1 if unknown
+	Failed   bool   // ended on its own, not on request, and not successfully
+	Message  string // e.g. "exited", "stopped"
+}
+
+// ProcessStats are the statistics of a task since the controller started, also across reloads
+type ProcessStats struct {
+	Starts      uint32        // runs launched or adopted
+	Restarts    uint32        // automatic, scheduled and manual restarts
+	Crashes     uint32        // runs that ended on their own without success
+	TotalUptime time.Duration // time all runs together were running, including the current one
+	MTBF        time.Duration // mean time between failures: TotalUptime per crash, zero without crashes
+	Exits       []ExitRecord  `json:",omitempty"` // latest exits, oldest first
+
+	runningSince time.Time // start of the current run, zero while not running
+}
+
+var (
+	gStats    = make(map[string]*ProcessStats) // by task name
+	gStatsMux sync.Mutex                       // never held while taking another lock
+)
+
+// recordStats counts a lifecycle event in the statistics of its process. It is called for every
+// published event.
+//------------------------------------------------------------------------------
+func recordStats(event Event) {
+	if len(event.Process) == 0 {
+		return
+	}
+	gStatsMux.Lock()
+	defer gStatsMux.Unlock()
+	stats, found := gStats[event.Process]
+	if !found {
+		if event.Type != EventProcessStarted {
+			return
+		}
+		stats = &ProcessStats{}
+		gStats[event.Process] = stats
+	}
+
+	switch event.Type {
+	case EventProcessStarted:
+		stats.Starts++
+		// A start-first restart starts the new run before the old one ends
+		if !stats.runningSince.IsZero() {
+			stats.TotalUptime += event.Time.Sub(stats.runningSince)
+		}
+		stats.runningSince = event.Time
+	case EventProcessRestarted:
+		stats.Restarts++
+	case EventProcessExited:
+		if !stats.runningSince.IsZero() {
+			stats.TotalUptime += event.Time.Sub(stats.runningSince)
+			stats.runningSince = time.Time{}
+		}
+		if event.Failed {
+			stats.Crashes++
+		}
+		stats.Exits = append(stats.Exits, ExitRecord{Time: event.Time, ExitCode: event.ExitCode, Failed: event.Failed, Message: event.Message})
+		if len(stats.Exits) > maxExitHistory {
+			stats.Exits = stats.Exits[len(stats.Exits)-maxExitHistory:]
+		}
+	}
+}
+
+//GetStats returns the statistics of a process, zero if it never ran
+//#########################################################
+func GetStats(procName string) (ProcessStats, error) {
+	if _, err := getRuntimeData(procName); err != nil {
+		return ProcessStats{}, err
+	}
+	return statsOf(procName), nil
+}
+
+// statsOf returns a copy of the statistics of a process with the current run counted in
+//------------------------------------------------------------------------------
+func statsOf(procName string) ProcessStats {
+	gStatsMux.Lock()
+	defer gStatsMux.Unlock()
+	stats, found := gStats[procName]
+	if !found {
+		return ProcessStats{}
+	}
+	out := *stats
+	out.Exits = append([]ExitRecord(nil), stats.Exits...)
+	if !out.runningSince.IsZero() {
+		out.TotalUptime += time.Since(out.runningSince)
+	}
+	if out.Crashes > 0 {
+		out.MTBF = out.TotalUptime / time.Duration(out.Crashes)
+	}
+	return out
+}
+
+//GetStatsText returns a line with the statistics of each configured process, sorted by name
+//#########################################################
+func GetStatsText() []string {
+	procNames := make([]string, 0)
+	for procName := range currentRuntimeData() {
+		procNames = append(procNames, procName)
+	}
+	sort.Strings(procNames)
+
+	lines := make([]string, 0, len(procNames))
+	for _, procName := range procNames {
+		stats := statsOf(procName)
+		line := fmt.Sprintf("%-24s Uptime=%-12s Starts=%-4d Restarts=%-4d Crashes=%-4d", procName, stats.TotalUptime.Round(time.Second),
+			stats.Starts, stats.Restarts, stats.Crashes)
+		if stats.MTBF > 0 {
+			line += fmt.Sprintf(" MTBF=%s", stats.MTBF.Round(time.Second))
+		}
+		if len(stats.Exits) > 0 {
+			lastExit := stats.Exits[len(stats.Exits)-1]
+			line += fmt.Sprintf("  last exit: %s code %d (%s)", lastExit.Time.Format(time.RFC3339), lastExit.ExitCode, lastExit.Message)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// logStatsSummary writes the statistics of all processes to the log, at the end of the shutdown
+//------------------------------------------------------------------------------
+func logStatsSummary() {
+	gpclogging.Info("Summary of the processes since the controller started:")
+	for _, line := range GetStatsText() {
+		gpclogging.Info("  %s", line)
+	}
+}
@@ -0,0 +1,75 @@
+package gpcprocessmgr
+
+import (
+	"sort"
+	"time"
+)
+
+// ProcessStatus is a copy of the runtime state of a process, see GetStatus
+type ProcessStatus struct {
+	Name         string
+	State        string         // running, starting, stopping, suspended, stopped, condition, quarantined, maintenance, error, timeout, done, scheduled, waiting or exited
+	PID          int            // PID of the current or last run, zero if it never ran
+	Adopted      bool           // running instance taken over from outside of the controller
+	StartTime    time.Time      // start of the current or last run, zero if it never ran
+	Uptime       time.Duration  // time since StartTime while running, zero otherwise
+	RestartCount uint32         // automatic restarts since the last manual start
+	LastExitCode int            // exit code of the last run, -1 if unknown: still running, never ran, killed by a signal, or an adopted process
+	LastError    string         // why the process failed, empty if it did not. LastError() returns it as error
+	Usage        ResourceUsage  // latest resource sample while running, zero otherwise
+	WaitingOn    string         // unmet precondition the launch waits for (state condition), empty otherwise
+	Ports        map[string]int `json:",omitempty"` // ports of AssignPorts by variable name, empty until it got them
+	Stats        ProcessStats   // starts, crashes, uptime and exits since the controller started
+}
+
+//GetStatus returns the state of all processes, sorted by name
+//#########################################################
+func GetStatus() []ProcessStatus {
+	procRuntimeData := currentRuntimeData()
+	procStatuses := make([]ProcessStatus, 0, len(procRuntimeData))
+	for procName, runtimeData := range procRuntimeData {
+		procStatuses = append(procStatuses, runtimeData.status(procName))
+	}
+	sort.Slice(procStatuses, func(i, j int) bool { return procStatuses[i].Name < procStatuses[j].Name })
+	return procStatuses
+}
+
+//GetProcessStatus returns the state of a single process
+//#########################################################
+func GetProcessStatus(procName string) (ProcessStatus, error) {
+	runtimeData, err := getRuntimeData(procName)
+	if err != nil {
+		return ProcessStatus{}, err
+	}
+	return runtimeData.status(procName), nil
+}
+
+// status copies the runtime state of the process
+//------------------------------------------------------------------------------
+func (r *GPCProcRuntimeData) status(procName string) ProcessStatus {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	procStatus := ProcessStatus{
+		Name:         procName,
+		State:        r.stateName(),
+		PID:          r.procStatus.pid,
+		Adopted:      r.procStatus.adopted && r.procStatus.active,
+		StartTime:    r.procStatus.startTime,
+		RestartCount: r.procStatus.restartCount,
+		LastExitCode: r.procStatus.exitCode,
+	}
+	if r.procStatus.active && !r.procStatus.startTime.IsZero() {
+		procStatus.Uptime = time.Since(r.procStatus.startTime)
+	}
+	if r.procStatus.active && r.procStatus.usagePID == r.procStatus.pid {
+		procStatus.Usage = r.procStatus.usage
+	}
+	if r.procStatus.lastError != nil {
+		procStatus.LastError = r.procStatus.lastError.Error()
+	}
+	procStatus.WaitingOn = r.procStatus.waitingOn
+	procStatus.Ports = r.portsOf()
+	procStatus.Stats = statsOf(procName)
+	return procStatus
+}
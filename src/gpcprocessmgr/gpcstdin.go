@@ -0,0 +1,70 @@
+package gpcprocessmgr
+
+import (
+	"context"
+	"gpclogging"
+	"io"
+	"strings"
+)
+
+//WriteProcessInput writes text to the stdin of a running StdinPipe task, e.g. a command for
+//the console of a game server. A missing line break at the end is added. Fails with
+//ErrNoStdin for tasks without StdinPipe and adopted processes. Waits until the process has
+//taken the text or the context is cancelled.
+//#########################################################
+func WriteProcessInput(ctx context.Context, procName string, sInput string) error {
+	gpclogging.Debug("Entering WriteProcessInput() for process <%s>", procName)
+
+	runtimeData, err := getRuntimeData(procName)
+	if err != nil {
+		return err
+	}
+	if !runtimeData.procStatus.active {
+		return newProcessError(procName, ErrNotRunning, nil)
+	}
+	if !strings.HasSuffix(sInput, "\n") {
+		sInput += "\n"
+	}
+	if err := writeInput(ctx, runtimeData, sInput); err != nil {
+		return err
+	}
+	gpclogging.Task(procName).Info("Wrote <%d> bytes to the stdin of process <%s>, PID=<%d>.", len(sInput), procName, runtimeData.procStatus.pid)
+
+	gpclogging.Debug("Leaving WriteProcessInput()")
+	return nil
+}
+
+// writeInput writes to the stdin pipe of a process. A process that does not read its input
+// blocks the write once the pipe is full, the write is then given up with the context and ends
+// when the pipe is closed.
+//------------------------------------------------------------------------------
+func writeInput(ctx context.Context, runtimeData *GPCProcRuntimeData, sInput string) error {
+	runtimeData.mux.Lock()
+	stdin := runtimeData.stdin
+	runtimeData.mux.Unlock()
+	if stdin == nil {
+		return newProcessError(runtimeData.procConfig.Name, ErrNoStdin, nil)
+	}
+
+	written := make(chan error, 1)
+	go func() {
+		runtimeData.stdinMux.Lock()
+		defer runtimeData.stdinMux.Unlock()
+		_, err := io.WriteString(stdin, sInput)
+		written <- err
+	}()
+	select {
+	case err := <-written:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closeStdin closes the stdin pipe of the process, so its reads end
+func (r *GPCProcRuntimeData) closeStdin() {
+	if r.stdin != nil {
+		r.stdin.Close()
+		r.stdin = nil
+	}
+}
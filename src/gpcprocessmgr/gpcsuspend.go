@@ -0,0 +1,61 @@
+package gpcprocessmgr
+
+import (
+	"context"
+	"gpclogging"
+)
+
+//SuspendProcess freezes a running process and all its children, with SIGSTOP on Unix and
+//NtSuspendProcess on Windows, until ResumeProcess lets it continue. The process keeps its
+//PID and memory and still counts as running, it is not restarted while suspended.
+//#########################################################
+func SuspendProcess(ctx context.Context, procName string) error {
+	gpclogging.Debug("Entering SuspendProcess() for process <%s>", procName)
+
+	runtimeData, err := getRuntimeData(procName)
+	if err != nil {
+		return err
+	}
+	runtimeData.mux.Lock()
+	switch {
+	case !runtimeData.procStatus.active:
+		runtimeData.mux.Unlock()
+		return newProcessError(procName, ErrNotRunning, nil)
+	case runtimeData.procStatus.suspended:
+		runtimeData.mux.Unlock()
+		return newProcessError(procName, ErrAlreadySuspended, nil)
+	}
+	if err := gPlatform.suspendTree(runtimeData); err != nil {
+		runtimeData.mux.Unlock()
+		return err
+	}
+	runtimeData.procStatus.suspended = true
+	runtimeData.mux.Unlock()
+
+	gpclogging.Task(procName).Warn("Process <%s>, PID=<%d> is suspended.", procName, runtimeData.procStatus.pid)
+	publishProcessEvent(EventProcessSuspended, procName, runtimeData, "suspended on request")
+
+	gpclogging.Debug("Leaving SuspendProcess()")
+	return nil
+}
+
+// resumeSuspended lets a process frozen by SuspendProcess continue
+//------------------------------------------------------------------------------
+func resumeSuspended(procName string, runtimeData *GPCProcRuntimeData) error {
+	runtimeData.mux.Lock()
+	if !runtimeData.procStatus.suspended {
+		// Resumed or stopped meanwhile
+		runtimeData.mux.Unlock()
+		return nil
+	}
+	if err := gPlatform.resumeTree(runtimeData); err != nil {
+		runtimeData.mux.Unlock()
+		return err
+	}
+	runtimeData.procStatus.suspended = false
+	runtimeData.mux.Unlock()
+
+	gpclogging.Task(procName).Info("Process <%s>, PID=<%d> is resumed.", procName, runtimeData.procStatus.pid)
+	publishProcessEvent(EventProcessResumed, procName, runtimeData, "resumed on request")
+	return nil
+}
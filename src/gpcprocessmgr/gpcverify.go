@@ -0,0 +1,44 @@
+package gpcprocessmgr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+//verifyExecutable compares the SHA256 of the executable on disk with the configured
+//ExpectedSHA256, to refuse starting tampered or half-copied binaries.
+//Returns nil if no checksum is configured.
+//-------------------------------------------------------------------
+func verifyExecutable(runtimeData *GPCProcRuntimeData) error {
+	expected := strings.ToLower(strings.TrimSpace(runtimeData.procConfig.ExpectedSHA256))
+	if len(expected) == 0 {
+		return nil
+	}
+
+	execPath, err := exec.LookPath(runtimeData.procConfig.Executable())
+	if err != nil {
+		return err
+	}
+
+	execFile, err := os.Open(execPath)
+	if err != nil {
+		return err
+	}
+	defer execFile.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, execFile); err != nil {
+		return err
+	}
+
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if actual != expected {
+		return fmt.Errorf("%w for <%s>: expected SHA256 <%s>, found <%s>", ErrChecksumMismatch, execPath, expected, actual)
+	}
+	return nil
+}
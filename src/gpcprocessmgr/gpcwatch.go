@@ -0,0 +1,161 @@
+package gpcprocessmgr
+
+import (
+	"context"
+	"encoding/binary"
+	"gpclogging"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// consts of the watch mode
+const (
+	// How often the WatchPaths are checked for changes
+	watchPollInterval = time.Second
+
+	// quiet time after the last change without WatchDebounce
+	defWatchDebounce = time.Second
+)
+
+// folders that are not searched for changes, they change a lot without a restart being needed
+var watchSkippedFolders = map[string]bool{".git": true, ".svn": true, ".hg": true, "node_modules": true, "__pycache__": true}
+
+// watchState is what the watcher knows about the WatchPaths of a process
+type watchState struct {
+	fingerprint uint64    // hash of the paths, sizes and modification times of the watched files
+	changedAt   time.Time // latest change not acted on yet, zero if there is none
+}
+
+// watchTaskPaths polls the WatchPaths of the no-wait processes and restarts a process gracefully
+// once its files changed and then stayed the same for its WatchDebounce. A process that ended on
+// its own is started again, e.g. after a crash the change fixes. Processes stopped on request or
+// quarantined are left alone. Runs apart from the monitor as a large folder takes a while to
+// search, until the controller shuts down.
+//------------------------------------------------------------------------------
+func watchTaskPaths() {
+	ctx := shutdownContext()
+	watchStates := make(map[*GPCProcRuntimeData]*watchState)
+	for sleepContext(ctx, watchPollInterval) {
+		procRuntimeData := currentRuntimeData()
+		for runtimeData := range watchStates {
+			if procRuntimeData[runtimeData.procConfig.Name] != runtimeData {
+				delete(watchStates, runtimeData)
+			}
+		}
+
+		for procName, runtimeData := range procRuntimeData {
+			if len(runtimeData.procConfig.WatchPaths) == 0 || runtimeData.procConfig.WaitForExitTimeout.Duration > 0 {
+				continue
+			}
+			fingerprint := watchFingerprint(runtimeData)
+			state, found := watchStates[runtimeData]
+			if !found {
+				watchStates[runtimeData] = &watchState{fingerprint: fingerprint}
+				continue
+			}
+			now := time.Now()
+			if fingerprint != state.fingerprint {
+				state.fingerprint = fingerprint
+				state.changedAt = now
+				continue
+			}
+			if state.changedAt.IsZero() || now.Sub(state.changedAt) < watchDebounce(runtimeData) {
+				continue
+			}
+			state.changedAt = time.Time{}
+			// a hand-off leaves the processes to the next controller as they are
+			if ctx.Err() != nil {
+				return
+			}
+			restartOnChange(ctx, procName, runtimeData)
+		}
+	}
+}
+
+// restartOnChange restarts a process whose watched files changed, or starts it if it ended on its own
+//------------------------------------------------------------------------------
+func restartOnChange(ctx context.Context, procName string, runtimeData *GPCProcRuntimeData) {
+	runtimeData.mux.Lock()
+	bActive := runtimeData.procStatus.active
+	bSuspended := runtimeData.procStatus.suspended
+	bLeftAlone := runtimeData.procStatus.stopped || runtimeData.procStatus.quarantined || len(runtimeData.procStatus.waitingOn) > 0 ||
+		runtimeData.procCmd == nil
+	runtimeData.mux.Unlock()
+
+	switch {
+	case bSuspended:
+		gpclogging.Task(procName).Info("Watched files of process <%s> changed, it is suspended and not restarted.", procName)
+	case inMaintenance(procName):
+		gpclogging.Task(procName).Info("Watched files of process <%s> changed, it is in maintenance and not restarted.", procName)
+	case bActive:
+		gpclogging.Task(procName).Info("Watched files of process <%s> changed. Restarting it.", procName)
+		restartGracefully(procName, runtimeData, "watched files changed")
+	case !bLeftAlone:
+		gpclogging.Task(procName).Info("Watched files of process <%s> changed. Starting it again.", procName)
+		if err := StartProcess(ctx, procName); err != nil {
+			gpclogging.Task(procName).Warn("Process <%s> could not be started after its watched files changed: <%s>", procName, err.Error())
+		}
+	}
+}
+
+// watchDebounce returns the WatchDebounce of a process
+//------------------------------------------------------------------------------
+func watchDebounce(runtimeData *GPCProcRuntimeData) time.Duration {
+	if runtimeData.procConfig.WatchDebounce.Duration > 0 {
+		return runtimeData.procConfig.WatchDebounce.Duration
+	}
+	return defWatchDebounce
+}
+
+// watchFingerprint hashes the paths, sizes and modification times of the files of the WatchPaths
+// of a process and of its executable. Paths that do not exist count as well, so creating them is
+// a change.
+//------------------------------------------------------------------------------
+func watchFingerprint(runtimeData *GPCProcRuntimeData) uint64 {
+	hash := fnv.New64a()
+	addFile := func(sPath string, info fs.FileInfo) {
+		var sizeAndTime [16]byte
+		binary.LittleEndian.PutUint64(sizeAndTime[:8], uint64(info.Size()))
+		binary.LittleEndian.PutUint64(sizeAndTime[8:], uint64(info.ModTime().UnixNano()))
+		hash.Write([]byte(sPath))
+		hash.Write(sizeAndTime[:])
+	}
+
+	watchPaths := runtimeData.procConfig.WatchPaths
+	if !runtimeData.procConfig.IsShellCommand() {
+		if sExecutable, err := exec.LookPath(runtimeData.procConfig.Executable()); err == nil {
+			watchPaths = append([]string{sExecutable}, watchPaths...)
+		}
+	}
+	for _, sWatchPath := range watchPaths {
+		info, err := os.Stat(sWatchPath)
+		if err != nil {
+			hash.Write([]byte("missing:" + sWatchPath))
+			continue
+		}
+		if !info.IsDir() {
+			addFile(sWatchPath, info)
+			continue
+		}
+		filepath.WalkDir(sWatchPath, func(sPath string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if entry.IsDir() {
+				if sPath != sWatchPath && watchSkippedFolders[entry.Name()] {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if fileInfo, err := entry.Info(); err == nil {
+				addFile(sPath, fileInfo)
+			}
+			return nil
+		})
+	}
+	return hash.Sum64()
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package gpcprocessmgr
+
+import (
+	"gpclogging"
+)
+
+//applyWindowSettings does nothing on Unix, there is no window of the process to find
+//-------------------------------------------------------------------
+func applyWindowSettings(runtimeData *GPCProcRuntimeData) {
+	procConfig := runtimeData.procConfig
+	if len(procConfig.WindowTitle) > 0 || len(procConfig.WindowIcon) > 0 || len(procConfig.WindowPosition) > 0 {
+		gpclogging.Warn("Process <%s>: WindowTitle, WindowIcon and WindowPosition are only supported on Windows, ignored.", procConfig.Name)
+	}
+}
@@ -0,0 +1,202 @@
+package gpcprocessmgr
+
+import (
+	"gpclogging"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// os/exec does not give access to the STARTUPINFO of a new process, so the window
+// settings are applied to the first visible top-level window of its process tree
+// once it shows up. For console programs that is the console window.
+
+// consts
+const (
+	windowWaitTimeout = 10 * time.Second // how long to wait for the window of a process
+	windowWaitStep    = 200 * time.Millisecond
+	wmSetIcon         = 0x0080
+	iconSmall         = 0
+	iconBig           = 1
+	imageIcon         = 1
+	lrDefaultSize     = 0x0040
+	lrLoadFromFile    = 0x0010
+	swpNoSize         = 0x0001
+	swpNoZOrder       = 0x0004
+	swpNoActivate     = 0x0010
+	wmClose           = 0x0010
+)
+
+var (
+	moduser32                    = syscall.NewLazyDLL("user32.dll")
+	procEnumWindows              = moduser32.NewProc("EnumWindows")
+	procGetWindowThreadProcessID = moduser32.NewProc("GetWindowThreadProcessId")
+	procIsWindowVisible          = moduser32.NewProc("IsWindowVisible")
+	procSetWindowTextW           = moduser32.NewProc("SetWindowTextW")
+	procSetWindowPos             = moduser32.NewProc("SetWindowPos")
+	procLoadImageW               = moduser32.NewProc("LoadImageW")
+	procSendMessageW             = moduser32.NewProc("SendMessageW")
+	procPostMessageW             = moduser32.NewProc("PostMessageW")
+)
+
+// The EnumWindows callback can not get a Go pointer passed, it works on these instead
+var gWindowSearchMux sync.Mutex
+var gWindowSearchPids map[uint32]bool
+var gWindowSearchResult uintptr
+var gWindowSearchAll bool // collect all windows in gWindowSearchResults instead of stopping at the first
+var gWindowSearchResults []uintptr
+var gEnumWindowsCallback = syscall.NewCallback(enumWindowsProc)
+
+//applyWindowSettings sets title, position and icon of the window of a just started process
+//in background, as soon as the window exists
+//-------------------------------------------------------------------
+func applyWindowSettings(runtimeData *GPCProcRuntimeData) {
+	procConfig := runtimeData.procConfig
+	if procConfig.HideWindow || (len(procConfig.WindowTitle) == 0 && len(procConfig.WindowIcon) == 0 && len(procConfig.WindowPosition) == 0) {
+		return
+	}
+
+	go func() {
+		hWnd := waitForWindow(runtimeData)
+		if hWnd == 0 {
+			gpclogging.Warn("No window of process <%s> appeared within <%s>, window settings not applied.", procConfig.Name, windowWaitTimeout)
+			return
+		}
+
+		if len(procConfig.WindowTitle) > 0 {
+			title, err := syscall.UTF16PtrFromString(procConfig.WindowTitle)
+			if err == nil {
+				procSetWindowTextW.Call(hWnd, uintptr(unsafe.Pointer(title)))
+			}
+		}
+		if len(procConfig.WindowPosition) >= 2 {
+			pos := procConfig.WindowPosition
+			flags := uintptr(swpNoZOrder | swpNoActivate)
+			var width, height int
+			if len(pos) == 4 {
+				width, height = pos[2], pos[3]
+			} else {
+				flags |= swpNoSize
+			}
+			r1, _, e1 := procSetWindowPos.Call(hWnd, 0, uintptr(pos[0]), uintptr(pos[1]), uintptr(width), uintptr(height), flags)
+			if r1 == 0 {
+				gpclogging.Warn("Could not move the window of process <%s>: <%s>", procConfig.Name, os.NewSyscallError("SetWindowPos", e1).Error())
+			}
+		}
+		if len(procConfig.WindowIcon) > 0 {
+			if err := setWindowIcon(hWnd, procConfig.WindowIcon); err != nil {
+				gpclogging.Warn("Could not set the window icon of process <%s>: <%s>", procConfig.Name, err.Error())
+			}
+		}
+	}()
+}
+
+// waitForWindow polls for a visible top-level window of the process tree, returns zero if
+// none appeared in time or the process is gone
+//------------------------------------------------------------------------------
+func waitForWindow(runtimeData *GPCProcRuntimeData) uintptr {
+	deadline := time.Now().Add(windowWaitTimeout)
+	for time.Now().Before(deadline) && runtimeData.procStatus.active {
+		pids := []int{runtimeData.procStatus.pid}
+		if runtimeData.treeHandle != 0 {
+			if jobPids, err := jobProcessIds(runtimeData); err == nil {
+				pids = jobPids
+			}
+		}
+		if hWnd := findProcessWindow(pids); hWnd != 0 {
+			return hWnd
+		}
+		if !sleepUnlessStopping(windowWaitStep) {
+			return 0
+		}
+	}
+	return 0
+}
+
+// findProcessWindow returns the first visible top-level window owned by one of the processes
+//------------------------------------------------------------------------------
+func findProcessWindow(pids []int) uintptr {
+	gWindowSearchMux.Lock()
+	defer gWindowSearchMux.Unlock()
+
+	gWindowSearchPids = make(map[uint32]bool)
+	for _, pid := range pids {
+		gWindowSearchPids[uint32(pid)] = true
+	}
+	gWindowSearchResult = 0
+	gWindowSearchAll = false
+	procEnumWindows.Call(gEnumWindowsCallback, 0)
+	return gWindowSearchResult
+}
+
+// findProcessWindows returns all visible top-level windows owned by one of the processes
+//------------------------------------------------------------------------------
+func findProcessWindows(pids []int) []uintptr {
+	gWindowSearchMux.Lock()
+	defer gWindowSearchMux.Unlock()
+
+	gWindowSearchPids = make(map[uint32]bool)
+	for _, pid := range pids {
+		gWindowSearchPids[uint32(pid)] = true
+	}
+	gWindowSearchAll = true
+	gWindowSearchResults = nil
+	procEnumWindows.Call(gEnumWindowsCallback, 0)
+	return gWindowSearchResults
+}
+
+// closeTreeWindows asks the windows of the process tree to close, as if the user closed them.
+// Returns the number of windows asked.
+//------------------------------------------------------------------------------
+func closeTreeWindows(runtimeData *GPCProcRuntimeData) int {
+	pids := []int{runtimeData.procStatus.pid}
+	if runtimeData.treeHandle != 0 {
+		if jobPids, err := jobProcessIds(runtimeData); err == nil {
+			pids = jobPids
+		}
+	}
+
+	hWnds := findProcessWindows(pids)
+	for _, hWnd := range hWnds {
+		procPostMessageW.Call(hWnd, wmClose, 0, 0)
+	}
+	return len(hWnds)
+}
+
+// enumWindowsProc is called by EnumWindows for each top-level window, returns 0 to stop
+//------------------------------------------------------------------------------
+func enumWindowsProc(hWnd uintptr, lParam uintptr) uintptr {
+	if visible, _, _ := procIsWindowVisible.Call(hWnd); visible == 0 {
+		return 1
+	}
+	var pid uint32
+	procGetWindowThreadProcessID.Call(hWnd, uintptr(unsafe.Pointer(&pid)))
+	if gWindowSearchPids[pid] {
+		if gWindowSearchAll {
+			gWindowSearchResults = append(gWindowSearchResults, hWnd)
+			return 1
+		}
+		gWindowSearchResult = hWnd
+		return 0
+	}
+	return 1
+}
+
+// setWindowIcon loads an .ico file and sets it as small and large icon of a window. The icon
+// is not freed, the window may use it as long as it exists.
+//------------------------------------------------------------------------------
+func setWindowIcon(hWnd uintptr, sIconPath string) error {
+	iconPath, err := syscall.UTF16PtrFromString(sIconPath)
+	if err != nil {
+		return err
+	}
+	hIcon, _, e1 := procLoadImageW.Call(0, uintptr(unsafe.Pointer(iconPath)), imageIcon, 0, 0, lrLoadFromFile|lrDefaultSize)
+	if hIcon == 0 {
+		return os.NewSyscallError("LoadImage", e1)
+	}
+	procSendMessageW.Call(hWnd, wmSetIcon, iconSmall, hIcon)
+	procSendMessageW.Call(hWnd, wmSetIcon, iconBig, hIcon)
+	return nil
+}
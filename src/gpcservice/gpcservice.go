@@ -0,0 +1,31 @@
+package gpcservice
+
+/*
+Package gpcservice lets the process controller run as a Windows service, so it does not need
+a logged-in console session. It installs, removes, starts and stops the service in the service
+control manager (SCM), and serves the SCM while the controller runs as the service: a stop or a
+shutdown of the host stops the controller, its warnings and errors go to the Event Log.
+
+Other systems have their own service managers (systemd, launchd), there all functions fail with
+ErrNotSupported.
+*/
+import (
+	"errors"
+	"time"
+)
+
+//#######################################################
+//### GLOBAL VARIABLES, INIT, CONSTS
+//#######################################################
+
+// ErrNotSupported is returned on systems without Windows services
+var ErrNotSupported = errors.New("Windows services are not supported on this system")
+
+// consts
+const (
+	// name of the service and of its Event Log source
+	DefServiceName = "GoProcessController"
+
+	// how long Stop waits for the service to stop
+	stopTimeout = 60 * time.Second
+)
@@ -0,0 +1,34 @@
+//go:build !windows
+// +build !windows
+
+package gpcservice
+
+//Install registers the controller executable as a service started with the given arguments
+//#########################################################
+func Install(sName string, args []string) error {
+	return ErrNotSupported
+}
+
+//Uninstall removes the service and its Event Log source
+//#########################################################
+func Uninstall(sName string) error {
+	return ErrNotSupported
+}
+
+//Start asks the SCM to start the service
+//#########################################################
+func Start(sName string) error {
+	return ErrNotSupported
+}
+
+//Stop asks the SCM to stop the service and waits until it stopped
+//#########################################################
+func Stop(sName string) error {
+	return ErrNotSupported
+}
+
+//Run serves the SCM while the controller runs as the service
+//#########################################################
+func Run(sName string, run func(), stop func()) error {
+	return ErrNotSupported
+}
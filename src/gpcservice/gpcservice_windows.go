@@ -0,0 +1,416 @@
+package gpcservice
+
+import (
+	"fmt"
+	"gpclogging"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// consts
+const (
+	scManagerConnect       = 0x0001
+	scManagerCreateService = 0x0002
+
+	serviceQueryStatus = 0x0004
+	serviceStart       = 0x0010
+	serviceStop        = 0x0020
+	serviceAllAccess   = 0xF01FF
+	accessDelete       = 0x10000
+
+	serviceWin32OwnProcess = 0x10
+	serviceAutoStart       = 2
+	serviceErrorNormal     = 1
+
+	serviceStopped     = 1
+	serviceStopPending = 3
+	serviceRunning     = 4
+
+	serviceAcceptStop     = 0x1
+	serviceAcceptShutdown = 0x4
+
+	serviceControlStop        = 1
+	serviceControlInterrogate = 4
+	serviceControlShutdown    = 5
+
+	errorCallNotImplemented = 120
+
+	eventlogErrorType       = 0x1
+	eventlogWarningType     = 0x2
+	eventlogInformationType = 0x4
+
+	// the Event Log source uses the messages of EventCreate.exe, whose IDs 1-1000 just print the text
+	eventMessageFile = `%SystemRoot%\System32\EventCreate.exe`
+	eventID          = 1
+	eventSourceKey   = `SYSTEM\CurrentControlSet\Services\EventLog\Application\`
+
+	// time the SCM is told a stop may take, the processes get stopped one by one
+	stopWaitHint = 30 * time.Second
+)
+
+var (
+	modadvapi32                       = syscall.NewLazyDLL("advapi32.dll")
+	procOpenSCManagerW                = modadvapi32.NewProc("OpenSCManagerW")
+	procCreateServiceW                = modadvapi32.NewProc("CreateServiceW")
+	procOpenServiceW                  = modadvapi32.NewProc("OpenServiceW")
+	procDeleteService                 = modadvapi32.NewProc("DeleteService")
+	procStartServiceW                 = modadvapi32.NewProc("StartServiceW")
+	procControlService                = modadvapi32.NewProc("ControlService")
+	procQueryServiceStatus            = modadvapi32.NewProc("QueryServiceStatus")
+	procCloseServiceHandle            = modadvapi32.NewProc("CloseServiceHandle")
+	procStartServiceCtrlDispatcherW   = modadvapi32.NewProc("StartServiceCtrlDispatcherW")
+	procRegisterServiceCtrlHandlerExW = modadvapi32.NewProc("RegisterServiceCtrlHandlerExW")
+	procSetServiceStatus              = modadvapi32.NewProc("SetServiceStatus")
+	procRegisterEventSourceW          = modadvapi32.NewProc("RegisterEventSourceW")
+	procDeregisterEventSource         = modadvapi32.NewProc("DeregisterEventSource")
+	procReportEventW                  = modadvapi32.NewProc("ReportEventW")
+	procRegCreateKeyExW               = modadvapi32.NewProc("RegCreateKeyExW")
+	procRegSetValueExW                = modadvapi32.NewProc("RegSetValueExW")
+	procRegDeleteKeyW                 = modadvapi32.NewProc("RegDeleteKeyW")
+)
+
+// serviceStatus is SERVICE_STATUS
+type serviceStatus struct {
+	ServiceType             uint32
+	CurrentState            uint32
+	ControlsAccepted        uint32
+	Win32ExitCode           uint32
+	ServiceSpecificExitCode uint32
+	CheckPoint              uint32
+	WaitHint                uint32
+}
+
+// serviceTableEntry is SERVICE_TABLE_ENTRYW
+type serviceTableEntry struct {
+	ServiceName *uint16
+	ServiceProc uintptr
+}
+
+// state of the service while Run serves the SCM
+var gService struct {
+	name         *uint16
+	run          func()
+	stop         func()
+	stopOnce     sync.Once
+	statusHandle uintptr
+	eventLog     *eventLog
+}
+
+//Install registers the controller executable as a service started automatically with the
+//given arguments, running as LocalSystem, and registers its Event Log source
+//#########################################################
+func Install(sName string, args []string) error {
+	gpclogging.Debug("Entering gpcservice.Install() for service <%s>", sName)
+
+	sExecutable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmdLine := []string{syscall.EscapeArg(sExecutable)}
+	for _, arg := range args {
+		cmdLine = append(cmdLine, syscall.EscapeArg(arg))
+	}
+
+	scm, err := openSCManager(scManagerConnect | scManagerCreateService)
+	if err != nil {
+		return err
+	}
+	defer procCloseServiceHandle.Call(scm)
+
+	pName, err := syscall.UTF16PtrFromString(sName)
+	if err != nil {
+		return err
+	}
+	pCmdLine, err := syscall.UTF16PtrFromString(strings.Join(cmdLine, " "))
+	if err != nil {
+		return err
+	}
+	service, _, e1 := procCreateServiceW.Call(scm, uintptr(unsafe.Pointer(pName)), uintptr(unsafe.Pointer(pName)), serviceAllAccess,
+		serviceWin32OwnProcess, serviceAutoStart, serviceErrorNormal, uintptr(unsafe.Pointer(pCmdLine)), 0, 0, 0, 0, 0)
+	if service == 0 {
+		return os.NewSyscallError("CreateService", e1)
+	}
+	defer procCloseServiceHandle.Call(service)
+
+	if err := installEventSource(sName); err != nil {
+		procDeleteService.Call(service)
+		return fmt.Errorf("Event Log source: %s", err.Error())
+	}
+	return nil
+}
+
+//Uninstall removes the service and its Event Log source. A running service is removed once it stopped.
+//#########################################################
+func Uninstall(sName string) error {
+	gpclogging.Debug("Entering gpcservice.Uninstall() for service <%s>", sName)
+
+	service, closeService, err := openService(sName, accessDelete)
+	if err != nil {
+		return err
+	}
+	defer closeService()
+
+	r1, _, e1 := procDeleteService.Call(service)
+	if r1 == 0 {
+		return os.NewSyscallError("DeleteService", e1)
+	}
+	if err := removeEventSource(sName); err != nil && err != syscall.ERROR_FILE_NOT_FOUND {
+		return fmt.Errorf("Event Log source: %s", err.Error())
+	}
+	return nil
+}
+
+//Start asks the SCM to start the service
+//#########################################################
+func Start(sName string) error {
+	service, closeService, err := openService(sName, serviceStart)
+	if err != nil {
+		return err
+	}
+	defer closeService()
+
+	r1, _, e1 := procStartServiceW.Call(service, 0, 0)
+	if r1 == 0 {
+		return os.NewSyscallError("StartService", e1)
+	}
+	return nil
+}
+
+//Stop asks the SCM to stop the service and waits until it stopped
+//#########################################################
+func Stop(sName string) error {
+	service, closeService, err := openService(sName, serviceStop|serviceQueryStatus)
+	if err != nil {
+		return err
+	}
+	defer closeService()
+
+	var status serviceStatus
+	r1, _, e1 := procControlService.Call(service, serviceControlStop, uintptr(unsafe.Pointer(&status)))
+	if r1 == 0 {
+		return os.NewSyscallError("ControlService", e1)
+	}
+
+	deadline := time.Now().Add(stopTimeout)
+	for status.CurrentState != serviceStopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service <%s> did not stop within %s", sName, stopTimeout.String())
+		}
+		time.Sleep(300 * time.Millisecond)
+		r1, _, e1 := procQueryServiceStatus.Call(service, uintptr(unsafe.Pointer(&status)))
+		if r1 == 0 {
+			return os.NewSyscallError("QueryServiceStatus", e1)
+		}
+	}
+	return nil
+}
+
+//Run serves the SCM while the controller runs as the service. run is the controller, it is
+//called once the service is reported running and must return after stop was called. stop is
+//called when the SCM stops the service or the host shuts down. Warnings and errors are written
+//to the Event Log meanwhile. Fails if the process was not started by the SCM.
+//#########################################################
+func Run(sName string, run func(), stop func()) error {
+	pName, err := syscall.UTF16PtrFromString(sName)
+	if err != nil {
+		return err
+	}
+	gService.name = pName
+	gService.run = run
+	gService.stop = stop
+
+	serviceTable := []serviceTableEntry{{ServiceName: pName, ServiceProc: syscall.NewCallback(serviceMain)}, {}}
+	r1, _, e1 := procStartServiceCtrlDispatcherW.Call(uintptr(unsafe.Pointer(&serviceTable[0])))
+	if r1 == 0 {
+		return os.NewSyscallError("StartServiceCtrlDispatcher", e1)
+	}
+	return nil
+}
+
+// serviceMain is called by the SCM on a thread of its own, the service stops when it returns
+//------------------------------------------------------------------------------
+func serviceMain(argc uintptr, argv uintptr) uintptr {
+	handle, _, _ := procRegisterServiceCtrlHandlerExW.Call(uintptr(unsafe.Pointer(gService.name)), syscall.NewCallback(controlHandler), 0)
+	if handle == 0 {
+		return 0
+	}
+	gService.statusHandle = handle
+
+	gService.eventLog = openEventLog(gService.name)
+	if gService.eventLog != nil {
+		gpclogging.SetEventSink(gService.eventLog)
+	}
+
+	setServiceStatus(serviceRunning)
+	gService.eventLog.report(eventlogInformationType, "Process controller service started.")
+	gService.run()
+	gService.eventLog.report(eventlogInformationType, "Process controller service stopped.")
+
+	gpclogging.SetEventSink(nil)
+	gService.eventLog.close()
+	setServiceStatus(serviceStopped)
+	return 0
+}
+
+// controlHandler receives the requests of the SCM
+//------------------------------------------------------------------------------
+func controlHandler(control uintptr, eventType uintptr, eventData uintptr, context uintptr) uintptr {
+	switch control {
+	case serviceControlStop, serviceControlShutdown:
+		setServiceStatus(serviceStopPending)
+		gService.stopOnce.Do(gService.stop)
+	case serviceControlInterrogate:
+	default:
+		return errorCallNotImplemented
+	}
+	return 0
+}
+
+// setServiceStatus reports the state of the service to the SCM
+//------------------------------------------------------------------------------
+func setServiceStatus(state uint32) {
+	status := serviceStatus{ServiceType: serviceWin32OwnProcess, CurrentState: state}
+	switch state {
+	case serviceRunning:
+		status.ControlsAccepted = serviceAcceptStop | serviceAcceptShutdown
+	case serviceStopPending:
+		status.WaitHint = uint32(stopWaitHint / time.Millisecond)
+	}
+	procSetServiceStatus.Call(gService.statusHandle, uintptr(unsafe.Pointer(&status)))
+}
+
+// openSCManager connects to the SCM of the local host
+//------------------------------------------------------------------------------
+func openSCManager(access uint32) (uintptr, error) {
+	scm, _, e1 := procOpenSCManagerW.Call(0, 0, uintptr(access))
+	if scm == 0 {
+		return 0, os.NewSyscallError("OpenSCManager", e1)
+	}
+	return scm, nil
+}
+
+// openService opens an installed service, the returned function closes it
+//------------------------------------------------------------------------------
+func openService(sName string, access uint32) (uintptr, func(), error) {
+	pName, err := syscall.UTF16PtrFromString(sName)
+	if err != nil {
+		return 0, nil, err
+	}
+	scm, err := openSCManager(scManagerConnect)
+	if err != nil {
+		return 0, nil, err
+	}
+	service, _, e1 := procOpenServiceW.Call(scm, uintptr(unsafe.Pointer(pName)), uintptr(access))
+	if service == 0 {
+		procCloseServiceHandle.Call(scm)
+		return 0, nil, os.NewSyscallError("OpenService", e1)
+	}
+	return service, func() {
+		procCloseServiceHandle.Call(service)
+		procCloseServiceHandle.Call(scm)
+	}, nil
+}
+
+// installEventSource registers the service as source of the Application Event Log
+//------------------------------------------------------------------------------
+func installEventSource(sName string) error {
+	pKey, err := syscall.UTF16PtrFromString(eventSourceKey + sName)
+	if err != nil {
+		return err
+	}
+	var hKey syscall.Handle
+	var disposition uint32
+	r1, _, _ := procRegCreateKeyExW.Call(uintptr(syscall.HKEY_LOCAL_MACHINE), uintptr(unsafe.Pointer(pKey)), 0, 0, 0,
+		syscall.KEY_WRITE, 0, uintptr(unsafe.Pointer(&hKey)), uintptr(unsafe.Pointer(&disposition)))
+	if r1 != 0 {
+		return syscall.Errno(r1)
+	}
+	defer syscall.RegCloseKey(hKey)
+
+	messageFile, err := syscall.UTF16FromString(eventMessageFile)
+	if err != nil {
+		return err
+	}
+	if err := setRegistryValue(hKey, "EventMessageFile", syscall.REG_EXPAND_SZ, unsafe.Pointer(&messageFile[0]), len(messageFile)*2); err != nil {
+		return err
+	}
+	typesSupported := uint32(eventlogErrorType | eventlogWarningType | eventlogInformationType)
+	return setRegistryValue(hKey, "TypesSupported", syscall.REG_DWORD, unsafe.Pointer(&typesSupported), 4)
+}
+
+// setRegistryValue writes a value of an open registry key
+//------------------------------------------------------------------------------
+func setRegistryValue(hKey syscall.Handle, sValueName string, valueType uint32, data unsafe.Pointer, dataSize int) error {
+	pValueName, err := syscall.UTF16PtrFromString(sValueName)
+	if err != nil {
+		return err
+	}
+	r1, _, _ := procRegSetValueExW.Call(uintptr(hKey), uintptr(unsafe.Pointer(pValueName)), 0, uintptr(valueType), uintptr(data), uintptr(dataSize))
+	if r1 != 0 {
+		return syscall.Errno(r1)
+	}
+	return nil
+}
+
+// removeEventSource deletes the Event Log source of the service
+//------------------------------------------------------------------------------
+func removeEventSource(sName string) error {
+	pKey, err := syscall.UTF16PtrFromString(eventSourceKey + sName)
+	if err != nil {
+		return err
+	}
+	r1, _, _ := procRegDeleteKeyW.Call(uintptr(syscall.HKEY_LOCAL_MACHINE), uintptr(unsafe.Pointer(pKey)))
+	if r1 != 0 {
+		return syscall.Errno(r1)
+	}
+	return nil
+}
+
+// eventLog writes to the Application Event Log, it is the gpclogging.EventSink of the service
+type eventLog struct {
+	handle uintptr
+}
+
+// openEventLog opens the Event Log source of the service, nil if that fails
+//------------------------------------------------------------------------------
+func openEventLog(pName *uint16) *eventLog {
+	handle, _, _ := procRegisterEventSourceW.Call(0, uintptr(unsafe.Pointer(pName)))
+	if handle == 0 {
+		return nil
+	}
+	return &eventLog{handle: handle}
+}
+
+func (e *eventLog) Warn(msg string) {
+	e.report(eventlogWarningType, msg)
+}
+
+func (e *eventLog) Error(msg string) {
+	e.report(eventlogErrorType, msg)
+}
+
+// report writes an event with the message as its only string. Does nothing without Event Log.
+//------------------------------------------------------------------------------
+func (e *eventLog) report(eventType uint16, msg string) {
+	if e == nil {
+		return
+	}
+	pMsg, err := syscall.UTF16PtrFromString(strings.ReplaceAll(msg, "\x00", ""))
+	if err != nil {
+		return
+	}
+	procReportEventW.Call(e.handle, uintptr(eventType), 0, eventID, 0, 1, 0, uintptr(unsafe.Pointer(&pMsg)), 0)
+}
+
+// close closes the Event Log source
+//------------------------------------------------------------------------------
+func (e *eventLog) close() {
+	if e == nil {
+		return
+	}
+	procDeregisterEventSource.Call(e.handle)
+}
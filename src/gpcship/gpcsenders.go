@@ -0,0 +1,171 @@
+package gpcship
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gpcconfig"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Timeout of connecting and of each attempt to send a batch
+const sendTimeout = 10 * time.Second
+
+// newSender creates the sender of the configured protocol
+//------------------------------------------------------------------------------
+func newSender(config gpcconfig.LogShippingConfig) (sender, error) {
+	switch config.Protocol {
+	case "tcp":
+		return &tcpSender{address: config.Address, labels: config.Labels}, nil
+	case "http":
+		return &httpSender{config: config, client: &http.Client{Timeout: sendTimeout}, contentType: "application/x-ndjson", body: jsonLines}, nil
+	case "loki":
+		return &httpSender{config: config, client: &http.Client{Timeout: sendTimeout}, contentType: "application/json", body: lokiPush}, nil
+	}
+	return nil, fmt.Errorf("unknown protocol <%s>", config.Protocol)
+}
+
+// jsonLines encodes the lines as one JSON object per line, with the labels as further fields
+//------------------------------------------------------------------------------
+func jsonLines(lines []logLine, labels map[string]string) ([]byte, error) {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, line := range lines {
+		record := make(map[string]string, len(labels)+5)
+		for sKey, sValue := range labels {
+			record[sKey] = sValue
+		}
+		record["time"] = line.time.Format(time.RFC3339Nano)
+		record["host"] = gHostName
+		record["task"] = line.task
+		record["stream"] = line.stream
+		record["line"] = line.line
+		if err := encoder.Encode(record); err != nil {
+			return nil, err
+		}
+	}
+	return body.Bytes(), nil
+}
+
+// lokiPush encodes the lines as a push request of Loki, one stream per task and output stream
+//------------------------------------------------------------------------------
+func lokiPush(lines []logLine, labels map[string]string) ([]byte, error) {
+	type lokiStream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	var streams []*lokiStream
+	streamIndex := make(map[[2]string]*lokiStream)
+	for _, line := range lines {
+		stream, found := streamIndex[[2]string{line.task, line.stream}]
+		if !found {
+			stream = &lokiStream{Stream: map[string]string{"host": gHostName, "task": line.task, "stream": line.stream}}
+			for sKey, sValue := range labels {
+				stream.Stream[sKey] = sValue
+			}
+			streamIndex[[2]string{line.task, line.stream}] = stream
+			streams = append(streams, stream)
+		}
+		stream.Values = append(stream.Values, [2]string{strconv.FormatInt(line.time.UnixNano(), 10), line.line})
+	}
+	return json.Marshal(map[string][]*lokiStream{"streams": streams})
+}
+
+// tcpSender writes JSON lines to a TCP connection, reconnecting after errors
+type tcpSender struct {
+	address string
+	labels  map[string]string
+	conn    net.Conn
+}
+
+// name tells the address
+//------------------------------------------------------------------------------
+func (t *tcpSender) name() string {
+	return "tcp " + t.address
+}
+
+// send connects if not connected and writes the lines
+//------------------------------------------------------------------------------
+func (t *tcpSender) send(lines []logLine) error {
+	body, err := jsonLines(lines, t.labels)
+	if err != nil {
+		return err
+	}
+	if t.conn == nil {
+		if t.conn, err = net.DialTimeout("tcp", t.address, sendTimeout); err != nil {
+			return err
+		}
+	}
+	t.conn.SetWriteDeadline(time.Now().Add(sendTimeout))
+	if _, err := t.conn.Write(body); err != nil {
+		t.close()
+		return err
+	}
+	return nil
+}
+
+// close drops the connection, the next send connects again
+//------------------------------------------------------------------------------
+func (t *tcpSender) close() {
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+}
+
+// httpSender POSTs each batch, as NDJSON or as Loki push request
+type httpSender struct {
+	config      gpcconfig.LogShippingConfig
+	client      *http.Client
+	contentType string
+	body        func(lines []logLine, labels map[string]string) ([]byte, error)
+}
+
+// name tells the host only, the path or query of the URL may hold a token
+//------------------------------------------------------------------------------
+func (h *httpSender) name() string {
+	shippingURL, err := url.Parse(h.config.Address)
+	if err != nil {
+		return h.config.Protocol
+	}
+	return h.config.Protocol + " " + shippingURL.Host
+}
+
+// send POSTs the lines, any status but 2xx is an error
+//------------------------------------------------------------------------------
+func (h *httpSender) send(lines []logLine) error {
+	body, err := h.body(lines, h.config.Labels)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, h.config.Address, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", h.contentType)
+	for sName, sValue := range h.config.Headers {
+		request.Header.Set(sName, sValue)
+	}
+
+	response, err := h.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 4096))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("HTTP status <%s>", response.Status)
+	}
+	return nil
+}
+
+// close releases the idle connections
+//------------------------------------------------------------------------------
+func (h *httpSender) close() {
+	h.client.CloseIdleConnections()
+}
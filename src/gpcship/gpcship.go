@@ -0,0 +1,256 @@
+package gpcship
+
+/*
+Package gpcship forwards the captured output of the tasks to a central logging endpoint: a TCP
+input taking JSON lines, an HTTP bulk input taking NDJSON, or the push API of Grafana Loki.
+Lines are buffered and sent in batches in background. While the endpoint fails, the batch is
+retried with growing pauses and new lines wait in the buffer, once it is full they are dropped:
+the processes are never held up by their log shipping.
+*/
+import (
+	"gpcconfig"
+	"gpclogging"
+	"gpcprocessmgr"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//#######################################################
+//### GLOBAL VARIABLES, INIT, CONSTS
+//#######################################################
+
+// sender delivers batches of lines to an endpoint
+type sender interface {
+	// name tells the endpoint in log messages, without secrets
+	name() string
+	// send delivers the lines once, the shipper retries on errors
+	send(lines []logLine) error
+	// close releases connections
+	close()
+}
+
+// logLine is a line of output of a task
+type logLine struct {
+	time   time.Time
+	task   string
+	stream string // stdout, stderr, or output for both
+	line   string
+}
+
+// shipper buffers the output lines and passes them to the sender in batches
+type shipper struct {
+	config        gpcconfig.LogShippingConfig
+	target        sender
+	lines         chan logLine
+	batchLines    int
+	flushInterval time.Duration
+	maxBackoff    time.Duration
+	dropped       atomic.Uint64 // lines lost because the buffer was full or the endpoint failed at the end
+	stop          chan bool
+	done          chan bool // closed when the shipper has ended
+}
+
+var (
+	gShipper  *shipper
+	gShipMux  sync.Mutex
+	gHostName string
+)
+
+// consts
+const (
+	// defaults of the shipping settings
+	defBufferLines   = 10000
+	defBatchLines    = 500
+	defFlushInterval = 2 * time.Second
+	defMaxBackoff    = time.Minute
+
+	// first pause after a failed attempt, doubled after each further one
+	minBackoff = time.Second
+
+	// how often lost lines are reported at most
+	dropReportInterval = time.Minute
+
+	// how long Stop tries to send the lines still buffered
+	stopTimeout = 10 * time.Second
+)
+
+func init() {
+	gHostName, _ = os.Hostname()
+}
+
+//Configure starts the log shipping of the configuration. A changed configuration replaces the
+//running shipper, lines it still buffered are sent first. Processes already running keep their
+//output capture as it was at their launch.
+//#########################################################
+func Configure(tConfigData *gpcconfig.ConfigData) {
+	gShipMux.Lock()
+	defer gShipMux.Unlock()
+
+	if gShipper != nil && reflect.DeepEqual(gShipper.config, tConfigData.LogShipping) {
+		return
+	}
+	if gShipper != nil {
+		gpcprocessmgr.RemoveOutputSink(gShipper)
+		gShipper.shutdown()
+		gShipper = nil
+	}
+	if len(tConfigData.LogShipping.Protocol) == 0 {
+		return
+	}
+
+	target, err := newSender(tConfigData.LogShipping)
+	if err != nil {
+		gpclogging.Error("Log shipping disabled: <%s>", err.Error())
+		return
+	}
+	gShipper = newShipper(tConfigData.LogShipping, target)
+	go gShipper.run()
+	gpcprocessmgr.AddOutputSink(gShipper)
+	gpclogging.Info("Shipping the output of the tasks to <%s>.", target.name())
+}
+
+//Stop ends the log shipping, lines still buffered are sent for a while
+//#########################################################
+func Stop() {
+	gShipMux.Lock()
+	defer gShipMux.Unlock()
+
+	if gShipper != nil {
+		gpcprocessmgr.RemoveOutputSink(gShipper)
+		gShipper.shutdown()
+		gShipper = nil
+	}
+}
+
+// newShipper creates the shipper of a configuration, run starts it
+//------------------------------------------------------------------------------
+func newShipper(config gpcconfig.LogShippingConfig, target sender) *shipper {
+	s := &shipper{
+		config:        config,
+		target:        target,
+		batchLines:    int(config.BatchLines),
+		flushInterval: config.FlushInterval.Duration,
+		maxBackoff:    config.MaxBackoff.Duration,
+		stop:          make(chan bool),
+		done:          make(chan bool),
+	}
+	bufferLines := int(config.BufferLines)
+	if bufferLines == 0 {
+		bufferLines = defBufferLines
+	}
+	s.lines = make(chan logLine, bufferLines)
+	if s.batchLines == 0 {
+		s.batchLines = defBatchLines
+	}
+	if s.flushInterval <= 0 {
+		s.flushInterval = defFlushInterval
+	}
+	if s.maxBackoff <= 0 {
+		s.maxBackoff = defMaxBackoff
+	}
+	return s
+}
+
+// Output buffers a line of output, it is dropped if the buffer is full
+//------------------------------------------------------------------------------
+func (s *shipper) Output(procName string, sStream string, line string) {
+	select {
+	case s.lines <- logLine{time: time.Now(), task: procName, stream: sStream, line: line}:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// run collects the lines into batches and sends them until shutdown
+//------------------------------------------------------------------------------
+func (s *shipper) run() {
+	defer close(s.done)
+	defer s.target.close()
+
+	flushTicker := time.NewTicker(s.flushInterval)
+	defer flushTicker.Stop()
+
+	var batch []logLine
+	var backoff time.Duration
+	var reportedDrops uint64
+	var lastDropReport time.Time
+	for {
+		// A full batch is sent right away, a partial one at the next tick
+		if len(batch) < s.batchLines {
+			select {
+			case line := <-s.lines:
+				batch = append(batch, line)
+				if len(batch) < s.batchLines {
+					continue
+				}
+			case <-flushTicker.C:
+				if dropped := s.dropped.Load(); dropped > reportedDrops && time.Since(lastDropReport) >= dropReportInterval {
+					gpclogging.Warn("<%d> output lines were not shipped to <%s>, the buffer of <%d> lines was full.", dropped-reportedDrops, s.target.name(), cap(s.lines))
+					reportedDrops, lastDropReport = dropped, time.Now()
+				}
+			case <-s.stop:
+				s.drain(batch)
+				return
+			}
+		}
+		if len(batch) == 0 {
+			continue
+		}
+
+		if err := s.target.send(batch); err != nil {
+			if backoff == 0 {
+				gpclogging.Warn("Could not ship output to <%s>, retrying: <%s>", s.target.name(), err.Error())
+				backoff = minBackoff
+			} else if backoff = 2 * backoff; backoff > s.maxBackoff {
+				backoff = s.maxBackoff
+			}
+			select {
+			case <-time.After(backoff):
+			case <-s.stop:
+				s.drain(batch)
+				return
+			}
+			continue
+		}
+		if backoff > 0 {
+			gpclogging.Info("Shipping output to <%s> again.", s.target.name())
+			backoff = 0
+		}
+		batch = nil
+	}
+}
+
+// drain sends the batch and the lines still buffered, one attempt each. What cannot be sent is lost.
+//------------------------------------------------------------------------------
+func (s *shipper) drain(batch []logLine) {
+	for {
+		for len(batch) < s.batchLines && len(s.lines) > 0 {
+			batch = append(batch, <-s.lines)
+		}
+		if len(batch) == 0 {
+			break
+		}
+		if err := s.target.send(batch); err != nil {
+			s.dropped.Add(uint64(len(batch) + len(s.lines)))
+			break
+		}
+		batch = nil
+	}
+	if dropped := s.dropped.Load(); dropped > 0 {
+		gpclogging.Warn("<%d> output lines were not shipped to <%s>.", dropped, s.target.name())
+	}
+}
+
+// shutdown ends the shipper and waits a while for it to send the rest
+//------------------------------------------------------------------------------
+func (s *shipper) shutdown() {
+	close(s.stop)
+	select {
+	case <-s.done:
+	case <-time.After(stopTimeout):
+		gpclogging.Warn("Output still buffered for <%s> after <%s> is dropped.", s.target.name(), stopTimeout.String())
+	}
+}
@@ -1,15 +1,27 @@
 package main
 
 import (
+	"crypto/sha256"
 	"flag"
 	"fmt"
+	"gpcapi"
 	"gpcconfig"
+	"gpccontrol"
 	"gpclogging"
+	"gpcnotify"
 	"gpcprocessmgr"
+	"gpcservice"
+	"gpcship"
+	"io"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
+	_ "time/tzdata" // timezone database for hosts without one (Windows)
 )
 
 //#######################################################
@@ -19,6 +31,19 @@ func initProg() {
 
 }
 
+// The configuration currently applied, changed by reloads and by adding/removing tasks at runtime
+var gActiveConfig gpcconfig.ConfigData
+var gActiveConfigMux sync.Mutex
+
+// Option of the add and remove commands to write the change back to the configuration file
+const persistOption = "-persist"
+
+// How often WatchConfig polls a remote configuration
+const remoteWatchInterval = time.Minute
+
+// How long -takeover waits for the previous controller to end after the handoff
+const takeoverTimeout = 30 * time.Second
+
 // const strings
 const (
 	GPCVersion = "0.2"
@@ -38,39 +63,693 @@ func printHelp() {
 	fmt.Println("# ")
 	fmt.Println("#   -h")
 	fmt.Println("#       Prints this help output")
-	fmt.Println("#   -cf <path to file>")
-	fmt.Println("#       Path to the configuration file. Must be in JSON format. Default is", GPCDefConfigFile)
+	fmt.Println("#   -cf <path to file or URL>")
+	fmt.Println("#       Path or https:// URL of the configuration file, in JSON or YAML format. Default is", GPCDefConfigFile)
+	fmt.Println("#       Files ending with .yaml or .yml are read as YAML, see -cffmt.")
+	fmt.Println("#       An https:// URL fetches the configuration, the last good copy is cached and used")
+	fmt.Println("#       while the URL is unreachable.")
+	fmt.Println("#   -cfheader <Name: value>")
+	fmt.Println("#       Request header of a remote configuration, e.g. \"Authorization: Bearer ${TOKEN}\".")
+	fmt.Println("#       $VAR is taken from the environment. May be given more than once.")
+	fmt.Println("#   -cfcache <path to file>")
+	fmt.Println("#       Cache of a remote configuration. Default is a file in the user cache folder")
+	fmt.Println("#   -cffmt <json|yaml>")
+	fmt.Println("#       Forces the format of the configuration file instead of detecting it by extension")
 	fmt.Println("#   -dc <path to file>")
 	fmt.Println("#       Creates a new default configuration file with the specified file name")
+	fmt.Println("#   -simulate <path to failure script>")
+	fmt.Println("#       Prints what the controller would do with the configuration file, starting at midnight")
+	fmt.Println("#       today, when processes end as the script says. Nothing is started.")
+	fmt.Println("#   -simfor <duration>")
+	fmt.Println("#       Time span of -simulate, e.g. 72h. Default is 24h")
+	fmt.Println("#   -import <path to file>")
+	fmt.Println("#       Converts a supervisord configuration (.conf, .ini) or a PM2 ecosystem file (.json, .js)")
+	fmt.Println("#       into the new configuration file given by -cf and prints what was not converted.")
+	fmt.Println("#   -export <systemd|taskscheduler>")
+	fmt.Println("#       Writes each task of the configuration as systemd service (and timer) unit or as")
+	fmt.Println("#       Task Scheduler XML to the folder given by -exportdir (default: export) and prints")
+	fmt.Println("#       the settings that have no equivalent there.")
+	fmt.Println("#   -genkey <path to file>")
+	fmt.Println("#       Creates a key file for encrypted values, to be set as Secrets.KeyFile")
+	fmt.Println("#   -encrypt <value|->")
+	fmt.Println("#       Prints the value (- reads it from stdin) encrypted, to be used instead of a plain text")
+	fmt.Println("#       password or token.")
+	fmt.Println("#       Uses the Secrets.KeyFile of the -cf configuration, DPAPI on Windows without one.")
+	fmt.Println("#   -profile <name>")
+	fmt.Println("#       Merges a profile into the configuration: the section <name> of Profiles and the")
+	fmt.Println("#       overlay file next to the configuration file, e.g. pc-conf.<name>.json")
+	fmt.Println("#   -only <filters>")
+	fmt.Println("#       Runs only the tasks matching one of the comma separated filters tag=<tag> or name=<name>.")
+	fmt.Println("#       Values may use wildcards, e.g. -only tag=batch,name=web-*")
+	fmt.Println("#   -skip <filters>")
+	fmt.Println("#       Leaves out the tasks matching one of the filters, like -only. Tasks with")
+	fmt.Println("#       \"Enabled\": false are always left out.")
+	fmt.Println("#   -validate")
+	fmt.Println("#       Checks the configuration file, its executables and prints the planned startup order.")
+	fmt.Println("#       Exits with code 1 on problems. Nothing is started.")
+	fmt.Println("#   -run <task name>")
+	fmt.Println("#       Runs just this task once, to debug its definition: its output is shown on the console")
+	fmt.Println("#       (and logged), the controller exits with its exit code. Restarts, retries, follow up")
+	fmt.Println("#       tasks and the schedule do not apply, the timeout of a wait task does.")
+	fmt.Println("#   -takeover")
+	fmt.Println("#       Upgrades the controller without downtime: the controller already running with the")
+	fmt.Println("#       Control.SocketPath of the configuration hands its processes over and exits, they")
+	fmt.Println("#       keep running and are monitored by the new one. Output that went through a pipe")
+	fmt.Println("#       of the previous controller (timestamps, rotation, recent output) is lost.")
+	fmt.Println("#   -exitwhendone")
+	fmt.Println("#       Exits once all processes ended and none of them is restarted any more, e.g. when the")
+	fmt.Println("#       controller launches a fixed set of programs. Same as Shutdown.ExitWhenDone in the")
+	fmt.Println("#       configuration. Scheduled tasks keep the controller running.")
+	fmt.Println("#   -once")
+	fmt.Println("#       Runs the configuration as a batch, e.g. in a CI pipeline: starts the tasks, waits until")
+	fmt.Println("#       the wait tasks (and their retries and follow up tasks) are done, stops the rest and")
+	fmt.Println("#       exits with code 1 if a task failed, timed out or could not be started.")
+	fmt.Println("#   -foreground, -attach")
+	fmt.Println("#       Echoes the output of all tasks to the console as well, each line prefixed with the")
+	fmt.Println("#       task name. Colored on a terminal unless NO_COLOR is set. Lines are dropped when the")
+	fmt.Println("#       console can not keep up, the output logs are complete.")
+	fmt.Println("#   -service <install|uninstall|start|stop>")
+	fmt.Println("#       Windows only: manages the controller as the service", gpcservice.DefServiceName+".")
+	fmt.Println("#       install registers it with the -cf (and -cffmt) given, to start with Windows as LocalSystem.")
+	fmt.Println("#       It runs in the folder of the configuration file and logs warnings and errors to the Event Log.")
 	fmt.Println("############################################################")
 }
 
+//logRemoteFallback warns when a remote configuration was loaded from the cached copy
+//#########################################################
+func logRemoteFallback(sConfigFilePath string) {
+	if err := gpcconfig.RemoteFallback(); err != nil {
+		gpclogging.Warn("Remote configuration <%s> is unreachable, using the cached copy: <%s>", sConfigFilePath, err.Error())
+	}
+}
+
+//reloadConfig reads the configuration file again and applies the changes to the running processes
+//#########################################################
+func reloadConfig(sConfigFilePath string) ([]string, error) {
+	gpclogging.Info("Reloading configuration from <%s>", sConfigFilePath)
+
+	tNewConfigData, err := gpcconfig.LoadConfigFromFile(sConfigFilePath)
+	if err != nil {
+		gpclogging.Error("Config reload failed, keeping the current configuration: <%s>", err.Error())
+		return nil, err
+	}
+	logRemoteFallback(sConfigFilePath)
+	gpclogging.Info("Note: changes to the Logging and Control sections require a restart of the controller.")
+
+	gActiveConfigMux.Lock()
+	defer gActiveConfigMux.Unlock()
+	gActiveConfig = tNewConfigData
+	gpcnotify.Configure(&gActiveConfig)
+	gpcship.Configure(&gActiveConfig)
+	return gpcprocessmgr.ApplyConfig(&gActiveConfig), nil
+}
+
+//preflightChecks runs all checks of a configuration that can be done before starting anything
+//#########################################################
+func preflightChecks(tConfigData *gpcconfig.ConfigData) []error {
+	checkErrs := gpcconfig.CheckExecutables(tConfigData)
+	checkErrs = append(checkErrs, gpcconfig.CheckTimezones(tConfigData)...)
+	checkErrs = append(checkErrs, gpcconfig.CheckCalendars(tConfigData)...)
+	checkErrs = append(checkErrs, gpcconfig.CheckFollowUps(tConfigData)...)
+	checkErrs = append(checkErrs, gpcconfig.CheckSchedules(tConfigData)...)
+	checkErrs = append(checkErrs, gpcconfig.CheckMemoryLimits(tConfigData)...)
+	checkErrs = append(checkErrs, gpcconfig.CheckStandbys(tConfigData)...)
+	checkErrs = append(checkErrs, gpcconfig.CheckCPULimits(tConfigData)...)
+	checkErrs = append(checkErrs, gpcconfig.CheckGPUs(tConfigData)...)
+	checkErrs = append(checkErrs, gpcconfig.CheckAdoptions(tConfigData)...)
+	checkErrs = append(checkErrs, gpcconfig.CheckWindows(tConfigData)...)
+	checkErrs = append(checkErrs, gpcconfig.CheckDesktops(tConfigData)...)
+	checkErrs = append(checkErrs, gpcconfig.CheckShellCommands(tConfigData)...)
+	checkErrs = append(checkErrs, gpcconfig.CheckDependencies(tConfigData)...)
+	checkErrs = append(checkErrs, gpcconfig.CheckPreconditions(tConfigData)...)
+	checkErrs = append(checkErrs, gpcconfig.CheckNotifications(tConfigData)...)
+	checkErrs = append(checkErrs, gpcconfig.CheckLogShipping(tConfigData)...)
+	checkErrs = append(checkErrs, gpcconfig.CheckAPI(tConfigData)...)
+	checkErrs = append(checkErrs, gpcconfig.CheckHeartbeats(tConfigData)...)
+	checkErrs = append(checkErrs, gpcconfig.CheckPorts(tConfigData)...)
+	return checkErrs
+}
+
+//splitPersistOption removes a leading -persist option from command arguments
+//#########################################################
+func splitPersistOption(args []string) (bool, []string) {
+	if len(args) > 0 && args[0] == persistOption {
+		return true, args[1:]
+	}
+	return false, args
+}
+
+//changeTasks applies a change of the task list to the active configuration. With bPersist the
+//same change is applied to the configuration file as well, other runtime changes are not written.
+//The change gets bAsWritten set for the tasks of the file, where Instances are not expanded.
+//Returns the actions taken and the check warnings.
+//#########################################################
+func changeTasks(sConfigFilePath string, bPersist bool, change func(tasks []gpcconfig.ProcessConfig, bAsWritten bool) ([]gpcconfig.ProcessConfig, error)) ([]string, error) {
+	gActiveConfigMux.Lock()
+	defer gActiveConfigMux.Unlock()
+
+	tNewConfigData := gActiveConfig
+	tasks, err := change(gActiveConfig.Tasks, false)
+	if err != nil {
+		return nil, err
+	}
+	tNewConfigData.Tasks = tasks
+
+	if bPersist {
+		tFileConfigData, err := gpcconfig.LoadConfigFileAsWritten(sConfigFilePath)
+		if err != nil {
+			return nil, err
+		}
+		if tFileConfigData.Tasks, err = change(tFileConfigData.Tasks, true); err != nil {
+			return nil, fmt.Errorf("configuration file: %s", err.Error())
+		}
+		if err = gpcconfig.SaveConfigToFile(sConfigFilePath, &tFileConfigData); err != nil {
+			return nil, err
+		}
+		gpclogging.Info("Configuration written to <%s>", sConfigFilePath)
+	}
+
+	var output []string
+	for _, checkErr := range preflightChecks(&tNewConfigData) {
+		gpclogging.Warn("Preflight check failed: %s", checkErr.Error())
+		output = append(output, "WARNING: "+checkErr.Error())
+	}
+
+	gActiveConfig = tNewConfigData
+	return append(output, gpcprocessmgr.ApplyConfig(&gActiveConfig)...), nil
+}
+
+//addTask registers a new task given as JSON and starts it like a task from the configuration file.
+//A task with Instances adds all its instances.
+//#########################################################
+func addTask(sConfigFilePath string, sArgs string) ([]string, error) {
+	// the JSON is taken as sent, splitting it would change the whitespace in its strings
+	sTask := strings.TrimSpace(sArgs)
+	bPersist := false
+	if sOption, sRest, _ := strings.Cut(sTask, " "); sOption == persistOption {
+		bPersist, sTask = true, strings.TrimSpace(sRest)
+	}
+	if len(sTask) == 0 {
+		return nil, fmt.Errorf("usage: add [%s] <task JSON>", persistOption)
+	}
+	taskBytes := []byte(sTask)
+	tTask, err := gpcconfig.ParseTaskDefinition(taskBytes, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	gpclogging.Info("Adding task <%s> at runtime.", tTask.Name)
+	return changeTasks(sConfigFilePath, bPersist, func(tasks []gpcconfig.ProcessConfig, bAsWritten bool) ([]gpcconfig.ProcessConfig, error) {
+		addedTasks := []gpcconfig.ProcessConfig{tTask}
+		if !bAsWritten {
+			// the running task gets the Defaults and Secrets of the active configuration, the file keeps it as given
+			tActiveTask, err := gpcconfig.ParseTaskDefinition(taskBytes, &gActiveConfig)
+			if err != nil {
+				return nil, fmt.Errorf("in the active configuration: %s", err.Error())
+			}
+			if addedTasks, err = gpcconfig.ExpandTask(tActiveTask); err != nil {
+				return nil, err
+			}
+		}
+		newTasks := make([]gpcconfig.ProcessConfig, 0, len(tasks)+len(addedTasks))
+		for _, task := range tasks {
+			for _, addedTask := range addedTasks {
+				if task.Name == addedTask.Name {
+					return nil, fmt.Errorf("task <%s> already exists", addedTask.Name)
+				}
+			}
+			newTasks = append(newTasks, task)
+		}
+		return append(newTasks, addedTasks...), nil
+	})
+}
+
+//removeTask stops and deregisters a task, the name of a task with Instances removes all instances
+//#########################################################
+func removeTask(sConfigFilePath string, args []string) ([]string, error) {
+	bPersist, args := splitPersistOption(args)
+	if len(args) != 1 {
+		return nil, fmt.Errorf("usage: remove [%s] <name>", persistOption)
+	}
+
+	gpclogging.Info("Removing task <%s> at runtime.", args[0])
+	return changeTasks(sConfigFilePath, bPersist, func(tasks []gpcconfig.ProcessConfig, bAsWritten bool) ([]gpcconfig.ProcessConfig, error) {
+		newTasks := make([]gpcconfig.ProcessConfig, 0, len(tasks))
+		for _, task := range tasks {
+			if task.Name != args[0] && task.Template != args[0] {
+				newTasks = append(newTasks, task)
+			}
+		}
+		if len(newTasks) == len(tasks) {
+			return nil, fmt.Errorf("unknown task <%s>", args[0])
+		}
+		return newTasks, nil
+	})
+}
+
+//exportSnapshot writes the active configuration, including tasks added at runtime, and the
+//state of the processes to an archive
+//#########################################################
+func exportSnapshot(args []string) ([]string, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("usage: export <archive>")
+	}
+
+	gActiveConfigMux.Lock()
+	defer gActiveConfigMux.Unlock()
+	if err := gpcprocessmgr.ExportSnapshot(args[0], &gActiveConfig); err != nil {
+		return nil, err
+	}
+	return []string{fmt.Sprintf("snapshot written to %s", args[0])}, nil
+}
+
+//importSnapshot replaces the configuration file by the one of a snapshot archive and applies
+//it, processes stopped at the time of the snapshot stay stopped. Holiday calendars are
+//extracted to the folder "calendars" next to the configuration file.
+//#########################################################
+func importSnapshot(sConfigFilePath string, args []string) ([]string, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("usage: import <archive>")
+	}
+	if gpcconfig.IsRemote(sConfigFilePath) {
+		return nil, fmt.Errorf("the remote configuration <%s> can not be replaced here", sConfigFilePath)
+	}
+
+	gActiveConfigMux.Lock()
+	defer gActiveConfigMux.Unlock()
+
+	snapshot, err := gpcprocessmgr.ReadSnapshot(args[0], filepath.Join(filepath.Dir(sConfigFilePath), "calendars"))
+	if err != nil {
+		return nil, err
+	}
+	if err = gpcconfig.SaveConfigToFile(sConfigFilePath, &snapshot.Config); err != nil {
+		return nil, err
+	}
+	gpclogging.Info("Configuration of snapshot <%s> written to <%s>", args[0], sConfigFilePath)
+	gpclogging.Info("Note: changes to the Logging and Control sections require a restart of the controller.")
+	// The snapshot holds the encrypted values, like the file
+	if err = snapshot.Config.DecryptSecrets(); err != nil {
+		return nil, err
+	}
+
+	var output []string
+	for _, checkErr := range preflightChecks(&snapshot.Config) {
+		gpclogging.Warn("Preflight check failed: %s", checkErr.Error())
+		output = append(output, "WARNING: "+checkErr.Error())
+	}
+
+	gActiveConfig = snapshot.Config
+	return append(output, gpcprocessmgr.RestoreSnapshot(snapshot, &gActiveConfig)...), nil
+}
+
+//simulate prints the actions the controller would take for a configuration file when the
+//processes end as given by a failure script, see gpcprocessmgr.Simulate. The simulation
+//starts at midnight today, so offsets of the script are times of the day.
+//#########################################################
+func simulate(sConfigFilePath string, sScriptFilePath string, duration time.Duration) error {
+	tConfigData, err := gpcconfig.LoadConfigFromFile(sConfigFilePath)
+	if err != nil {
+		return err
+	}
+	fScript, err := os.Open(sScriptFilePath)
+	if err != nil {
+		return err
+	}
+	defer fScript.Close()
+
+	year, month, day := time.Now().Date()
+	return gpcprocessmgr.Simulate(&tConfigData, fScript, time.Date(year, month, day, 0, 0, 0, 0, time.Local), duration, os.Stdout)
+}
+
+//validateConfig handles -validate: it loads the configuration file like at startup, runs the
+//preflight checks and prints the resolved paths and the planned startup order. Nothing is
+//started. Returns an error when the configuration has problems.
+//#########################################################
+func validateConfig(sConfigFilePath string) error {
+	sAbsConfigPath, err := absConfigPath(sConfigFilePath)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Configuration file:", sAbsConfigPath)
+
+	tConfigData, err := gpcconfig.LoadConfigFromFile(sConfigFilePath)
+	if err != nil {
+		return err
+	}
+	if err := gpcconfig.RemoteFallback(); err != nil {
+		fmt.Println("Remote configuration is unreachable, checked the cached copy:", err.Error())
+	}
+	if sLogsFolder, err := filepath.Abs(tConfigData.Logging.LogsFolder); err == nil {
+		fmt.Println("Logs folder:", sLogsFolder)
+	}
+
+	fmt.Println("Planned startup:")
+	for _, sLine := range gpcprocessmgr.StartupPlan(&tConfigData) {
+		fmt.Println(sLine)
+	}
+
+	checkErrs := preflightChecks(&tConfigData)
+	if len(checkErrs) > 0 {
+		fmt.Println("Problems:")
+		for _, checkErr := range checkErrs {
+			fmt.Println("  " + checkErr.Error())
+		}
+		return fmt.Errorf("configuration <%s> has %d problem(s)", sAbsConfigPath, len(checkErrs))
+	}
+	fmt.Println("Configuration is valid.")
+	return nil
+}
+
+//takeOver handles -takeover: it asks the controller running on the control socket of the
+//configuration to hand its processes over and waits until it released socket and API. The
+//processes are re-attached when the controller starts, instead of starting them again.
+//#########################################################
+func takeOver(sConfigFilePath string) error {
+	tConfigData, err := gpcconfig.LoadConfigFromFile(sConfigFilePath)
+	if err != nil {
+		return err
+	}
+	sSocketPath := tConfigData.Control.SocketPath
+	if len(sSocketPath) == 0 {
+		return fmt.Errorf("-takeover needs the Control.SocketPath of the running controller")
+	}
+	stateLines, err := gpccontrol.SendCommand(sSocketPath, []string{"handoff"})
+	if err != nil {
+		return err
+	}
+	if err := gpcprocessmgr.SetHandoffState(stateLines); err != nil {
+		return fmt.Errorf("invalid state from the running controller: %s", err.Error())
+	}
+	fmt.Printf("Took over the state of %d processes, waiting for the previous controller to end.\n", len(stateLines))
+
+	// The socket is gone once the previous controller answered all commands and closed the API.
+	// Its processes are not monitored meanwhile, so this continues after a while in any case.
+	deadline := time.Now().Add(takeoverTimeout)
+	for time.Now().Before(deadline) {
+		if _, err := gpccontrol.SendCommand(sSocketPath, []string{"status"}); err != nil {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	fmt.Printf("The previous controller still answers on %s after %s, starting anyway.\n", sSocketPath, takeoverTimeout)
+	return nil
+}
+
+//runTask handles -run: it runs a single task of the configuration file with its output on the
+//console and returns the exit code for the controller, the one of the task or 1 if it is
+//unknown or the task could not be run
+//#########################################################
+func runTask(sConfigFilePath string, sTaskName string) int {
+	tConfigData, err := gpcconfig.ReadConfigFromFile(sConfigFilePath)
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+	gpclogging.Init(tConfigData.Logging.LogsFolder, 2, 1, tConfigData.Logging.LogFileSizeMB, tConfigData.Logging.LogDebugEnabled)
+	gpclogging.SetLogFormatJSON(tConfigData.Logging.LogFormat == "json")
+
+	consoleSink := gpcprocessmgr.NewConsoleSink(os.Stdout, os.Stderr)
+	gpcprocessmgr.AddOutputSink(consoleSink)
+	exitCode, err := gpcprocessmgr.RunTask(&tConfigData, sTaskName)
+	consoleSink.Close()
+	if err != nil {
+		fmt.Println(err.Error())
+		return max(exitCode, 1)
+	}
+	fmt.Printf("Task %s ended with exit code %d\n", sTaskName, exitCode)
+	if exitCode < 0 {
+		return 1
+	}
+	return exitCode
+}
+
+//importConfig handles -import: it converts the configuration of supervisord or PM2 into a new
+//configuration file and prints what could not be converted
+//#########################################################
+func importConfig(sImportFilePath string, sConfigFilePath string) error {
+	if _, err := os.Stat(sConfigFilePath); err == nil {
+		return fmt.Errorf("<%s> already exists, give the new configuration file with -cf", sConfigFilePath)
+	}
+
+	tConfigData, notes, err := gpcconfig.ImportConfig(sImportFilePath)
+	if err != nil {
+		return err
+	}
+	for _, sNote := range notes {
+		fmt.Println("Note:", sNote)
+	}
+	if err = gpcconfig.SaveConfigToFile(sConfigFilePath, &tConfigData); err != nil {
+		return err
+	}
+	fmt.Printf("%d tasks written to %s, check them with -validate.\n", len(tConfigData.Tasks), sConfigFilePath)
+	return nil
+}
+
+//exportTasks handles -export: it writes the tasks of the configuration as units of another
+//service manager to a folder and prints what could not be exported
+//#########################################################
+func exportTasks(sConfigFilePath string, sFormat string, sExportDir string) error {
+	tConfigData, err := gpcconfig.LoadConfigFromFile(sConfigFilePath)
+	if err != nil {
+		return err
+	}
+	// the tasks run in the folder of the controller, so do the exported ones
+	sWorkDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	files, notes, err := gpcconfig.ExportTasks(&tConfigData, sFormat, sWorkDir)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(sExportDir, 0755); err != nil {
+		return fmt.Errorf("Can not create export folder: %s", err.Error())
+	}
+	fileNames := make([]string, 0, len(files))
+	for sFileName := range files {
+		fileNames = append(fileNames, sFileName)
+	}
+	sort.Strings(fileNames)
+	for _, sFileName := range fileNames {
+		// decrypted values of the configuration end up in the files
+		if err = os.WriteFile(filepath.Join(sExportDir, sFileName), files[sFileName], 0600); err != nil {
+			return fmt.Errorf("Can not write <%s>: %s", sFileName, err.Error())
+		}
+		fmt.Println("Written:", filepath.Join(sExportDir, sFileName))
+	}
+	for _, sNote := range notes {
+		fmt.Println("Note:", sNote)
+	}
+	return nil
+}
+
+//encryptValue handles -encrypt: it prints a value encrypted with the key file of the
+//configuration, by DPAPI if it has none or the file does not exist. The value "-" reads it
+//from stdin instead, keeping it out of the shell history.
+//#########################################################
+func encryptValue(sConfigFilePath string, sValue string) error {
+	if sValue == "-" {
+		valueBytes, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		sValue = strings.TrimRight(string(valueBytes), "\r\n")
+	}
+
+	var sKeyFile string
+	if _, err := os.Stat(sConfigFilePath); err == nil || gpcconfig.IsRemote(sConfigFilePath) {
+		tConfigData, err := gpcconfig.LoadConfigFileAsWritten(sConfigFilePath)
+		if err != nil {
+			return err
+		}
+		sKeyFile = tConfigData.Secrets.KeyFile
+	}
+
+	sEncrypted, err := gpcconfig.EncryptSecret(sValue, sKeyFile)
+	if err != nil {
+		return err
+	}
+	fmt.Println(sEncrypted)
+	return nil
+}
+
+//serviceCommand handles -service. install, uninstall, start and stop manage the Windows service,
+//run is what the service is started with: it runs the controller until the SCM stops it.
+//optionArgs are the further command line options the service is installed with.
+//#########################################################
+func serviceCommand(sCommand string, sConfigFilePath string, optionArgs []string, run func(), stop func()) error {
+	sAbsConfigPath, err := absConfigPath(sConfigFilePath)
+	if err != nil {
+		return err
+	}
+
+	switch sCommand {
+	case "install":
+		args := append([]string{"-service", "run", "-cf", sAbsConfigPath}, optionArgs...)
+		err = gpcservice.Install(gpcservice.DefServiceName, args)
+	case "uninstall":
+		err = gpcservice.Uninstall(gpcservice.DefServiceName)
+	case "start":
+		err = gpcservice.Start(gpcservice.DefServiceName)
+	case "stop":
+		err = gpcservice.Stop(gpcservice.DefServiceName)
+	case "run":
+		// Services start in the system folder, relative paths of the configuration refer to its own
+		// folder, those of a remote configuration to the folder of the controller
+		sWorkDir := filepath.Dir(sAbsConfigPath)
+		if gpcconfig.IsRemote(sConfigFilePath) {
+			sExecutable, err := os.Executable()
+			if err != nil {
+				return err
+			}
+			sWorkDir = filepath.Dir(sExecutable)
+		}
+		if err := os.Chdir(sWorkDir); err != nil {
+			return err
+		}
+		return gpcservice.Run(gpcservice.DefServiceName, run, stop)
+	default:
+		return fmt.Errorf("unknown service command <%s>, expected install, uninstall, start or stop", sCommand)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Service %s: %s done.\n", gpcservice.DefServiceName, sCommand)
+	return nil
+}
+
+//absConfigPath returns the absolute path of a configuration file, remote ones as they are
+//#########################################################
+func absConfigPath(sConfigFilePath string) (string, error) {
+	if gpcconfig.IsRemote(sConfigFilePath) {
+		return sConfigFilePath, nil
+	}
+	return filepath.Abs(sConfigFilePath)
+}
+
+//watchConfigFile polls the configuration file and reloads it whenever it was modified
+//#########################################################
+func watchConfigFile(sConfigFilePath string) {
+	if gpcconfig.IsRemote(sConfigFilePath) {
+		watchRemoteConfig(sConfigFilePath)
+		return
+	}
+
+	var lastModTime time.Time
+	if fileInfo, err := os.Stat(sConfigFilePath); err == nil {
+		lastModTime = fileInfo.ModTime()
+	}
+
+	for {
+		time.Sleep(2 * time.Second)
+		fileInfo, err := os.Stat(sConfigFilePath)
+		if err != nil || fileInfo.ModTime().Equal(lastModTime) {
+			continue
+		}
+		lastModTime = fileInfo.ModTime()
+		reloadConfig(sConfigFilePath)
+	}
+}
+
+//watchRemoteConfig polls a remote configuration and reloads it whenever its content changed
+//#########################################################
+func watchRemoteConfig(sConfigURL string) {
+	var lastHash [sha256.Size]byte
+	if configBytes, err := gpcconfig.FetchRemoteConfig(sConfigURL); err == nil {
+		lastHash = sha256.Sum256(configBytes)
+	}
+
+	for {
+		time.Sleep(remoteWatchInterval)
+		configBytes, err := gpcconfig.FetchRemoteConfig(sConfigURL)
+		if err != nil {
+			gpclogging.Debug("Could not poll remote configuration <%s>: <%s>", sConfigURL, err.Error())
+			continue
+		}
+		if hash := sha256.Sum256(configBytes); hash != lastHash {
+			lastHash = hash
+			reloadConfig(sConfigURL)
+		}
+	}
+}
+
 //#########################################################
 //#########################################################
 func main() {
 
 	// First thing is to register a catch of the Crtl-C and kill events
 	sigs := make(chan os.Signal, 1)
-	appEnd := make(chan bool, 1)
 	var shutdownWaitGroup sync.WaitGroup
 
+	// The signal, ExitWhenDone and -once may all end the application, the first one closes appEnd
+	appEnd := make(chan struct{})
+	var appEndOnce sync.Once
+	endApp := func() {
+		appEndOnce.Do(func() { close(appEnd) })
+	}
+
 	signal.Notify(sigs, os.Interrupt, os.Kill, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		signal := <-sigs
 		fmt.Println("Shutdown request received:", signal)
 		gpcprocessmgr.ShutdownAll()
-		appEnd <- true
+		endApp()
 	}()
 
 	// ---- Local Variables
 	var bCmdFlagH bool
 	var sCmdFlagCF string
 	var sCmdFlagDC string
+	var sCmdFlagCFFmt string
+	var sCmdFlagSimulate string
+	var dCmdFlagSimFor time.Duration
+	var sCmdFlagService string
+	var bCmdFlagValidate bool
+	var bCmdFlagForeground bool
+	var bCmdFlagOnce bool
+	var sCmdFlagRun string
+	var bCmdFlagExitWhenDone bool
+	var bCmdFlagTakeover bool
+	var sCmdFlagOnly string
+	var sCmdFlagSkip string
+	var sCmdFlagProfile string
+	var sCmdFlagEncrypt string
+	var sCmdFlagGenKey string
+	var sCmdFlagCFCache string
+	var sCmdFlagImport string
+	var sCmdFlagExport string
+	var sCmdFlagExportDir string
+	var cfHeaders []string
 
 	// SETUP CMD LINE ARGUMENTS
 	flag.BoolVar(&bCmdFlagH, "h", false, "Prints help output")
-	flag.StringVar(&sCmdFlagCF, "cf", GPCDefConfigFile, "Path to the configuration file. Must be in JSON format.")
+	flag.StringVar(&sCmdFlagCF, "cf", GPCDefConfigFile, "Path or https:// URL of the configuration file, JSON or YAML (.yaml/.yml or -cffmt)")
 	flag.StringVar(&sCmdFlagDC, "dc", "", "Creates a new default configuration file with the specified file name")
+	flag.Func("cfheader", "Request header of an https:// -cf, \"Name: value\", may be given more than once", func(sHeader string) error {
+		cfHeaders = append(cfHeaders, sHeader)
+		return nil
+	})
+	flag.StringVar(&sCmdFlagCFCache, "cfcache", "", "Cache file of the last good copy of an https:// -cf")
+	flag.StringVar(&sCmdFlagCFFmt, "cffmt", "", "Format of the configuration file (json or yaml), detected by extension if empty")
+	flag.StringVar(&sCmdFlagSimulate, "simulate", "", "Prints the actions for the configuration file and a failure script, nothing is started")
+	flag.DurationVar(&dCmdFlagSimFor, "simfor", 24*time.Hour, "Time span of -simulate")
+	flag.StringVar(&sCmdFlagImport, "import", "", "Converts a supervisord .conf or PM2 ecosystem file into a new configuration file given by -cf")
+	flag.StringVar(&sCmdFlagExport, "export", "", "Writes the tasks of the configuration file as systemd units or Task Scheduler XML: systemd or taskscheduler")
+	flag.StringVar(&sCmdFlagExportDir, "exportdir", "export", "Folder -export writes to")
+	flag.StringVar(&sCmdFlagEncrypt, "encrypt", "", "Prints the value encrypted for the configuration, with the Secrets.KeyFile of -cf or DPAPI")
+	flag.StringVar(&sCmdFlagGenKey, "genkey", "", "Creates a new key file for encrypted values")
+	flag.StringVar(&sCmdFlagProfile, "profile", "", "Merges the profile of this name into the configuration, e.g. prod")
+	flag.StringVar(&sCmdFlagOnly, "only", "", "Starts only the tasks matching one of the filters, e.g. tag=batch,name=report")
+	flag.StringVar(&sCmdFlagSkip, "skip", "", "Leaves out the tasks matching one of the filters, e.g. name=foo")
+	flag.BoolVar(&bCmdFlagValidate, "validate", false, "Checks the configuration file and prints the planned startup order, nothing is started")
+	flag.StringVar(&sCmdFlagRun, "run", "", "Runs the task of this name once in the foreground with its output on the console, exits with its exit code")
+	flag.BoolVar(&bCmdFlagTakeover, "takeover", false, "Takes the running processes over from the controller on the same control socket, which then exits")
+	flag.BoolVar(&bCmdFlagExitWhenDone, "exitwhendone", false, "Exits once all processes ended and none is restarted, like Shutdown.ExitWhenDone")
+	flag.BoolVar(&bCmdFlagOnce, "once", false, "Runs the tasks as a batch: exits when the wait tasks are done, with exit code 1 if a task failed")
+	flag.BoolVar(&bCmdFlagForeground, "foreground", false, "Echoes the output of all tasks to the console, prefixed with the task name")
+	flag.BoolVar(&bCmdFlagForeground, "attach", false, "Same as -foreground")
+	flag.StringVar(&sCmdFlagService, "service", "", "Manages the Windows service: install, uninstall, start or stop")
 	flag.Parse()
 
 	if bCmdFlagH {
@@ -78,14 +757,158 @@ func main() {
 		return
 	}
 
+	if err := gpcconfig.SetFormat(sCmdFlagCFFmt); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	if err := gpcconfig.SetRemoteOptions(cfHeaders, sCmdFlagCFCache); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	if err := gpcconfig.SetProfile(sCmdFlagProfile); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	if err := gpcconfig.SetTaskSelection(sCmdFlagOnly, sCmdFlagSkip); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
 	if len(sCmdFlagDC) > 1 {
 		fmt.Println("Creating default configuration file...")
-		gpcconfig.WriteDefaultConfigFile(sCmdFlagDC)
+		if err := gpcconfig.WriteDefaultConfigFile(sCmdFlagDC); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(sCmdFlagImport) > 0 {
+		if err := importConfig(sCmdFlagImport, sCmdFlagCF); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(sCmdFlagExport) > 0 {
+		if err := exportTasks(sCmdFlagCF, sCmdFlagExport, sCmdFlagExportDir); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(sCmdFlagGenKey) > 0 {
+		if err := gpcconfig.GenerateKeyFile(sCmdFlagGenKey); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		fmt.Println("Key file created, set it as Secrets.KeyFile and keep it out of version control.")
+		return
+	}
+
+	if len(sCmdFlagEncrypt) > 0 {
+		if err := encryptValue(sCmdFlagCF, sCmdFlagEncrypt); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if bCmdFlagValidate {
+		if err := validateConfig(sCmdFlagCF); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(sCmdFlagRun) > 0 {
+		os.Exit(runTask(sCmdFlagCF, sCmdFlagRun))
+	}
+
+	if len(sCmdFlagSimulate) > 0 {
+		if err := simulate(sCmdFlagCF, sCmdFlagSimulate, dCmdFlagSimFor); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(sCmdFlagService) > 0 {
+		run := func() {
+			if err := runController(sCmdFlagCF, false, bCmdFlagExitWhenDone, appEnd, endApp, &shutdownWaitGroup); err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+		}
+		stop := func() {
+			select {
+			case sigs <- syscall.SIGTERM:
+			default: // a shutdown is already pending
+			}
+		}
+		var optionArgs []string
+		if len(sCmdFlagCFFmt) > 0 {
+			optionArgs = append(optionArgs, "-cffmt", sCmdFlagCFFmt)
+		}
+		for _, sHeader := range cfHeaders {
+			optionArgs = append(optionArgs, "-cfheader", sHeader)
+		}
+		if len(sCmdFlagCFCache) > 0 {
+			optionArgs = append(optionArgs, "-cfcache", sCmdFlagCFCache)
+		}
+		if len(sCmdFlagProfile) > 0 {
+			optionArgs = append(optionArgs, "-profile", sCmdFlagProfile)
+		}
+		if len(sCmdFlagOnly) > 0 {
+			optionArgs = append(optionArgs, "-only", sCmdFlagOnly)
+		}
+		if len(sCmdFlagSkip) > 0 {
+			optionArgs = append(optionArgs, "-skip", sCmdFlagSkip)
+		}
+		if bCmdFlagExitWhenDone {
+			optionArgs = append(optionArgs, "-exitwhendone")
+		}
+		if err := serviceCommand(sCmdFlagService, sCmdFlagCF, optionArgs, run, stop); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
 		return
 	}
 
+	if bCmdFlagTakeover {
+		if err := takeOver(sCmdFlagCF); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+	if bCmdFlagForeground {
+		gpcprocessmgr.AddOutputSink(gpcprocessmgr.NewConsoleSink(os.Stdout, os.Stderr))
+	}
+	if err := runController(sCmdFlagCF, bCmdFlagOnce, bCmdFlagExitWhenDone, appEnd, endApp, &shutdownWaitGroup); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
+
+//runController starts the processes of the configuration file and controls them until appEnd
+//is closed. endApp closes it, it may be called more than once. Returns an error if the configuration file can not be read, nothing is
+//started then. bOnce shuts down once the wait tasks are done and returns an error if a task
+//failed or the batch was interrupted. bExitWhenDone sets Shutdown.ExitWhenDone.
+//#########################################################
+func runController(sCmdFlagCF string, bOnce bool, bExitWhenDone bool, appEnd <-chan struct{}, endApp func(), shutdownWaitGroup *sync.WaitGroup) error {
 	// READ CONFIG FILE
-	tConfigData := gpcconfig.ReadConfigFromFile(sCmdFlagCF)
+	tActiveConfig, err := gpcconfig.ReadConfigFromFile(sCmdFlagCF)
+	if err != nil {
+		return err
+	}
+	gActiveConfig = tActiveConfig
+	tConfigData := &gActiveConfig
+	if bExitWhenDone {
+		tConfigData.Shutdown.ExitWhenDone = true
+	}
 
 	// SETUP LOGGER
 	gpclogging.Init(tConfigData.Logging.LogsFolder, // specify the directory to save the logfiles
@@ -93,13 +916,120 @@ func main() {
 		1,                                   // number of logfiles to delete when number of logfiles exceeds the configured limit
 		tConfigData.Logging.LogFileSizeMB,   // maximum size of a logfile in MB
 		tConfigData.Logging.LogDebugEnabled) // whether logs with Debug level are written down
+	gpclogging.SetLogFormatJSON(tConfigData.Logging.LogFormat == "json")
+	gpclogging.SetCompressRotated(tConfigData.Logging.CompressRotated)
+	gpclogging.SetMaxTotalSize(tConfigData.Logging.MaxTotalSizeMB)
 	gpclogging.Info("Application sucessfully initalized. Starting up")
+	logRemoteFallback(sCmdFlagCF)
+
+	// PREFLIGHT CHECKS - report unusable executables now rather than at shutdown
+	checkErrs := preflightChecks(tConfigData)
+	for _, checkErr := range checkErrs {
+		gpclogging.Warn("Preflight check failed: %s", checkErr.Error())
+	}
+
+	// NOTIFICATIONS - before the first process starts, so no event is missed
+	gpcnotify.Configure(tConfigData)
+	defer gpcnotify.Stop()
+
+	// LOG SHIPPING - before the first process starts, its output is captured from the start
+	gpcship.Configure(tConfigData)
+	defer gpcship.Stop()
 
 	// LETS DO THE ACTUAL WORK
-	gpcprocessmgr.StartProcessesFromConfig(&tConfigData, &shutdownWaitGroup)
+	gpcprocessmgr.StartProcessesFromConfig(tConfigData, shutdownWaitGroup)
+
+	// CONFIG RELOAD - on request via gpcctl and optionally when the file changes
+	gpccontrol.RegisterCommand("reload", func(args []string) ([]string, error) {
+		return reloadConfig(sCmdFlagCF)
+	})
+	// RUNTIME TASK CHANGES - optionally written back to the file
+	gpccontrol.RegisterTextCommand("add", func(sArgs string) ([]string, error) {
+		return addTask(sCmdFlagCF, sArgs)
+	})
+	gpccontrol.RegisterCommand("remove", func(args []string) ([]string, error) {
+		return removeTask(sCmdFlagCF, args)
+	})
+	// HOT HANDOFF - a new controller started with -takeover continues with the processes
+	handedOff := make(chan bool, 1)
+	gpccontrol.RegisterCommand("handoff", func(args []string) ([]string, error) {
+		stateLines := gpcprocessmgr.HandOff()
+		select {
+		case handedOff <- true:
+		default: // handed off already
+		}
+		return stateLines, nil
+	})
+	// SNAPSHOTS - to stand up a replacement host
+	gpccontrol.RegisterCommand("export", exportSnapshot)
+	gpccontrol.RegisterCommand("import", func(args []string) ([]string, error) {
+		return importSnapshot(sCmdFlagCF, args)
+	})
+	if tConfigData.Control.WatchConfig {
+		go watchConfigFile(sCmdFlagCF)
+	}
+
+	// OPEN THE CONTROL SOCKET FOR gpcctl
+	if len(tConfigData.Control.SocketPath) > 0 {
+		err := gpccontrol.Start(tConfigData.Control.SocketPath)
+		if err != nil {
+			gpclogging.Error("Could not open control socket <%s>: <%s>", tConfigData.Control.SocketPath, err.Error())
+		}
+		defer gpccontrol.Stop()
+	}
+
+	// OPEN THE HTTP API
+	if len(tConfigData.API.ListenAddress) > 0 {
+		err := gpcapi.Start(tConfigData.API)
+		if err != nil {
+			gpclogging.Error("Could not open HTTP API on <%s>: <%s>", tConfigData.API.ListenAddress, err.Error())
+		}
+		defer gpcapi.Stop()
+	}
+
+	// EXIT WHEN DONE - nothing left to control
+	if tConfigData.Shutdown.ExitWhenDone {
+		go func() {
+			<-gpcprocessmgr.AllDone()
+			gpcprocessmgr.ShutdownAll()
+			endApp()
+		}()
+	}
+
+	// BATCH MODE - the result is taken before the shutdown stops the remaining processes
+	batchResult := make(chan error, 1)
+	if bOnce {
+		go func() {
+			<-gpcprocessmgr.BatchDone()
+			batchErr := gpcprocessmgr.BatchError()
+			if batchErr != nil {
+				gpclogging.Error("Batch finished, %s", batchErr.Error())
+			} else {
+				gpclogging.Info("Batch finished, all tasks succeeded")
+			}
+			batchResult <- batchErr
+			gpcprocessmgr.ShutdownAll()
+			endApp()
+		}()
+	}
 
 	// GO TO SLEEP HERE IN MAIN AND WAIT FOR A SHUTDOWN REQUEST
-	<-appEnd
+	select {
+	case <-appEnd:
+	case <-handedOff:
+		// the processes are left running, the control socket and the API are released on return
+		gpclogging.Info("Processes handed off to the new controller. Exiting without stopping them...")
+		return nil
+	}
 	gpclogging.Info("Application shutting down...")
 	shutdownWaitGroup.Wait()
+	if bOnce {
+		select {
+		case batchErr := <-batchResult:
+			return batchErr
+		default:
+			return fmt.Errorf("Batch interrupted before all wait tasks finished")
+		}
+	}
+	return nil
 }